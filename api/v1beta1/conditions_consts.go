@@ -25,12 +25,40 @@ const (
 
 	// LoadBalancerReadyCondition reports on the readiness of the load balancer.
 	LoadBalancerReadyCondition clusterv1.ConditionType = "LoadBalancerReady"
+
+	// ExternalControlPlaneEndpointCondition reports that the control plane endpoint was provided by the user
+	// and no control plane load balancer is managed for the cluster.
+	ExternalControlPlaneEndpointCondition clusterv1.ConditionType = "ExternalControlPlaneEndpoint"
+
+	// APICompatibleCondition reports whether the DataCrunch API responds with the structure the provider
+	// expects. It is false after an incompatible change of the API, until the provider is upgraded.
+	APICompatibleCondition clusterv1.ConditionType = "APICompatible"
 )
 
 // Condition types for DataCrunchMachine
 const (
 	// InstanceReadyCondition reports on the readiness of the DataCrunch instance.
 	InstanceReadyCondition clusterv1.ConditionType = "InstanceReady"
+
+	// ImageSpecifiedCondition reports whether the instance uses an explicitly specified image
+	// rather than the provider default.
+	ImageSpecifiedCondition clusterv1.ConditionType = "ImageSpecified"
+
+	// GPUHealthyCondition reports on the health of the instance GPUs. It is optional: when it is not
+	// set, GPU health is not taken into account for the readiness of the machine.
+	GPUHealthyCondition clusterv1.ConditionType = "GPUHealthy"
+
+	// SSHKeySyncedCondition reports whether the SSH key of the instance matches the spec.
+	SSHKeySyncedCondition clusterv1.ConditionType = "SSHKeySynced"
+
+	// SecurityGroupsSyncedCondition reports whether the security groups of the instance match the spec.
+	SecurityGroupsSyncedCondition clusterv1.ConditionType = "SecurityGroupsSynced"
+
+	// InstanceTypeMatchesCondition reports whether the instance runs the instance type it was requested with.
+	InstanceTypeMatchesCondition clusterv1.ConditionType = "InstanceTypeMatches"
+
+	// PreStopHooksCompletedCondition reports on the PreStopCommands run before the instance is deleted.
+	PreStopHooksCompletedCondition clusterv1.ConditionType = "PreStopHooksCompleted"
 )
 
 // Condition reasons for DataCrunchCluster
@@ -41,8 +69,17 @@ const (
 	// NetworkReconciliationFailedReason used when network reconciliation fails.
 	NetworkReconciliationFailedReason = "NetworkReconciliationFailed"
 
+	// WaitingForVPCReason used while the cluster VPC is not available yet.
+	WaitingForVPCReason = "WaitingForVPC"
+
 	// LoadBalancerReconciliationFailedReason used when load balancer reconciliation fails.
 	LoadBalancerReconciliationFailedReason = "LoadBalancerReconciliationFailed"
+
+	// WaitingForLoadBalancerReason used while the control plane load balancer is not active yet.
+	WaitingForLoadBalancerReason = "WaitingForLoadBalancer"
+
+	// APIIncompatibleReason used when the DataCrunch API responds with an unexpected structure.
+	APIIncompatibleReason = "APIIncompatible"
 )
 
 // Condition reasons for DataCrunchMachine
@@ -53,12 +90,69 @@ const (
 	// WaitingForBootstrapDataReason used when machine is waiting for bootstrap data.
 	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
 
+	// InvalidInfrastructureRefReason used when the infrastructure of the machine's Cluster is not a DataCrunchCluster.
+	InvalidInfrastructureRefReason = "InvalidInfrastructureRef"
+
+	// ForeignMachineOwnerReason used when the machine's owner Machine references other infrastructure than the machine.
+	ForeignMachineOwnerReason = "ForeignMachineOwner"
+
 	// InstanceCreationFailedReason used when instance creation fails.
 	InstanceCreationFailedReason = "InstanceCreationFailed"
 
 	// InstanceNotReadyReason used when instance is not ready.
 	InstanceNotReadyReason = "InstanceNotReady"
 
+	// WaitingForInstanceAddressReason used when the instance is running but its IP addresses are not assigned yet.
+	WaitingForInstanceAddressReason = "WaitingForInstanceAddress"
+
 	// InstanceTerminatedReason used when instance is terminated.
 	InstanceTerminatedReason = "InstanceTerminated"
+
+	// InstanceLookupFailedReason used when the instance of a machine being deleted can't be looked up, so the deletion waits for it.
+	InstanceLookupFailedReason = "InstanceLookupFailed"
+
+	// InstanceReplacingReason used when the instance is being re-created because its image or bootstrap data changed.
+	InstanceReplacingReason = "InstanceReplacing"
+
+	// QuotaExceededReason used when instance creation is blocked by an account quota or limit.
+	QuotaExceededReason = "QuotaExceeded"
+
+	// BudgetExceededReason used when instance creation is blocked because the instance would exceed the hourly cost budget of the cluster.
+	BudgetExceededReason = "BudgetExceeded"
+
+	// InsufficientCapacityReason used when instance creation is blocked because none of the instance types has capacity in the region.
+	InsufficientCapacityReason = "InsufficientCapacity"
+
+	// DefaultImageAppliedReason used when no image is specified and the default image is used.
+	DefaultImageAppliedReason = "DefaultImageApplied"
+
+	// InstanceStoppingReason used when the instance is stopping.
+	InstanceStoppingReason = "InstanceStopping"
+
+	// InstanceStuckStoppingReason used when the instance has been stopping for too long and a forced stop was requested.
+	InstanceStuckStoppingReason = "InstanceStuckStopping"
+
+	// SSHKeyUpdateFailedReason used when updating the SSH key of the instance fails.
+	SSHKeyUpdateFailedReason = "SSHKeyUpdateFailed"
+
+	// SSHKeyReplacementRequiredReason used when the SSH key changed but can only be applied by replacing the instance.
+	SSHKeyReplacementRequiredReason = "SSHKeyReplacementRequired"
+
+	// SecurityGroupsUpdateFailedReason used when updating the security groups of the instance fails.
+	SecurityGroupsUpdateFailedReason = "SecurityGroupsUpdateFailed"
+
+	// SecurityGroupsReplacementRequiredReason used when the security groups changed but can only be applied by replacing the instance.
+	SecurityGroupsReplacementRequiredReason = "SecurityGroupsReplacementRequired"
+
+	// InstanceTypeMismatchReason used when the API provisioned another instance type than requested.
+	InstanceTypeMismatchReason = "InstanceTypeMismatch"
+
+	// PreStopHooksRunningReason used while the PreStopCommands run before the instance is deleted.
+	PreStopHooksRunningReason = "PreStopHooksRunning"
+
+	// PreStopHooksFailedReason used when the PreStopCommands failed and the instance is deleted regardless.
+	PreStopHooksFailedReason = "PreStopHooksFailed"
+
+	// PreStopHooksTimedOutReason used when the PreStopCommands didn't complete within PreStopTimeout.
+	PreStopHooksTimedOutReason = "PreStopHooksTimedOut"
 )
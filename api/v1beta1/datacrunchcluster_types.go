@@ -25,6 +25,10 @@ const (
 	// ClusterFinalizer allows DataCrunchClusterReconciler to clean up DataCrunch resources associated with DataCrunchCluster before
 	// removing it from the apiserver.
 	ClusterFinalizer = "datacrunchcluster.infrastructure.cluster.x-k8s.io"
+
+	// ForceCleanupAnnotation set to "true" on a DataCrunchCluster deletes all instances tagged with the name of
+	// its cluster when it is deleted, including instances left behind by DataCrunchMachines that are already gone.
+	ForceCleanupAnnotation = "infrastructure.cluster.x-k8s.io/force-cleanup"
 )
 
 // DataCrunchClusterSpec defines the desired state of DataCrunchCluster
@@ -33,7 +37,16 @@ type DataCrunchClusterSpec struct {
 	// +optional
 	Region string `json:"region,omitempty"`
 
+	// APIEndpoint is the base URL of the DataCrunch API the cluster is created with, e.g. a staging
+	// environment. Defaults to the public API at https://api.datacrunch.io/v1. It can't be changed
+	// once set, as the resources of the cluster only exist in the environment they were created in.
+	// +optional
+	APIEndpoint string `json:"apiEndpoint,omitempty"`
+
 	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
+	// When set by the user, e.g. to a static IP served by a load balancer outside of DataCrunch, it is
+	// authoritative: no control plane load balancer is created and the ExternalControlPlaneEndpoint
+	// condition is set. Otherwise it is set from the control plane load balancer, if enabled.
 	// +optional
 	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint,omitempty"`
 
@@ -44,6 +57,16 @@ type DataCrunchClusterSpec struct {
 	// Network configuration for the cluster
 	// +optional
 	Network *DataCrunchNetworkSpec `json:"network,omitempty"`
+
+	// DefaultSSHKeyName is the SSH key name used by the machines of the cluster that don't set SSHKeyName
+	// +optional
+	DefaultSSHKeyName string `json:"defaultSSHKeyName,omitempty"`
+
+	// MaxHourlyCost is the maximum combined hourly price of the instances of the cluster, e.g. "25.50", in
+	// the currency of the DataCrunch account. Machines whose instance would exceed it are not provisioned
+	// until enough budget is freed. The budget is unlimited if not set.
+	// +optional
+	MaxHourlyCost *string `json:"maxHourlyCost,omitempty"`
 }
 
 // DataCrunchLoadBalancerSpec defines the load balancer configuration
@@ -131,6 +154,33 @@ type DataCrunchClusterStatus struct {
 	// LoadBalancer contains information about the control plane load balancer
 	// +optional
 	LoadBalancer *DataCrunchLoadBalancerStatus `json:"loadBalancer,omitempty"`
+
+	// ReadyMachines is the number of DataCrunchMachines belonging to the cluster that are ready
+	// +optional
+	ReadyMachines int32 `json:"readyMachines,omitempty"`
+
+	// TotalMachines is the number of DataCrunchMachines belonging to the cluster
+	// +optional
+	TotalMachines int32 `json:"totalMachines,omitempty"`
+
+	// AccountLimits reports the instance and GPU limits of the DataCrunch account and their current usage
+	// +optional
+	AccountLimits *DataCrunchAccountLimitsStatus `json:"accountLimits,omitempty"`
+}
+
+// DataCrunchAccountLimitsStatus reports the limits of a DataCrunch account
+type DataCrunchAccountLimitsStatus struct {
+	// MaxInstances is the maximum number of instances the account may run
+	MaxInstances int32 `json:"maxInstances,omitempty"`
+
+	// MaxGPUs is the maximum number of GPUs the account may use
+	MaxGPUs int32 `json:"maxGPUs,omitempty"`
+
+	// UsedInstances is the number of instances currently running in the account
+	UsedInstances int32 `json:"usedInstances,omitempty"`
+
+	// UsedGPUs is the number of GPUs currently in use in the account
+	UsedGPUs int32 `json:"usedGPUs,omitempty"`
 }
 
 // DataCrunchNetworkStatus reports network status
@@ -154,6 +204,11 @@ type DataCrunchVPCStatus struct {
 
 	// State is the current state of the VPC
 	State string `json:"state,omitempty"`
+
+	// Created reports whether the VPC was created by the controller rather than adopted, in which
+	// case it is deleted with the cluster
+	// +optional
+	Created bool `json:"created,omitempty"`
 }
 
 // DataCrunchSubnetStatus reports subnet status
@@ -169,6 +224,11 @@ type DataCrunchSubnetStatus struct {
 
 	// State is the current state of the subnet
 	State string `json:"state,omitempty"`
+
+	// Created reports whether the subnet was created by the controller rather than adopted, in which
+	// case it is deleted with the cluster
+	// +optional
+	Created bool `json:"created,omitempty"`
 }
 
 // DataCrunchLoadBalancerStatus reports load balancer status
@@ -0,0 +1,202 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// SetupWebhookWithManager sets up the DataCrunchCluster webhooks with the manager.
+func (c *DataCrunchCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(c).
+		WithValidator(&dataCrunchClusterWebhook{}).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-datacrunchcluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=datacrunchclusters,versions=v1beta1,name=validation.datacrunchcluster.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+
+// dataCrunchClusterWebhook implements a validating webhook for DataCrunchCluster.
+type dataCrunchClusterWebhook struct{}
+
+var _ webhook.CustomValidator = &dataCrunchClusterWebhook{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (w *dataCrunchClusterWebhook) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	c, ok := obj.(*DataCrunchCluster)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a DataCrunchCluster but got a %T", obj))
+	}
+
+	return nil, c.validate()
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (w *dataCrunchClusterWebhook) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	c, ok := newObj.(*DataCrunchCluster)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a DataCrunchCluster but got a %T", newObj))
+	}
+	old, ok := oldObj.(*DataCrunchCluster)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a DataCrunchCluster but got a %T", oldObj))
+	}
+
+	if old.Spec.APIEndpoint != c.Spec.APIEndpoint {
+		return nil, apierrors.NewInvalid(GroupVersion.WithKind("DataCrunchCluster").GroupKind(), c.Name, field.ErrorList{
+			field.Forbidden(field.NewPath("spec", "apiEndpoint"), "cannot be changed once set"),
+		})
+	}
+
+	return nil, c.validate()
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (w *dataCrunchClusterWebhook) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (c *DataCrunchCluster) validate() error {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, c.Spec.Network.validate(field.NewPath("spec", "network"))...)
+	allErrs = append(allErrs, c.validateControlPlaneEndpoint()...)
+	allErrs = append(allErrs, c.validateMaxHourlyCost()...)
+	allErrs = append(allErrs, c.validateAPIEndpoint()...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("DataCrunchCluster").GroupKind(), c.Name, allErrs)
+}
+
+// validateControlPlaneEndpoint checks that the control plane endpoint port is a valid TCP port.
+// A zero port is allowed and defaulted by the controller.
+func (c *DataCrunchCluster) validateControlPlaneEndpoint() field.ErrorList {
+	var allErrs field.ErrorList
+
+	port := c.Spec.ControlPlaneEndpoint.Port
+	if port != 0 && (port < 1 || port > 65535) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "controlPlaneEndpoint", "port"), port, "must be between 1 and 65535"))
+	}
+
+	return allErrs
+}
+
+// validateMaxHourlyCost checks that the budget of the cluster is a non-negative decimal number.
+func (c *DataCrunchCluster) validateMaxHourlyCost() field.ErrorList {
+	var allErrs field.ErrorList
+
+	if c.Spec.MaxHourlyCost == nil {
+		return allErrs
+	}
+
+	cost, err := strconv.ParseFloat(*c.Spec.MaxHourlyCost, 64)
+	if err != nil || cost < 0 || math.IsInf(cost, 0) || math.IsNaN(cost) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "maxHourlyCost"), *c.Spec.MaxHourlyCost, "must be a non-negative decimal number"))
+	}
+
+	return allErrs
+}
+
+// validateAPIEndpoint checks that the API endpoint is an absolute http or https URL.
+func (c *DataCrunchCluster) validateAPIEndpoint() field.ErrorList {
+	var allErrs field.ErrorList
+
+	if c.Spec.APIEndpoint == "" {
+		return allErrs
+	}
+
+	endpoint, err := url.Parse(c.Spec.APIEndpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "apiEndpoint"), c.Spec.APIEndpoint, "must be an absolute http or https URL"))
+	}
+
+	return allErrs
+}
+
+// validate checks that subnet CIDR blocks are well-formed, contained in the VPC CIDR block and don't overlap.
+func (n *DataCrunchNetworkSpec) validate(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if n == nil {
+		return allErrs
+	}
+
+	var vpcNet *net.IPNet
+	if n.VPC != nil && n.VPC.CidrBlock != "" {
+		_, parsed, err := net.ParseCIDR(n.VPC.CidrBlock)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("vpc", "cidrBlock"), n.VPC.CidrBlock, "must be a valid CIDR block"))
+		} else {
+			vpcNet = parsed
+		}
+	}
+
+	subnetNets := make([]*net.IPNet, len(n.Subnets))
+	for i, subnet := range n.Subnets {
+		if subnet.CidrBlock == "" {
+			continue
+		}
+
+		subnetPath := fldPath.Child("subnets").Index(i).Child("cidrBlock")
+		_, subnetNet, err := net.ParseCIDR(subnet.CidrBlock)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(subnetPath, subnet.CidrBlock, "must be a valid CIDR block"))
+			continue
+		}
+
+		if vpcNet != nil && !cidrContains(vpcNet, subnetNet) {
+			allErrs = append(allErrs, field.Invalid(subnetPath, subnet.CidrBlock, fmt.Sprintf("must be within the VPC CIDR block %s", vpcNet)))
+		}
+
+		for j := 0; j < i; j++ {
+			if subnetNets[j] != nil && cidrsOverlap(subnetNets[j], subnetNet) {
+				allErrs = append(allErrs, field.Invalid(subnetPath, subnet.CidrBlock, fmt.Sprintf("overlaps with subnet %d CIDR block %s", j, n.Subnets[j].CidrBlock)))
+			}
+		}
+
+		subnetNets[i] = subnetNet
+	}
+
+	return allErrs
+}
+
+// cidrContains returns true if inner is fully contained in outer.
+func cidrContains(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && innerOnes >= outerOnes && outer.Contains(inner.IP)
+}
+
+// cidrsOverlap returns true if the two CIDR blocks share any address.
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestDataCrunchClusterWebhook_ValidateSubnetCidrBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		network *DataCrunchNetworkSpec
+		wantErr string
+	}{
+		{
+			name: "no network",
+		},
+		{
+			name: "valid non-overlapping subnets",
+			network: &DataCrunchNetworkSpec{
+				VPC: &DataCrunchVPCSpec{CidrBlock: "10.0.0.0/16"},
+				Subnets: []DataCrunchSubnetSpec{
+					{CidrBlock: "10.0.1.0/24"},
+					{CidrBlock: "10.0.2.0/24"},
+				},
+			},
+		},
+		{
+			name: "subnets without VPC CIDR",
+			network: &DataCrunchNetworkSpec{
+				Subnets: []DataCrunchSubnetSpec{
+					{CidrBlock: "10.0.1.0/24"},
+					{CidrBlock: "192.168.0.0/24"},
+				},
+			},
+		},
+		{
+			name: "subnet without CIDR block",
+			network: &DataCrunchNetworkSpec{
+				VPC:     &DataCrunchVPCSpec{CidrBlock: "10.0.0.0/16"},
+				Subnets: []DataCrunchSubnetSpec{{ID: "subnet-123"}},
+			},
+		},
+		{
+			name: "overlapping subnets",
+			network: &DataCrunchNetworkSpec{
+				VPC: &DataCrunchVPCSpec{CidrBlock: "10.0.0.0/16"},
+				Subnets: []DataCrunchSubnetSpec{
+					{CidrBlock: "10.0.0.0/23"},
+					{CidrBlock: "10.0.1.0/24"},
+				},
+			},
+			wantErr: "overlaps with subnet 0",
+		},
+		{
+			name: "identical subnets",
+			network: &DataCrunchNetworkSpec{
+				Subnets: []DataCrunchSubnetSpec{
+					{CidrBlock: "10.0.1.0/24"},
+					{CidrBlock: "10.0.1.0/24"},
+				},
+			},
+			wantErr: "overlaps with subnet 0",
+		},
+		{
+			name: "subnet outside VPC",
+			network: &DataCrunchNetworkSpec{
+				VPC:     &DataCrunchVPCSpec{CidrBlock: "10.0.0.0/16"},
+				Subnets: []DataCrunchSubnetSpec{{CidrBlock: "10.1.0.0/24"}},
+			},
+			wantErr: "must be within the VPC CIDR block",
+		},
+		{
+			name: "subnet larger than VPC",
+			network: &DataCrunchNetworkSpec{
+				VPC:     &DataCrunchVPCSpec{CidrBlock: "10.0.0.0/16"},
+				Subnets: []DataCrunchSubnetSpec{{CidrBlock: "10.0.0.0/8"}},
+			},
+			wantErr: "must be within the VPC CIDR block",
+		},
+		{
+			name: "invalid subnet CIDR",
+			network: &DataCrunchNetworkSpec{
+				Subnets: []DataCrunchSubnetSpec{{CidrBlock: "10.0.1.0"}},
+			},
+			wantErr: "must be a valid CIDR block",
+		},
+		{
+			name: "invalid VPC CIDR",
+			network: &DataCrunchNetworkSpec{
+				VPC: &DataCrunchVPCSpec{CidrBlock: "not-a-cidr"},
+			},
+			wantErr: "must be a valid CIDR block",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &DataCrunchCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+				Spec:       DataCrunchClusterSpec{Network: tt.network},
+			}
+
+			w := &dataCrunchClusterWebhook{}
+			_, createErr := w.ValidateCreate(context.Background(), cluster)
+			_, updateErr := w.ValidateUpdate(context.Background(), cluster, cluster)
+
+			for _, err := range []error{createErr, updateErr} {
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Errorf("Expected no error but got: %v", err)
+					}
+					continue
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDataCrunchClusterWebhook_ValidateControlPlaneEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint clusterv1.APIEndpoint
+		wantErr  string
+	}{
+		{
+			name: "no endpoint",
+		},
+		{
+			name:     "valid port",
+			endpoint: clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 6443},
+		},
+		{
+			name:     "host without port",
+			endpoint: clusterv1.APIEndpoint{Host: "10.0.0.1"},
+		},
+		{
+			name:     "highest port",
+			endpoint: clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 65535},
+		},
+		{
+			name:     "negative port",
+			endpoint: clusterv1.APIEndpoint{Host: "10.0.0.1", Port: -1},
+			wantErr:  "spec.controlPlaneEndpoint.port",
+		},
+		{
+			name:     "port out of range",
+			endpoint: clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 65536},
+			wantErr:  "must be between 1 and 65535",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &DataCrunchCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+				Spec:       DataCrunchClusterSpec{ControlPlaneEndpoint: tt.endpoint},
+			}
+
+			w := &dataCrunchClusterWebhook{}
+			_, createErr := w.ValidateCreate(context.Background(), cluster)
+			_, updateErr := w.ValidateUpdate(context.Background(), cluster, cluster)
+
+			for _, err := range []error{createErr, updateErr} {
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Errorf("Expected no error but got: %v", err)
+					}
+					continue
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDataCrunchClusterWebhook_ValidateMaxHourlyCost(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name          string
+		maxHourlyCost *string
+		wantErr       string
+	}{
+		{
+			name: "no budget",
+		},
+		{
+			name:          "decimal budget",
+			maxHourlyCost: ptr("25.50"),
+		},
+		{
+			name:          "zero budget",
+			maxHourlyCost: ptr("0"),
+		},
+		{
+			name:          "negative budget",
+			maxHourlyCost: ptr("-1"),
+			wantErr:       "spec.maxHourlyCost",
+		},
+		{
+			name:          "not a number",
+			maxHourlyCost: ptr("25 EUR"),
+			wantErr:       "must be a non-negative decimal number",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &DataCrunchCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+				Spec:       DataCrunchClusterSpec{MaxHourlyCost: tt.maxHourlyCost},
+			}
+
+			w := &dataCrunchClusterWebhook{}
+			_, err := w.ValidateCreate(context.Background(), cluster)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error but got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDataCrunchClusterWebhook_ValidateWrongType(t *testing.T) {
+	w := &dataCrunchClusterWebhook{}
+	if _, err := w.ValidateCreate(context.Background(), &DataCrunchMachine{}); err == nil {
+		t.Error("Expected error for wrong object type")
+	}
+}
+
+func TestDataCrunchClusterWebhook_ValidateAPIEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		apiEndpoint string
+		wantErr     string
+	}{
+		{
+			name: "public API",
+		},
+		{
+			name:        "staging API",
+			apiEndpoint: "https://api.staging.datacrunch.io/v1",
+		},
+		{
+			name:        "local mock",
+			apiEndpoint: "http://localhost:8080/v1",
+		},
+		{
+			name:        "relative URL",
+			apiEndpoint: "api.staging.datacrunch.io/v1",
+			wantErr:     "must be an absolute http or https URL",
+		},
+		{
+			name:        "unsupported scheme",
+			apiEndpoint: "ftp://api.staging.datacrunch.io/v1",
+			wantErr:     "spec.apiEndpoint",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &DataCrunchCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+				Spec:       DataCrunchClusterSpec{APIEndpoint: tt.apiEndpoint},
+			}
+
+			w := &dataCrunchClusterWebhook{}
+			_, err := w.ValidateCreate(context.Background(), cluster)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error but got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDataCrunchClusterWebhook_ValidateUpdate_APIEndpointImmutable(t *testing.T) {
+	old := &DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+		Spec:       DataCrunchClusterSpec{APIEndpoint: "https://api.staging.datacrunch.io/v1"},
+	}
+	w := &dataCrunchClusterWebhook{}
+
+	unchanged := old.DeepCopy()
+	unchanged.Spec.Region = "FIN-01"
+	if _, err := w.ValidateUpdate(context.Background(), old, unchanged); err != nil {
+		t.Errorf("Expected no error but got: %v", err)
+	}
+
+	for _, apiEndpoint := range []string{"https://api.datacrunch.io/v1", ""} {
+		changed := old.DeepCopy()
+		changed.Spec.APIEndpoint = apiEndpoint
+		if _, err := w.ValidateUpdate(context.Background(), old, changed); err == nil || !strings.Contains(err.Error(), "spec.apiEndpoint") {
+			t.Errorf("Expected changing the API endpoint to %q to be rejected, got: %v", apiEndpoint, err)
+		}
+	}
+}
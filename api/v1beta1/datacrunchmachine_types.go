@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
@@ -33,7 +34,18 @@ type DataCrunchMachineSpec struct {
 	// InstanceType specifies the DataCrunch instance type (e.g., "1V100.6V", "1H100.80S.32V", "8H100.80S.176V")
 	InstanceType string `json:"instanceType"`
 
-	// Image specifies the image to use for the instance
+	// InstanceTypeFallbacks are tried in order when InstanceType is unavailable in the region of the
+	// machine. VCPUs and MemoryGB only apply to InstanceType.
+	// +optional
+	InstanceTypeFallbacks []string `json:"instanceTypeFallbacks,omitempty"`
+
+	// Region overrides the region of the DataCrunchCluster for this machine, e.g. for fleets spanning
+	// several regions. It must be one of the regions available to the account.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Image specifies the image to use for the instance. Defaults to the default image of the controller,
+	// ubuntu-22.04-cuda-12.1 unless configured otherwise.
 	// +optional
 	Image string `json:"image,omitempty"`
 
@@ -41,11 +53,21 @@ type DataCrunchMachineSpec struct {
 	// +optional
 	SSHKeyName string `json:"sshKeyName,omitempty"`
 
+	// SSHPublicKeySecretRef references a Secret whose "value" key holds an OpenSSH public key. If no SSH key
+	// named like the SSH key of the machine exists in DataCrunch, it is created from this public key before
+	// the instance, and an existing key is reused. The Secret must be in the same namespace as the
+	// DataCrunchMachine.
+	// +optional
+	SSHPublicKeySecretRef *corev1.SecretReference `json:"sshPublicKeySecretRef,omitempty"`
+
 	// ProviderID is the unique identifier as specified by the cloud provider.
 	// +optional
 	ProviderID *string `json:"providerID,omitempty"`
 
-	// AdditionalMetadata is the additional metadata for the machine
+	// AdditionalMetadata is the additional metadata for the machine. Values may reference the names of the
+	// machine and its cluster with the placeholders ${machine.name}, ${machine.namespace} and ${cluster.name},
+	// which are expanded when the instance is created. Any other text, including unknown placeholders, is
+	// passed through literally.
 	// +optional
 	AdditionalMetadata map[string]string `json:"additionalMetadata,omitempty"`
 
@@ -54,11 +76,18 @@ type DataCrunchMachineSpec struct {
 	// +optional
 	AdditionalTags map[string]string `json:"additionalTags,omitempty"`
 
+	// AdditionalLabels is an optional set of labels to add to an instance. Unlike tags, labels are
+	// reported back by DataCrunch and can be used to select instances.
+	// +optional
+	AdditionalLabels map[string]string `json:"additionalLabels,omitempty"`
+
 	// RootVolume encapsulates the configuration options for the root volume
 	// +optional
 	RootVolume *Volume `json:"rootVolume,omitempty"`
 
-	// UncompressedUserData specifies whether the user data is compressed or not.
+	// UncompressedUserData specifies whether the user data is compressed or not. When false the user data
+	// is gzipped, which cloud-init decompresses. Defaults to the default of the controller, which is true
+	// unless configured otherwise.
 	// +optional
 	UncompressedUserData *bool `json:"uncompressedUserData,omitempty"`
 
@@ -73,6 +102,83 @@ type DataCrunchMachineSpec struct {
 	// Spot configures the instance to use spot pricing
 	// +optional
 	Spot *SpotMachineOptions `json:"spot,omitempty"`
+
+	// VCPUs overrides the number of vCPUs for instance types that support customization.
+	// The value must be within the range allowed by the instance type.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	VCPUs *int32 `json:"vcpus,omitempty"`
+
+	// AllowImageReplacement allows the controller to delete and re-create the instance when
+	// the running instance's image no longer matches Image.
+	// +optional
+	AllowImageReplacement *bool `json:"allowImageReplacement,omitempty"`
+
+	// ReBootstrapOnChange allows the controller to delete and re-create the instance when the
+	// bootstrap data of the Machine changes after the instance was created. By default the
+	// bootstrap data is only applied when the instance is created.
+	// +optional
+	ReBootstrapOnChange *bool `json:"reBootstrapOnChange,omitempty"`
+
+	// MemoryGB overrides the amount of memory in GB for instance types that support customization.
+	// The value must be within the range allowed by the instance type.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MemoryGB *int32 `json:"memoryGB,omitempty"`
+
+	// CredentialsRef references a Secret with "clientID" and "clientSecret" keys holding the DataCrunch
+	// API credentials to use for this machine instead of the controller-wide credentials.
+	// The Secret must be in the same namespace as the DataCrunchMachine.
+	// +optional
+	CredentialsRef *corev1.SecretReference `json:"credentialsRef,omitempty"`
+
+	// StartupScriptRef references a Secret whose "value" key holds a shell script to run on first boot after
+	// the bootstrap data, e.g. an organization-wide node hardening script.
+	// The Secret must be in the same namespace as the DataCrunchMachine.
+	// +optional
+	StartupScriptRef *corev1.SecretReference `json:"startupScriptRef,omitempty"`
+
+	// SnapshotOnDelete takes a snapshot of the instance before it is deleted, e.g. for backup.
+	// The ID of the snapshot is recorded in Status.SnapshotID and the instance is only deleted once the
+	// snapshot is available.
+	// +optional
+	SnapshotOnDelete *bool `json:"snapshotOnDelete,omitempty"`
+
+	// StopInsteadOfDelete stops the instance instead of deleting it when its MachineSet is scaled to zero,
+	// so that idle capacity only costs storage. When the MachineSet is scaled up again, its new machines
+	// start the stopped instances before creating new ones. Instances are still deleted when the machine
+	// is deleted for any other reason, e.g. a rollout or the deletion of the cluster.
+	// +optional
+	StopInsteadOfDelete *bool `json:"stopInsteadOfDelete,omitempty"`
+
+	// PreStopCommands are shell commands run in order on the node of the machine before its instance is
+	// deleted, e.g. to checkpoint long-running training jobs. They run with access to the host in a Job on
+	// the workload cluster. Failures and timeouts are reported but don't block the deletion.
+	// +optional
+	PreStopCommands []string `json:"preStopCommands,omitempty"`
+
+	// PreStopTimeout bounds how long the deletion waits for PreStopCommands to complete. Defaults to 5 minutes.
+	// +optional
+	PreStopTimeout *metav1.Duration `json:"preStopTimeout,omitempty"`
+
+	// AntiAffinityGroup places the instance on a different physical host than the other instances
+	// of the same group, e.g. to spread control plane machines for high availability.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	AntiAffinityGroup *string `json:"antiAffinityGroup,omitempty"`
+
+	// HardwareGeneration pins the instance to a hardware generation of the instance type, e.g. a
+	// specific GPU board revision. It must be one of the generations the instance type is offered in.
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	HardwareGeneration *string `json:"hardwareGeneration,omitempty"`
+
+	// Paused marks a running machine as paused, e.g. for maintenance. When the controller is started with
+	// --cordon-paused-nodes, the Node of a paused machine is cordoned and uncordoned again once unpaused.
+	// +optional
+	Paused *bool `json:"paused,omitempty"`
 }
 
 // SpotMachineOptions defines the configuration for spot instances
@@ -85,11 +191,12 @@ type SpotMachineOptions struct {
 
 // Volume encapsulates the configuration options for the storage device
 type Volume struct {
-	// Size specifies the size of the storage device in GB
+	// Size specifies the size of the storage device in GB. Defaults to 100, or to a size based on the GPU
+	// model of the instance type when the defaulting webhook is disabled.
 	// +optional
 	Size int64 `json:"size,omitempty"`
 
-	// Type is the type of storage to use (e.g., "SSD", "HDD")
+	// Type is the type of storage to use (e.g., "SSD", "HDD"). Defaults to "fast-ssd".
 	// +optional
 	Type string `json:"type,omitempty"`
 
@@ -100,6 +207,11 @@ type Volume struct {
 	// IOPS is the number of IOPS for the storage device
 	// +optional
 	IOPS *int64 `json:"iops,omitempty"`
+
+	// DeleteOnTermination specifies whether the volume is deleted together with the instance.
+	// Defaults to true; set to false to retain the volume after the instance is deleted.
+	// +optional
+	DeleteOnTermination *bool `json:"deleteOnTermination,omitempty"`
 }
 
 // NetworkInterface defines the network interface configuration
@@ -120,7 +232,9 @@ type NetworkInterface struct {
 	// +optional
 	DeleteOnTermination *bool `json:"deleteOnTermination,omitempty"`
 
-	// SecondaryPrivateIPAddressCount is the number of secondary private IP addresses
+	// SecondaryPrivateIPAddressCount is the number of private IP addresses assigned to the network interface
+	// in addition to its primary one, e.g. for CNI plugins that give pods addresses from the VPC
+	// +kubebuilder:validation:Minimum=0
 	// +optional
 	SecondaryPrivateIPAddressCount *int64 `json:"secondaryPrivateIPAddressCount,omitempty"`
 
@@ -143,6 +257,36 @@ type DataCrunchMachineStatus struct {
 	// +optional
 	InstanceState *InstanceState `json:"instanceState,omitempty"`
 
+	// Labels contains the labels reported by DataCrunch for the instance.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// InstanceType is the instance type the instance was created with. It differs from the InstanceType
+	// of the spec when one of the InstanceTypeFallbacks was used.
+	// +optional
+	InstanceType string `json:"instanceType,omitempty"`
+
+	// Region is the region the instance runs in.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// ImageID is the image the instance was created from.
+	// +optional
+	ImageID string `json:"imageID,omitempty"`
+
+	// InstanceCreatedAt is when DataCrunch created the instance.
+	// +optional
+	InstanceCreatedAt *metav1.Time `json:"instanceCreatedAt,omitempty"`
+
+	// BootstrapDataHash is the SHA-256 hash of the bootstrap data the instance was created with.
+	// +optional
+	BootstrapDataHash string `json:"bootstrapDataHash,omitempty"`
+
+	// CreateOperationID is the ID of the DataCrunch API operation that created the instance, to correlate
+	// the machine with the DataCrunch operation logs.
+	// +optional
+	CreateOperationID string `json:"createOperationID,omitempty"`
+
 	// Conditions defines current service state of the DataCrunchMachine.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
@@ -188,6 +332,66 @@ type DataCrunchMachineStatus struct {
 	// InterruptionReason contains the interrupt action reason
 	// +optional
 	InterruptionReason *string `json:"interruptionReason,omitempty"`
+
+	// Pricing contains the current hourly pricing of the instance type, for use by cost tooling.
+	// +optional
+	Pricing *InstancePricing `json:"pricing,omitempty"`
+
+	// Metrics contains the latest resource utilization reported by DataCrunch for the instance,
+	// for use by observability dashboards.
+	// +optional
+	Metrics *InstanceMetrics `json:"metrics,omitempty"`
+
+	// SnapshotID is the ID of the snapshot taken before the instance was deleted
+	// +optional
+	SnapshotID string `json:"snapshotID,omitempty"`
+
+	// StoppingSince is the time the instance was first observed in the stopping state.
+	// It is cleared once the instance leaves that state.
+	// +optional
+	StoppingSince *metav1.Time `json:"stoppingSince,omitempty"`
+
+	// NodeCordoned is true while the controller keeps the Node of the paused machine cordoned
+	// +optional
+	NodeCordoned bool `json:"nodeCordoned,omitempty"`
+}
+
+// InstancePricing reports the current on-demand and spot pricing of an instance type
+type InstancePricing struct {
+	// Currency is the currency the prices are expressed in
+	// +optional
+	Currency string `json:"currency,omitempty"`
+
+	// OnDemandPrice is the current hourly on-demand price
+	// +optional
+	OnDemandPrice string `json:"onDemandPrice,omitempty"`
+
+	// SpotPrice is the current hourly spot price
+	// +optional
+	SpotPrice string `json:"spotPrice,omitempty"`
+
+	// LastUpdated is the time the pricing was last refreshed
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+// InstanceMetrics reports the resource utilization of an instance, in percent
+type InstanceMetrics struct {
+	// GPUUtilization is the average utilization of the GPUs of the instance
+	// +optional
+	GPUUtilization string `json:"gpuUtilization,omitempty"`
+
+	// CPUUtilization is the utilization of the vCPUs of the instance
+	// +optional
+	CPUUtilization string `json:"cpuUtilization,omitempty"`
+
+	// MemoryUtilization is the share of the memory of the instance in use
+	// +optional
+	MemoryUtilization string `json:"memoryUtilization,omitempty"`
+
+	// LastUpdated is the time the metrics were last refreshed
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
 }
 
 // InstanceState describes the state of a DataCrunch instance.
@@ -0,0 +1,318 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// DefaultImage is the image of machines that don't specify one
+	DefaultImage = "ubuntu-22.04-cuda-12.1"
+
+	// DefaultRootVolumeSizeGB and DefaultRootVolumeType describe the root volume of machines that don't
+	// specify one
+	DefaultRootVolumeSizeGB int64 = 100
+	DefaultRootVolumeType         = "fast-ssd"
+)
+
+// DefaultInstanceTypePatterns are the formats of the DataCrunch instance type names, e.g. 1xH100 or
+// 1H100.80S.32V for GPU and CPU.4V.16G for CPU instance types.
+var DefaultInstanceTypePatterns = []*regexp.Regexp{
+	// GPU instance types by GPU count and model, optionally with the GPU memory, e.g. 8xH100 or 1xA100.40G
+	regexp.MustCompile(`^\d+x[A-Z][A-Z0-9]*(\.\d+G)?$`),
+	// GPU instance types as named by the API, e.g. 1V100.6V, 1H100.80S.32V or 1RTX6000ADA.10V
+	regexp.MustCompile(`^\d+[A-Z][A-Z0-9]*(\.\d+S)?\.\d+V$`),
+	// CPU instance types by vCPUs and memory, e.g. CPU.4V.16G or 4VCPU.16G, and customizable CPU instances
+	regexp.MustCompile(`^CPU\.(\d+V\.\d+G|FLEX)$`),
+	regexp.MustCompile(`^\d+VCPU\.\d+G$`),
+}
+
+// SetupWebhookWithManager sets up the DataCrunchMachine webhooks with the manager.
+// Machines missing any of requiredTags in their AdditionalTags are rejected, as are machines whose
+// instance types match neither DefaultInstanceTypePatterns nor instanceTypePatterns. Machines without
+// an image get defaultImage, or DefaultImage if it is empty.
+func (m *DataCrunchMachine) SetupWebhookWithManager(mgr ctrl.Manager, requiredTags []string, instanceTypePatterns []*regexp.Regexp, defaultImage string) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(m).
+		WithDefaulter(&dataCrunchMachineDefaulter{DefaultImage: defaultImage}).
+		WithValidator(&dataCrunchMachineWebhook{
+			Client:               mgr.GetClient(),
+			RequiredTags:         requiredTags,
+			InstanceTypePatterns: append(append([]*regexp.Regexp{}, DefaultInstanceTypePatterns...), instanceTypePatterns...),
+		}).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-datacrunchmachine,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=datacrunchmachines,versions=v1beta1,name=default.datacrunchmachine.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+
+// dataCrunchMachineDefaulter implements a defaulting webhook for DataCrunchMachine, so that the image and
+// root volume an instance is created with are visible in the spec.
+type dataCrunchMachineDefaulter struct {
+	// DefaultImage is the image of machines that don't specify one. Empty means DefaultImage.
+	DefaultImage string
+}
+
+var _ webhook.CustomDefaulter = &dataCrunchMachineDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type.
+func (d *dataCrunchMachineDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	m, ok := obj.(*DataCrunchMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a DataCrunchMachine but got a %T", obj))
+	}
+
+	if m.Spec.Image == "" {
+		m.Spec.Image = d.DefaultImage
+		if m.Spec.Image == "" {
+			m.Spec.Image = DefaultImage
+		}
+	}
+
+	if m.Spec.RootVolume == nil {
+		m.Spec.RootVolume = &Volume{}
+	}
+	if m.Spec.RootVolume.Size == 0 {
+		m.Spec.RootVolume.Size = DefaultRootVolumeSizeGB
+	}
+	if m.Spec.RootVolume.Type == "" {
+		m.Spec.RootVolume.Type = DefaultRootVolumeType
+	}
+
+	return nil
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-datacrunchmachine,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=datacrunchmachines,versions=v1beta1,name=validation.datacrunchmachine.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+
+// dataCrunchMachineWebhook implements a validating webhook for DataCrunchMachine.
+type dataCrunchMachineWebhook struct {
+	// Client is used to look up the DataCrunchCluster the machine belongs to.
+	Client client.Reader
+
+	// RequiredTags are the tag keys every machine must set with a non-empty value.
+	RequiredTags []string
+
+	// InstanceTypePatterns are the formats instance types must match one of. Only the presence of the
+	// instance type is checked if there are none.
+	InstanceTypePatterns []*regexp.Regexp
+}
+
+var _ webhook.CustomValidator = &dataCrunchMachineWebhook{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (w *dataCrunchMachineWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	m, ok := obj.(*DataCrunchMachine)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a DataCrunchMachine but got a %T", obj))
+	}
+
+	return nil, w.validate(ctx, m, nil)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+// Only the fields changed by the update are validated, so that machines created before a policy was
+// enabled can still have their status and finalizers updated, and machines being deleted are not
+// validated at all.
+func (w *dataCrunchMachineWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	m, ok := newObj.(*DataCrunchMachine)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a DataCrunchMachine but got a %T", newObj))
+	}
+	old, ok := oldObj.(*DataCrunchMachine)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a DataCrunchMachine but got a %T", oldObj))
+	}
+
+	if m.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	return nil, w.validate(ctx, m, old)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (w *dataCrunchMachineWebhook) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate validates the machine, or only the fields that differ from old if it is not nil.
+func (w *dataCrunchMachineWebhook) validate(ctx context.Context, m, old *DataCrunchMachine) error {
+	var allErrs field.ErrorList
+
+	if old == nil || old.Spec.InstanceType != m.Spec.InstanceType || !slices.Equal(old.Spec.InstanceTypeFallbacks, m.Spec.InstanceTypeFallbacks) {
+		allErrs = append(allErrs, m.validateInstanceTypes(w.InstanceTypePatterns)...)
+	}
+	if old == nil || !maps.Equal(old.Spec.AdditionalTags, m.Spec.AdditionalTags) {
+		allErrs = append(allErrs, m.validateRequiredTags(w.RequiredTags)...)
+	}
+
+	if old == nil || !reflect.DeepEqual(old.Spec.PublicIP, m.Spec.PublicIP) || !reflect.DeepEqual(old.Spec.NetworkInterfaces, m.Spec.NetworkInterfaces) {
+		dataCrunchCluster, err := w.getDataCrunchCluster(ctx, m)
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		if dataCrunchCluster != nil {
+			allErrs = append(allErrs, m.validatePublicIP(dataCrunchCluster)...)
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("DataCrunchMachine").GroupKind(), m.Name, allErrs)
+}
+
+// getDataCrunchCluster returns the DataCrunchCluster of the machine's Cluster, or nil if it doesn't exist yet.
+func (w *dataCrunchMachineWebhook) getDataCrunchCluster(ctx context.Context, m *DataCrunchMachine) (*DataCrunchCluster, error) {
+	clusterName := m.Labels[clusterv1.ClusterNameLabel]
+	if clusterName == "" || w.Client == nil {
+		return nil, nil
+	}
+
+	cluster := &clusterv1.Cluster{}
+	if err := w.Client.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: clusterName}, cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get Cluster %s: %w", clusterName, err)
+	}
+
+	infraRef := cluster.Spec.InfrastructureRef
+	if infraRef == nil || infraRef.Kind != "DataCrunchCluster" {
+		return nil, nil
+	}
+
+	dataCrunchCluster := &DataCrunchCluster{}
+	if err := w.Client.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: infraRef.Name}, dataCrunchCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get DataCrunchCluster %s: %w", infraRef.Name, err)
+	}
+
+	return dataCrunchCluster, nil
+}
+
+// validateInstanceTypes checks that the instance type is set and that it and the fallbacks match one of
+// the patterns, so that typos are rejected on admission rather than when the instance is created.
+func (m *DataCrunchMachine) validateInstanceTypes(patterns []*regexp.Regexp) field.ErrorList {
+	var allErrs field.ErrorList
+
+	specPath := field.NewPath("spec")
+	if m.Spec.InstanceType == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("instanceType"), "instance type is required"))
+	} else {
+		allErrs = append(allErrs, validateInstanceType(specPath.Child("instanceType"), m.Spec.InstanceType, patterns)...)
+	}
+	for i, instanceType := range m.Spec.InstanceTypeFallbacks {
+		allErrs = append(allErrs, validateInstanceType(specPath.Child("instanceTypeFallbacks").Index(i), instanceType, patterns)...)
+	}
+
+	return allErrs
+}
+
+// validateInstanceType checks that the instance type matches one of the patterns, if there are any.
+func validateInstanceType(path *field.Path, instanceType string, patterns []*regexp.Regexp) field.ErrorList {
+	if len(patterns) == 0 {
+		return nil
+	}
+	for _, pattern := range patterns {
+		if pattern.MatchString(instanceType) {
+			return nil
+		}
+	}
+
+	formats := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		formats = append(formats, pattern.String())
+	}
+	return field.ErrorList{field.Invalid(path, instanceType, fmt.Sprintf("must match one of the instance type formats %s", strings.Join(formats, ", ")))}
+}
+
+// validateRequiredTags checks that each of the required tag keys is set with a non-empty value.
+func (m *DataCrunchMachine) validateRequiredTags(requiredTags []string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	tagsPath := field.NewPath("spec", "additionalTags")
+	for _, key := range requiredTags {
+		if m.Spec.AdditionalTags[key] == "" {
+			allErrs = append(allErrs, field.Required(tagsPath.Key(key), "tag is required by policy"))
+		}
+	}
+
+	return allErrs
+}
+
+// validatePublicIP rejects a public IP when every subnet the machine is attached to is private,
+// as the address would not be reachable. Subnets unknown to the cluster are not checked.
+func (m *DataCrunchMachine) validatePublicIP(dataCrunchCluster *DataCrunchCluster) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if m.Spec.PublicIP == nil || !*m.Spec.PublicIP {
+		return allErrs
+	}
+
+	network := dataCrunchCluster.Spec.Network
+	if network == nil {
+		return allErrs
+	}
+
+	// Subnets created by the controller are only known by the ID recorded in the status
+	public := make(map[string]bool, len(network.Subnets))
+	for i, subnet := range network.Subnets {
+		if subnet.ID != "" {
+			public[subnet.ID] = subnet.IsPublic
+		}
+		if status := dataCrunchCluster.Status.Network; status != nil && i < len(status.Subnets) && status.Subnets[i].ID != "" {
+			public[status.Subnets[i].ID] = subnet.IsPublic
+		}
+	}
+
+	referenced := 0
+	for _, networkInterface := range m.Spec.NetworkInterfaces {
+		if networkInterface.SubnetID == "" {
+			continue
+		}
+		isPublic, known := public[networkInterface.SubnetID]
+		if !known || isPublic {
+			return allErrs
+		}
+		referenced++
+	}
+
+	if referenced > 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "publicIP"), *m.Spec.PublicIP, "cannot be true when all referenced subnets are private"))
+	}
+
+	return allErrs
+}
@@ -0,0 +1,525 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDataCrunchMachineWebhook_ValidatePublicIP(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clusterv1.AddToScheme(scheme)
+	_ = AddToScheme(scheme)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{Kind: "DataCrunchCluster", Name: "test-datacrunch-cluster"},
+		},
+	}
+	dataCrunchCluster := &DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-datacrunch-cluster", Namespace: "default"},
+		Spec: DataCrunchClusterSpec{
+			Network: &DataCrunchNetworkSpec{
+				Subnets: []DataCrunchSubnetSpec{
+					{ID: "subnet-private-a"},
+					{CidrBlock: "10.0.2.0/24"},
+					{ID: "subnet-public", IsPublic: true},
+				},
+			},
+		},
+		Status: DataCrunchClusterStatus{
+			Network: &DataCrunchNetworkStatus{
+				Subnets: []DataCrunchSubnetStatus{
+					{ID: "subnet-private-a"},
+					{ID: "subnet-private-b"},
+					{ID: "subnet-public"},
+				},
+			},
+		},
+	}
+
+	publicIP := true
+	noPublicIP := false
+
+	tests := []struct {
+		name      string
+		publicIP  *bool
+		subnetIDs []string
+		noCluster bool
+		wantErr   string
+	}{
+		{
+			name:      "public IP with only private subnets",
+			publicIP:  &publicIP,
+			subnetIDs: []string{"subnet-private-a", "subnet-private-b"},
+			wantErr:   "cannot be true when all referenced subnets are private",
+		},
+		{
+			name:      "public IP with a public subnet",
+			publicIP:  &publicIP,
+			subnetIDs: []string{"subnet-private-a", "subnet-public"},
+		},
+		{
+			name:      "no public IP with only private subnets",
+			publicIP:  &noPublicIP,
+			subnetIDs: []string{"subnet-private-a"},
+		},
+		{
+			name:      "public IP unset with only private subnets",
+			subnetIDs: []string{"subnet-private-a"},
+		},
+		{
+			name:     "public IP without referenced subnets",
+			publicIP: &publicIP,
+		},
+		{
+			name:      "public IP with a subnet unknown to the cluster",
+			publicIP:  &publicIP,
+			subnetIDs: []string{"subnet-private-a", "subnet-external"},
+		},
+		{
+			name:      "public IP with private subnets before the cluster exists",
+			publicIP:  &publicIP,
+			subnetIDs: []string{"subnet-private-a"},
+			noCluster: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if !tt.noCluster {
+				builder = builder.WithObjects(cluster, dataCrunchCluster)
+			}
+
+			machine := &DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machine",
+					Namespace: "default",
+					Labels:    map[string]string{clusterv1.ClusterNameLabel: "test-cluster"},
+				},
+				Spec: DataCrunchMachineSpec{
+					InstanceType: "1xH100",
+					PublicIP:     tt.publicIP,
+				},
+			}
+			for _, subnetID := range tt.subnetIDs {
+				machine.Spec.NetworkInterfaces = append(machine.Spec.NetworkInterfaces, NetworkInterface{SubnetID: subnetID})
+			}
+
+			// Updates only validate changed fields, so update from a machine without network interfaces
+			oldMachine := machine.DeepCopy()
+			oldMachine.Spec.PublicIP = nil
+			oldMachine.Spec.NetworkInterfaces = nil
+
+			w := &dataCrunchMachineWebhook{Client: builder.Build()}
+			_, createErr := w.ValidateCreate(context.Background(), machine)
+			_, updateErr := w.ValidateUpdate(context.Background(), oldMachine, machine)
+
+			for _, err := range []error{createErr, updateErr} {
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Errorf("Expected no error but got: %v", err)
+					}
+					continue
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineWebhook_ValidateRequiredTags(t *testing.T) {
+	tests := []struct {
+		name         string
+		requiredTags []string
+		tags         map[string]string
+		wantErr      []string
+	}{
+		{
+			name: "no policy",
+		},
+		{
+			name:         "all required tags set",
+			requiredTags: []string{"cost-center", "owner"},
+			tags:         map[string]string{"cost-center": "ml-1234", "owner": "team-ml", "extra": "value"},
+		},
+		{
+			name:         "required tag missing",
+			requiredTags: []string{"cost-center", "owner"},
+			tags:         map[string]string{"owner": "team-ml"},
+			wantErr:      []string{"spec.additionalTags[cost-center]"},
+		},
+		{
+			name:         "required tag empty",
+			requiredTags: []string{"owner"},
+			tags:         map[string]string{"owner": ""},
+			wantErr:      []string{"spec.additionalTags[owner]"},
+		},
+		{
+			name:         "no tags at all",
+			requiredTags: []string{"cost-center", "owner"},
+			wantErr:      []string{"spec.additionalTags[cost-center]", "spec.additionalTags[owner]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+				Spec: DataCrunchMachineSpec{
+					InstanceType:   "1xH100",
+					AdditionalTags: tt.tags,
+				},
+			}
+
+			oldMachine := machine.DeepCopy()
+			oldMachine.Spec.AdditionalTags = map[string]string{"previous": "value"}
+
+			w := &dataCrunchMachineWebhook{RequiredTags: tt.requiredTags}
+			_, createErr := w.ValidateCreate(context.Background(), machine)
+			_, updateErr := w.ValidateUpdate(context.Background(), oldMachine, machine)
+
+			for _, err := range []error{createErr, updateErr} {
+				if len(tt.wantErr) == 0 {
+					if err != nil {
+						t.Errorf("Expected no error but got: %v", err)
+					}
+					continue
+				}
+				if err == nil {
+					t.Fatalf("Expected errors for %v, got none", tt.wantErr)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Expected error containing %q, got: %v", want, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineWebhook_ValidateInstanceType(t *testing.T) {
+	tests := []struct {
+		name         string
+		instanceType string
+		fallbacks    []string
+		patterns     []*regexp.Regexp
+		wantErr      []string
+	}{
+		{name: "GPU count and model", instanceType: "8xH100"},
+		{name: "GPU count, model and memory", instanceType: "1xA100.40G"},
+		{name: "API GPU instance type", instanceType: "1H100.80S.32V"},
+		{name: "API GPU instance type without GPU memory", instanceType: "1RTX6000ADA.10V"},
+		{name: "CPU instance type", instanceType: "CPU.4V.16G"},
+		{name: "vCPU instance type", instanceType: "4VCPU.16G"},
+		{name: "customizable CPU instance type", instanceType: "CPU.FLEX"},
+		{
+			name:         "valid fallbacks",
+			instanceType: "1xH100",
+			fallbacks:    []string{"1H100.80S.32V", "1xA100"},
+		},
+		{
+			name:    "empty instance type",
+			wantErr: []string{"spec.instanceType: Required value"},
+		},
+		{
+			name:         "unknown format",
+			instanceType: "invalid-instance-type",
+			wantErr:      []string{"spec.instanceType: Invalid value"},
+		},
+		{
+			name:         "lowercase model",
+			instanceType: "1xh100",
+			wantErr:      []string{"spec.instanceType: Invalid value"},
+		},
+		{
+			name:         "invalid fallback",
+			instanceType: "1xH100",
+			fallbacks:    []string{"1xA100", "4vcpu-16gb"},
+			wantErr:      []string{"spec.instanceTypeFallbacks[1]: Invalid value"},
+		},
+		{
+			name:         "additional pattern",
+			instanceType: "custom-gpu-large",
+			patterns:     []*regexp.Regexp{regexp.MustCompile(`^custom-gpu-(small|large)$`)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+				Spec: DataCrunchMachineSpec{
+					InstanceType:          tt.instanceType,
+					InstanceTypeFallbacks: tt.fallbacks,
+				},
+			}
+
+			oldMachine := machine.DeepCopy()
+			oldMachine.Spec.InstanceType = "previous-instance-type"
+
+			w := &dataCrunchMachineWebhook{InstanceTypePatterns: append(append([]*regexp.Regexp{}, DefaultInstanceTypePatterns...), tt.patterns...)}
+			_, createErr := w.ValidateCreate(context.Background(), machine)
+			_, updateErr := w.ValidateUpdate(context.Background(), oldMachine, machine)
+
+			for _, err := range []error{createErr, updateErr} {
+				if len(tt.wantErr) == 0 {
+					if err != nil {
+						t.Errorf("Expected no error but got: %v", err)
+					}
+					continue
+				}
+				if err == nil {
+					t.Fatalf("Expected errors for %v, got none", tt.wantErr)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Expected error containing %q, got: %v", want, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineWebhook_ValidateUpdate_NonCompliant(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clusterv1.AddToScheme(scheme)
+	_ = AddToScheme(scheme)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{Kind: "DataCrunchCluster", Name: "test-datacrunch-cluster"},
+		},
+	}
+	dataCrunchCluster := &DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-datacrunch-cluster", Namespace: "default"},
+		Spec: DataCrunchClusterSpec{
+			Network: &DataCrunchNetworkSpec{Subnets: []DataCrunchSubnetSpec{{ID: "subnet-private"}}},
+		},
+	}
+
+	// The machine was created before the policies were enabled and violates all of them
+	publicIP := true
+	existing := &DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-machine",
+			Namespace:  "default",
+			Labels:     map[string]string{clusterv1.ClusterNameLabel: "test-cluster"},
+			Finalizers: []string{MachineFinalizer},
+		},
+		Spec: DataCrunchMachineSpec{
+			InstanceType:      "custom-gpu",
+			PublicIP:          &publicIP,
+			NetworkInterfaces: []NetworkInterface{{SubnetID: "subnet-private"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		update  func(m *DataCrunchMachine)
+		wantErr []string
+	}{
+		{
+			name: "metadata only",
+			update: func(m *DataCrunchMachine) {
+				m.Annotations = map[string]string{"example.com/note": "value"}
+			},
+		},
+		{
+			name: "provider ID set",
+			update: func(m *DataCrunchMachine) {
+				providerID := "datacrunch://instance-123"
+				m.Spec.ProviderID = &providerID
+			},
+		},
+		{
+			name: "finalizer removed while deleting",
+			update: func(m *DataCrunchMachine) {
+				m.DeletionTimestamp = &metav1.Time{}
+				m.Finalizers = nil
+			},
+		},
+		{
+			name: "changed fields while deleting",
+			update: func(m *DataCrunchMachine) {
+				m.DeletionTimestamp = &metav1.Time{}
+				m.Spec.InstanceType = "other-gpu"
+			},
+		},
+		{
+			name: "instance type changed",
+			update: func(m *DataCrunchMachine) {
+				m.Spec.InstanceType = "other-gpu"
+			},
+			wantErr: []string{"spec.instanceType"},
+		},
+		{
+			name: "tags changed without the required tag",
+			update: func(m *DataCrunchMachine) {
+				m.Spec.AdditionalTags = map[string]string{"team": "ml"}
+			},
+			wantErr: []string{"spec.additionalTags[cost-center]"},
+		},
+		{
+			name: "network interfaces changed",
+			update: func(m *DataCrunchMachine) {
+				m.Spec.NetworkInterfaces = append(m.Spec.NetworkInterfaces, NetworkInterface{SubnetID: "subnet-private"})
+			},
+			wantErr: []string{"spec.publicIP"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &dataCrunchMachineWebhook{
+				Client:               fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, dataCrunchCluster).Build(),
+				RequiredTags:         []string{"cost-center"},
+				InstanceTypePatterns: DefaultInstanceTypePatterns,
+			}
+
+			updated := existing.DeepCopy()
+			tt.update(updated)
+			_, err := w.ValidateUpdate(context.Background(), existing, updated)
+
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error but got: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected errors for %v, got none", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error containing %q, got: %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineDefaulter_Default(t *testing.T) {
+	deleteOnTermination := false
+
+	tests := []struct {
+		name         string
+		defaultImage string
+		spec         DataCrunchMachineSpec
+		want         DataCrunchMachineSpec
+	}{
+		{
+			name: "image and root volume unset",
+			spec: DataCrunchMachineSpec{InstanceType: "1xH100"},
+			want: DataCrunchMachineSpec{
+				InstanceType: "1xH100",
+				Image:        DefaultImage,
+				RootVolume:   &Volume{Size: DefaultRootVolumeSizeGB, Type: DefaultRootVolumeType},
+			},
+		},
+		{
+			name:         "configured default image",
+			defaultImage: "ubuntu-24.04-cuda-12.4",
+			spec:         DataCrunchMachineSpec{InstanceType: "1xH100"},
+			want: DataCrunchMachineSpec{
+				InstanceType: "1xH100",
+				Image:        "ubuntu-24.04-cuda-12.4",
+				RootVolume:   &Volume{Size: DefaultRootVolumeSizeGB, Type: DefaultRootVolumeType},
+			},
+		},
+		{
+			name:         "image and root volume set",
+			defaultImage: "ubuntu-24.04-cuda-12.4",
+			spec: DataCrunchMachineSpec{
+				InstanceType: "1xH100",
+				Image:        "ubuntu-20.04",
+				RootVolume:   &Volume{Size: 500, Type: "NVMe"},
+			},
+			want: DataCrunchMachineSpec{
+				InstanceType: "1xH100",
+				Image:        "ubuntu-20.04",
+				RootVolume:   &Volume{Size: 500, Type: "NVMe"},
+			},
+		},
+		{
+			name: "root volume partially set",
+			spec: DataCrunchMachineSpec{
+				InstanceType: "1xH100",
+				Image:        "ubuntu-20.04",
+				RootVolume:   &Volume{Size: 250, DeleteOnTermination: &deleteOnTermination},
+			},
+			want: DataCrunchMachineSpec{
+				InstanceType: "1xH100",
+				Image:        "ubuntu-20.04",
+				RootVolume:   &Volume{Size: 250, Type: DefaultRootVolumeType, DeleteOnTermination: &deleteOnTermination},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+				Spec:       tt.spec,
+			}
+
+			d := &dataCrunchMachineDefaulter{DefaultImage: tt.defaultImage}
+			if err := d.Default(context.Background(), machine); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if !reflect.DeepEqual(machine.Spec, tt.want) {
+				t.Errorf("Expected spec %+v, got %+v", tt.want, machine.Spec)
+			}
+
+			// Defaulting again doesn't change anything
+			if err := d.Default(context.Background(), machine); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if !reflect.DeepEqual(machine.Spec, tt.want) {
+				t.Errorf("Expected defaulting to be idempotent, got %+v", machine.Spec)
+			}
+		})
+	}
+
+	if err := (&dataCrunchMachineDefaulter{}).Default(context.Background(), &DataCrunchCluster{}); err == nil {
+		t.Error("Expected error for wrong object type")
+	}
+}
+
+func TestDataCrunchMachineWebhook_ValidateWrongType(t *testing.T) {
+	w := &dataCrunchMachineWebhook{}
+	if _, err := w.ValidateCreate(context.Background(), &DataCrunchCluster{}); err == nil {
+		t.Error("Expected error for wrong object type")
+	}
+}
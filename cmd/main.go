@@ -18,12 +18,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
+	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -38,9 +43,17 @@ import (
 
 	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
 	controllers "github.com/rusik69/cluster-api-provider-datacrunch/internal/controller"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud/datacrunch"
 	"github.com/rusik69/cluster-api-provider-datacrunch/version"
 )
 
+// redactedValue replaces the value of secret-bearing flags in logs
+const redactedValue = "<redacted>"
+
+// secretFlagSubstrings identify flags whose values must not be logged
+var secretFlagSubstrings = []string{"secret", "password", "token", "credential"}
+
 var (
 	myscheme    = runtime.NewScheme()
 	setupLog    = ctrl.Log.WithName("setup")
@@ -64,15 +77,32 @@ func main() {
 		profilerAddress              string
 		dataCrunchClusterConcurrency int
 		dataCrunchMachineConcurrency int
+		adaptiveConcurrency          bool
+		adaptiveConcurrencyMin       int
 		syncPeriod                   time.Duration
+		reconcileTimeout             time.Duration
+		apiRateLimit                 float64
+		apiRateBurst                 int
+		requiredTags                 string
+		instanceTypePatterns         []*regexp.Regexp
+		publishCPEndpoint            bool
+		cordonPausedNodes            bool
+		spotInterruptionPollInterval time.Duration
+		instancePollInterval         time.Duration
+		errorRequeueInterval         time.Duration
+		defaultImage                 string
+		uncompressedUserData         bool
 		healthAddr                   string
 		webhookPort                  int
 		webhookCertDir               string
 		logLevel                     string
+		clientIDFile                 string
+		clientSecretFile             string
+		validateCredentialsOnly      bool
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-addr", ":8080",
-		"The address the metric endpoint binds to.")
+		"The address the metric endpoint binds to. Use 0 to disable metrics.")
 
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
@@ -98,9 +128,59 @@ func main() {
 	flag.IntVar(&dataCrunchMachineConcurrency, "datacrunchmachine-concurrency", 10,
 		"Number of DataCrunchMachines to process simultaneously")
 
+	flag.BoolVar(&adaptiveConcurrency, "adaptive-concurrency", false,
+		"Reduce the number of DataCrunchClusters and DataCrunchMachines processed simultaneously while the DataCrunch API rate limits requests, "+
+			"and increase it up to the sum of the concurrency flags while requests succeed")
+
+	flag.IntVar(&adaptiveConcurrencyMin, "adaptive-concurrency-min", 1,
+		"Minimum number of DataCrunchClusters and DataCrunchMachines processed simultaneously with adaptive concurrency")
+
 	flag.DurationVar(&syncPeriod, "sync-period", 10*time.Minute,
 		"The minimum interval at which watched resources are reconciled (e.g. 15m)")
 
+	flag.DurationVar(&reconcileTimeout, "reconcile-timeout", 0,
+		"The maximum duration of a single reconcile of a DataCrunchCluster or DataCrunchMachine (e.g. 5m). 0 disables the timeout")
+
+	flag.Float64Var(&apiRateLimit, "api-rate-limit", 0,
+		"Maximum number of DataCrunch API requests per second across all reconcilers. 0 disables rate limiting")
+
+	flag.IntVar(&apiRateBurst, "api-rate-burst", 10,
+		"Maximum burst of DataCrunch API requests allowed above the rate limit")
+
+	flag.StringVar(&requiredTags, "required-tags", "",
+		"Comma-separated list of tag keys every DataCrunchMachine must set in additionalTags (e.g. cost-center,owner)")
+	flag.Func("instance-type-pattern",
+		"Regular expression of an additional instance type format DataCrunchMachines may use, can be repeated",
+		func(value string) error {
+			pattern, err := regexp.Compile(value)
+			if err != nil {
+				return err
+			}
+			instanceTypePatterns = append(instanceTypePatterns, pattern)
+			return nil
+		})
+
+	flag.BoolVar(&publishCPEndpoint, "publish-control-plane-endpoint", false,
+		"Publish the control plane endpoint of each cluster into a <cluster-name>-control-plane-endpoint ConfigMap")
+
+	flag.BoolVar(&cordonPausedNodes, "cordon-paused-nodes", false,
+		"Cordon the Node of a running machine while its DataCrunchMachine is paused, and uncordon it once unpaused")
+
+	flag.DurationVar(&spotInterruptionPollInterval, "spot-interruption-poll-interval", 15*time.Second,
+		"How often spot instances are checked for interruption notices, draining and deleting interrupted machines. 0 disables polling")
+
+	flag.DurationVar(&instancePollInterval, "instance-poll-interval", 10*time.Second,
+		"How often a provisioning instance is checked, e.g. while it is pending or waiting for an address")
+
+	flag.DurationVar(&errorRequeueInterval, "error-requeue-interval", 30*time.Second,
+		"How long to back off before retrying a failed step of a DataCrunchMachine reconcile")
+
+	flag.StringVar(&defaultImage, "default-image", infrav1beta1.DefaultImage,
+		"Image of DataCrunchMachines that don't specify one")
+
+	flag.BoolVar(&uncompressedUserData, "uncompressed-user-data", true,
+		"Whether the user data of DataCrunchMachines that don't set uncompressedUserData is sent uncompressed, false gzips it")
+
 	flag.StringVar(&healthAddr, "health-addr", ":9440",
 		"The address the health endpoint binds to.")
 
@@ -113,6 +193,15 @@ func main() {
 	flag.StringVar(&logLevel, "log-level", "info",
 		"Log level for the controller (debug, info, warn, error)")
 
+	flag.StringVar(&clientIDFile, "datacrunch-client-id-file", "",
+		"File to read the DataCrunch API client ID from, e.g. a mounted Secret key. Reloaded when it changes.")
+
+	flag.StringVar(&clientSecretFile, "datacrunch-client-secret-file", "",
+		"File to read the DataCrunch API client secret from, e.g. a mounted Secret key. Reloaded when it changes.")
+
+	flag.BoolVar(&validateCredentialsOnly, "validate-credentials", false,
+		"Validate the DataCrunch API credentials by obtaining an access token and exit, with status 0 if they are valid and 1 otherwise")
+
 	// Add flags registered by imported packages (e.g. klog-v2, controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 	pflag.Parse()
@@ -126,7 +215,21 @@ func main() {
 
 	ctx := ctrl.SetupSignalHandler()
 
+	if validateCredentialsOnly {
+		if err := validateCredentials(ctx, clientIDFile, clientSecretFile); err != nil {
+			if errors.Is(err, cloud.ErrInvalidCredentials) {
+				setupLog.Error(err, "DataCrunch API credentials are invalid")
+			} else {
+				setupLog.Error(err, "unable to validate DataCrunch API credentials")
+			}
+			os.Exit(1)
+		}
+		setupLog.Info("DataCrunch API credentials are valid")
+		os.Exit(0)
+	}
+
 	setupLog.Info("Version", "version", version.Get().String())
+	setupLog.Info("Effective configuration", effectiveConfig(pflag.CommandLine)...)
 
 	restConfig := ctrl.GetConfigOrDie()
 	restConfig.UserAgent = "cluster-api-provider-datacrunch-manager"
@@ -141,7 +244,7 @@ func main() {
 		HealthProbeBindAddress:     healthAddr,
 		Logger:                     log.FromContext(ctx),
 		Metrics: server.Options{
-			BindAddress: metricsAddr,
+			BindAddress: metricsBindAddress(metricsAddr),
 		},
 		WebhookServer: webhook.NewServer(webhook.Options{
 			Port:    webhookPort,
@@ -156,11 +259,36 @@ func main() {
 	// Setup the context that's going to be used in controllers and for the manager.
 	ctx = ctrl.LoggerInto(ctx, ctrl.Log)
 
+	// Shared by all DataCrunch clients so the readiness check reflects the health of the API
+	apiHealth := datacrunch.NewHealthTracker()
+
+	concurrency, err := reconcileConcurrency(adaptiveConcurrency, adaptiveConcurrencyMin, dataCrunchClusterConcurrency+dataCrunchMachineConcurrency)
+	if err != nil {
+		setupLog.Error(err, "invalid adaptive concurrency")
+		os.Exit(1)
+	}
+
+	credentials, err := fileCredentials(clientIDFile, clientSecretFile)
+	if err != nil {
+		setupLog.Error(err, "unable to read DataCrunch credentials files")
+		os.Exit(1)
+	}
+	if credentials != nil {
+		if err := mgr.Add(credentials); err != nil {
+			setupLog.Error(err, "unable to watch DataCrunch credentials files")
+			os.Exit(1)
+		}
+	}
+
 	setupReconcilers(ctx, mgr, controller.Options{
 		MaxConcurrentReconciles: dataCrunchClusterConcurrency,
 	}, controller.Options{
 		MaxConcurrentReconciles: dataCrunchMachineConcurrency,
-	}, watchFilterValue)
+	}, watchFilterValue, reconcileTimeout, apiRateLimiter(apiRateLimit, apiRateBurst), apiHealth, concurrency, credentials, publishCPEndpoint, cordonPausedNodes, spotInterruptionPollInterval, instancePollInterval, errorRequeueInterval, defaultImage, uncompressedUserData)
+
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		setupWebhooks(mgr, parseRequiredTags(requiredTags), instanceTypePatterns, defaultImage)
+	}
 
 	//+kubebuilder:scaffold:builder
 
@@ -172,6 +300,10 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("datacrunch-api", apiHealth.Check); err != nil {
+		setupLog.Error(err, "unable to set up DataCrunch API ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctx); err != nil {
@@ -180,26 +312,177 @@ func main() {
 	}
 }
 
-func setupReconcilers(ctx context.Context, mgr ctrl.Manager, dataCrunchClusterOptions, dataCrunchMachineOptions controller.Options, watchFilterValue string) {
+// effectiveConfig returns the name and value of every flag as logr key/value pairs, with the values
+// of secret-bearing flags redacted.
+func effectiveConfig(fs *pflag.FlagSet) []interface{} {
+	var keysAndValues []interface{}
+	fs.VisitAll(func(f *pflag.Flag) {
+		value := f.Value.String()
+		if value != "" && isSecretFlag(f.Name) {
+			value = redactedValue
+		}
+		keysAndValues = append(keysAndValues, f.Name, value)
+	})
+	return keysAndValues
+}
+
+func isSecretFlag(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range secretFlagSubstrings {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// metricsBindAddress returns the address the metrics server should bind to. If addr can't be bound,
+// e.g. because the port is already in use, metrics are disabled instead of failing the manager start.
+func metricsBindAddress(addr string) string {
+	if addr == "0" {
+		setupLog.Info("Metrics are disabled")
+		return addr
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		setupLog.Error(err, "unable to bind metrics address, disabling metrics. Use --metrics-bind-addr to pick a free address or set it to 0 to disable metrics", "address", addr)
+		return "0"
+	}
+	_ = listener.Close()
+
+	return addr
+}
+
+func setupReconcilers(ctx context.Context, mgr ctrl.Manager, dataCrunchClusterOptions, dataCrunchMachineOptions controller.Options, watchFilterValue string, reconcileTimeout time.Duration, limiter *rate.Limiter, apiHealth *datacrunch.HealthTracker, concurrency *datacrunch.AdaptiveConcurrency, credentials *controllers.FileCredentials, publishControlPlaneEndpoint, cordonPausedNodes bool, spotInterruptionPollInterval, instancePollInterval, errorRequeueInterval time.Duration, defaultImage string, uncompressedUserData bool) {
+	// Both reconcilers share the rate limiter, health tracker and concurrency, so they can share their clients too
+	clientCache := datacrunch.NewClientCache()
+
 	if err := (&controllers.DataCrunchClusterReconciler{
 		Client:           mgr.GetClient(),
 		Scheme:           mgr.GetScheme(),
 		Recorder:         mgr.GetEventRecorderFor("datacrunchcluster-controller"),
 		Log:              ctrl.Log.WithName("controllers").WithName("DataCrunchCluster"),
 		WatchFilterValue: watchFilterValue,
+		ReconcileTimeout: reconcileTimeout,
+		APIRateLimiter:   limiter,
+		APIHealth:        apiHealth,
+		Concurrency:      concurrency,
+		Credentials:      credentials,
+		ClientCache:      clientCache,
+
+		PublishControlPlaneEndpoint: publishControlPlaneEndpoint,
 	}).SetupWithManager(ctx, mgr, dataCrunchClusterOptions); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DataCrunchCluster")
 		os.Exit(1)
 	}
 
-	if err := (&controllers.DataCrunchMachineReconciler{
+	dataCrunchMachineReconciler := &controllers.DataCrunchMachineReconciler{
 		Client:           mgr.GetClient(),
 		Scheme:           mgr.GetScheme(),
 		Recorder:         mgr.GetEventRecorderFor("datacrunchmachine-controller"),
 		Log:              ctrl.Log.WithName("controllers").WithName("DataCrunchMachine"),
 		WatchFilterValue: watchFilterValue,
-	}).SetupWithManager(ctx, mgr, dataCrunchMachineOptions); err != nil {
+		ReconcileTimeout: reconcileTimeout,
+		APIRateLimiter:   limiter,
+		APIHealth:        apiHealth,
+		Concurrency:      concurrency,
+		Credentials:      credentials,
+		ClientCache:      clientCache,
+
+		CordonPausedNodes:    cordonPausedNodes,
+		InstancePollInterval: instancePollInterval,
+		ErrorRequeueInterval: errorRequeueInterval,
+		DefaultImage:         defaultImage,
+
+		DefaultUncompressedUserData: &uncompressedUserData,
+	}
+	if err := dataCrunchMachineReconciler.SetupWithManager(ctx, mgr, dataCrunchMachineOptions); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DataCrunchMachine")
 		os.Exit(1)
 	}
+
+	if spotInterruptionPollInterval > 0 {
+		if err := mgr.Add(&controllers.SpotInterruptionPoller{
+			Reconciler: dataCrunchMachineReconciler,
+			Interval:   spotInterruptionPollInterval,
+		}); err != nil {
+			setupLog.Error(err, "unable to add spot interruption poller")
+			os.Exit(1)
+		}
+	}
+}
+
+// apiRateLimiter returns a limiter allowing limit DataCrunch API requests per second with the given burst,
+// or nil if limit is not positive.
+func apiRateLimiter(limit float64, burst int) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+// reconcileConcurrency returns the adaptive concurrency shared by the reconcilers, or nil if it is disabled.
+func reconcileConcurrency(enabled bool, minConcurrency, maxConcurrency int) (*datacrunch.AdaptiveConcurrency, error) {
+	if !enabled {
+		return nil, nil
+	}
+	return datacrunch.NewAdaptiveConcurrency(minConcurrency, maxConcurrency)
+}
+
+// fileCredentials returns the credentials read from the client ID and secret files, or nil if neither is set.
+func fileCredentials(clientIDFile, clientSecretFile string) (*controllers.FileCredentials, error) {
+	if clientIDFile == "" && clientSecretFile == "" {
+		return nil, nil
+	}
+	if clientIDFile == "" || clientSecretFile == "" {
+		return nil, errors.New("--datacrunch-client-id-file and --datacrunch-client-secret-file must be set together")
+	}
+	return controllers.NewFileCredentials(clientIDFile, clientSecretFile)
+}
+
+// parseRequiredTags splits a comma-separated list of tag keys, dropping empty entries.
+func parseRequiredTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func setupWebhooks(mgr ctrl.Manager, requiredTags []string, instanceTypePatterns []*regexp.Regexp, defaultImage string) {
+	if err := (&infrav1beta1.DataCrunchCluster{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "DataCrunchCluster")
+		os.Exit(1)
+	}
+	if err := (&infrav1beta1.DataCrunchMachine{}).SetupWebhookWithManager(mgr, requiredTags, instanceTypePatterns, defaultImage); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "DataCrunchMachine")
+		os.Exit(1)
+	}
+}
+
+// validateCredentials obtains an access token with the controller-wide DataCrunch API credentials, read
+// from the credentials files if set and from the DATACRUNCH_CLIENT_ID and DATACRUNCH_CLIENT_SECRET
+// environment variables otherwise, from the API at DATACRUNCH_API_URL.
+func validateCredentials(ctx context.Context, clientIDFile, clientSecretFile string) error {
+	clientID := os.Getenv("DATACRUNCH_CLIENT_ID")
+	clientSecret := os.Getenv("DATACRUNCH_CLIENT_SECRET")
+	credentials, err := fileCredentials(clientIDFile, clientSecretFile)
+	if err != nil {
+		return err
+	}
+	if credentials != nil {
+		clientID, clientSecret = credentials.Get()
+	}
+	if clientID == "" || clientSecret == "" {
+		return errors.New("no DataCrunch API credentials configured")
+	}
+
+	dataCrunchClient := datacrunch.NewClientWithOptions(clientID, clientSecret, datacrunch.ClientOptions{
+		BaseURL:    os.Getenv("DATACRUNCH_API_URL"),
+		MaxRetries: datacrunch.DefaultMaxRetries,
+	})
+	return dataCrunchClient.ValidateCredentials(ctx)
 }
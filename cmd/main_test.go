@@ -17,8 +17,20 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/spf13/pflag"
+
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
 )
 
 func TestMainFunction(t *testing.T) {
@@ -50,3 +62,173 @@ func TestInit(t *testing.T) {
 	// The init function is called automatically when the package is imported
 	t.Log("init function completed successfully")
 }
+
+func TestMetricsBindAddress(t *testing.T) {
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to occupy a port: %v", err)
+	}
+	defer func() { _ = occupied.Close() }()
+
+	tests := []struct {
+		name string
+		addr string
+		want string
+	}{
+		{
+			name: "metrics disabled",
+			addr: "0",
+			want: "0",
+		},
+		{
+			name: "free address",
+			addr: "127.0.0.1:0",
+			want: "127.0.0.1:0",
+		},
+		{
+			name: "address in use",
+			addr: occupied.Addr().String(),
+			want: "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metricsBindAddress(tt.addr); got != tt.want {
+				t.Errorf("metricsBindAddress(%q) = %q, want %q", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("metrics-bind-addr", ":8080", "")
+	fs.String("client-secret", "", "")
+	fs.String("api-token", "", "")
+	fs.String("bootstrap-password", "", "")
+	fs.String("empty-secret", "", "")
+
+	if err := fs.Parse([]string{"--client-secret=s3cr3t", "--api-token=t0k3n", "--bootstrap-password=hunter2"}); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+
+	keysAndValues := effectiveConfig(fs)
+	if len(keysAndValues)%2 != 0 {
+		t.Fatalf("Expected key/value pairs, got %v", keysAndValues)
+	}
+
+	config := map[string]interface{}{}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		config[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+
+	want := map[string]interface{}{
+		"metrics-bind-addr":  ":8080",
+		"client-secret":      redactedValue,
+		"api-token":          redactedValue,
+		"bootstrap-password": redactedValue,
+		"empty-secret":       "",
+	}
+	for name, value := range want {
+		if config[name] != value {
+			t.Errorf("Expected %s=%v, got %v", name, value, config[name])
+		}
+	}
+
+	for _, v := range keysAndValues {
+		if s, ok := v.(string); ok && (s == "s3cr3t" || s == "t0k3n" || s == "hunter2") {
+			t.Errorf("Secret value %q leaked into the effective configuration", s)
+		}
+	}
+}
+
+func TestParseRequiredTags(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+	}{
+		{value: "", expected: nil},
+		{value: "owner", expected: []string{"owner"}},
+		{value: "cost-center, owner,,", expected: []string{"cost-center", "owner"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseRequiredTags(tt.value); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("parseRequiredTags(%q) = %v, expected %v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir := t.TempDir()
+	clientIDFile := dir + "/client-id"
+	clientSecretFile := dir + "/client-secret"
+	if err := os.WriteFile(clientIDFile, []byte("client-id\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(clientSecretFile, []byte("client-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if credentials, err := fileCredentials("", ""); credentials != nil || err != nil {
+		t.Errorf("Expected no file credentials without flags, got %v, %v", credentials, err)
+	}
+	if _, err := fileCredentials(clientIDFile, ""); err == nil {
+		t.Error("Expected an error when only the client ID file is set")
+	}
+
+	credentials, err := fileCredentials(clientIDFile, clientSecretFile)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if clientID, clientSecret := credentials.Get(); clientID != "client-id" || clientSecret != "client-secret" {
+		t.Errorf("Expected credentials from the files, got %q/%q", clientID, clientSecret)
+	}
+}
+
+func TestValidateCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/oauth/token" {
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), `"client_secret":"valid-secret"`) {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	t.Setenv("DATACRUNCH_API_URL", server.URL)
+	t.Setenv("DATACRUNCH_CLIENT_ID", "client-id")
+
+	t.Setenv("DATACRUNCH_CLIENT_SECRET", "valid-secret")
+	if err := validateCredentials(context.Background(), "", ""); err != nil {
+		t.Errorf("Expected valid credentials, got: %v", err)
+	}
+
+	t.Setenv("DATACRUNCH_CLIENT_SECRET", "invalid-secret")
+	if err := validateCredentials(context.Background(), "", ""); !errors.Is(err, cloud.ErrInvalidCredentials) {
+		t.Errorf("Expected invalid credentials, got: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/client-id", []byte("client-id\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/client-secret", []byte("valid-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateCredentials(context.Background(), dir+"/client-id", dir+"/client-secret"); err != nil {
+		t.Errorf("Expected the credentials files to take precedence, got: %v", err)
+	}
+
+	t.Setenv("DATACRUNCH_CLIENT_SECRET", "")
+	if err := validateCredentials(context.Background(), "", ""); err == nil || errors.Is(err, cloud.ErrInvalidCredentials) {
+		t.Errorf("Expected an error for missing credentials, got: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+)
+
+// checkBudget returns ErrBudgetExceeded if creating the instance of the machine would take the combined
+// hourly price of the instances of the cluster above its MaxHourlyCost. Only machines that already have
+// an instance are accounted for, so machines created at the same time may overshoot the budget together.
+func (r *DataCrunchMachineReconciler) checkBudget(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	if dataCrunchCluster.Spec.MaxHourlyCost == nil {
+		return nil
+	}
+	budget, err := strconv.ParseFloat(*dataCrunchCluster.Spec.MaxHourlyCost, 64)
+	if err != nil {
+		return errors.Wrapf(err, "invalid maxHourlyCost %q of DataCrunchCluster %s", *dataCrunchCluster.Spec.MaxHourlyCost, dataCrunchCluster.Name)
+	}
+
+	machines := &infrav1beta1.DataCrunchMachineList{}
+	if err := r.List(ctx, machines, client.InNamespace(dataCrunchMachine.Namespace), client.MatchingLabels{clusterv1.ClusterNameLabel: cluster.Name}); err != nil {
+		return errors.Wrap(err, "failed to list DataCrunchMachines of the cluster")
+	}
+
+	var spent float64
+	for i := range machines.Items {
+		other := &machines.Items[i]
+		// Machines being deleted are still billed until their instance is gone
+		if other.Name == dataCrunchMachine.Name || providerInstanceID(other.Spec.ProviderID) == "" {
+			continue
+		}
+		price, err := machineHourlyPrice(ctx, dataCrunchClient, other, dataCrunchCluster)
+		if err != nil {
+			return err
+		}
+		spent += price
+	}
+
+	price, err := machineHourlyPrice(ctx, dataCrunchClient, dataCrunchMachine, dataCrunchCluster)
+	if err != nil {
+		return err
+	}
+	if spent+price > budget {
+		return errors.Wrapf(ErrBudgetExceeded, "instance type %s costs %s per hour, but only %s of the %s per hour budget remain",
+			machineInstanceType(dataCrunchMachine), formatPrice(price), formatPrice(max(budget-spent, 0)), formatPrice(budget))
+	}
+
+	return nil
+}
+
+// machineHourlyPrice returns the current hourly price of the instance type of the machine, the spot price
+// for spot machines.
+func machineHourlyPrice(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, dataCrunchCluster *infrav1beta1.DataCrunchCluster) (float64, error) {
+	instanceType := machineInstanceType(dataCrunchMachine)
+	price, err := dataCrunchClient.GetInstanceTypePrice(ctx, instanceType, machineRegion(dataCrunchMachine, dataCrunchCluster))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get price for instance type %s", instanceType)
+	}
+	if dataCrunchMachine.Spec.Spot != nil {
+		return price.SpotPrice, nil
+	}
+	return price.OnDemandPrice, nil
+}
+
+// formatPrice formats an hourly price for messages
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+)
+
+// budgetMachine returns a DataCrunchMachine of test-cluster, provisioned if it has an instance ID
+func budgetMachine(name, instanceID string) *infrav1beta1.DataCrunchMachine {
+	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: "test-cluster"},
+		},
+		Spec: infrav1beta1.DataCrunchMachineSpec{
+			InstanceType: "1xH100",
+		},
+	}
+	if instanceID != "" {
+		providerID := "datacrunch://" + instanceID
+		dataCrunchMachine.Spec.ProviderID = &providerID
+	}
+	return dataCrunchMachine
+}
+
+func TestDataCrunchMachineReconciler_checkBudget(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	price := &cloud.InstanceTypePrice{InstanceType: "1xH100", Currency: "USD", OnDemandPrice: 3, SpotPrice: 1}
+
+	otherCluster := budgetMachine("other-cluster-machine", "instance-9")
+	otherCluster.Labels[clusterv1.ClusterNameLabel] = "other-cluster"
+
+	tests := []struct {
+		name          string
+		maxHourlyCost *string
+		spot          bool
+		others        []client.Object
+		wantErr       string
+	}{
+		{
+			name:   "no budget",
+			others: []client.Object{budgetMachine("machine-1", "instance-1"), budgetMachine("machine-2", "instance-2")},
+		},
+		{
+			name:          "under budget",
+			maxHourlyCost: ptr("10"),
+			others:        []client.Object{budgetMachine("machine-1", "instance-1"), budgetMachine("machine-2", "instance-2")},
+		},
+		{
+			name:          "exactly at budget",
+			maxHourlyCost: ptr("9"),
+			others:        []client.Object{budgetMachine("machine-1", "instance-1"), budgetMachine("machine-2", "instance-2")},
+		},
+		{
+			name:          "over budget",
+			maxHourlyCost: ptr("8.50"),
+			others:        []client.Object{budgetMachine("machine-1", "instance-1"), budgetMachine("machine-2", "instance-2")},
+			wantErr:       "only 2.50 of the 8.50 per hour budget remain",
+		},
+		{
+			name:          "budget too small for a single instance",
+			maxHourlyCost: ptr("2"),
+			wantErr:       "costs 3.00 per hour",
+		},
+		{
+			name:          "machines without an instance and of other clusters are not billed",
+			maxHourlyCost: ptr("6"),
+			others: []client.Object{
+				budgetMachine("machine-1", "instance-1"),
+				budgetMachine("machine-2", ""),
+				otherCluster,
+			},
+		},
+		{
+			name:          "spot machines are billed at the spot price",
+			maxHourlyCost: ptr("7"),
+			spot:          true,
+			others:        []client.Object{budgetMachine("machine-1", "instance-1"), budgetMachine("machine-2", "instance-2")},
+		},
+		{
+			name:          "invalid budget",
+			maxHourlyCost: ptr("lots"),
+			wantErr:       "invalid maxHourlyCost",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = infrav1beta1.AddToScheme(scheme)
+
+			dataCrunchMachine := budgetMachine("new-machine", "")
+			if tt.spot {
+				dataCrunchMachine.Spec.Spot = &infrav1beta1.SpotMachineOptions{}
+			}
+			objects := append([]client.Object{dataCrunchMachine}, tt.others...)
+
+			reconciler := &DataCrunchMachineReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+			}
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+			dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+				Spec:       infrav1beta1.DataCrunchClusterSpec{MaxHourlyCost: tt.maxHourlyCost},
+			}
+
+			err := reconciler.checkBudget(context.Background(), &fakeCloudClient{price: price}, dataCrunchMachine, cluster, dataCrunchCluster)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error but got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_BudgetExceeded(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = infrav1beta1.AddToScheme(scheme)
+
+	maxHourlyCost := "5"
+	dataCrunchMachine := budgetMachine("new-machine", "")
+	existing := budgetMachine("machine-1", "instance-1")
+
+	reconciler := &DataCrunchMachineReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(dataCrunchMachine, existing).Build(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+		Spec:       infrav1beta1.DataCrunchClusterSpec{MaxHourlyCost: &maxHourlyCost},
+	}
+	fakeClient := &fakeCloudClient{price: &cloud.InstanceTypePrice{OnDemandPrice: 3}}
+
+	_, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, &clusterv1.Machine{}, dataCrunchMachine, cluster, dataCrunchCluster)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got: %v", err)
+	}
+	if len(fakeClient.created) != 0 {
+		t.Errorf("Expected no instance to be created over budget, got %d", len(fakeClient.created))
+	}
+}
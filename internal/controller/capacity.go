@@ -0,0 +1,75 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+)
+
+// insufficientCapacity wraps ErrInsufficientCapacity with the reason none of the instance types could be
+// created in the region, suggesting the other regions that have capacity for them. Failing to look up
+// the suggestions doesn't hide the reason.
+func insufficientCapacity(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, instanceTypes []string, region string, reason error) error {
+	suggestions, err := placementSuggestions(ctx, dataCrunchClient, instanceTypes, region)
+	switch {
+	case err != nil:
+		log.Error(err, "failed to look up regions with capacity")
+		return errors.Wrapf(ErrInsufficientCapacity, "%v", reason)
+	case len(suggestions) == 0:
+		return errors.Wrapf(ErrInsufficientCapacity, "%v, no other region has capacity for %s either", reason, strings.Join(instanceTypes, ", "))
+	default:
+		return errors.Wrapf(ErrInsufficientCapacity, "%v, capacity is available for %s", reason, strings.Join(suggestions, "; "))
+	}
+}
+
+// placementSuggestions returns, for each of the instance types in order, the regions other than region
+// in which it is available, e.g. "1xH100 in FIN-02, ICE-01". Instance types available in no other region
+// are left out.
+func placementSuggestions(ctx context.Context, dataCrunchClient cloud.Client, instanceTypes []string, region string) ([]string, error) {
+	locations, err := dataCrunchClient.ListLocations(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list locations")
+	}
+
+	var suggestions []string
+	for _, instanceType := range instanceTypes {
+		var regions []string
+		for _, location := range locations {
+			if location.Code == region {
+				continue
+			}
+			available, err := dataCrunchClient.IsInstanceTypeAvailable(ctx, instanceType, location.Code)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to check availability of instance type %s in %s", instanceType, location.Code)
+			}
+			if available {
+				regions = append(regions, location.Code)
+			}
+		}
+		if len(regions) > 0 {
+			suggestions = append(suggestions, instanceType+" in "+strings.Join(regions, ", "))
+		}
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+)
+
+func TestPlacementSuggestions(t *testing.T) {
+	locations := []*cloud.Location{{Code: "FIN-01"}, {Code: "FIN-02"}, {Code: "ICE-01"}}
+
+	tests := []struct {
+		name             string
+		instanceTypes    []string
+		availableRegions map[string][]string
+		want             []string
+	}{
+		{
+			name:             "other regions with capacity",
+			instanceTypes:    []string{"1xH100"},
+			availableRegions: map[string][]string{"1xH100": {"FIN-02", "ICE-01"}},
+			want:             []string{"1xH100 in FIN-02, ICE-01"},
+		},
+		{
+			name:             "the region of the machine is not suggested",
+			instanceTypes:    []string{"1xH100"},
+			availableRegions: map[string][]string{"1xH100": {"FIN-01", "ICE-01"}},
+			want:             []string{"1xH100 in ICE-01"},
+		},
+		{
+			name:             "fallbacks are suggested in order",
+			instanceTypes:    []string{"1xH100", "1xA100", "1V100.6V"},
+			availableRegions: map[string][]string{"1xH100": nil, "1xA100": {"ICE-01"}, "1V100.6V": {"FIN-02"}},
+			want:             []string{"1xA100 in ICE-01", "1V100.6V in FIN-02"},
+		},
+		{
+			name:             "no capacity anywhere",
+			instanceTypes:    []string{"1xH100"},
+			availableRegions: map[string][]string{"1xH100": {"FIN-01"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := &fakeCloudClient{locations: locations, availableRegions: tt.availableRegions}
+			got, err := placementSuggestions(context.Background(), fakeClient, tt.instanceTypes, "FIN-01")
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected suggestions %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_InsufficientCapacity(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	secretName := "test-machine-bootstrap"
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config\n")},
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+		Spec:       clusterv1.MachineSpec{Bootstrap: clusterv1.Bootstrap{DataSecretName: &secretName}},
+	}
+	locations := []*cloud.Location{{Code: "FIN-01"}, {Code: "FIN-02"}, {Code: "ICE-01"}}
+
+	tests := []struct {
+		name             string
+		fallbacks        []string
+		noCapacityTypes  map[string]bool
+		availableRegions map[string][]string
+		wantErr          string
+	}{
+		{
+			name:             "out of capacity on create",
+			noCapacityTypes:  map[string]bool{"1xH100": true},
+			availableRegions: map[string][]string{"1xH100": {"FIN-02", "ICE-01"}},
+			wantErr:          "capacity is available for 1xH100 in FIN-02, ICE-01",
+		},
+		{
+			name:             "all types unavailable",
+			fallbacks:        []string{"1xA100"},
+			availableRegions: map[string][]string{"1xH100": {"ICE-01"}, "1xA100": {"FIN-02"}},
+			wantErr:          "capacity is available for 1xH100 in ICE-01; 1xA100 in FIN-02",
+		},
+		{
+			name:             "no capacity in other regions",
+			noCapacityTypes:  map[string]bool{"1xH100": true},
+			availableRegions: map[string][]string{"1xH100": nil},
+			wantErr:          "no other region has capacity for 1xH100 either",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType:          "1xH100",
+					InstanceTypeFallbacks: tt.fallbacks,
+					Region:                "FIN-01",
+				},
+			}
+			reconciler := &DataCrunchMachineReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			fakeClient := &fakeCloudClient{
+				locations:        locations,
+				noCapacityTypes:  tt.noCapacityTypes,
+				availableRegions: tt.availableRegions,
+			}
+
+			_, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{})
+			if !errors.Is(err, ErrInsufficientCapacity) {
+				t.Fatalf("Expected ErrInsufficientCapacity, got: %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
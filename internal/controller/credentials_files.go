@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// FileCredentials provides the controller-wide DataCrunch API credentials from files, e.g. the keys of a
+// mounted Secret. Once started, the credentials are re-read whenever the files change, so rotating the
+// Secret doesn't require restarting the controller.
+type FileCredentials struct {
+	clientIDFile     string
+	clientSecretFile string
+
+	mutex        sync.RWMutex
+	clientID     string
+	clientSecret string
+}
+
+var _ manager.LeaderElectionRunnable = &FileCredentials{}
+
+// NewFileCredentials reads the client ID and secret from the given files.
+func NewFileCredentials(clientIDFile, clientSecretFile string) (*FileCredentials, error) {
+	c := &FileCredentials{
+		clientIDFile:     clientIDFile,
+		clientSecretFile: clientSecretFile,
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns the last credentials read from the files.
+func (c *FileCredentials) Get() (clientID, clientSecret string) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.clientID, c.clientSecret
+}
+
+// reload re-reads both files. The credentials are left unchanged if either file can't be read.
+func (c *FileCredentials) reload() error {
+	clientID, err := readCredentialsFile(c.clientIDFile)
+	if err != nil {
+		return err
+	}
+	clientSecret, err := readCredentialsFile(c.clientSecretFile)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.clientID = clientID
+	c.clientSecret = clientSecret
+	return nil
+}
+
+// readCredentialsFile returns the content of a credentials file without surrounding whitespace.
+func readCredentialsFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read credentials file %s", path)
+	}
+
+	value := string(bytes.TrimSpace(data))
+	if value == "" {
+		return "", errors.Errorf("credentials file %s is empty", path)
+	}
+	return value, nil
+}
+
+// Start implements manager.Runnable and reloads the credentials on changes until the context is cancelled.
+func (c *FileCredentials) Start(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx).WithName("credentials")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create credentials file watcher")
+	}
+	defer func() { _ = watcher.Close() }()
+
+	// Mounted Secrets are updated by swapping a symlink, so the directories are watched rather than the files
+	for _, dir := range uniqueSorted([]string{filepath.Dir(c.clientIDFile), filepath.Dir(c.clientSecretFile)}) {
+		if err := watcher.Add(dir); err != nil {
+			return errors.Wrapf(err, "failed to watch credentials directory %s", dir)
+		}
+	}
+
+	// Catch changes made between reading the files initially and watching them
+	if err := c.reload(); err != nil {
+		log.Error(err, "failed to reload DataCrunch credentials")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) && !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			// A file may be caught mid-update, the next event of the update reloads it again
+			if err := c.reload(); err != nil {
+				log.Error(err, "failed to reload DataCrunch credentials, keeping the previous ones")
+				continue
+			}
+			log.Info("Reloaded DataCrunch credentials", "file", event.Name)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error(err, "credentials file watcher failed")
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every replica needs the current credentials.
+func (c *FileCredentials) NeedLeaderElection() bool {
+	return false
+}
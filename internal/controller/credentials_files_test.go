@@ -0,0 +1,185 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func writeCredentialsFile(t *testing.T, path, value string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestNewFileCredentials(t *testing.T) {
+	tests := []struct {
+		name             string
+		clientID         *string
+		clientSecret     string
+		wantClientID     string
+		wantClientSecret string
+		wantErr          bool
+	}{
+		{
+			name:             "mounted Secret keys with trailing newlines",
+			clientID:         ptrTo("client-id\n"),
+			clientSecret:     "client-secret\n",
+			wantClientID:     "client-id",
+			wantClientSecret: "client-secret",
+		},
+		{
+			name:         "missing client ID file",
+			clientSecret: "client-secret",
+			wantErr:      true,
+		},
+		{
+			name:         "empty client ID file",
+			clientID:     ptrTo(" \n"),
+			clientSecret: "client-secret",
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			clientIDFile := filepath.Join(dir, "client-id")
+			clientSecretFile := filepath.Join(dir, "client-secret")
+			if tt.clientID != nil {
+				writeCredentialsFile(t, clientIDFile, *tt.clientID)
+			}
+			writeCredentialsFile(t, clientSecretFile, tt.clientSecret)
+
+			credentials, err := NewFileCredentials(clientIDFile, clientSecretFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got: %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			clientID, clientSecret := credentials.Get()
+			if clientID != tt.wantClientID || clientSecret != tt.wantClientSecret {
+				t.Errorf("Expected credentials %q/%q, got %q/%q", tt.wantClientID, tt.wantClientSecret, clientID, clientSecret)
+			}
+		})
+	}
+}
+
+func TestFileCredentials_Start_Reloads(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T, dir string)
+		update func(t *testing.T, dir string)
+	}{
+		{
+			name: "files rewritten in place",
+			setup: func(t *testing.T, dir string) {
+				writeCredentialsFile(t, filepath.Join(dir, "client-id"), "old-id")
+				writeCredentialsFile(t, filepath.Join(dir, "client-secret"), "old-secret")
+			},
+			update: func(t *testing.T, dir string) {
+				writeCredentialsFile(t, filepath.Join(dir, "client-id"), "new-id")
+				writeCredentialsFile(t, filepath.Join(dir, "client-secret"), "new-secret")
+			},
+		},
+		{
+			// The kubelet writes a new version of a mounted Secret next to the old one and swaps the ..data symlink
+			name: "mounted Secret updated by the kubelet",
+			setup: func(t *testing.T, dir string) {
+				writeSecretVersion(t, dir, "v1", "old-id", "old-secret")
+				mustSymlink(t, "v1", filepath.Join(dir, "..data"))
+				mustSymlink(t, filepath.Join("..data", "client-id"), filepath.Join(dir, "client-id"))
+				mustSymlink(t, filepath.Join("..data", "client-secret"), filepath.Join(dir, "client-secret"))
+			},
+			update: func(t *testing.T, dir string) {
+				writeSecretVersion(t, dir, "v2", "new-id", "new-secret")
+				mustSymlink(t, "v2", filepath.Join(dir, "..data_tmp"))
+				if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+					t.Fatalf("Failed to swap the ..data symlink: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir)
+
+			credentials, err := NewFileCredentials(filepath.Join(dir, "client-id"), filepath.Join(dir, "client-secret"))
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(ctrl.LoggerInto(context.Background(), logr.Discard()))
+			done := make(chan error)
+			go func() { done <- credentials.Start(ctx) }()
+			defer func() {
+				cancel()
+				if err := <-done; err != nil {
+					t.Errorf("Expected Start to stop without error, got: %v", err)
+				}
+			}()
+
+			// Give the watcher time to start before changing the files
+			time.Sleep(100 * time.Millisecond)
+			tt.update(t, dir)
+
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				clientID, clientSecret := credentials.Get()
+				if clientID == "new-id" && clientSecret == "new-secret" {
+					return
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("Expected the credentials to be reloaded, got %q/%q", clientID, clientSecret)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func writeSecretVersion(t *testing.T, dir, version, clientID, clientSecret string) {
+	t.Helper()
+	if err := os.Mkdir(filepath.Join(dir, version), 0o700); err != nil {
+		t.Fatalf("Failed to create %s: %v", version, err)
+	}
+	writeCredentialsFile(t, filepath.Join(dir, version, "client-id"), clientID)
+	writeCredentialsFile(t, filepath.Join(dir, version, "client-secret"), clientSecret)
+}
+
+func mustSymlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("Failed to create symlink %s: %v", link, err)
+	}
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
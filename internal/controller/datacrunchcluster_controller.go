@@ -18,13 +18,20 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
@@ -36,6 +43,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
@@ -51,18 +59,84 @@ type DataCrunchClusterReconciler struct {
 	Log      logr.Logger
 
 	WatchFilterValue string
+
+	// ReconcileTimeout bounds the duration of a single Reconcile call. Zero means no timeout.
+	ReconcileTimeout time.Duration
+
+	// APIRateLimiter, if set, is shared by all DataCrunch clients created by the reconciler to pace API requests.
+	APIRateLimiter *rate.Limiter
+
+	// APIHealth, if set, records the outcome of the API requests of all DataCrunch clients created by the reconciler.
+	APIHealth *datacrunch.HealthTracker
+
+	// Concurrency, if set, bounds the reconciles running at once. It is tuned by the API requests of all DataCrunch
+	// clients created by the reconciler and can be shared with other reconcilers to bound their reconciles together.
+	Concurrency *datacrunch.AdaptiveConcurrency
+
+	// ClientCache, if set, reuses DataCrunch clients and their access tokens across reconciles. It can be
+	// shared between reconcilers using the same rate limiter, health tracker and concurrency.
+	ClientCache *datacrunch.ClientCache
+
+	// Credentials, if set, provides the controller-wide API credentials instead of the DATACRUNCH_CLIENT_ID
+	// and DATACRUNCH_CLIENT_SECRET environment variables.
+	Credentials *FileCredentials
+
+	// PublishControlPlaneEndpoint enables publishing the control plane endpoint into a ConfigMap
+	// named after the cluster, so workers can discover it.
+	PublishControlPlaneEndpoint bool
 }
 
+const (
+	// controlPlaneEndpointConfigMapSuffix is appended to the cluster name to form the name of the
+	// ConfigMap publishing the control plane endpoint
+	controlPlaneEndpointConfigMapSuffix = "-control-plane-endpoint"
+
+	// defaultControlPlaneEndpointPort is the API server port used when the control plane endpoint doesn't set one
+	defaultControlPlaneEndpointPort = 6443
+
+	// vpcStateAvailable is the state of a VPC that subnets and instances can be created in
+	vpcStateAvailable = "available"
+
+	// loadBalancerStateActive is the state of a load balancer that serves traffic
+	loadBalancerStateActive = "active"
+
+	// apiIncompatibleRequeueAfter is how long to wait before retrying a cluster or machine whose API
+	// responses the provider can't parse, as retrying sooner won't help until it is upgraded
+	apiIncompatibleRequeueAfter = 30 * time.Minute
+
+	// defaultFailureDomain is the failure domain of clusters without subnets in an availability zone, its
+	// machines are placed in any availability zone of the region
+	defaultFailureDomain = "default"
+)
+
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=datacrunchclusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=datacrunchclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=datacrunchclusters/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=datacrunchmachines,verbs=get;list;watch
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 func (r *DataCrunchClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	log := r.Log.WithValues("namespace", req.Namespace, "datacrunchCluster", req.Name)
 
+	// Workers beyond the current limit wait, so the API isn't flooded while it rate limits requests. The
+	// wait doesn't count towards the reconcile timeout.
+	if r.Concurrency != nil {
+		if err := r.Concurrency.Acquire(ctx); err != nil {
+			return reconcile.Result{}, err
+		}
+		defer r.Concurrency.Release()
+	}
+
+	// Bound the reconcile so a hung API call can't block a worker indefinitely
+	if r.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.ReconcileTimeout)
+		defer cancel()
+	}
+
 	// Fetch the DataCrunchCluster instance
 	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{}
 	err := r.Get(ctx, req.NamespacedName, dataCrunchCluster)
@@ -107,8 +181,15 @@ func (r *DataCrunchClusterReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return reconcile.Result{}, err
 	}
 
-	// Always attempt to Patch the DataCrunchCluster object and status after each reconciliation.
+	original := dataCrunchCluster.DeepCopy()
+
+	// Patch the DataCrunchCluster object and status after each reconciliation that changed them.
 	defer func() {
+		if equality.Semantic.DeepEqual(original.ObjectMeta, dataCrunchCluster.ObjectMeta) &&
+			equality.Semantic.DeepEqual(original.Spec, dataCrunchCluster.Spec) &&
+			equality.Semantic.DeepEqual(original.Status, dataCrunchCluster.Status) {
+			return
+		}
 		if err := patchHelper.Patch(ctx, dataCrunchCluster); err != nil {
 			log.Error(err, "failed to patch DataCrunchCluster")
 			if reterr == nil {
@@ -123,7 +204,26 @@ func (r *DataCrunchClusterReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 
 	// Handle non-deleted clusters
-	return r.reconcileNormal(ctx, log, cluster, dataCrunchCluster)
+	result, err := r.reconcileNormal(ctx, log, cluster, dataCrunchCluster)
+	if errors.Is(err, cloud.ErrUnexpectedResponse) {
+		r.markAPIIncompatible(log, dataCrunchCluster, err)
+		return reconcile.Result{RequeueAfter: apiIncompatibleRequeueAfter}, nil
+	}
+	if err == nil {
+		conditions.MarkTrue(dataCrunchCluster, infrav1beta1.APICompatibleCondition)
+	}
+	return result, err
+}
+
+// markAPIIncompatible reports that the DataCrunch API responded with a structure the provider doesn't
+// expect, so an operator can upgrade the provider. The machines of the cluster stop calling the API until
+// the cluster is reconciled successfully again.
+func (r *DataCrunchClusterReconciler) markAPIIncompatible(log logr.Logger, dataCrunchCluster *infrav1beta1.DataCrunchCluster, err error) {
+	if conditions.GetReason(dataCrunchCluster, infrav1beta1.APICompatibleCondition) != infrav1beta1.APIIncompatibleReason {
+		log.Error(err, "DataCrunch API responded with an unexpected structure, the provider may need to be upgraded")
+		r.Recorder.Eventf(dataCrunchCluster, corev1.EventTypeWarning, infrav1beta1.APIIncompatibleReason, "DataCrunch API responded with an unexpected structure, the provider may need to be upgraded: %v", err)
+	}
+	conditions.MarkFalse(dataCrunchCluster, infrav1beta1.APICompatibleCondition, infrav1beta1.APIIncompatibleReason, clusterv1.ConditionSeverityError, "%s", err.Error())
 }
 
 func (r *DataCrunchClusterReconciler) reconcileNormal(ctx context.Context, log logr.Logger, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) (reconcile.Result, error) {
@@ -136,7 +236,7 @@ func (r *DataCrunchClusterReconciler) reconcileNormal(ctx context.Context, log l
 	}
 
 	// Create DataCrunch client
-	dataCrunchClient, err := r.createDataCrunchClient(ctx, cluster)
+	dataCrunchClient, err := r.createDataCrunchClient(ctx, cluster, dataCrunchCluster)
 	if err != nil {
 		log.Error(err, "failed to create DataCrunch client")
 		conditions.MarkFalse(dataCrunchCluster, infrav1beta1.NetworkInfrastructureReadyCondition, infrav1beta1.DataCrunchClientFailedReason, clusterv1.ConditionSeverityError, err.Error())
@@ -150,6 +250,14 @@ func (r *DataCrunchClusterReconciler) reconcileNormal(ctx context.Context, log l
 		return reconcile.Result{RequeueAfter: 30 * time.Second}, err
 	}
 
+	// Machines can't be placed in the VPC before it is available
+	if vpc := dataCrunchCluster.Status.Network.VPC; vpc != nil && vpc.State != vpcStateAvailable {
+		log.Info("Waiting for VPC to become available", "vpcID", vpc.ID, "state", vpc.State)
+		dataCrunchCluster.Status.Ready = false
+		conditions.MarkFalse(dataCrunchCluster, infrav1beta1.NetworkInfrastructureReadyCondition, infrav1beta1.WaitingForVPCReason, clusterv1.ConditionSeverityInfo, "Waiting for VPC %s to become available, current state: %s", vpc.ID, vpc.State)
+		return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
 	// Reconcile load balancer if needed
 	if err := r.reconcileLoadBalancer(ctx, log, dataCrunchClient, cluster, dataCrunchCluster); err != nil {
 		log.Error(err, "failed to reconcile load balancer")
@@ -157,6 +265,31 @@ func (r *DataCrunchClusterReconciler) reconcileNormal(ctx context.Context, log l
 		return reconcile.Result{RequeueAfter: 30 * time.Second}, err
 	}
 
+	// The control plane endpoint is only known once the load balancer is active
+	if lb := dataCrunchCluster.Status.LoadBalancer; lb != nil && lb.State != loadBalancerStateActive {
+		log.Info("Waiting for load balancer to become active", "loadBalancerID", lb.ID, "state", lb.State)
+		dataCrunchCluster.Status.Ready = false
+		conditions.MarkFalse(dataCrunchCluster, infrav1beta1.LoadBalancerReadyCondition, infrav1beta1.WaitingForLoadBalancerReason, clusterv1.ConditionSeverityInfo, "Waiting for load balancer %s to become active, current state: %s", lb.ID, lb.State)
+		return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	// Account limits are informational, so failing to read them doesn't block the cluster
+	if err := r.reconcileAccountLimits(ctx, dataCrunchClient, dataCrunchCluster); err != nil {
+		log.Error(err, "failed to get account limits")
+	}
+
+	if r.PublishControlPlaneEndpoint {
+		if err := r.reconcileControlPlaneEndpointConfigMap(ctx, log, cluster, dataCrunchCluster); err != nil {
+			log.Error(err, "failed to publish control plane endpoint")
+			return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+		}
+	}
+
+	// The machine summary is informational only, so failures must not block reconciliation
+	if err := r.reconcileMachineSummary(ctx, cluster, dataCrunchCluster); err != nil {
+		log.Error(err, "failed to summarize DataCrunchMachines")
+	}
+
 	// Mark the cluster as ready
 	dataCrunchCluster.Status.Ready = true
 	conditions.MarkTrue(dataCrunchCluster, infrav1beta1.NetworkInfrastructureReadyCondition)
@@ -170,7 +303,7 @@ func (r *DataCrunchClusterReconciler) reconcileDelete(ctx context.Context, log l
 	log.Info("Reconciling DataCrunchCluster delete")
 
 	// Create DataCrunch client
-	dataCrunchClient, err := r.createDataCrunchClient(ctx, cluster)
+	dataCrunchClient, err := r.createDataCrunchClient(ctx, cluster, dataCrunchCluster)
 	if err != nil {
 		log.Error(err, "failed to create DataCrunch client during deletion")
 		// Continue with deletion even if we can't create the client
@@ -178,14 +311,36 @@ func (r *DataCrunchClusterReconciler) reconcileDelete(ctx context.Context, log l
 
 	// Delete load balancer if it exists
 	if dataCrunchClient != nil && dataCrunchCluster.Status.LoadBalancer != nil {
-		if err := dataCrunchClient.DeleteLoadBalancer(ctx, dataCrunchCluster.Status.LoadBalancer.ID); err != nil {
+		if err := dataCrunchClient.DeleteLoadBalancer(ctx, dataCrunchCluster.Status.LoadBalancer.ID); err != nil && !errors.Is(err, cloud.ErrNotFound) {
 			log.Error(err, "failed to delete load balancer")
-			// Don't return error, continue with cleanup
+			return reconcile.Result{RequeueAfter: 30 * time.Second}, err
 		}
+		dataCrunchCluster.Status.LoadBalancer = nil
 	}
 
-	// Clean up network resources would go here
-	// For now, we'll just remove the finalizer
+	// Stopped instances of MachineSets scaled to zero have no DataCrunchMachine left to delete them
+	if dataCrunchClient != nil {
+		if err := r.deleteStoppedInstances(ctx, log, dataCrunchClient, cluster, dataCrunchCluster); err != nil {
+			log.Error(err, "failed to delete stopped instances")
+			return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+		}
+	}
+
+	// Instances are normally deleted with their DataCrunchMachines, force cleanup catches the orphans
+	if dataCrunchClient != nil && dataCrunchCluster.Annotations[infrav1beta1.ForceCleanupAnnotation] == "true" {
+		if err := r.deleteClusterInstances(ctx, log, dataCrunchClient, cluster, dataCrunchCluster); err != nil {
+			log.Error(err, "failed to delete cluster instances")
+			return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+		}
+	}
+
+	// Subnets and VPCs created for the cluster go last, as its instances and load balancer use them
+	if dataCrunchClient != nil {
+		if err := r.deleteNetwork(ctx, log, dataCrunchClient, dataCrunchCluster); err != nil {
+			log.Error(err, "failed to delete network resources")
+			return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+		}
+	}
 
 	// Remove our finalizer from the list and update it
 	controllerutil.RemoveFinalizer(dataCrunchCluster, infrav1beta1.ClusterFinalizer)
@@ -194,23 +349,216 @@ func (r *DataCrunchClusterReconciler) reconcileDelete(ctx context.Context, log l
 	return reconcile.Result{}, nil
 }
 
-func (r *DataCrunchClusterReconciler) reconcileNetwork(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
-	// For now, we'll assume the network infrastructure is ready
-	// In a real implementation, you would create VPCs, subnets, security groups, etc.
+// deleteClusterInstances deletes all instances tagged with the name of the cluster.
+func (r *DataCrunchClusterReconciler) deleteClusterInstances(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	instances, err := dataCrunchClient.ListInstances(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
 
-	log.Info("Network infrastructure reconciliation completed")
+	var errs []error
+	for _, instance := range cloud.FilterInstancesByTags(instances, map[string]string{clusterv1.ClusterNameLabel: cluster.Name}) {
+		if err := dataCrunchClient.DeleteInstance(ctx, instance.ID); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to delete instance %s", instance.ID))
+			continue
+		}
+		log.Info("Deleted cluster instance during force cleanup", "instanceId", instance.ID)
+		r.Recorder.Eventf(dataCrunchCluster, corev1.EventTypeNormal, "InstanceDeleted", "Deleted DataCrunch instance %s during force cleanup", instance.ID)
+	}
+
+	return kerrors.NewAggregate(errs)
+}
+
+// deleteNetwork deletes the subnets and VPC created for the cluster. Adopted ones are left alone.
+// Deleted resources are dropped from the status so that a retry after a failure skips them.
+func (r *DataCrunchClusterReconciler) deleteNetwork(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	network := dataCrunchCluster.Status.Network
+	if network == nil {
+		return nil
+	}
+
+	for i, subnet := range network.Subnets {
+		if !subnet.Created || subnet.ID == "" {
+			continue
+		}
+		if err := dataCrunchClient.DeleteSubnet(ctx, subnet.ID); err != nil && !errors.Is(err, cloud.ErrNotFound) {
+			return errors.Wrapf(err, "failed to delete subnet %s", subnet.ID)
+		}
+		log.Info("Deleted subnet", "subnetID", subnet.ID)
+		r.Recorder.Eventf(dataCrunchCluster, corev1.EventTypeNormal, "SubnetDeleted", "Deleted subnet %s", subnet.ID)
+		network.Subnets[i] = infrav1beta1.DataCrunchSubnetStatus{}
+	}
+
+	if vpc := network.VPC; vpc != nil && vpc.Created && vpc.ID != "" {
+		if err := dataCrunchClient.DeleteVPC(ctx, vpc.ID); err != nil && !errors.Is(err, cloud.ErrNotFound) {
+			return errors.Wrapf(err, "failed to delete VPC %s", vpc.ID)
+		}
+		log.Info("Deleted VPC", "vpcID", vpc.ID)
+		r.Recorder.Eventf(dataCrunchCluster, corev1.EventTypeNormal, "VPCDeleted", "Deleted VPC %s", vpc.ID)
+		network.VPC = nil
+	}
+
+	return nil
+}
 
+func (r *DataCrunchClusterReconciler) reconcileNetwork(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
 	// Initialize network status if not exists
 	if dataCrunchCluster.Status.Network == nil {
 		dataCrunchCluster.Status.Network = &infrav1beta1.DataCrunchNetworkStatus{}
 	}
 
-	// Set default failure domains
-	if dataCrunchCluster.Status.FailureDomains == nil {
-		dataCrunchCluster.Status.FailureDomains = clusterv1.FailureDomains{
-			"default": clusterv1.FailureDomainSpec{
-				ControlPlane: true,
-			},
+	if dataCrunchCluster.Spec.Network != nil {
+		if err := r.reconcileVPC(ctx, log, dataCrunchClient, dataCrunchCluster); err != nil {
+			return err
+		}
+
+		// Subnets are created once the VPC is available, until then reconcileNormal waits for it
+		if vpc := dataCrunchCluster.Status.Network.VPC; vpc != nil && vpc.State != vpcStateAvailable {
+			return nil
+		}
+
+		if err := r.reconcileSubnets(ctx, log, dataCrunchClient, dataCrunchCluster); err != nil {
+			return err
+		}
+	}
+
+	log.Info("Network infrastructure reconciliation completed")
+
+	dataCrunchCluster.Status.FailureDomains = clusterFailureDomains(dataCrunchCluster)
+
+	return nil
+}
+
+// clusterFailureDomains returns a failure domain for every availability zone of the subnets of the cluster,
+// suitable for control plane machines if one of its subnets is public. Clusters without subnets in an
+// availability zone have the single default failure domain.
+func clusterFailureDomains(dataCrunchCluster *infrav1beta1.DataCrunchCluster) clusterv1.FailureDomains {
+	failureDomains := clusterv1.FailureDomains{}
+	if network := dataCrunchCluster.Spec.Network; network != nil {
+		for _, subnet := range network.Subnets {
+			if subnet.AvailabilityZone == "" {
+				continue
+			}
+			failureDomain := failureDomains[subnet.AvailabilityZone]
+			failureDomain.ControlPlane = failureDomain.ControlPlane || subnet.IsPublic
+			failureDomains[subnet.AvailabilityZone] = failureDomain
+		}
+	}
+
+	if len(failureDomains) == 0 {
+		return clusterv1.FailureDomains{
+			defaultFailureDomain: clusterv1.FailureDomainSpec{ControlPlane: true},
+		}
+	}
+	return failureDomains
+}
+
+// reconcileVPC adopts the VPC referenced by spec.network.vpc.id, or creates a
+// new one when no ID is given and none has been created yet.
+func (r *DataCrunchClusterReconciler) reconcileVPC(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	vpcSpec := dataCrunchCluster.Spec.Network.VPC
+	if vpcSpec == nil {
+		return nil
+	}
+
+	// A VPC created by an earlier reconcile is still owned by the cluster
+	vpcID := vpcSpec.ID
+	created := false
+	if vpcID == "" && dataCrunchCluster.Status.Network.VPC != nil {
+		vpcID = dataCrunchCluster.Status.Network.VPC.ID
+		created = dataCrunchCluster.Status.Network.VPC.Created
+	}
+
+	var vpc *cloud.VPC
+	var err error
+	if vpcID != "" {
+		// Never create when an ID is known; the VPC must already exist
+		vpc, err = dataCrunchClient.GetVPC(ctx, vpcID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get VPC %s", vpcID)
+		}
+	} else {
+		vpc, err = dataCrunchClient.CreateVPC(ctx, &cloud.VPCSpec{
+			Name:      dataCrunchCluster.Name,
+			CidrBlock: vpcSpec.CidrBlock,
+			Region:    dataCrunchCluster.Spec.Region,
+			Tags:      vpcSpec.Tags,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to create VPC")
+		}
+		log.Info("Created VPC", "vpcID", vpc.ID)
+		r.Recorder.Eventf(dataCrunchCluster, "Normal", "VPCCreated", "Created VPC %s", vpc.ID)
+		created = true
+	}
+
+	dataCrunchCluster.Status.Network.VPC = &infrav1beta1.DataCrunchVPCStatus{
+		ID:        vpc.ID,
+		CidrBlock: vpc.CidrBlock,
+		State:     vpc.State,
+		Created:   created,
+	}
+
+	return nil
+}
+
+// reconcileSubnets adopts subnets that reference an existing ID and creates
+// the remaining ones inside the cluster VPC.
+func (r *DataCrunchClusterReconciler) reconcileSubnets(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	subnetSpecs := dataCrunchCluster.Spec.Network.Subnets
+	if len(subnetSpecs) == 0 {
+		return nil
+	}
+
+	// Statuses are updated in place so that subnets created before a failure
+	// are recorded and not created again on the next reconcile
+	statuses := make([]infrav1beta1.DataCrunchSubnetStatus, len(subnetSpecs))
+	copy(statuses, dataCrunchCluster.Status.Network.Subnets)
+	dataCrunchCluster.Status.Network.Subnets = statuses
+
+	for i, subnetSpec := range subnetSpecs {
+		subnetID := subnetSpec.ID
+		created := false
+		if subnetID == "" {
+			subnetID = statuses[i].ID
+			created = statuses[i].Created
+		}
+
+		var subnet *cloud.Subnet
+		var err error
+		if subnetID != "" {
+			subnet, err = dataCrunchClient.GetSubnet(ctx, subnetID)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get subnet %s", subnetID)
+			}
+		} else {
+			vpcStatus := dataCrunchCluster.Status.Network.VPC
+			if vpcStatus == nil || vpcStatus.ID == "" {
+				return errors.Errorf("cannot create subnet %d without a VPC", i)
+			}
+
+			subnet, err = dataCrunchClient.CreateSubnet(ctx, &cloud.SubnetSpec{
+				Name:             fmt.Sprintf("%s-%d", dataCrunchCluster.Name, i),
+				VPCID:            vpcStatus.ID,
+				CidrBlock:        subnetSpec.CidrBlock,
+				AvailabilityZone: subnetSpec.AvailabilityZone,
+				IsPublic:         subnetSpec.IsPublic,
+				Tags:             subnetSpec.Tags,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to create subnet %d", i)
+			}
+			log.Info("Created subnet", "subnetID", subnet.ID)
+			r.Recorder.Eventf(dataCrunchCluster, "Normal", "SubnetCreated", "Created subnet %s", subnet.ID)
+			created = true
+		}
+
+		statuses[i] = infrav1beta1.DataCrunchSubnetStatus{
+			ID:               subnet.ID,
+			CidrBlock:        subnet.CidrBlock,
+			AvailabilityZone: subnet.AvailabilityZone,
+			State:            subnet.State,
+			Created:          created,
 		}
 	}
 
@@ -218,28 +566,216 @@ func (r *DataCrunchClusterReconciler) reconcileNetwork(ctx context.Context, log
 }
 
 func (r *DataCrunchClusterReconciler) reconcileLoadBalancer(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	// A user-provided host without a port would yield an unreachable API server
+	endpoint := &dataCrunchCluster.Spec.ControlPlaneEndpoint
+	if endpoint.Host != "" && endpoint.Port == 0 {
+		endpoint.Port = defaultControlPlaneEndpointPort
+		log.Info("Defaulted control plane endpoint port", "port", endpoint.Port)
+	}
+
+	// A load balancer created by an earlier reconcile is followed until it is active
+	if dataCrunchCluster.Status.LoadBalancer != nil {
+		return r.reconcileLoadBalancerEndpoint(ctx, log, dataCrunchClient, dataCrunchCluster)
+	}
+
+	lbSpec := dataCrunchCluster.Spec.ControlPlaneLoadBalancer
+	lbEnabled := lbSpec != nil && lbSpec.Enabled != nil && *lbSpec.Enabled
+
 	// Check if control plane endpoint is already set
-	if !dataCrunchCluster.Spec.ControlPlaneEndpoint.IsZero() {
-		log.Info("Control plane endpoint already set", "endpoint", dataCrunchCluster.Spec.ControlPlaneEndpoint)
+	if !endpoint.IsZero() {
+		if endpoint.Host == placeholderControlPlaneHost(cluster) {
+			log.Info("Control plane endpoint already set", "endpoint", *endpoint)
+			return nil
+		}
+
+		// A user-provided endpoint is authoritative, e.g. a static IP of a load balancer outside of DataCrunch,
+		// so no load balancer is created even if enabled
+		log.Info("Using external control plane endpoint", "endpoint", *endpoint, "loadBalancerSkipped", lbEnabled)
+		conditions.MarkTrue(dataCrunchCluster, infrav1beta1.ExternalControlPlaneEndpointCondition)
+		return nil
+	}
+
+	if !lbEnabled {
+		// Without a load balancer, set a placeholder endpoint
+		dataCrunchCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
+			Host: placeholderControlPlaneHost(cluster),
+			Port: defaultControlPlaneEndpointPort,
+		}
+
+		log.Info("Set control plane endpoint", "endpoint", dataCrunchCluster.Spec.ControlPlaneEndpoint)
+		return nil
+	}
+
+	lb, err := dataCrunchClient.CreateLoadBalancer(ctx, &cloud.LoadBalancerSpec{
+		Name:            cluster.Name + "-apiserver",
+		Type:            lbSpec.Type,
+		HealthCheckPath: lbSpec.HealthCheckPath,
+		Tags:            map[string]string{clusterv1.ClusterNameLabel: cluster.Name},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create control plane load balancer")
+	}
+
+	log.Info("Created control plane load balancer", "loadBalancerID", lb.ID)
+	r.Recorder.Eventf(dataCrunchCluster, corev1.EventTypeNormal, "LoadBalancerCreated", "Created control plane load balancer %s", lb.ID)
+	setLoadBalancerStatus(dataCrunchCluster, lb)
+
+	return r.reconcileLoadBalancerEndpoint(ctx, log, dataCrunchClient, dataCrunchCluster)
+}
+
+// placeholderControlPlaneHost is the control plane endpoint host set for clusters without a load balancer
+// or a user-provided endpoint.
+func placeholderControlPlaneHost(cluster *clusterv1.Cluster) string {
+	return "cluster-" + cluster.Name + ".datacrunch.local"
+}
+
+// reconcileLoadBalancerEndpoint refreshes the status of the control plane load balancer until it is active
+// and then points the control plane endpoint at its DNS name.
+func (r *DataCrunchClusterReconciler) reconcileLoadBalancerEndpoint(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	status := dataCrunchCluster.Status.LoadBalancer
+	if status.State != loadBalancerStateActive || status.DNSName == "" {
+		lb, err := dataCrunchClient.GetLoadBalancer(ctx, status.ID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get load balancer %s", status.ID)
+		}
+		setLoadBalancerStatus(dataCrunchCluster, lb)
+		status = dataCrunchCluster.Status.LoadBalancer
+	}
+
+	// reconcileNormal waits for the load balancer to become active
+	if status.State != loadBalancerStateActive || status.DNSName == "" {
+		return nil
+	}
+
+	if dataCrunchCluster.Spec.ControlPlaneEndpoint.IsZero() {
+		dataCrunchCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
+			Host: status.DNSName,
+			Port: defaultControlPlaneEndpointPort,
+		}
+		log.Info("Set control plane endpoint from load balancer", "endpoint", dataCrunchCluster.Spec.ControlPlaneEndpoint, "loadBalancerID", status.ID)
+	}
+
+	conditions.MarkTrue(dataCrunchCluster, infrav1beta1.LoadBalancerReadyCondition)
+	return nil
+}
+
+// setLoadBalancerStatus records the control plane load balancer in the cluster status.
+func setLoadBalancerStatus(dataCrunchCluster *infrav1beta1.DataCrunchCluster, lb *cloud.LoadBalancer) {
+	dataCrunchCluster.Status.LoadBalancer = &infrav1beta1.DataCrunchLoadBalancerStatus{
+		ID:      lb.ID,
+		DNSName: lb.DNSName,
+		State:   lb.State,
+	}
+}
+
+// reconcileAccountLimits records the limits of the DataCrunch account and their usage in the cluster status.
+func (r *DataCrunchClusterReconciler) reconcileAccountLimits(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	limits, err := dataCrunchClient.GetAccountLimits(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get account limits")
+	}
+
+	dataCrunchCluster.Status.AccountLimits = &infrav1beta1.DataCrunchAccountLimitsStatus{
+		MaxInstances:  int32(limits.MaxInstances),
+		MaxGPUs:       int32(limits.MaxGPUs),
+		UsedInstances: int32(limits.UsedInstances),
+		UsedGPUs:      int32(limits.UsedGPUs),
+	}
+	return nil
+}
+
+// reconcileMachineSummary counts the DataCrunchMachines belonging to the cluster and how many of them are ready.
+func (r *DataCrunchClusterReconciler) reconcileMachineSummary(ctx context.Context, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	machines := &infrav1beta1.DataCrunchMachineList{}
+	if err := r.List(ctx, machines, client.InNamespace(dataCrunchCluster.Namespace), client.MatchingLabels{clusterv1.ClusterNameLabel: cluster.Name}); err != nil {
+		return errors.Wrap(err, "failed to list DataCrunchMachines")
+	}
+
+	var ready int32
+	for i := range machines.Items {
+		if machines.Items[i].Status.Ready {
+			ready++
+		}
+	}
+
+	dataCrunchCluster.Status.TotalMachines = int32(len(machines.Items))
+	dataCrunchCluster.Status.ReadyMachines = ready
+
+	return nil
+}
+
+// dataCrunchMachineToDataCrunchCluster maps a DataCrunchMachine to the DataCrunchCluster of its owning Cluster.
+func (r *DataCrunchClusterReconciler) dataCrunchMachineToDataCrunchCluster(ctx context.Context, o client.Object) []reconcile.Request {
+	clusterName, ok := o.GetLabels()[clusterv1.ClusterNameLabel]
+	if !ok {
 		return nil
 	}
 
-	// For simplicity, we'll set a placeholder endpoint
-	// In a real implementation, you would create a load balancer and get its endpoint
-	dataCrunchCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
-		Host: "cluster-" + cluster.Name + ".datacrunch.local",
-		Port: 6443,
+	cluster := &clusterv1.Cluster{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: o.GetNamespace(), Name: clusterName}, cluster); err != nil {
+		return nil
+	}
+
+	infraRef := cluster.Spec.InfrastructureRef
+	if infraRef == nil || infraRef.Kind != "DataCrunchCluster" {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{
+			NamespacedName: client.ObjectKey{
+				Namespace: cluster.Namespace,
+				Name:      infraRef.Name,
+			},
+		},
+	}
+}
+
+// reconcileControlPlaneEndpointConfigMap creates or updates the ConfigMap publishing the control plane
+// endpoint of the cluster. Nothing is published until the endpoint is known.
+func (r *DataCrunchClusterReconciler) reconcileControlPlaneEndpointConfigMap(ctx context.Context, log logr.Logger, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	endpoint := dataCrunchCluster.Spec.ControlPlaneEndpoint
+	if endpoint.IsZero() {
+		return nil
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cluster.Name + controlPlaneEndpointConfigMapSuffix,
+			Namespace: dataCrunchCluster.Namespace,
+		},
+	}
+
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+		if configMap.Labels == nil {
+			configMap.Labels = make(map[string]string)
+		}
+		configMap.Labels[clusterv1.ClusterNameLabel] = cluster.Name
+		configMap.Data = map[string]string{
+			"host":     endpoint.Host,
+			"port":     strconv.Itoa(int(endpoint.Port)),
+			"endpoint": endpoint.String(),
+		}
+		return controllerutil.SetOwnerReference(dataCrunchCluster, configMap, r.Scheme)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to create or update ConfigMap %s", configMap.Name)
 	}
 
-	log.Info("Set control plane endpoint", "endpoint", dataCrunchCluster.Spec.ControlPlaneEndpoint)
+	if result != controllerutil.OperationResultNone {
+		log.Info("Published control plane endpoint", "configMap", configMap.Name, "endpoint", endpoint.String(), "operation", result)
+	}
 	return nil
 }
 
-func (r *DataCrunchClusterReconciler) createDataCrunchClient(ctx context.Context, cluster *clusterv1.Cluster) (cloud.Client, error) {
+func (r *DataCrunchClusterReconciler) createDataCrunchClient(ctx context.Context, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) (cloud.Client, error) {
 	// Get credentials from environment variables (for testing) or secrets (for production)
 	clientID := os.Getenv("DATACRUNCH_CLIENT_ID")
 	clientSecret := os.Getenv("DATACRUNCH_CLIENT_SECRET")
-	apiURL := os.Getenv("DATACRUNCH_API_URL")
+	apiURL := dataCrunchAPIURL(dataCrunchCluster)
+	if r.Credentials != nil {
+		clientID, clientSecret = r.Credentials.Get()
+	}
 
 	if clientID == "" {
 		clientID = "your-datacrunch-client-id" // fallback for development
@@ -248,11 +784,33 @@ func (r *DataCrunchClusterReconciler) createDataCrunchClient(ctx context.Context
 		clientSecret = "your-datacrunch-client-secret" // fallback for development
 	}
 
-	if apiURL != "" {
-		return datacrunch.NewClientWithURL(clientID, clientSecret, apiURL), nil
+	if r.ClientCache != nil {
+		return r.ClientCache.Get(clientID, clientSecret, apiURL, r.newDataCrunchClient), nil
+	}
+	return r.newDataCrunchClient(clientID, clientSecret, apiURL), nil
+}
+
+// dataCrunchAPIURL returns the base URL of the DataCrunch API of a cluster, which is the APIEndpoint of its
+// DataCrunchCluster if set and DATACRUNCH_API_URL otherwise. Empty means the public API.
+func dataCrunchAPIURL(dataCrunchCluster *infrav1beta1.DataCrunchCluster) string {
+	if dataCrunchCluster != nil && dataCrunchCluster.Spec.APIEndpoint != "" {
+		return dataCrunchCluster.Spec.APIEndpoint
 	}
+	return os.Getenv("DATACRUNCH_API_URL")
+}
 
-	return datacrunch.NewClient(clientID, clientSecret), nil
+// newDataCrunchClient creates a DataCrunch client sharing the rate limiter, health tracker and concurrency
+// of the reconciler.
+func (r *DataCrunchClusterReconciler) newDataCrunchClient(clientID, clientSecret, apiURL string) *datacrunch.Client {
+	dataCrunchClient := datacrunch.NewClientWithOptions(clientID, clientSecret, datacrunch.ClientOptions{
+		BaseURL:    apiURL,
+		MaxRetries: datacrunch.DefaultMaxRetries,
+	})
+	dataCrunchClient.SetRateLimiter(r.APIRateLimiter)
+	dataCrunchClient.SetHealthTracker(r.APIHealth)
+	dataCrunchClient.SetAdaptiveConcurrency(r.Concurrency)
+
+	return dataCrunchClient
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -261,6 +819,10 @@ func (r *DataCrunchClusterReconciler) SetupWithManager(ctx context.Context, mgr
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&infrav1beta1.DataCrunchCluster{}).
+		Watches(
+			&infrav1beta1.DataCrunchMachine{},
+			handler.EnqueueRequestsFromMapFunc(r.dataCrunchMachineToDataCrunchCluster),
+		).
 		WithOptions(options).
 		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(log, r.WatchFilterValue)).
 		Complete(r)
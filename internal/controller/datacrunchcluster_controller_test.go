@@ -18,25 +18,33 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"reflect"
 	"testing"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/go-logr/logr"
 	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud/datacrunch"
 )
 
 func TestDataCrunchClusterReconciler_Reconcile(t *testing.T) {
-	scheme := runtime.NewScheme()
-	_ = clusterv1.AddToScheme(scheme)
-	_ = infrav1beta1.AddToScheme(scheme)
+	scheme := newTestScheme()
 
 	tests := []struct {
 		name        string
@@ -141,9 +149,7 @@ func TestDataCrunchClusterReconciler_Reconcile(t *testing.T) {
 }
 
 func TestDataCrunchClusterReconciler_reconcileNormal(t *testing.T) {
-	scheme := runtime.NewScheme()
-	_ = infrav1beta1.AddToScheme(scheme)
-	_ = clusterv1.AddToScheme(scheme)
+	scheme := newTestScheme()
 
 	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
 		ObjectMeta: metav1.ObjectMeta{
@@ -181,9 +187,7 @@ func TestDataCrunchClusterReconciler_reconcileNormal(t *testing.T) {
 }
 
 func TestDataCrunchClusterReconciler_reconcileDelete(t *testing.T) {
-	scheme := runtime.NewScheme()
-	_ = infrav1beta1.AddToScheme(scheme)
-	_ = clusterv1.AddToScheme(scheme)
+	scheme := newTestScheme()
 
 	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
 		ObjectMeta: metav1.ObjectMeta{
@@ -222,6 +226,166 @@ func TestDataCrunchClusterReconciler_reconcileDelete(t *testing.T) {
 	}
 }
 
+func TestDataCrunchClusterReconciler_reconcileDelete_ForceCleanup(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantDeleted []string
+	}{
+		{
+			name:        "force cleanup deletes all cluster instances",
+			annotations: map[string]string{infrav1beta1.ForceCleanupAnnotation: "true"},
+			wantDeleted: []string{"instance-1", "instance-2"},
+		},
+		{
+			name: "instances are left to the machines without force cleanup",
+		},
+		{
+			name:        "force cleanup disabled",
+			annotations: map[string]string{infrav1beta1.ForceCleanupAnnotation: "false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"GET /instances": respondJSON(`{"instances":[
+					{"id":"instance-1","status":"running","tags":{"cluster.x-k8s.io/cluster-name":"test-cluster"}},
+					{"id":"instance-2","status":"offline","tags":{"cluster.x-k8s.io/cluster-name":"test-cluster","owner":"team-ml"}},
+					{"id":"instance-3","status":"running","tags":{"cluster.x-k8s.io/cluster-name":"other-cluster"}},
+					{"id":"instance-4","status":"running"}
+				]}`),
+				"DELETE /instances/{id}": func(w http.ResponseWriter, r *http.Request) {
+					deleted = append(deleted, r.PathValue("id"))
+					w.WriteHeader(http.StatusNoContent)
+				},
+			})
+
+			dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-datacrunch-cluster",
+					Namespace:   "default",
+					Annotations: tt.annotations,
+					Finalizers:  []string{infrav1beta1.ClusterFinalizer},
+				},
+			}
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+			}
+
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &DataCrunchClusterReconciler{Recorder: recorder}
+
+			if _, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), cluster, dataCrunchCluster); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("Expected deleted instances %v, got %v", tt.wantDeleted, deleted)
+			}
+			if len(tt.wantDeleted) > 0 && !hasEvent(recorder, "InstanceDeleted") {
+				t.Error("Expected InstanceDeleted event")
+			}
+			if controllerutil.ContainsFinalizer(dataCrunchCluster, infrav1beta1.ClusterFinalizer) {
+				t.Error("Expected the finalizer to be removed")
+			}
+		})
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileDelete_ForceCleanupFailureKeepsFinalizer(t *testing.T) {
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"GET /instances": respondJSON(`{"instances":[{"id":"instance-1","tags":{"cluster.x-k8s.io/cluster-name":"test-cluster"}}]}`),
+		"/":              respondStatus(http.StatusInternalServerError),
+	})
+
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-datacrunch-cluster",
+			Namespace:   "default",
+			Annotations: map[string]string{infrav1beta1.ForceCleanupAnnotation: "true"},
+			Finalizers:  []string{infrav1beta1.ClusterFinalizer},
+		},
+	}
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+	}
+
+	reconciler := &DataCrunchClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+
+	if _, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), cluster, dataCrunchCluster); err == nil {
+		t.Fatal("Expected an error when an instance can't be deleted")
+	}
+	if !controllerutil.ContainsFinalizer(dataCrunchCluster, infrav1beta1.ClusterFinalizer) {
+		t.Error("Expected the finalizer to be kept until all instances are deleted")
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileDelete_LoadBalancer(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantErr       bool
+		wantFinalizer bool
+	}{
+		{
+			name:   "load balancer deleted",
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "load balancer already gone",
+			status: http.StatusNotFound,
+		},
+		{
+			name:          "load balancer deletion fails",
+			status:        http.StatusInternalServerError,
+			wantErr:       true,
+			wantFinalizer: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"GET /instances": respondJSON(`{"instances":[]}`),
+				"DELETE /load-balancers/{id}": func(w http.ResponseWriter, r *http.Request) {
+					deleted = append(deleted, r.PathValue("id"))
+					w.WriteHeader(tt.status)
+				},
+			})
+
+			dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test-datacrunch-cluster",
+					Namespace:  "default",
+					Finalizers: []string{infrav1beta1.ClusterFinalizer},
+				},
+				Status: infrav1beta1.DataCrunchClusterStatus{
+					LoadBalancer: &infrav1beta1.DataCrunchLoadBalancerStatus{ID: "lb-123", State: "active"},
+				},
+			}
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+			}
+
+			reconciler := &DataCrunchClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+			_, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), cluster, dataCrunchCluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(deleted, []string{"lb-123"}) {
+				t.Errorf("Expected load balancer lb-123 to be deleted, got %v", deleted)
+			}
+			if finalizer := controllerutil.ContainsFinalizer(dataCrunchCluster, infrav1beta1.ClusterFinalizer); finalizer != tt.wantFinalizer {
+				t.Errorf("Expected finalizer=%v, got %v", tt.wantFinalizer, finalizer)
+			}
+		})
+	}
+}
+
 func TestDataCrunchClusterReconciler_reconcileNetwork(t *testing.T) {
 	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
 		Spec: infrav1beta1.DataCrunchClusterSpec{
@@ -229,59 +393,816 @@ func TestDataCrunchClusterReconciler_reconcileNetwork(t *testing.T) {
 		},
 	}
 
-	reconciler := &DataCrunchClusterReconciler{}
-	log := logr.Discard()
-
-	err := reconciler.reconcileNetwork(context.Background(), log, nil, dataCrunchCluster)
-	if err != nil {
-		t.Errorf("reconcileNetwork should not return error: %v", err)
+	reconciler := &DataCrunchClusterReconciler{}
+	log := logr.Discard()
+
+	err := reconciler.reconcileNetwork(context.Background(), log, nil, dataCrunchCluster)
+	if err != nil {
+		t.Errorf("reconcileNetwork should not return error: %v", err)
+	}
+
+	// Verify network status is set
+	if dataCrunchCluster.Status.Network == nil {
+		t.Error("Expected network status to be set")
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileNetwork_AdoptOrCreate(t *testing.T) {
+	tests := []struct {
+		name               string
+		network            *infrav1beta1.DataCrunchNetworkSpec
+		wantErr            bool
+		wantVPCID          string
+		wantSubnetIDs      []string
+		wantCreatedVPCs    int
+		wantCreatedSubnets int
+	}{
+		{
+			name: "adopt existing VPC and subnet",
+			network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC:     &infrav1beta1.DataCrunchVPCSpec{ID: "vpc-existing"},
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{{ID: "subnet-existing"}},
+			},
+			wantVPCID:     "vpc-existing",
+			wantSubnetIDs: []string{"subnet-existing"},
+		},
+		{
+			name: "create new VPC and subnet",
+			network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC:     &infrav1beta1.DataCrunchVPCSpec{CidrBlock: "10.0.0.0/16"},
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{{CidrBlock: "10.0.1.0/24"}},
+			},
+			wantVPCID:          "vpc-new",
+			wantSubnetIDs:      []string{"subnet-new"},
+			wantCreatedVPCs:    1,
+			wantCreatedSubnets: 1,
+		},
+		{
+			name: "create subnet in adopted VPC",
+			network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC: &infrav1beta1.DataCrunchVPCSpec{ID: "vpc-existing"},
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{
+					{ID: "subnet-existing"},
+					{CidrBlock: "10.0.2.0/24"},
+				},
+			},
+			wantVPCID:          "vpc-existing",
+			wantSubnetIDs:      []string{"subnet-existing", "subnet-new"},
+			wantCreatedSubnets: 1,
+		},
+		{
+			name: "adopted VPC does not exist",
+			network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC: &infrav1beta1.DataCrunchVPCSpec{ID: "vpc-missing"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "adopted subnet does not exist",
+			network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC:     &infrav1beta1.DataCrunchVPCSpec{ID: "vpc-existing"},
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{{ID: "subnet-missing"}},
+			},
+			wantErr:   true,
+			wantVPCID: "vpc-existing",
+		},
+		{
+			name: "new subnet without VPC",
+			network: &infrav1beta1.DataCrunchNetworkSpec{
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{{CidrBlock: "10.0.1.0/24"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloudClient := &fakeCloudClient{
+				vpcs: map[string]*cloud.VPC{
+					"vpc-existing": {ID: "vpc-existing", CidrBlock: "10.0.0.0/16", State: "available"},
+				},
+				subnets: map[string]*cloud.Subnet{
+					"subnet-existing": {ID: "subnet-existing", VPCID: "vpc-existing", CidrBlock: "10.0.1.0/24", State: "available"},
+				},
+			}
+			dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+				Spec: infrav1beta1.DataCrunchClusterSpec{
+					Region:  "FIN-01",
+					Network: tt.network,
+				},
+			}
+
+			reconciler := &DataCrunchClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+			err := reconciler.reconcileNetwork(context.Background(), logr.Discard(), cloudClient, dataCrunchCluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileNetwork() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(cloudClient.createdVPCs) != tt.wantCreatedVPCs {
+				t.Errorf("Expected %d VPCs to be created, got %d", tt.wantCreatedVPCs, len(cloudClient.createdVPCs))
+			}
+			if len(cloudClient.createdSubnets) != tt.wantCreatedSubnets {
+				t.Errorf("Expected %d subnets to be created, got %d", tt.wantCreatedSubnets, len(cloudClient.createdSubnets))
+			}
+
+			network := dataCrunchCluster.Status.Network
+			if tt.wantVPCID != "" && (network.VPC == nil || network.VPC.ID != tt.wantVPCID) {
+				t.Errorf("Expected VPC status ID %q, got %+v", tt.wantVPCID, network.VPC)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(network.Subnets) != len(tt.wantSubnetIDs) {
+				t.Fatalf("Expected %d subnet statuses, got %d", len(tt.wantSubnetIDs), len(network.Subnets))
+			}
+			if network.VPC.Created != (tt.wantCreatedVPCs > 0) {
+				t.Errorf("Expected VPC created=%v, got %v", tt.wantCreatedVPCs > 0, network.VPC.Created)
+			}
+			for i, id := range tt.wantSubnetIDs {
+				if network.Subnets[i].ID != id {
+					t.Errorf("Expected subnet %d ID %q, got %q", i, id, network.Subnets[i].ID)
+				}
+				if created := id == "subnet-new"; network.Subnets[i].Created != created {
+					t.Errorf("Expected subnet %d created=%v, got %v", i, created, network.Subnets[i].Created)
+				}
+			}
+		})
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileNetwork_KeepsCreated(t *testing.T) {
+	cloudClient := &fakeCloudClient{
+		vpcs:    map[string]*cloud.VPC{"vpc-new": {ID: "vpc-new", State: "available"}},
+		subnets: map[string]*cloud.Subnet{"subnet-new": {ID: "subnet-new", State: "available"}},
+	}
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+		Spec: infrav1beta1.DataCrunchClusterSpec{
+			Network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC:     &infrav1beta1.DataCrunchVPCSpec{CidrBlock: "10.0.0.0/16"},
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{{CidrBlock: "10.0.1.0/24"}},
+			},
+		},
+		Status: infrav1beta1.DataCrunchClusterStatus{
+			Network: &infrav1beta1.DataCrunchNetworkStatus{
+				VPC:     &infrav1beta1.DataCrunchVPCStatus{ID: "vpc-new", Created: true},
+				Subnets: []infrav1beta1.DataCrunchSubnetStatus{{ID: "subnet-new", Created: true}},
+			},
+		},
+	}
+
+	reconciler := &DataCrunchClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+	if err := reconciler.reconcileNetwork(context.Background(), logr.Discard(), cloudClient, dataCrunchCluster); err != nil {
+		t.Fatalf("reconcileNetwork() error = %v", err)
+	}
+
+	if len(cloudClient.createdVPCs) != 0 || len(cloudClient.createdSubnets) != 0 {
+		t.Errorf("Expected nothing to be created again, got VPCs %v and subnets %v", cloudClient.createdVPCs, cloudClient.createdSubnets)
+	}
+	network := dataCrunchCluster.Status.Network
+	if !network.VPC.Created || !network.Subnets[0].Created {
+		t.Errorf("Expected the VPC and subnet to stay recorded as created, got %+v and %+v", network.VPC, network.Subnets[0])
+	}
+}
+
+func TestDataCrunchClusterReconciler_deleteNetwork(t *testing.T) {
+	tests := []struct {
+		name               string
+		network            *infrav1beta1.DataCrunchNetworkStatus
+		deleteSubnetErr    error
+		wantErr            bool
+		wantDeletedVPCs    []string
+		wantDeletedSubnets []string
+	}{
+		{
+			name: "no network",
+		},
+		{
+			name: "created VPC and subnet",
+			network: &infrav1beta1.DataCrunchNetworkStatus{
+				VPC:     &infrav1beta1.DataCrunchVPCStatus{ID: "vpc-new", Created: true},
+				Subnets: []infrav1beta1.DataCrunchSubnetStatus{{ID: "subnet-new", Created: true}},
+			},
+			wantDeletedVPCs:    []string{"vpc-new"},
+			wantDeletedSubnets: []string{"subnet-new"},
+		},
+		{
+			name: "adopted VPC and subnets are kept",
+			network: &infrav1beta1.DataCrunchNetworkStatus{
+				VPC: &infrav1beta1.DataCrunchVPCStatus{ID: "vpc-existing"},
+				Subnets: []infrav1beta1.DataCrunchSubnetStatus{
+					{ID: "subnet-existing"},
+					{ID: "subnet-new", Created: true},
+				},
+			},
+			wantDeletedSubnets: []string{"subnet-new"},
+		},
+		{
+			name: "VPC is kept when a subnet can't be deleted",
+			network: &infrav1beta1.DataCrunchNetworkStatus{
+				VPC:     &infrav1beta1.DataCrunchVPCStatus{ID: "vpc-new", Created: true},
+				Subnets: []infrav1beta1.DataCrunchSubnetStatus{{ID: "subnet-new", Created: true}},
+			},
+			deleteSubnetErr: errors.New("subnet in use"),
+			wantErr:         true,
+		},
+		{
+			name: "already deleted subnet",
+			network: &infrav1beta1.DataCrunchNetworkStatus{
+				Subnets: []infrav1beta1.DataCrunchSubnetStatus{{ID: "subnet-new", Created: true}},
+			},
+			deleteSubnetErr: cloud.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloudClient := &fakeCloudClient{deleteSubnetErr: tt.deleteSubnetErr}
+			dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+				Status: infrav1beta1.DataCrunchClusterStatus{Network: tt.network},
+			}
+
+			reconciler := &DataCrunchClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+			err := reconciler.deleteNetwork(context.Background(), logr.Discard(), cloudClient, dataCrunchCluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deleteNetwork() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(cloudClient.deletedVPCs, tt.wantDeletedVPCs) {
+				t.Errorf("Expected deleted VPCs %v, got %v", tt.wantDeletedVPCs, cloudClient.deletedVPCs)
+			}
+			if tt.deleteSubnetErr == nil && !reflect.DeepEqual(cloudClient.deletedSubnets, tt.wantDeletedSubnets) {
+				t.Errorf("Expected deleted subnets %v, got %v", tt.wantDeletedSubnets, cloudClient.deletedSubnets)
+			}
+		})
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileNetwork_FailureDomains(t *testing.T) {
+	tests := []struct {
+		name    string
+		network *infrav1beta1.DataCrunchNetworkSpec
+		want    clusterv1.FailureDomains
+	}{
+		{
+			name: "no network",
+			want: clusterv1.FailureDomains{defaultFailureDomain: clusterv1.FailureDomainSpec{ControlPlane: true}},
+		},
+		{
+			name: "subnets without availability zones",
+			network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC:     &infrav1beta1.DataCrunchVPCSpec{ID: "vpc-existing"},
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{{CidrBlock: "10.0.1.0/24", IsPublic: true}},
+			},
+			want: clusterv1.FailureDomains{defaultFailureDomain: clusterv1.FailureDomainSpec{ControlPlane: true}},
+		},
+		{
+			name: "subnets across availability zones",
+			network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC: &infrav1beta1.DataCrunchVPCSpec{ID: "vpc-existing"},
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{
+					{CidrBlock: "10.0.1.0/24", AvailabilityZone: "FIN-01a", IsPublic: true},
+					{CidrBlock: "10.0.2.0/24", AvailabilityZone: "FIN-01b", IsPublic: true},
+					{CidrBlock: "10.0.3.0/24", AvailabilityZone: "FIN-01b"},
+					{CidrBlock: "10.0.4.0/24", AvailabilityZone: "FIN-01c"},
+					{CidrBlock: "10.0.5.0/24"},
+				},
+			},
+			want: clusterv1.FailureDomains{
+				"FIN-01a": clusterv1.FailureDomainSpec{ControlPlane: true},
+				"FIN-01b": clusterv1.FailureDomainSpec{ControlPlane: true},
+				"FIN-01c": clusterv1.FailureDomainSpec{ControlPlane: false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloudClient := &fakeCloudClient{
+				vpcs: map[string]*cloud.VPC{
+					"vpc-existing": {ID: "vpc-existing", CidrBlock: "10.0.0.0/16", State: "available"},
+				},
+			}
+			dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+				Spec: infrav1beta1.DataCrunchClusterSpec{
+					Region:  "FIN-01",
+					Network: tt.network,
+				},
+				// Failure domains follow the subnets rather than being kept once set
+				Status: infrav1beta1.DataCrunchClusterStatus{
+					FailureDomains: clusterv1.FailureDomains{defaultFailureDomain: clusterv1.FailureDomainSpec{ControlPlane: true}},
+				},
+			}
+
+			reconciler := &DataCrunchClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+			if err := reconciler.reconcileNetwork(context.Background(), logr.Discard(), cloudClient, dataCrunchCluster); err != nil {
+				t.Fatalf("reconcileNetwork() error = %v", err)
+			}
+
+			if got := dataCrunchCluster.Status.FailureDomains; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected failure domains %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileNetwork_ReusesCreatedResources(t *testing.T) {
+	cloudClient := &fakeCloudClient{
+		vpcs:    map[string]*cloud.VPC{},
+		subnets: map[string]*cloud.Subnet{},
+	}
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+		Spec: infrav1beta1.DataCrunchClusterSpec{
+			Network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC:     &infrav1beta1.DataCrunchVPCSpec{CidrBlock: "10.0.0.0/16"},
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{{CidrBlock: "10.0.1.0/24"}},
+			},
+		},
+	}
+
+	reconciler := &DataCrunchClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+	if err := reconciler.reconcileNetwork(context.Background(), logr.Discard(), cloudClient, dataCrunchCluster); err != nil {
+		t.Fatalf("first reconcileNetwork() error = %v", err)
+	}
+
+	// Make the created resources visible to subsequent lookups
+	cloudClient.vpcs["vpc-new"] = &cloud.VPC{ID: "vpc-new", CidrBlock: "10.0.0.0/16", State: "available"}
+	cloudClient.subnets["subnet-new"] = &cloud.Subnet{ID: "subnet-new", CidrBlock: "10.0.1.0/24"}
+
+	if err := reconciler.reconcileNetwork(context.Background(), logr.Discard(), cloudClient, dataCrunchCluster); err != nil {
+		t.Fatalf("second reconcileNetwork() error = %v", err)
+	}
+
+	if len(cloudClient.createdVPCs) != 1 || len(cloudClient.createdSubnets) != 1 {
+		t.Errorf("Expected resources to be created once, got %d VPCs and %d subnets", len(cloudClient.createdVPCs), len(cloudClient.createdSubnets))
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileNormal_WaitsForVPC(t *testing.T) {
+	vpcState := "pending"
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/vpcs/vpc-123": func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"id":"vpc-123","cidr_block":"10.0.0.0/16","status":"` + vpcState + `"}`))
+		},
+		"/subnets/subnet-123": respondJSON(`{"id":"subnet-123","vpc_id":"vpc-123","cidr_block":"10.0.1.0/24","status":"available"}`),
+	})
+
+	scheme := newTestScheme()
+
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-cluster",
+			Namespace:  "default",
+			Finalizers: []string{infrav1beta1.ClusterFinalizer},
+		},
+		Spec: infrav1beta1.DataCrunchClusterSpec{
+			Region: "FIN-01",
+			Network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC:     &infrav1beta1.DataCrunchVPCSpec{ID: "vpc-123"},
+				Subnets: []infrav1beta1.DataCrunchSubnetSpec{{ID: "subnet-123"}},
+			},
+		},
+	}
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+	}
+
+	reconciler := &DataCrunchClusterReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(dataCrunchCluster, cluster).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	result, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), cluster, dataCrunchCluster)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if result.RequeueAfter == 0 {
+		t.Error("Expected a requeue while the VPC is pending")
+	}
+	if dataCrunchCluster.Status.Ready {
+		t.Error("Expected the cluster not to be ready while the VPC is pending")
+	}
+	if got := dataCrunchCluster.Status.Network.VPC.State; got != "pending" {
+		t.Errorf("Expected VPC state pending, got %q", got)
+	}
+	if conditions.GetReason(dataCrunchCluster, infrav1beta1.NetworkInfrastructureReadyCondition) != infrav1beta1.WaitingForVPCReason {
+		t.Errorf("Expected NetworkInfrastructureReady reason %s, got %q", infrav1beta1.WaitingForVPCReason, conditions.GetReason(dataCrunchCluster, infrav1beta1.NetworkInfrastructureReadyCondition))
+	}
+	if len(dataCrunchCluster.Status.Network.Subnets) != 0 {
+		t.Errorf("Expected subnets to wait for the VPC, got %+v", dataCrunchCluster.Status.Network.Subnets)
+	}
+
+	vpcState = "available"
+	if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), cluster, dataCrunchCluster); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !dataCrunchCluster.Status.Ready {
+		t.Error("Expected the cluster to be ready once the VPC is available")
+	}
+	if got := dataCrunchCluster.Status.Network.VPC.State; got != "available" {
+		t.Errorf("Expected VPC state available, got %q", got)
+	}
+	if !conditions.IsTrue(dataCrunchCluster, infrav1beta1.NetworkInfrastructureReadyCondition) {
+		t.Error("Expected NetworkInfrastructureReady condition to be true")
+	}
+	if len(dataCrunchCluster.Status.Network.Subnets) != 1 {
+		t.Errorf("Expected the subnet to be reconciled, got %+v", dataCrunchCluster.Status.Network.Subnets)
+	}
+}
+
+func TestDataCrunchClusterReconciler_Reconcile_APIIncompatible(t *testing.T) {
+	mismatched := true
+	vpcRequests := 0
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/vpcs/vpc-123": func(w http.ResponseWriter, _ *http.Request) {
+			vpcRequests++
+			if mismatched {
+				// A newer API version turning the CIDR block into an object
+				_, _ = w.Write([]byte(`{"id":"vpc-123","cidr_block":{"ipv4":"10.0.0.0/16"},"status":"available"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"vpc-123","cidr_block":"10.0.0.0/16","status":"available"}`))
+		},
+	})
+
+	scheme := newTestScheme()
+
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-cluster",
+			Namespace:  "default",
+			Finalizers: []string{infrav1beta1.ClusterFinalizer},
+		},
+		Spec: infrav1beta1.DataCrunchClusterSpec{
+			Region: "FIN-01",
+			Network: &infrav1beta1.DataCrunchNetworkSpec{
+				VPC: &infrav1beta1.DataCrunchVPCSpec{ID: "vpc-123"},
+			},
+		},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dataCrunchCluster).WithStatusSubresource(dataCrunchCluster).Build()
+	reconciler := &DataCrunchClusterReconciler{
+		Client:   k8sClient,
+		Scheme:   scheme,
+		Log:      logr.Discard(),
+		Recorder: recorder,
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-cluster", Namespace: "default"}}
+
+	for i := 0; i < 2; i++ {
+		result, err := reconciler.Reconcile(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Expected no error so the cluster isn't retried with a backoff, got: %v", err)
+		}
+		if result.RequeueAfter != apiIncompatibleRequeueAfter {
+			t.Errorf("Expected a requeue after %s, got %s", apiIncompatibleRequeueAfter, result.RequeueAfter)
+		}
+	}
+
+	got := &infrav1beta1.DataCrunchCluster{}
+	if err := k8sClient.Get(context.Background(), req.NamespacedName, got); err != nil {
+		t.Fatalf("Failed to get DataCrunchCluster: %v", err)
+	}
+	if !conditions.IsFalse(got, infrav1beta1.APICompatibleCondition) || conditions.GetReason(got, infrav1beta1.APICompatibleCondition) != infrav1beta1.APIIncompatibleReason {
+		t.Errorf("Expected APICompatible condition false with reason %s, got %+v", infrav1beta1.APIIncompatibleReason, conditions.Get(got, infrav1beta1.APICompatibleCondition))
+	}
+	if n := len(recorder.Events); n != 1 {
+		t.Errorf("Expected a single APIIncompatible event, got %d events", n)
+	}
+	if !hasEvent(recorder, infrav1beta1.APIIncompatibleReason) {
+		t.Error("Expected an APIIncompatible event")
+	}
+
+	// Once the provider understands the API again, the cluster recovers
+	mismatched = false
+	if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if err := k8sClient.Get(context.Background(), req.NamespacedName, got); err != nil {
+		t.Fatalf("Failed to get DataCrunchCluster: %v", err)
+	}
+	if !conditions.IsTrue(got, infrav1beta1.APICompatibleCondition) {
+		t.Errorf("Expected APICompatible condition true, got %+v", conditions.Get(got, infrav1beta1.APICompatibleCondition))
+	}
+	if vpcRequests != 3 {
+		t.Errorf("Expected one VPC request per reconcile, got %d", vpcRequests)
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileLoadBalancer(t *testing.T) {
+	enabled := true
+	disabled := false
+	active := &cloud.LoadBalancer{ID: "lb-new", DNSName: "lb-new.lb.datacrunch.io", State: "active"}
+	provisioning := &cloud.LoadBalancer{ID: "lb-new", State: "provisioning"}
+
+	tests := []struct {
+		name              string
+		dataCrunchCluster *infrav1beta1.DataCrunchCluster
+		loadBalancer      *cloud.LoadBalancer
+		wantCreated       bool
+		wantStatus        *infrav1beta1.DataCrunchLoadBalancerStatus
+		wantEndpoint      clusterv1.APIEndpoint
+		wantReady         bool
+	}{
+		{
+			name: "no load balancer config",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
+				Spec: infrav1beta1.DataCrunchClusterSpec{
+					Region: "us-east-1",
+				},
+			},
+			wantEndpoint: clusterv1.APIEndpoint{Host: "cluster-test-cluster.datacrunch.local", Port: 6443},
+		},
+		{
+			name: "load balancer disabled",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
+				Spec: infrav1beta1.DataCrunchClusterSpec{
+					Region: "us-east-1",
+					ControlPlaneLoadBalancer: &infrav1beta1.DataCrunchLoadBalancerSpec{
+						Enabled: &disabled,
+					},
+				},
+			},
+			wantEndpoint: clusterv1.APIEndpoint{Host: "cluster-test-cluster.datacrunch.local", Port: 6443},
+		},
+		{
+			name: "load balancer enabled and active",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
+				Spec: infrav1beta1.DataCrunchClusterSpec{
+					Region: "us-east-1",
+					ControlPlaneLoadBalancer: &infrav1beta1.DataCrunchLoadBalancerSpec{
+						Enabled:         &enabled,
+						Type:            "application",
+						HealthCheckPath: "/readyz",
+					},
+				},
+			},
+			loadBalancer: active,
+			wantCreated:  true,
+			wantStatus:   &infrav1beta1.DataCrunchLoadBalancerStatus{ID: "lb-new", DNSName: "lb-new.lb.datacrunch.io", State: "active"},
+			wantEndpoint: clusterv1.APIEndpoint{Host: "lb-new.lb.datacrunch.io", Port: 6443},
+			wantReady:    true,
+		},
+		{
+			name: "load balancer enabled and provisioning",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
+				Spec: infrav1beta1.DataCrunchClusterSpec{
+					ControlPlaneLoadBalancer: &infrav1beta1.DataCrunchLoadBalancerSpec{Enabled: &enabled},
+				},
+			},
+			loadBalancer: provisioning,
+			wantCreated:  true,
+			wantStatus:   &infrav1beta1.DataCrunchLoadBalancerStatus{ID: "lb-new", State: "provisioning"},
+		},
+		{
+			name: "created load balancer became active",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
+				Spec: infrav1beta1.DataCrunchClusterSpec{
+					ControlPlaneLoadBalancer: &infrav1beta1.DataCrunchLoadBalancerSpec{Enabled: &enabled},
+				},
+				Status: infrav1beta1.DataCrunchClusterStatus{
+					LoadBalancer: &infrav1beta1.DataCrunchLoadBalancerStatus{ID: "lb-new", State: "provisioning"},
+				},
+			},
+			loadBalancer: active,
+			wantStatus:   &infrav1beta1.DataCrunchLoadBalancerStatus{ID: "lb-new", DNSName: "lb-new.lb.datacrunch.io", State: "active"},
+			wantEndpoint: clusterv1.APIEndpoint{Host: "lb-new.lb.datacrunch.io", Port: 6443},
+			wantReady:    true,
+		},
+		{
+			name: "user-provided endpoint is kept",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
+				Spec: infrav1beta1.DataCrunchClusterSpec{
+					ControlPlaneEndpoint:     clusterv1.APIEndpoint{Host: "api.example.com", Port: 443},
+					ControlPlaneLoadBalancer: &infrav1beta1.DataCrunchLoadBalancerSpec{Enabled: &enabled},
+				},
+			},
+			wantEndpoint: clusterv1.APIEndpoint{Host: "api.example.com", Port: 443},
+		},
+	}
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
 	}
 
-	// Verify network status is set
-	if dataCrunchCluster.Status.Network == nil {
-		t.Error("Expected network status to be set")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := &fakeCloudClient{}
+			if tt.loadBalancer != nil {
+				fakeClient.loadBalancers = map[string]*cloud.LoadBalancer{tt.loadBalancer.ID: tt.loadBalancer}
+			}
+			reconciler := &DataCrunchClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+
+			if err := reconciler.reconcileLoadBalancer(context.Background(), logr.Discard(), fakeClient, cluster, tt.dataCrunchCluster); err != nil {
+				t.Fatalf("reconcileLoadBalancer() error = %v", err)
+			}
+
+			if created := len(fakeClient.createdLoadBalancers) == 1; created != tt.wantCreated {
+				t.Fatalf("Expected load balancer created=%v, got %+v", tt.wantCreated, fakeClient.createdLoadBalancers)
+			}
+			if tt.wantCreated {
+				spec := fakeClient.createdLoadBalancers[0]
+				lbSpec := tt.dataCrunchCluster.Spec.ControlPlaneLoadBalancer
+				if spec.Name != "test-cluster-apiserver" || spec.Type != lbSpec.Type || spec.HealthCheckPath != lbSpec.HealthCheckPath {
+					t.Errorf("Unexpected load balancer spec %+v", spec)
+				}
+				if spec.Tags[clusterv1.ClusterNameLabel] != "test-cluster" {
+					t.Errorf("Expected the load balancer to be tagged with its cluster, got %v", spec.Tags)
+				}
+			}
+			if !reflect.DeepEqual(tt.dataCrunchCluster.Status.LoadBalancer, tt.wantStatus) {
+				t.Errorf("Expected load balancer status %+v, got %+v", tt.wantStatus, tt.dataCrunchCluster.Status.LoadBalancer)
+			}
+			if tt.dataCrunchCluster.Spec.ControlPlaneEndpoint != tt.wantEndpoint {
+				t.Errorf("Expected endpoint %v, got %v", tt.wantEndpoint, tt.dataCrunchCluster.Spec.ControlPlaneEndpoint)
+			}
+			if ready := conditions.IsTrue(tt.dataCrunchCluster, infrav1beta1.LoadBalancerReadyCondition); ready != tt.wantReady {
+				t.Errorf("Expected LoadBalancerReady=%v, got %v", tt.wantReady, ready)
+			}
+		})
 	}
 }
 
-func TestDataCrunchClusterReconciler_reconcileLoadBalancer(t *testing.T) {
+func TestDataCrunchClusterReconciler_reconcileLoadBalancer_ExternalEndpoint(t *testing.T) {
+	enabled := true
+
 	tests := []struct {
 		name              string
 		dataCrunchCluster *infrav1beta1.DataCrunchCluster
-		wantLB            bool
+		wantEndpoint      clusterv1.APIEndpoint
+		wantExternal      bool
 	}{
 		{
-			name: "no load balancer config",
+			name: "static IP without load balancer",
 			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
 				Spec: infrav1beta1.DataCrunchClusterSpec{
-					Region: "us-east-1",
+					ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "203.0.113.10", Port: 6443},
 				},
 			},
-			wantLB: false,
+			wantEndpoint: clusterv1.APIEndpoint{Host: "203.0.113.10", Port: 6443},
+			wantExternal: true,
 		},
 		{
-			name: "load balancer disabled",
+			name: "static IP skips enabled load balancer",
 			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
 				Spec: infrav1beta1.DataCrunchClusterSpec{
-					Region: "us-east-1",
-					ControlPlaneLoadBalancer: &infrav1beta1.DataCrunchLoadBalancerSpec{
-						Enabled: func() *bool { b := false; return &b }(),
-					},
+					ControlPlaneEndpoint:     clusterv1.APIEndpoint{Host: "203.0.113.10"},
+					ControlPlaneLoadBalancer: &infrav1beta1.DataCrunchLoadBalancerSpec{Enabled: &enabled},
 				},
 			},
-			wantLB: false,
+			wantEndpoint: clusterv1.APIEndpoint{Host: "203.0.113.10", Port: 6443},
+			wantExternal: true,
 		},
 		{
-			name: "load balancer enabled",
+			name: "placeholder endpoint set by an earlier reconcile",
 			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
 				Spec: infrav1beta1.DataCrunchClusterSpec{
-					Region: "us-east-1",
-					ControlPlaneLoadBalancer: &infrav1beta1.DataCrunchLoadBalancerSpec{
-						Enabled: func() *bool { b := true; return &b }(),
-						Type:    "application",
-					},
+					ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "cluster-test-cluster.datacrunch.local", Port: 6443},
 				},
 			},
-			wantLB: true,
+			wantEndpoint: clusterv1.APIEndpoint{Host: "cluster-test-cluster.datacrunch.local", Port: 6443},
+		},
+		{
+			name:              "no endpoint",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{},
+			wantEndpoint:      clusterv1.APIEndpoint{Host: "cluster-test-cluster.datacrunch.local", Port: 6443},
+		},
+	}
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := &fakeCloudClient{}
+			reconciler := &DataCrunchClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+
+			// Reconciling again must not change the outcome
+			for i := 0; i < 2; i++ {
+				if err := reconciler.reconcileLoadBalancer(context.Background(), logr.Discard(), fakeClient, cluster, tt.dataCrunchCluster); err != nil {
+					t.Fatalf("reconcileLoadBalancer() error = %v", err)
+				}
+			}
+
+			if len(fakeClient.createdLoadBalancers) != 0 {
+				t.Errorf("Expected no load balancer to be created, got %+v", fakeClient.createdLoadBalancers)
+			}
+			if tt.dataCrunchCluster.Status.LoadBalancer != nil {
+				t.Errorf("Expected no load balancer status, got %+v", tt.dataCrunchCluster.Status.LoadBalancer)
+			}
+			if tt.dataCrunchCluster.Spec.ControlPlaneEndpoint != tt.wantEndpoint {
+				t.Errorf("Expected endpoint %v, got %v", tt.wantEndpoint, tt.dataCrunchCluster.Spec.ControlPlaneEndpoint)
+			}
+			if external := conditions.IsTrue(tt.dataCrunchCluster, infrav1beta1.ExternalControlPlaneEndpointCondition); external != tt.wantExternal {
+				t.Errorf("Expected ExternalControlPlaneEndpoint=%v, got %v", tt.wantExternal, external)
+			}
+		})
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileNormal_WaitsForLoadBalancer(t *testing.T) {
+	lbState := "provisioning"
+	var creates int
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"POST /load-balancers": func(w http.ResponseWriter, _ *http.Request) {
+			creates++
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"lb-123","status":"provisioning"}`))
+		},
+		"/load-balancers/lb-123": func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"id":"lb-123","dns_name":"lb-123.lb.datacrunch.io","status":"` + lbState + `"}`))
+		},
+	})
+
+	scheme := newTestScheme()
+
+	enabled := true
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-cluster",
+			Namespace:  "default",
+			Finalizers: []string{infrav1beta1.ClusterFinalizer},
+		},
+		Spec: infrav1beta1.DataCrunchClusterSpec{
+			Region:                   "FIN-01",
+			ControlPlaneLoadBalancer: &infrav1beta1.DataCrunchLoadBalancerSpec{Enabled: &enabled},
+		},
+	}
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+	}
+
+	reconciler := &DataCrunchClusterReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(dataCrunchCluster, cluster).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	result, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), cluster, dataCrunchCluster)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if result.RequeueAfter == 0 {
+		t.Error("Expected a requeue while the load balancer is provisioning")
+	}
+	if dataCrunchCluster.Status.Ready {
+		t.Error("Expected the cluster not to be ready while the load balancer is provisioning")
+	}
+	if conditions.GetReason(dataCrunchCluster, infrav1beta1.LoadBalancerReadyCondition) != infrav1beta1.WaitingForLoadBalancerReason {
+		t.Errorf("Expected LoadBalancerReady reason %s, got %q", infrav1beta1.WaitingForLoadBalancerReason, conditions.GetReason(dataCrunchCluster, infrav1beta1.LoadBalancerReadyCondition))
+	}
+	if !dataCrunchCluster.Spec.ControlPlaneEndpoint.IsZero() {
+		t.Errorf("Expected no control plane endpoint before the load balancer is active, got %v", dataCrunchCluster.Spec.ControlPlaneEndpoint)
+	}
+
+	lbState = "active"
+	if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), cluster, dataCrunchCluster); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !dataCrunchCluster.Status.Ready {
+		t.Error("Expected the cluster to be ready once the load balancer is active")
+	}
+	if creates != 1 {
+		t.Errorf("Expected the load balancer to be created once, got %d", creates)
+	}
+	want := clusterv1.APIEndpoint{Host: "lb-123.lb.datacrunch.io", Port: 6443}
+	if dataCrunchCluster.Spec.ControlPlaneEndpoint != want {
+		t.Errorf("Expected endpoint %v, got %v", want, dataCrunchCluster.Spec.ControlPlaneEndpoint)
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileLoadBalancer_DefaultsPort(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint clusterv1.APIEndpoint
+		want     clusterv1.APIEndpoint
+	}{
+		{
+			name:     "host without port",
+			endpoint: clusterv1.APIEndpoint{Host: "10.0.0.1"},
+			want:     clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 6443},
+		},
+		{
+			name:     "port is kept",
+			endpoint: clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 443},
+			want:     clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 443},
+		},
+		{
+			name: "no endpoint",
+			want: clusterv1.APIEndpoint{Host: "cluster-test-cluster.datacrunch.local", Port: 6443},
 		},
 	}
 
@@ -294,13 +1215,17 @@ func TestDataCrunchClusterReconciler_reconcileLoadBalancer(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+				Spec: infrav1beta1.DataCrunchClusterSpec{ControlPlaneEndpoint: tt.endpoint},
+			}
+
 			reconciler := &DataCrunchClusterReconciler{}
-			log := logr.Discard()
+			if err := reconciler.reconcileLoadBalancer(context.Background(), logr.Discard(), nil, cluster, dataCrunchCluster); err != nil {
+				t.Fatalf("reconcileLoadBalancer() error = %v", err)
+			}
 
-			err := reconciler.reconcileLoadBalancer(context.Background(), log, nil, cluster, tt.dataCrunchCluster)
-			// We expect this to not panic, testing method signature
-			if err != nil {
-				t.Logf("reconcileLoadBalancer completed with expected error: %v", err)
+			if dataCrunchCluster.Spec.ControlPlaneEndpoint != tt.want {
+				t.Errorf("Expected endpoint %v, got %v", tt.want, dataCrunchCluster.Spec.ControlPlaneEndpoint)
 			}
 		})
 	}
@@ -316,7 +1241,7 @@ func TestDataCrunchClusterReconciler_createDataCrunchClient(t *testing.T) {
 		},
 	}
 
-	client, err := reconciler.createDataCrunchClient(context.Background(), cluster)
+	client, err := reconciler.createDataCrunchClient(context.Background(), cluster, &infrav1beta1.DataCrunchCluster{})
 	// We expect this to fail without proper credentials, but testing method signature
 	if err != nil {
 		t.Logf("createDataCrunchClient completed with expected error: %v", err)
@@ -326,10 +1251,66 @@ func TestDataCrunchClusterReconciler_createDataCrunchClient(t *testing.T) {
 	}
 }
 
+func TestCreateDataCrunchClient_APIEndpoint(t *testing.T) {
+	tests := []struct {
+		name              string
+		envURL            string
+		dataCrunchCluster *infrav1beta1.DataCrunchCluster
+		wantURL           string
+	}{
+		{
+			name:              "public API by default",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{},
+			wantURL:           "https://api.datacrunch.io/v1",
+		},
+		{
+			name:              "environment override",
+			envURL:            "http://localhost:8080/v1",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{},
+			wantURL:           "http://localhost:8080/v1",
+		},
+		{
+			name:              "cluster endpoint",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{Spec: infrav1beta1.DataCrunchClusterSpec{APIEndpoint: "https://api.staging.datacrunch.io/v1"}},
+			wantURL:           "https://api.staging.datacrunch.io/v1",
+		},
+		{
+			name:              "cluster endpoint takes precedence over the environment",
+			envURL:            "http://localhost:8080/v1",
+			dataCrunchCluster: &infrav1beta1.DataCrunchCluster{Spec: infrav1beta1.DataCrunchClusterSpec{APIEndpoint: "https://api.staging.datacrunch.io/v1"}},
+			wantURL:           "https://api.staging.datacrunch.io/v1",
+		},
+		{
+			name:    "no DataCrunchCluster",
+			envURL:  "http://localhost:8080/v1",
+			wantURL: "http://localhost:8080/v1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATACRUNCH_API_URL", tt.envURL)
+
+			clusterClient, err := (&DataCrunchClusterReconciler{}).createDataCrunchClient(context.Background(), &clusterv1.Cluster{}, tt.dataCrunchCluster)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			machineClient, err := (&DataCrunchMachineReconciler{}).createDataCrunchClient(context.Background(), &clusterv1.Cluster{}, tt.dataCrunchCluster, nil)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			for _, dataCrunchClient := range []cloud.Client{clusterClient, machineClient} {
+				if got := dataCrunchClient.(*datacrunch.Client).BaseURL(); got != tt.wantURL {
+					t.Errorf("Expected base URL %q, got %q", tt.wantURL, got)
+				}
+			}
+		})
+	}
+}
+
 func TestDataCrunchClusterReconciler_SetupWithManager(t *testing.T) {
-	scheme := runtime.NewScheme()
-	_ = infrav1beta1.AddToScheme(scheme)
-	_ = clusterv1.AddToScheme(scheme)
+	scheme := newTestScheme()
 
 	reconciler := &DataCrunchClusterReconciler{
 		Scheme: scheme,
@@ -347,3 +1328,235 @@ func TestDataCrunchClusterReconciler_SetupWithManager(t *testing.T) {
 	// We expect this to fail since mgr is nil, so we don't check the error
 	_ = err
 }
+
+func TestDataCrunchClusterReconciler_ReconcileTimeout(t *testing.T) {
+	scheme := newTestScheme()
+
+	// Simulate a hung API server by blocking until the request context is done
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, _ client.WithWatch, _ client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}).
+		Build()
+
+	reconciler := &DataCrunchClusterReconciler{
+		Client:           fakeClient,
+		Scheme:           scheme,
+		ReconcileTimeout: 50 * time.Millisecond,
+	}
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(context.Background(), req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context deadline exceeded error, got: %v", err)
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileAccountLimits(t *testing.T) {
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{}
+	reconciler := &DataCrunchClusterReconciler{}
+
+	if err := reconciler.reconcileAccountLimits(context.Background(), &fakeCloudClient{}, dataCrunchCluster); err == nil {
+		t.Error("Expected error when account limits are not available")
+	}
+	if dataCrunchCluster.Status.AccountLimits != nil {
+		t.Errorf("Expected no account limits in status, got %v", dataCrunchCluster.Status.AccountLimits)
+	}
+
+	cloudClient := &fakeCloudClient{
+		accountLimits: &cloud.AccountLimits{MaxInstances: 10, MaxGPUs: 16, UsedInstances: 3, UsedGPUs: 10},
+	}
+	if err := reconciler.reconcileAccountLimits(context.Background(), cloudClient, dataCrunchCluster); err != nil {
+		t.Fatalf("reconcileAccountLimits() error = %v", err)
+	}
+
+	want := infrav1beta1.DataCrunchAccountLimitsStatus{MaxInstances: 10, MaxGPUs: 16, UsedInstances: 3, UsedGPUs: 10}
+	if got := dataCrunchCluster.Status.AccountLimits; got == nil || *got != want {
+		t.Errorf("Expected account limits %v, got %v", want, got)
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileMachineSummary(t *testing.T) {
+	scheme := newTestScheme()
+
+	newMachine := func(name, clusterName string, ready bool) *infrav1beta1.DataCrunchMachine {
+		return &infrav1beta1.DataCrunchMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{clusterv1.ClusterNameLabel: clusterName},
+			},
+			Status: infrav1beta1.DataCrunchMachineStatus{Ready: ready},
+		}
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			newMachine("machine-1", "test-cluster", true),
+			newMachine("machine-2", "test-cluster", false),
+			newMachine("machine-3", "test-cluster", true),
+			newMachine("other-machine", "other-cluster", true),
+		).
+		Build()
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+
+	reconciler := &DataCrunchClusterReconciler{Client: fakeClient}
+	if err := reconciler.reconcileMachineSummary(context.Background(), cluster, dataCrunchCluster); err != nil {
+		t.Fatalf("reconcileMachineSummary() error = %v", err)
+	}
+
+	if dataCrunchCluster.Status.TotalMachines != 3 {
+		t.Errorf("Expected 3 total machines, got %d", dataCrunchCluster.Status.TotalMachines)
+	}
+	if dataCrunchCluster.Status.ReadyMachines != 2 {
+		t.Errorf("Expected 2 ready machines, got %d", dataCrunchCluster.Status.ReadyMachines)
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileControlPlaneEndpointConfigMap(t *testing.T) {
+	scheme := newTestScheme()
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-datacrunch-cluster",
+			Namespace: "default",
+			UID:       "cluster-uid",
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	reconciler := &DataCrunchClusterReconciler{Client: fakeClient, Scheme: scheme}
+	key := types.NamespacedName{Namespace: "default", Name: "test-cluster-control-plane-endpoint"}
+
+	// Nothing is published before the endpoint is known
+	if err := reconciler.reconcileControlPlaneEndpointConfigMap(context.Background(), logr.Discard(), cluster, dataCrunchCluster); err != nil {
+		t.Fatalf("reconcileControlPlaneEndpointConfigMap() error = %v", err)
+	}
+	if err := fakeClient.Get(context.Background(), key, &corev1.ConfigMap{}); err == nil {
+		t.Fatal("Expected no ConfigMap without a control plane endpoint")
+	}
+
+	dataCrunchCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 6443}
+	if err := reconciler.reconcileControlPlaneEndpointConfigMap(context.Background(), logr.Discard(), cluster, dataCrunchCluster); err != nil {
+		t.Fatalf("reconcileControlPlaneEndpointConfigMap() error = %v", err)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := fakeClient.Get(context.Background(), key, configMap); err != nil {
+		t.Fatalf("Expected ConfigMap to be created: %v", err)
+	}
+	if configMap.Data["host"] != "10.0.0.1" || configMap.Data["port"] != "6443" || configMap.Data["endpoint"] != "10.0.0.1:6443" {
+		t.Errorf("Unexpected ConfigMap data: %v", configMap.Data)
+	}
+	if configMap.Labels[clusterv1.ClusterNameLabel] != "test-cluster" {
+		t.Errorf("Expected cluster name label, got %v", configMap.Labels)
+	}
+	if len(configMap.OwnerReferences) != 1 || configMap.OwnerReferences[0].Name != "test-datacrunch-cluster" {
+		t.Errorf("Expected ConfigMap to be owned by the DataCrunchCluster, got %v", configMap.OwnerReferences)
+	}
+
+	dataCrunchCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "203.0.113.10", Port: 443}
+	if err := reconciler.reconcileControlPlaneEndpointConfigMap(context.Background(), logr.Discard(), cluster, dataCrunchCluster); err != nil {
+		t.Fatalf("reconcileControlPlaneEndpointConfigMap() error = %v", err)
+	}
+
+	if err := fakeClient.Get(context.Background(), key, configMap); err != nil {
+		t.Fatalf("Expected ConfigMap to exist: %v", err)
+	}
+	if configMap.Data["endpoint"] != "203.0.113.10:443" {
+		t.Errorf("Expected ConfigMap to be updated with the new endpoint, got %v", configMap.Data)
+	}
+}
+
+func TestDataCrunchClusterReconciler_dataCrunchMachineToDataCrunchCluster(t *testing.T) {
+	scheme := newTestScheme()
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{
+				Kind: "DataCrunchCluster",
+				Name: "test-dc-cluster",
+			},
+		},
+	}
+
+	reconciler := &DataCrunchClusterReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster).Build(),
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   []reconcile.Request
+	}{
+		{
+			name:   "machine of a DataCrunch cluster",
+			labels: map[string]string{clusterv1.ClusterNameLabel: "test-cluster"},
+			want: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "test-dc-cluster"}},
+			},
+		},
+		{
+			name: "machine without cluster label",
+		},
+		{
+			name:   "machine of an unknown cluster",
+			labels: map[string]string{clusterv1.ClusterNameLabel: "missing-cluster"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &infrav1beta1.DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machine",
+					Namespace: "default",
+					Labels:    tt.labels,
+				},
+			}
+
+			got := reconciler.dataCrunchMachineToDataCrunchCluster(context.Background(), machine)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d requests, got %v", len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Expected request %v, got %v", tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
@@ -17,17 +17,37 @@ limitations under the License.
 package controller
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
 	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
@@ -41,12 +61,99 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 
 	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
 	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
 	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud/datacrunch"
 )
 
+const (
+	// idempotencyKeyTag is the instance and SSH key tag holding the UID of the DataCrunchMachine they were created for
+	idempotencyKeyTag = "infrastructure.cluster.x-k8s.io/datacrunchmachine-uid"
+
+	// credentialsClientIDKey and credentialsClientSecretKey are the keys of the Secret referenced by
+	// a DataCrunchMachine's CredentialsRef
+	credentialsClientIDKey     = "clientID"
+	credentialsClientSecretKey = "clientSecret"
+
+	// startupScriptKey is the key of the Secret referenced by a DataCrunchMachine's StartupScriptRef
+	startupScriptKey = "value"
+
+	// sshPublicKeyKey is the key of the Secret referenced by a DataCrunchMachine's SSHPublicKeySecretRef
+	sshPublicKeyKey = "value"
+
+	// userDataBoundary separates the parts of user data combining the bootstrap data with a startup script.
+	// It is fixed so the same inputs always yield the same user data, unless a part contains it.
+	userDataBoundary = "==DATACRUNCH-USER-DATA=="
+
+	// defaultInstancePollInterval is how often a provisioning instance is checked when InstancePollInterval is not set
+	defaultInstancePollInterval = 10 * time.Second
+
+	// defaultErrorRequeueInterval is how long to back off after a failed step when ErrorRequeueInterval is not set
+	defaultErrorRequeueInterval = 30 * time.Second
+
+	// quotaExceededRequeueAfter is how long to back off before retrying instance creation once a quota is hit
+	quotaExceededRequeueAfter = 5 * time.Minute
+
+	// stoppingTimeout is how long an instance may stay in the stopping state before a forced stop is requested
+	stoppingTimeout = 10 * time.Minute
+
+	// minStoppingRequeueAfter and maxStoppingRequeueAfter bound the backoff while waiting for an instance to stop
+	minStoppingRequeueAfter = 15 * time.Second
+	maxStoppingRequeueAfter = 2 * time.Minute
+
+	// startedInstanceRequeueAfter is how soon a stopped instance is checked again after starting it
+	startedInstanceRequeueAfter = 2 * time.Second
+
+	// runningInstanceRequeueAfter is how often a running instance is re-checked, e.g. for its health and tags
+	runningInstanceRequeueAfter = 5 * time.Minute
+
+	// gpuModelNodeLabel and gpuCountNodeLabel advertise the GPUs of an instance on its Node. They live
+	// under the node.cluster.x-k8s.io domain so Cluster API syncs them from the Machine to the Node.
+	gpuModelNodeLabel = "datacrunch.node.cluster.x-k8s.io/gpu-model"
+	gpuCountNodeLabel = "datacrunch.node.cluster.x-k8s.io/gpu-count"
+
+	// kubeconfigSecretSuffix and kubeconfigSecretKey locate the kubeconfig of a workload cluster, following
+	// the Cluster API "<cluster>-kubeconfig" secret convention
+	kubeconfigSecretSuffix = "-kubeconfig"
+	kubeconfigSecretKey    = "value"
+
+	// fallbackRootVolumeSizeGB is the root volume size for CPU-only and unknown GPU instance types
+	fallbackRootVolumeSizeGB int64 = 50
+
+	// snapshotStateAvailable and snapshotStateError are the states of a snapshot that can be restored
+	// from and of one that failed
+	snapshotStateAvailable = "available"
+	snapshotStateError     = "error"
+)
+
+// defaultRootVolumeSizes maps GPU models to the root volume size in GB used when a DataCrunchMachine
+// does not set one. Larger GPUs are typically paired with bigger CUDA images, drivers and model caches.
+var defaultRootVolumeSizes = map[string]int64{
+	"H200":       250,
+	"H100":       200,
+	"A100":       200,
+	"L40S":       150,
+	"RTX6000ADA": 150,
+	"A6000":      100,
+	"V100":       100,
+}
+
+// gpuInstanceTypePattern matches GPU instance type names such as "8H100.80S.176V", "1V100.6V" or "1xH100",
+// capturing the GPU count and model.
+var gpuInstanceTypePattern = regexp.MustCompile(`^([1-9][0-9]*)x?([A-Za-z][A-Za-z0-9]*)(?:\.|$)`)
+
+// BootstrapDataTransformFunc transforms the decoded bootstrap data of a Machine before it is sent
+// to DataCrunch, e.g. to inject registry mirrors or proxy settings.
+type BootstrapDataTransformFunc func(ctx context.Context, machine *clusterv1.Machine, data []byte) ([]byte, error)
+
+// UserDataValidatorFunc validates the base64 encoded user data of an instance before it is created.
+type UserDataValidatorFunc func(userData string) error
+
+// WorkloadClusterClientFunc returns a client for the workload cluster of a Cluster.
+type WorkloadClusterClientFunc func(ctx context.Context, cluster *clusterv1.Cluster) (client.Client, error)
+
 // DataCrunchMachineReconciler reconciles a DataCrunchMachine object
 type DataCrunchMachineReconciler struct {
 	client.Client
@@ -55,12 +162,66 @@ type DataCrunchMachineReconciler struct {
 	Log      logr.Logger
 
 	WatchFilterValue string
+
+	// ReconcileTimeout bounds the duration of a single Reconcile call. Zero means no timeout.
+	ReconcileTimeout time.Duration
+
+	// InstancePollInterval is how often an instance is checked while it is provisioning, e.g. pending or
+	// waiting for an address. Zero means defaultInstancePollInterval.
+	InstancePollInterval time.Duration
+
+	// ErrorRequeueInterval is how long to back off before retrying a failed reconcile step. Such failures are
+	// reported in a condition or event instead of being returned, as controller-runtime ignores the result
+	// of a reconcile returning an error. Zero means defaultErrorRequeueInterval.
+	ErrorRequeueInterval time.Duration
+
+	// DefaultUncompressedUserData is whether the user data of machines that leave UncompressedUserData unset
+	// is sent uncompressed. Nil means true, as images without cloud-init can't decompress it.
+	DefaultUncompressedUserData *bool
+
+	// DefaultImage is the image of machines that don't specify one, which is normally filled in by the
+	// defaulting webhook. Empty means infrav1beta1.DefaultImage.
+	DefaultImage string
+
+	// APIRateLimiter, if set, is shared by all DataCrunch clients created by the reconciler to pace API requests.
+	APIRateLimiter *rate.Limiter
+
+	// APIHealth, if set, records the outcome of the API requests of all DataCrunch clients created by the reconciler.
+	APIHealth *datacrunch.HealthTracker
+
+	// Concurrency, if set, bounds the reconciles running at once. It is tuned by the API requests of all DataCrunch
+	// clients created by the reconciler and can be shared with other reconcilers to bound their reconciles together.
+	Concurrency *datacrunch.AdaptiveConcurrency
+
+	// ClientCache, if set, reuses DataCrunch clients and their access tokens across reconciles. It can be
+	// shared between reconcilers using the same rate limiter, health tracker and concurrency.
+	ClientCache *datacrunch.ClientCache
+
+	// Credentials, if set, provides the controller-wide API credentials instead of the DATACRUNCH_CLIENT_ID
+	// and DATACRUNCH_CLIENT_SECRET environment variables.
+	Credentials *FileCredentials
+
+	// BootstrapDataTransform, if set, is applied to the bootstrap data before the instance is created.
+	BootstrapDataTransform BootstrapDataTransformFunc
+
+	// UserDataValidator, if set, replaces the default validation of the encoded user data, see validateUserData.
+	UserDataValidator UserDataValidatorFunc
+
+	// CordonPausedNodes cordons the Node of a running machine while its DataCrunchMachine is paused.
+	CordonPausedNodes bool
+
+	// WorkloadClusterClient, if set, replaces the client built from the kubeconfig secret of the workload cluster.
+	WorkloadClusterClient WorkloadClusterClientFunc
+
+	// stoppedInstanceClaims holds a *sync.Mutex per MachineSet, see lockStoppedInstanceClaims.
+	stoppedInstanceClaims sync.Map
 }
 
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=datacrunchmachines,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=datacrunchmachines/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=datacrunchmachines/finalizers,verbs=update
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=patch
 //+kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -68,6 +229,22 @@ type DataCrunchMachineReconciler struct {
 func (r *DataCrunchMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	log := r.Log.WithValues("namespace", req.Namespace, "datacrunchMachine", req.Name)
 
+	// Workers beyond the current limit wait, so the API isn't flooded while it rate limits requests. The
+	// wait doesn't count towards the reconcile timeout.
+	if r.Concurrency != nil {
+		if err := r.Concurrency.Acquire(ctx); err != nil {
+			return reconcile.Result{}, err
+		}
+		defer r.Concurrency.Release()
+	}
+
+	// Bound the reconcile so a hung API call can't block a worker indefinitely
+	if r.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.ReconcileTimeout)
+		defer cancel()
+	}
+
 	// Fetch the DataCrunchMachine instance
 	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{}
 	err := r.Get(ctx, req.NamespacedName, dataCrunchMachine)
@@ -104,24 +281,19 @@ func (r *DataCrunchMachineReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
 	log = log.WithValues("cluster", cluster.Name)
 
-	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{}
-	dataCrunchClusterName := client.ObjectKey{
-		Namespace: dataCrunchMachine.Namespace,
-		Name:      cluster.Spec.InfrastructureRef.Name,
-	}
-	if err := r.Get(ctx, dataCrunchClusterName, dataCrunchCluster); err != nil {
-		log.Info("DataCrunchCluster is not available yet")
-		return reconcile.Result{}, nil
-	}
-
 	// Initialize the patch helper
 	patchHelper, err := patch.NewHelper(dataCrunchMachine, r.Client)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	// Always attempt to Patch the DataCrunchMachine object and status after each reconciliation.
+	original := dataCrunchMachine.DeepCopy()
+
+	// Patch the DataCrunchMachine object and status after each reconciliation that changed them.
 	defer func() {
+		if !dataCrunchMachineChanged(original, dataCrunchMachine) {
+			return
+		}
 		if err := patchHelper.Patch(ctx, dataCrunchMachine); err != nil {
 			log.Error(err, "failed to patch DataCrunchMachine")
 			if reterr == nil {
@@ -130,13 +302,167 @@ func (r *DataCrunchMachineReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		}
 	}()
 
+	// Don't provision for a Machine backed by other infrastructure, e.g. after an owner reference was copied
+	// along with a manifest, or under a Cluster without a DataCrunchCluster. Deletion is still handled so the
+	// finalizer doesn't block it.
+	if dataCrunchMachine.DeletionTimestamp.IsZero() {
+		if err := validateMachineInfrastructureRef(machine, dataCrunchMachine); err != nil {
+			log.Info("Owner Machine does not reference this DataCrunchMachine", "reason", err.Error())
+			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.ForeignMachineOwnerReason, clusterv1.ConditionSeverityError, "%s", err.Error())
+			return reconcile.Result{}, nil
+		}
+
+		// The infrastructure reference may still be set on the Cluster, e.g. by a ClusterClass topology
+		if cluster.Spec.InfrastructureRef == nil {
+			log.Info("Cluster infrastructure reference is not set yet")
+			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "cluster infrastructure reference not set")
+			return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+
+		// Fail clearly instead of looking up a DataCrunchCluster named after another provider's cluster
+		if err := validateInfrastructureRef(cluster); err != nil {
+			log.Info("Cluster infrastructure is not a DataCrunchCluster", "reason", err.Error())
+			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InvalidInfrastructureRefReason, clusterv1.ConditionSeverityError, "%s", err.Error())
+			return reconcile.Result{}, nil
+		}
+	}
+
+	// A machine deleted under a Cluster without a DataCrunchCluster is cleaned up with its own settings
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{}
+	if validateInfrastructureRef(cluster) == nil {
+		dataCrunchClusterName := client.ObjectKey{
+			Namespace: dataCrunchMachine.Namespace,
+			Name:      cluster.Spec.InfrastructureRef.Name,
+		}
+		if err := r.Get(ctx, dataCrunchClusterName, dataCrunchCluster); err != nil {
+			log.Info("DataCrunchCluster is not available yet")
+			return reconcile.Result{}, nil
+		}
+	}
+
 	// Handle deleted machines
 	if !dataCrunchMachine.DeletionTimestamp.IsZero() {
 		return r.reconcileDelete(ctx, log, machine, dataCrunchMachine, cluster, dataCrunchCluster)
 	}
 
+	// Calls to an API the provider can't parse the responses of would only fail again until it is upgraded
+	if conditions.GetReason(dataCrunchCluster, infrav1beta1.APICompatibleCondition) == infrav1beta1.APIIncompatibleReason {
+		log.Info("DataCrunch API is incompatible with the provider, waiting for the DataCrunchCluster to reconcile successfully", "dataCrunchCluster", dataCrunchCluster.Name)
+		return reconcile.Result{RequeueAfter: apiIncompatibleRequeueAfter}, nil
+	}
+
 	// Handle non-deleted machines
-	return r.reconcileNormal(ctx, log, machine, dataCrunchMachine, cluster, dataCrunchCluster)
+	result, err := r.reconcileNormal(ctx, log, machine, dataCrunchMachine, cluster, dataCrunchCluster)
+	if errors.Is(err, cloud.ErrUnexpectedResponse) {
+		log.Error(err, "DataCrunch API responded with an unexpected structure, the provider may need to be upgraded")
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.APIIncompatibleReason, "DataCrunch API responded with an unexpected structure, the provider may need to be upgraded: %v", err)
+		return reconcile.Result{RequeueAfter: apiIncompatibleRequeueAfter}, nil
+	}
+	return result, err
+}
+
+// instancePollInterval returns how often to check an instance while it is provisioning.
+func (r *DataCrunchMachineReconciler) instancePollInterval() time.Duration {
+	if r.InstancePollInterval > 0 {
+		return r.InstancePollInterval
+	}
+	return defaultInstancePollInterval
+}
+
+// instanceStateRequeueAfter returns how long to wait before checking an instance again once it was
+// reconciled in the given state. Zero means the instance is not checked again, and stopping instances
+// back off from minStoppingRequeueAfter in reconcileStopping.
+func (r *DataCrunchMachineReconciler) instanceStateRequeueAfter(state infrav1beta1.InstanceState) time.Duration {
+	switch state {
+	case infrav1beta1.InstanceStatePending:
+		return r.instancePollInterval()
+	case infrav1beta1.InstanceStateRunning:
+		return runningInstanceRequeueAfter
+	case infrav1beta1.InstanceStateStopped:
+		return startedInstanceRequeueAfter
+	case infrav1beta1.InstanceStateStopping:
+		return minStoppingRequeueAfter
+	case infrav1beta1.InstanceStateTerminated:
+		return 0
+	default:
+		return r.errorRequeueInterval()
+	}
+}
+
+// defaultImage returns the image of machines that don't specify one.
+func (r *DataCrunchMachineReconciler) defaultImage() string {
+	if r.DefaultImage != "" {
+		return r.DefaultImage
+	}
+	return infrav1beta1.DefaultImage
+}
+
+// uncompressedUserData returns whether the user data of a machine is sent uncompressed.
+func (r *DataCrunchMachineReconciler) uncompressedUserData(dataCrunchMachine *infrav1beta1.DataCrunchMachine) bool {
+	if dataCrunchMachine.Spec.UncompressedUserData != nil {
+		return *dataCrunchMachine.Spec.UncompressedUserData
+	}
+	if r.DefaultUncompressedUserData != nil {
+		return *r.DefaultUncompressedUserData
+	}
+	return true
+}
+
+// errorRequeueInterval returns how long to back off before retrying a failed reconcile step.
+func (r *DataCrunchMachineReconciler) errorRequeueInterval() time.Duration {
+	if r.ErrorRequeueInterval > 0 {
+		return r.ErrorRequeueInterval
+	}
+	return defaultErrorRequeueInterval
+}
+
+// validateInfrastructureRef returns an error unless the infrastructure of the Cluster is a DataCrunchCluster.
+func validateInfrastructureRef(cluster *clusterv1.Cluster) error {
+	ref := cluster.Spec.InfrastructureRef
+	if ref == nil {
+		return errors.Errorf("Cluster %s/%s has no infrastructure reference", cluster.Namespace, cluster.Name)
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return errors.Wrapf(err, "Cluster %s/%s has an invalid infrastructure reference API version", cluster.Namespace, cluster.Name)
+	}
+	if ref.Kind != "DataCrunchCluster" || (ref.APIVersion != "" && gv.Group != infrav1beta1.GroupVersion.Group) {
+		return errors.Errorf("Cluster %s/%s references infrastructure %s %s, not a DataCrunchCluster", cluster.Namespace, cluster.Name, ref.Kind, ref.Name)
+	}
+
+	return nil
+}
+
+// validateMachineInfrastructureRef returns an error unless the infrastructure of the Machine is the DataCrunchMachine.
+func validateMachineInfrastructureRef(machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine) error {
+	ref := machine.Spec.InfrastructureRef
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return errors.Wrapf(err, "Machine %s/%s has an invalid infrastructure reference API version", machine.Namespace, machine.Name)
+	}
+	if ref.Kind != "DataCrunchMachine" || (ref.APIVersion != "" && gv.Group != infrav1beta1.GroupVersion.Group) ||
+		ref.Name != dataCrunchMachine.Name || (ref.Namespace != "" && ref.Namespace != dataCrunchMachine.Namespace) {
+		return errors.Errorf("Machine %s/%s references infrastructure %s %s, not DataCrunchMachine %s", machine.Namespace, machine.Name, ref.Kind, ref.Name, dataCrunchMachine.Name)
+	}
+
+	return nil
+}
+
+// dataCrunchMachineChanged reports whether a reconcile changed the DataCrunchMachine in a way worth writing
+// back. Refreshing the pricing timestamp without a price change is not.
+func dataCrunchMachineChanged(before, after *infrav1beta1.DataCrunchMachine) bool {
+	if !equality.Semantic.DeepEqual(before.ObjectMeta, after.ObjectMeta) || !equality.Semantic.DeepEqual(before.Spec, after.Spec) {
+		return true
+	}
+
+	afterStatus := after.Status.DeepCopy()
+	if before.Status.Pricing != nil && afterStatus.Pricing != nil {
+		afterStatus.Pricing.LastUpdated = before.Status.Pricing.LastUpdated
+	}
+
+	return !equality.Semantic.DeepEqual(before.Status, *afterStatus)
 }
 
 func (r *DataCrunchMachineReconciler) reconcileNormal(ctx context.Context, log logr.Logger, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) (reconcile.Result, error) {
@@ -148,27 +474,33 @@ func (r *DataCrunchMachineReconciler) reconcileNormal(ctx context.Context, log l
 		return reconcile.Result{}, nil
 	}
 
-	// If the DataCrunchMachine doesn't have our finalizer, add it.
+	// If the DataCrunchMachine doesn't have our finalizer, add it. Before the first instance is created the
+	// finalizer must be persisted first, afterwards it was removed externally and the instance still has to
+	// be reconciled, so carry on and let the deferred patch restore it.
 	if !controllerutil.ContainsFinalizer(dataCrunchMachine, infrav1beta1.MachineFinalizer) {
 		controllerutil.AddFinalizer(dataCrunchMachine, infrav1beta1.MachineFinalizer)
-		return reconcile.Result{}, nil
-	}
-
-	if !cluster.Status.InfrastructureReady {
-		log.Info("Cluster infrastructure is not ready yet")
-		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
-		return reconcile.Result{}, nil
+		if providerInstanceID(dataCrunchMachine.Spec.ProviderID) == "" {
+			return reconcile.Result{}, nil
+		}
+		log.Info("Restored finalizer removed from DataCrunchMachine with an instance")
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, "FinalizerRestored", "Restored finalizer %s, removing it would orphan the instance", infrav1beta1.MachineFinalizer)
 	}
 
-	// Make sure bootstrap data is available and populated.
-	if machine.Spec.Bootstrap.DataSecretName == nil {
-		log.Info("Bootstrap data secret reference is not yet available")
-		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "")
+	// Wait for the cluster infrastructure and make sure bootstrap data is available and populated.
+	if err := machinePreconditions(machine, cluster); err != nil {
+		switch {
+		case errors.Is(err, ErrClusterInfraNotReady):
+			log.Info("Cluster infrastructure is not ready yet")
+			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+		case errors.Is(err, ErrBootstrapNotReady):
+			log.Info("Bootstrap data secret reference is not yet available")
+			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "")
+		}
 		return reconcile.Result{}, nil
 	}
 
 	// Create DataCrunch client
-	dataCrunchClient, err := r.createDataCrunchClient(ctx, cluster)
+	dataCrunchClient, err := r.createDataCrunchClient(ctx, cluster, dataCrunchCluster, dataCrunchMachine)
 	if err != nil {
 		log.Error(err, "failed to create DataCrunch client")
 		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.DataCrunchClientFailedReason, clusterv1.ConditionSeverityError, err.Error())
@@ -176,24 +508,100 @@ func (r *DataCrunchMachineReconciler) reconcileNormal(ctx context.Context, log l
 	}
 
 	// Try to find existing instance
-	instance, err := r.findInstance(ctx, dataCrunchClient, dataCrunchMachine)
+	instance, err := r.findInstance(ctx, dataCrunchClient, machine, dataCrunchMachine, cluster)
 	if err != nil {
 		log.Error(err, "failed to query for existing instance")
 		return reconcile.Result{}, err
 	}
 
+	if instance != nil && dataCrunchMachine.Spec.ProviderID == nil {
+		log.Info("Adopting existing DataCrunch instance created for this machine", "instanceId", instance.ID)
+	}
+
+	// A MachineSet scaled up again starts the instances it stopped when scaled to zero before creating new ones
+	if instance == nil {
+		instance, err = r.claimStoppedInstance(ctx, log, dataCrunchClient, machine, dataCrunchMachine, cluster)
+		if err != nil {
+			log.Error(err, "failed to claim stopped instance")
+			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceNotReadyReason, clusterv1.ConditionSeverityWarning, "Failed to claim a stopped instance: %v", err)
+			return reconcile.Result{RequeueAfter: r.errorRequeueInterval()}, nil
+		}
+	}
+
 	if instance == nil {
 		// Instance doesn't exist, so create it
 		instance, err = r.createInstance(ctx, log, dataCrunchClient, machine, dataCrunchMachine, cluster, dataCrunchCluster)
 		if err != nil {
+			// Quota is freed as other instances go away, so wait for it instead of failing
+			if cloud.IsQuotaExceeded(err) {
+				log.Info("Instance creation blocked by quota, will retry", "reason", err.Error())
+				conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.QuotaExceededReason, clusterv1.ConditionSeverityWarning, err.Error())
+				r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.QuotaExceededReason, "Instance creation blocked by quota: %v", err)
+				return reconcile.Result{RequeueAfter: quotaExceededRequeueAfter}, nil
+			}
+			// Capacity comes back as other instances go away, the condition suggests where it is available meanwhile
+			if errors.Is(err, ErrInsufficientCapacity) {
+				log.Info("Instance creation blocked by insufficient capacity, will retry", "reason", err.Error())
+				conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InsufficientCapacityReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+				r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.InsufficientCapacityReason, "Instance creation blocked by insufficient capacity: %v", err)
+				return reconcile.Result{RequeueAfter: r.errorRequeueInterval()}, nil
+			}
+			// Like quota, budget is freed as other machines of the cluster go away
+			if errors.Is(err, ErrBudgetExceeded) {
+				log.Info("Instance creation blocked by the cluster budget, will retry", "reason", err.Error())
+				conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.BudgetExceededReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+				r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.BudgetExceededReason, "Instance creation blocked by the cluster budget: %v", err)
+				return reconcile.Result{RequeueAfter: quotaExceededRequeueAfter}, nil
+			}
+
 			log.Error(err, "failed to create instance")
 			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceCreationFailedReason, clusterv1.ConditionSeverityError, err.Error())
+
+			// The API rejected the spec itself, so retrying won't help until the spec is fixed
+			if !cloud.IsRetryable(err) {
+				failureReason := capierrors.CreateMachineError
+				failureMessage := fmt.Sprintf("Instance creation was rejected: %v", err)
+				dataCrunchMachine.Status.FailureReason = &failureReason
+				dataCrunchMachine.Status.FailureMessage = &failureMessage
+				r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.InstanceCreationFailedReason, "Instance creation was rejected: %v", err)
+				return reconcile.Result{}, nil
+			}
 			return reconcile.Result{}, err
 		}
 
-		log.Info("Created new DataCrunch instance", "instanceId", instance.ID)
+		log.Info("Created new DataCrunch instance", "instanceId", instance.ID, "operationId", instance.OperationID)
 		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceNotReadyReason, clusterv1.ConditionSeverityInfo, "")
 		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "InstanceCreated", "Created new DataCrunch instance %s", instance.ID)
+	} else {
+		// Replace the instance if its image drifted from the spec
+		replaced, err := r.reconcileImageDrift(ctx, log, dataCrunchClient, dataCrunchMachine, instance)
+		if err != nil {
+			log.Error(err, "failed to replace instance after image change")
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, "InstanceReplacementFailed", "Failed to replace instance %s after image change: %v", instance.ID, err)
+			return reconcile.Result{RequeueAfter: r.errorRequeueInterval()}, nil
+		}
+		if replaced {
+			return reconcile.Result{RequeueAfter: r.instancePollInterval()}, nil
+		}
+
+		// Replace the instance if its bootstrap data changed and re-bootstrapping is enabled
+		replaced, err = r.reconcileBootstrapDataDrift(ctx, log, dataCrunchClient, machine, dataCrunchMachine, instance)
+		if err != nil {
+			log.Error(err, "failed to replace instance after bootstrap data change")
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, "InstanceReplacementFailed", "Failed to replace instance %s after bootstrap data change: %v", instance.ID, err)
+			return reconcile.Result{RequeueAfter: r.errorRequeueInterval()}, nil
+		}
+		if replaced {
+			return reconcile.Result{RequeueAfter: r.instancePollInterval()}, nil
+		}
+
+		// A stale SSH key or security group membership doesn't stop the workload, so failures must not block reconciliation
+		if err := r.reconcileSSHKeyDrift(ctx, log, dataCrunchClient, dataCrunchMachine, dataCrunchCluster, instance); err != nil {
+			log.Error(err, "failed to update instance SSH key")
+		}
+		if err := r.reconcileSecurityGroupDrift(ctx, log, dataCrunchClient, dataCrunchMachine, instance); err != nil {
+			log.Error(err, "failed to update instance security groups")
+		}
 	}
 
 	// Set the provider ID to identify the instance
@@ -202,94 +610,185 @@ func (r *DataCrunchMachineReconciler) reconcileNormal(ctx context.Context, log l
 		dataCrunchMachine.Spec.ProviderID = &providerID
 	}
 
+	// Advertise the GPUs of the instance so workloads can target them with a nodeSelector
+	if err := r.reconcileNodeLabels(ctx, machine, dataCrunchMachine); err != nil {
+		log.Error(err, "failed to set GPU node labels on Machine")
+		return reconcile.Result{}, err
+	}
+
 	// Update machine status based on instance state
 	dataCrunchMachine.Status.InstanceState = (*infrav1beta1.InstanceState)(&instance.State)
+	dataCrunchMachine.Status.Labels = instance.Labels
+	if createdAt := instanceCreatedAt(instance); createdAt != nil {
+		dataCrunchMachine.Status.InstanceCreatedAt = createdAt
+	}
+	if instance.InterruptionReason != "" {
+		interruptionReason := instance.InterruptionReason
+		dataCrunchMachine.Status.InterruptionReason = &interruptionReason
+	}
+	// Checked before the backfill, which would record the provisioned instance type as the requested one
+	r.reconcileInstanceTypeMismatch(log, dataCrunchMachine, instance)
+	if backfilled := backfillStatus(dataCrunchMachine, instance); len(backfilled) > 0 {
+		log.Info("Backfilled status fields of DataCrunch instance", "instanceId", instance.ID, "fields", backfilled)
+	}
+
+	// Pricing is informational only, so failures must not block reconciliation
+	if err := r.reconcilePricing(ctx, dataCrunchClient, dataCrunchMachine, dataCrunchCluster); err != nil {
+		log.Error(err, "failed to reconcile instance type pricing")
+	}
+
+	// Metrics are informational only as well
+	if err := r.reconcileMetrics(ctx, dataCrunchClient, dataCrunchMachine, instance); err != nil {
+		log.Error(err, "failed to reconcile instance metrics")
+	}
+
+	dataCrunchMachine.Status.Ready = computeReady(machine, cluster, dataCrunchMachine, instance)
+
+	if instance.State != "stopping" {
+		dataCrunchMachine.Status.StoppingSince = nil
+	}
 
 	switch instance.State {
 	case "running":
 		log.Info("DataCrunch instance is running", "instanceId", instance.ID)
-		dataCrunchMachine.Status.Ready = true
-		conditions.MarkTrue(dataCrunchMachine, infrav1beta1.InstanceReadyCondition)
 
-		// Set machine addresses
-		dataCrunchMachine.Status.Addresses = []clusterv1.MachineAddress{
-			{
-				Type:    clusterv1.MachineHostName,
-				Address: instance.Name,
-			},
+		// Some tags are dropped when an instance is stopped and started again, so re-assert them
+		if err := r.reconcileInstanceTags(ctx, log, dataCrunchClient, machine, dataCrunchMachine, cluster, instance); err != nil {
+			log.Error(err, "failed to re-apply instance tags")
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, "InstanceTagsReapplyFailed", "Failed to re-apply tags on instance %s: %v", instance.ID, err)
+			return reconcile.Result{RequeueAfter: r.errorRequeueInterval()}, nil
 		}
 
-		if instance.PrivateIP != "" {
-			dataCrunchMachine.Status.Addresses = append(dataCrunchMachine.Status.Addresses, clusterv1.MachineAddress{
-				Type:    clusterv1.MachineInternalIP,
-				Address: instance.PrivateIP,
-			})
+		if err := r.reconcileNodeCordon(ctx, log, machine, dataCrunchMachine, cluster); err != nil {
+			log.Error(err, "failed to reconcile node cordon")
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, "NodeCordonFailed", "Failed to reconcile the cordon of the Node: %v", err)
+			return reconcile.Result{RequeueAfter: r.errorRequeueInterval()}, nil
 		}
 
-		if instance.PublicIP != "" {
-			dataCrunchMachine.Status.Addresses = append(dataCrunchMachine.Status.Addresses, clusterv1.MachineAddress{
-				Type:    clusterv1.MachineExternalIP,
-				Address: instance.PublicIP,
-			})
+		// Set machine addresses
+		dataCrunchMachine.Status.Addresses = machineAddresses(instance)
+
+		// Instances may report running before their network is configured
+		if !hasRequiredAddresses(dataCrunchMachine, instance) {
+			log.Info("DataCrunch instance is running but has no IP address yet", "instanceId", instance.ID)
+			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.WaitingForInstanceAddressReason, clusterv1.ConditionSeverityInfo, "Instance is running but has no IP address yet")
+			return reconcile.Result{RequeueAfter: r.instancePollInterval()}, nil
 		}
 
+		conditions.MarkTrue(dataCrunchMachine, infrav1beta1.InstanceReadyCondition)
+
 	case "pending":
 		log.Info("DataCrunch instance is pending", "instanceId", instance.ID)
 		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceNotReadyReason, clusterv1.ConditionSeverityInfo, "Instance is pending")
-		return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+		return reconcile.Result{RequeueAfter: r.instanceStateRequeueAfter(infrav1beta1.InstanceStatePending)}, nil
 
 	case "stopped":
 		log.Info("DataCrunch instance is stopped, starting it", "instanceId", instance.ID)
+
+		// Stopping may release the public IP, so don't keep reporting it
+		dataCrunchMachine.Status.Addresses = machineAddresses(instance)
+
 		if err := dataCrunchClient.StartInstance(ctx, instance.ID); err != nil {
 			log.Error(err, "failed to start instance")
-			return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceNotReadyReason, clusterv1.ConditionSeverityWarning, "Failed to start instance: %v", err)
+			return reconcile.Result{RequeueAfter: r.errorRequeueInterval()}, nil
 		}
-		return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+		return reconcile.Result{RequeueAfter: r.instanceStateRequeueAfter(infrav1beta1.InstanceStateStopped)}, nil
+
+	case "stopping":
+		return r.reconcileStopping(ctx, log, dataCrunchClient, dataCrunchMachine, instance)
 
 	case "terminated":
 		log.Info("DataCrunch instance is terminated")
 		failureReason := capierrors.UpdateMachineError
 		failureMessage := "Instance was terminated"
+		if instance.InterruptionReason != "" {
+			failureMessage = fmt.Sprintf("Spot instance was reclaimed: %s", instance.InterruptionReason)
+		}
 		dataCrunchMachine.Status.FailureReason = &failureReason
 		dataCrunchMachine.Status.FailureMessage = &failureMessage
-		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceTerminatedReason, clusterv1.ConditionSeverityError, "Instance was terminated")
-		return reconcile.Result{}, nil
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceTerminatedReason, clusterv1.ConditionSeverityError, "%s", failureMessage)
+		return reconcile.Result{RequeueAfter: r.instanceStateRequeueAfter(infrav1beta1.InstanceStateTerminated)}, nil
 
 	default:
 		log.Info("DataCrunch instance is in unknown state", "state", instance.State, "instanceId", instance.ID)
 		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceNotReadyReason, clusterv1.ConditionSeverityWarning, fmt.Sprintf("Instance is in unknown state: %s", instance.State))
-		return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+		return reconcile.Result{RequeueAfter: r.instanceStateRequeueAfter(infrav1beta1.InstanceState(instance.State))}, nil
 	}
 
 	log.Info("Successfully reconciled DataCrunchMachine")
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: r.instanceStateRequeueAfter(infrav1beta1.InstanceStateRunning)}, nil
 }
 
 func (r *DataCrunchMachineReconciler) reconcileDelete(ctx context.Context, log logr.Logger, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) (reconcile.Result, error) {
 	log.Info("Reconciling DataCrunchMachine delete")
 
 	// Create DataCrunch client
-	dataCrunchClient, err := r.createDataCrunchClient(ctx, cluster)
+	dataCrunchClient, err := r.createDataCrunchClient(ctx, cluster, dataCrunchCluster, dataCrunchMachine)
 	if err != nil {
+		// Without a client the instance can't be looked up, so the finalizer is kept rather than orphaning it
 		log.Error(err, "failed to create DataCrunch client during deletion")
-		// Continue with deletion even if we can't create the client
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.DataCrunchClientFailedReason, clusterv1.ConditionSeverityError, err.Error())
+		return reconcile.Result{}, err
 	}
 
 	// Try to find and delete the instance
-	if dataCrunchClient != nil {
-		instance, err := r.findInstance(ctx, dataCrunchClient, dataCrunchMachine)
+	instance, err := r.findInstance(ctx, dataCrunchClient, machine, dataCrunchMachine, cluster)
+	if err != nil {
+		// The instance may still exist, so the finalizer is kept until it can be looked up
+		log.Error(err, "failed to find instance during deletion")
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceLookupFailedReason, clusterv1.ConditionSeverityWarning, "Failed to look up the instance: %v", err)
+		return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	if instance != nil {
+		// Pre-stop commands run first so that a snapshot includes what they checkpointed
+		done, err := r.reconcilePreStopHooks(ctx, log, machine, dataCrunchMachine, cluster)
+		if err != nil {
+			log.Error(err, "failed to run pre-stop commands")
+		}
+		if !done {
+			return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+
+		machineSet, err := r.scaledToZeroMachineSet(ctx, machine, dataCrunchMachine, cluster)
 		if err != nil {
-			log.Error(err, "failed to find instance during deletion")
-		} else if instance != nil {
+			log.Error(err, "failed to check whether the MachineSet of the machine was scaled to zero")
+			return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+		}
+
+		if machineSet != nil {
+			if err := r.stopInstance(ctx, log, dataCrunchClient, dataCrunchMachine, instance, machineSet); err != nil {
+				log.Error(err, "failed to stop instance")
+				return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+			}
+		} else {
+			snapshotted, err := r.reconcileSnapshotOnDelete(ctx, log, dataCrunchClient, dataCrunchMachine, instance)
+			if err != nil {
+				log.Error(err, "failed to snapshot instance before deletion")
+				return reconcile.Result{RequeueAfter: 30 * time.Second}, err
+			}
+			if !snapshotted {
+				return reconcile.Result{RequeueAfter: r.instancePollInterval()}, nil
+			}
+
 			log.Info("Deleting DataCrunch instance", "instanceId", instance.ID)
 			if err := dataCrunchClient.DeleteInstance(ctx, instance.ID); err != nil {
 				log.Error(err, "failed to delete instance")
 				return reconcile.Result{RequeueAfter: 30 * time.Second}, err
 			}
 			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "InstanceDeleted", "Deleted DataCrunch instance %s", instance.ID)
+			if rootVolume := dataCrunchMachine.Spec.RootVolume; rootVolume != nil && rootVolume.DeleteOnTermination != nil && !*rootVolume.DeleteOnTermination {
+				r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "RootVolumeRetained", "Retained root volume of DataCrunch instance %s", instance.ID)
+			}
 		}
 	}
 
+	// Leftover SSH keys are harmless for the deletion, so failures are only logged
+	if err := r.deleteOwnedSSHKeys(ctx, log, dataCrunchClient, dataCrunchMachine, dataCrunchCluster); err != nil {
+		log.Error(err, "failed to delete SSH keys created for the machine")
+	}
+
 	// Remove our finalizer from the list and update it
 	controllerutil.RemoveFinalizer(dataCrunchMachine, infrav1beta1.MachineFinalizer)
 
@@ -297,108 +796,1508 @@ func (r *DataCrunchMachineReconciler) reconcileDelete(ctx context.Context, log l
 	return reconcile.Result{}, nil
 }
 
-func (r *DataCrunchMachineReconciler) findInstance(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine) (*cloud.Instance, error) {
-	if dataCrunchMachine.Spec.ProviderID != nil {
-		// Extract instance ID from provider ID (format: datacrunch://instance-id)
-		instanceID := ""
-		if len(*dataCrunchMachine.Spec.ProviderID) > 13 { // len("datacrunch://") = 13
-			instanceID = (*dataCrunchMachine.Spec.ProviderID)[13:]
-		}
+// reconcileSnapshotOnDelete snapshots the instance before it is deleted when requested by the spec and
+// reports whether the instance can be deleted. The snapshot is only taken once: its ID is recorded in
+// the status and the deletion waits, across reconciles, until the snapshot is available.
+func (r *DataCrunchMachineReconciler) reconcileSnapshotOnDelete(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) (bool, error) {
+	if dataCrunchMachine.Spec.SnapshotOnDelete == nil || !*dataCrunchMachine.Spec.SnapshotOnDelete {
+		return true, nil
+	}
 
-		if instanceID != "" {
-			instance, err := dataCrunchClient.GetInstance(ctx, instanceID)
-			if err != nil {
-				// Instance not found is not an error during deletion
-				if err.Error() == fmt.Sprintf("instance not found: %s", instanceID) {
-					return nil, nil
-				}
-				return nil, err
-			}
-			return instance, nil
+	if dataCrunchMachine.Status.SnapshotID == "" {
+		snapshotName := fmt.Sprintf("%s-%d", dataCrunchMachine.Name, time.Now().Unix())
+		snapshot, err := dataCrunchClient.CreateInstanceSnapshot(ctx, instance.ID, snapshotName)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to create snapshot of instance %s", instance.ID)
 		}
+
+		// The ID is persisted before the instance is touched again, so a retried deletion doesn't take another snapshot
+		dataCrunchMachine.Status.SnapshotID = snapshot.ID
+		log.Info("Created snapshot of DataCrunch instance before deletion", "instanceId", instance.ID, "snapshotId", snapshot.ID)
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "SnapshotCreated", "Created snapshot %s of DataCrunch instance %s", snapshot.ID, instance.ID)
+		return false, nil
+	}
+
+	snapshot, err := dataCrunchClient.GetSnapshot(ctx, dataCrunchMachine.Status.SnapshotID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get snapshot %s of instance %s", dataCrunchMachine.Status.SnapshotID, instance.ID)
 	}
 
-	// If no provider ID, we can't find the instance
-	return nil, nil
+	switch snapshot.State {
+	case snapshotStateAvailable:
+		return true, nil
+	case snapshotStateError:
+		return false, errors.Errorf("snapshot %s of instance %s failed", snapshot.ID, instance.ID)
+	default:
+		log.Info("Waiting for snapshot to become available before deleting the instance", "instanceId", instance.ID, "snapshotId", snapshot.ID, "state", snapshot.State)
+		return false, nil
+	}
 }
 
-func (r *DataCrunchMachineReconciler) createInstance(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) (*cloud.Instance, error) {
-	// Get bootstrap data
-	userData, err := r.getBootstrapData(ctx, machine)
+// deleteOwnedSSHKeys deletes the SSH keys tagged as created for the DataCrunchMachine. Keys still used
+// by another DataCrunchMachine in the namespace, by name or as the default SSH key of the cluster, are
+// shared and kept.
+func (r *DataCrunchMachineReconciler) deleteOwnedSSHKeys(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	if dataCrunchMachine.UID == "" {
+		return nil
+	}
+
+	keys, err := dataCrunchClient.ListSSHKeys(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get bootstrap data")
+		return errors.Wrap(err, "failed to list SSH keys")
 	}
 
-	// Prepare instance specification
-	instanceSpec := &cloud.InstanceSpec{
-		Name:         dataCrunchMachine.Name,
-		InstanceType: dataCrunchMachine.Spec.InstanceType,
-		ImageID:      dataCrunchMachine.Spec.Image,
-		SSHKeyName:   dataCrunchMachine.Spec.SSHKeyName,
-		UserData:     userData,
-		Metadata:     dataCrunchMachine.Spec.AdditionalMetadata,
-		Tags:         dataCrunchMachine.Spec.AdditionalTags,
-		PublicIP:     dataCrunchMachine.Spec.PublicIP != nil && *dataCrunchMachine.Spec.PublicIP,
+	owned := cloud.FilterSSHKeysByTags(keys, map[string]string{idempotencyKeyTag: string(dataCrunchMachine.UID)})
+	if len(owned) == 0 {
+		return nil
 	}
 
-	// Set default image if not specified
-	if instanceSpec.ImageID == "" {
-		instanceSpec.ImageID = "ubuntu-22.04-cuda-12.1"
+	machines := &infrav1beta1.DataCrunchMachineList{}
+	if err := r.List(ctx, machines, client.InNamespace(dataCrunchMachine.Namespace)); err != nil {
+		return errors.Wrap(err, "failed to list DataCrunchMachines")
 	}
 
-	// Add cluster and machine labels to tags
-	if instanceSpec.Tags == nil {
-		instanceSpec.Tags = make(map[string]string)
+	inUse := make(map[string]bool, len(machines.Items))
+	for i := range machines.Items {
+		if machines.Items[i].UID == dataCrunchMachine.UID {
+			continue
+		}
+		if keyName := machineSSHKeyName(&machines.Items[i], dataCrunchCluster); keyName != "" {
+			inUse[keyName] = true
+		}
 	}
-	instanceSpec.Tags["cluster.x-k8s.io/cluster-name"] = cluster.Name
-	instanceSpec.Tags["cluster.x-k8s.io/machine-name"] = machine.Name
 
-	// Create the instance
-	instance, err := dataCrunchClient.CreateInstance(ctx, instanceSpec)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create DataCrunch instance")
+	for _, key := range owned {
+		if inUse[key.Name] {
+			log.Info("Keeping SSH key used by other machines", "sshKeyId", key.ID, "sshKeyName", key.Name)
+			continue
+		}
+
+		if err := dataCrunchClient.DeleteSSHKey(ctx, key.ID); err != nil {
+			return errors.Wrapf(err, "failed to delete SSH key %s", key.ID)
+		}
+		log.Info("Deleted SSH key created for the machine", "sshKeyId", key.ID, "sshKeyName", key.Name)
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "SSHKeyDeleted", "Deleted SSH key %s", key.Name)
 	}
 
-	return instance, nil
+	return nil
 }
 
-func (r *DataCrunchMachineReconciler) getBootstrapData(ctx context.Context, machine *clusterv1.Machine) (string, error) {
-	if machine.Spec.Bootstrap.DataSecretName == nil {
-		return "", errors.New("error retrieving bootstrap data: linked Machine's bootstrap.dataSecretName is nil")
+// reconcileImageDrift deletes the instance when its image differs from the spec and replacement is
+// allowed. It returns true when the instance was deleted so that the next reconcile re-creates it.
+func (r *DataCrunchMachineReconciler) reconcileImageDrift(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) (bool, error) {
+	desiredImage := dataCrunchMachine.Spec.Image
+	if desiredImage == "" {
+		desiredImage = r.defaultImage()
 	}
 
-	secret := &corev1.Secret{}
-	key := client.ObjectKey{Namespace: machine.Namespace, Name: *machine.Spec.Bootstrap.DataSecretName}
-	if err := r.Get(ctx, key, secret); err != nil {
-		return "", errors.Wrapf(err, "failed to retrieve bootstrap data secret for DataCrunchMachine %s/%s", machine.Namespace, machine.Name)
+	if instance.ImageID == "" || instance.ImageID == desiredImage {
+		return false, nil
 	}
 
-	value, ok := secret.Data["value"]
-	if !ok {
-		return "", errors.New("error retrieving bootstrap data: secret value key is missing")
+	if dataCrunchMachine.Spec.AllowImageReplacement == nil || !*dataCrunchMachine.Spec.AllowImageReplacement {
+		log.Info("DataCrunch instance image differs from spec, replacement is not allowed", "instanceId", instance.ID, "currentImage", instance.ImageID, "desiredImage", desiredImage)
+		return false, nil
+	}
+
+	log.Info("Replacing DataCrunch instance after image change", "instanceId", instance.ID, "currentImage", instance.ImageID, "desiredImage", desiredImage)
+	if err := r.replaceInstance(ctx, dataCrunchClient, dataCrunchMachine, instance, fmt.Sprintf("Replacing instance with image %s", desiredImage)); err != nil {
+		return false, err
 	}
+	r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "InstanceReplaced", "Deleted DataCrunch instance %s to replace image %s with %s", instance.ID, instance.ImageID, desiredImage)
 
-	return base64.StdEncoding.EncodeToString(value), nil
+	return true, nil
 }
 
-func (r *DataCrunchMachineReconciler) createDataCrunchClient(ctx context.Context, cluster *clusterv1.Cluster) (cloud.Client, error) {
-	// Get credentials from environment variables (for testing) or secrets (for production)
-	clientID := os.Getenv("DATACRUNCH_CLIENT_ID")
-	clientSecret := os.Getenv("DATACRUNCH_CLIENT_SECRET")
-	apiURL := os.Getenv("DATACRUNCH_API_URL")
+// reconcileBootstrapDataDrift deletes the instance when the bootstrap data of the Machine changed since
+// the instance was created and ReBootstrapOnChange is set. It returns true when the instance was deleted
+// so that the next reconcile re-creates it with the new bootstrap data.
+func (r *DataCrunchMachineReconciler) reconcileBootstrapDataDrift(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) (bool, error) {
+	if dataCrunchMachine.Spec.ReBootstrapOnChange == nil || !*dataCrunchMachine.Spec.ReBootstrapOnChange {
+		return false, nil
+	}
 
-	if clientID == "" {
-		clientID = "your-datacrunch-client-id" // fallback for development
+	bootstrapData, err := r.getBootstrapData(ctx, machine)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get bootstrap data")
 	}
-	if clientSecret == "" {
-		clientSecret = "your-datacrunch-client-secret" // fallback for development
+	desiredHash := bootstrapDataHash(bootstrapData)
+
+	// Instances created or adopted without a recorded hash are assumed to run the current bootstrap data
+	if dataCrunchMachine.Status.BootstrapDataHash == "" {
+		dataCrunchMachine.Status.BootstrapDataHash = desiredHash
+		return false, nil
+	}
+	if dataCrunchMachine.Status.BootstrapDataHash == desiredHash {
+		return false, nil
+	}
+
+	log.Info("Replacing DataCrunch instance after bootstrap data change", "instanceId", instance.ID, "currentHash", dataCrunchMachine.Status.BootstrapDataHash, "desiredHash", desiredHash)
+	if err := r.replaceInstance(ctx, dataCrunchClient, dataCrunchMachine, instance, "Replacing instance with new bootstrap data"); err != nil {
+		return false, err
+	}
+	r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "InstanceReplaced", "Deleted DataCrunch instance %s to apply the new bootstrap data of Machine %s", instance.ID, machine.Name)
+
+	return true, nil
+}
+
+// replaceInstance deletes the instance and clears its identity from the DataCrunchMachine, so that the next
+// reconcile creates a new instance from the spec.
+func (r *DataCrunchMachineReconciler) replaceInstance(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance, message string) error {
+	dataCrunchMachine.Status.Ready = false
+	conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceReplacingReason, clusterv1.ConditionSeverityInfo, "%s", message)
+
+	if err := dataCrunchClient.DeleteInstance(ctx, instance.ID); err != nil {
+		return errors.Wrapf(err, "failed to delete instance %s", instance.ID)
+	}
+
+	dataCrunchMachine.Spec.ProviderID = nil
+	dataCrunchMachine.Status.InstanceState = nil
+	dataCrunchMachine.Status.Addresses = nil
+	dataCrunchMachine.Status.InstanceCreatedAt = nil
+	dataCrunchMachine.Status.InterruptionReason = nil
+	dataCrunchMachine.Status.Region = ""
+	dataCrunchMachine.Status.ImageID = ""
+	dataCrunchMachine.Status.BootstrapDataHash = ""
+	dataCrunchMachine.Status.CreateOperationID = ""
+
+	return nil
+}
+
+// reconcileInstanceTypeMismatch reports in the InstanceTypeMatches condition whether the instance runs the
+// instance type it was requested with, as the API may provision another type than requested. Machines
+// created before the requested type was recorded may run any of their instance types.
+func (r *DataCrunchMachineReconciler) reconcileInstanceTypeMismatch(log logr.Logger, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) {
+	if instance.InstanceType == "" {
+		return
+	}
+
+	requested := []string{dataCrunchMachine.Status.InstanceType}
+	if dataCrunchMachine.Status.InstanceType == "" {
+		requested = append([]string{dataCrunchMachine.Spec.InstanceType}, dataCrunchMachine.Spec.InstanceTypeFallbacks...)
+	}
+	if slices.Contains(requested, instance.InstanceType) {
+		conditions.MarkTrue(dataCrunchMachine, infrav1beta1.InstanceTypeMatchesCondition)
+		return
+	}
+
+	// Keep the backfill from taking the provisioned type for the requested one
+	if dataCrunchMachine.Status.InstanceType == "" {
+		dataCrunchMachine.Status.InstanceType = dataCrunchMachine.Spec.InstanceType
+	}
+	if conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceTypeMatchesCondition) != infrav1beta1.InstanceTypeMismatchReason {
+		log.Info("DataCrunch instance runs another instance type than requested", "instanceId", instance.ID, "instanceType", instance.InstanceType, "requestedInstanceType", requested[0])
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.InstanceTypeMismatchReason, "Instance %s runs instance type %s instead of the requested %s", instance.ID, instance.InstanceType, requested[0])
+	}
+	conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceTypeMatchesCondition, infrav1beta1.InstanceTypeMismatchReason, clusterv1.ConditionSeverityWarning, "Instance runs instance type %s instead of the requested %s", instance.InstanceType, requested[0])
+}
+
+// backfillStatus fills the status fields recorded when an instance is created from the running instance,
+// as they are empty for machines created by an earlier version of the controller. It returns the names of
+// the fields it filled.
+func backfillStatus(dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) []string {
+	if instance.State != "running" {
+		return nil
+	}
+
+	var backfilled []string
+	backfill := func(name string, field *string, value string) {
+		if *field == "" && value != "" {
+			*field = value
+			backfilled = append(backfilled, name)
+		}
+	}
+	backfill("instanceType", &dataCrunchMachine.Status.InstanceType, instance.InstanceType)
+	backfill("region", &dataCrunchMachine.Status.Region, instance.Region)
+	backfill("imageID", &dataCrunchMachine.Status.ImageID, instance.ImageID)
+
+	return backfilled
+}
+
+// bootstrapDataHash returns the hex encoded SHA-256 hash of the bootstrap data
+func bootstrapDataHash(bootstrapData []byte) string {
+	sum := sha256.Sum256(bootstrapData)
+	return hex.EncodeToString(sum[:])
+}
+
+// reconcileSSHKeyDrift updates the SSH key of the instance when it differs from the spec. If the API
+// doesn't support changing the SSH key of an existing instance, the SSHKeySynced condition reports that
+// the machine must be replaced for the change to take effect.
+func (r *DataCrunchMachineReconciler) reconcileSSHKeyDrift(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, dataCrunchCluster *infrav1beta1.DataCrunchCluster, instance *cloud.Instance) error {
+	desiredSSHKey := machineSSHKeyName(dataCrunchMachine, dataCrunchCluster)
+	if desiredSSHKey == "" || instance.SSHKeyName == "" {
+		return nil
+	}
+
+	if instance.SSHKeyName == desiredSSHKey {
+		conditions.MarkTrue(dataCrunchMachine, infrav1beta1.SSHKeySyncedCondition)
+		return nil
+	}
+
+	err := dataCrunchClient.UpdateInstanceSSHKey(ctx, instance.ID, desiredSSHKey)
+	switch {
+	case errors.Is(err, cloud.ErrOperationNotSupported):
+		// Only warn once, not on every reconcile
+		if conditions.GetReason(dataCrunchMachine, infrav1beta1.SSHKeySyncedCondition) != infrav1beta1.SSHKeyReplacementRequiredReason {
+			log.Info("DataCrunch instance SSH key differs from spec and can't be updated in place", "instanceId", instance.ID, "currentSSHKey", instance.SSHKeyName, "desiredSSHKey", desiredSSHKey)
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.SSHKeyReplacementRequiredReason, "SSH key %s of instance %s can only be changed to %s by replacing the machine", instance.SSHKeyName, instance.ID, desiredSSHKey)
+		}
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.SSHKeySyncedCondition, infrav1beta1.SSHKeyReplacementRequiredReason, clusterv1.ConditionSeverityWarning, "SSH key %s can only be applied by replacing the machine", desiredSSHKey)
+		return nil
+	case err != nil:
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.SSHKeySyncedCondition, infrav1beta1.SSHKeyUpdateFailedReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+		return errors.Wrapf(err, "failed to update SSH key of instance %s", instance.ID)
+	}
+
+	log.Info("Updated DataCrunch instance SSH key", "instanceId", instance.ID, "previousSSHKey", instance.SSHKeyName, "sshKey", desiredSSHKey)
+	r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "SSHKeyUpdated", "Updated SSH key of instance %s from %s to %s", instance.ID, instance.SSHKeyName, desiredSSHKey)
+	conditions.MarkTrue(dataCrunchMachine, infrav1beta1.SSHKeySyncedCondition)
+
+	return nil
+}
+
+// reconcileSecurityGroupDrift updates the security groups of the instance when they differ from the
+// security groups of the network interfaces in the spec. If the API doesn't support changing them on an
+// existing instance, the SecurityGroupsSynced condition reports that the machine must be replaced.
+func (r *DataCrunchMachineReconciler) reconcileSecurityGroupDrift(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) error {
+	desiredSecurityGroups := machineSecurityGroupIDs(dataCrunchMachine)
+	if len(desiredSecurityGroups) == 0 || len(instance.SecurityGroupIDs) == 0 {
+		return nil
+	}
+
+	currentSecurityGroups := uniqueSorted(instance.SecurityGroupIDs)
+	if slices.Equal(currentSecurityGroups, desiredSecurityGroups) {
+		conditions.MarkTrue(dataCrunchMachine, infrav1beta1.SecurityGroupsSyncedCondition)
+		return nil
+	}
+
+	err := dataCrunchClient.UpdateInstanceSecurityGroups(ctx, instance.ID, desiredSecurityGroups)
+	switch {
+	case errors.Is(err, cloud.ErrOperationNotSupported):
+		// Only warn once, not on every reconcile
+		if conditions.GetReason(dataCrunchMachine, infrav1beta1.SecurityGroupsSyncedCondition) != infrav1beta1.SecurityGroupsReplacementRequiredReason {
+			log.Info("DataCrunch instance security groups differ from spec and can't be updated in place", "instanceId", instance.ID, "currentSecurityGroups", currentSecurityGroups, "desiredSecurityGroups", desiredSecurityGroups)
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.SecurityGroupsReplacementRequiredReason, "Security groups %v of instance %s can only be changed to %v by replacing the machine", currentSecurityGroups, instance.ID, desiredSecurityGroups)
+		}
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.SecurityGroupsSyncedCondition, infrav1beta1.SecurityGroupsReplacementRequiredReason, clusterv1.ConditionSeverityWarning, "Security groups %v can only be applied by replacing the machine", desiredSecurityGroups)
+		return nil
+	case err != nil:
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.SecurityGroupsSyncedCondition, infrav1beta1.SecurityGroupsUpdateFailedReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+		return errors.Wrapf(err, "failed to update security groups of instance %s", instance.ID)
+	}
+
+	log.Info("Updated DataCrunch instance security groups", "instanceId", instance.ID, "previousSecurityGroups", currentSecurityGroups, "securityGroups", desiredSecurityGroups)
+	r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "SecurityGroupsUpdated", "Updated security groups of instance %s from %v to %v", instance.ID, currentSecurityGroups, desiredSecurityGroups)
+	conditions.MarkTrue(dataCrunchMachine, infrav1beta1.SecurityGroupsSyncedCondition)
+
+	return nil
+}
+
+// reconcilePricing surfaces the current on-demand and spot price of the instance type in the machine status.
+func (r *DataCrunchMachineReconciler) reconcilePricing(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	instanceType := machineInstanceType(dataCrunchMachine)
+	price, err := dataCrunchClient.GetInstanceTypePrice(ctx, instanceType, machineRegion(dataCrunchMachine, dataCrunchCluster))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get price for instance type %s", instanceType)
+	}
+
+	now := metav1.Now()
+	dataCrunchMachine.Status.Pricing = &infrav1beta1.InstancePricing{
+		Currency:      price.Currency,
+		OnDemandPrice: strconv.FormatFloat(price.OnDemandPrice, 'f', -1, 64),
+		SpotPrice:     strconv.FormatFloat(price.SpotPrice, 'f', -1, 64),
+		LastUpdated:   &now,
+	}
+
+	return nil
+}
+
+// reconcileMetrics surfaces the latest resource utilization of a running instance in the machine status.
+// The status is cleared while the instance isn't running or DataCrunch reports no metrics for it.
+func (r *DataCrunchMachineReconciler) reconcileMetrics(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) error {
+	if instance.State != "running" {
+		dataCrunchMachine.Status.Metrics = nil
+		return nil
+	}
+
+	metrics, err := dataCrunchClient.GetInstanceMetrics(ctx, instance.ID)
+	if errors.Is(err, cloud.ErrOperationNotSupported) {
+		dataCrunchMachine.Status.Metrics = nil
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get metrics of instance %s", instance.ID)
+	}
+	if metrics == nil {
+		dataCrunchMachine.Status.Metrics = nil
+		return nil
+	}
+
+	// Prefer the time the metrics were sampled, so unchanged metrics don't change the status
+	lastUpdated := metav1.Now()
+	if sampledAt, err := time.Parse(time.RFC3339, metrics.Timestamp); err == nil {
+		lastUpdated = metav1.NewTime(sampledAt)
+	}
+	dataCrunchMachine.Status.Metrics = &infrav1beta1.InstanceMetrics{
+		GPUUtilization:    strconv.FormatFloat(metrics.GPUUtilization, 'f', -1, 64),
+		CPUUtilization:    strconv.FormatFloat(metrics.CPUUtilization, 'f', -1, 64),
+		MemoryUtilization: strconv.FormatFloat(metrics.MemoryUtilization, 'f', -1, 64),
+		LastUpdated:       &lastUpdated,
+	}
+
+	return nil
+}
+
+func (r *DataCrunchMachineReconciler) findInstance(ctx context.Context, dataCrunchClient cloud.Client, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster) (*cloud.Instance, error) {
+	if instanceID := providerInstanceID(dataCrunchMachine.Spec.ProviderID); instanceID != "" {
+		instance, err := dataCrunchClient.GetInstance(ctx, instanceID)
+		if err != nil {
+			// Instance not found is not an error during deletion
+			if errors.Is(err, cloud.ErrNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return instance, nil
+	}
+
+	// Without a provider ID, look for an instance created by an earlier reconcile whose provider ID
+	// was never persisted, e.g. because the controller restarted mid-create or the patch failed
+	if dataCrunchMachine.UID == "" && machine.Name == "" {
+		return nil, nil
+	}
+
+	instances, err := dataCrunchClient.ListInstances(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list instances")
+	}
+
+	return machineInstance(instances, machine, dataCrunchMachine, cluster), nil
+}
+
+// machineInstance returns the instance tagged with the DataCrunchMachine's UID or, failing that, the
+// instance tagged with the names of the machine and its cluster, e.g. created by a controller version
+// that didn't tag the UID yet. Instances tagged with the UID of another DataCrunchMachine never match,
+// and neither do instances being deleted, e.g. the old instance of a machine that is being replaced.
+func machineInstance(instances []*cloud.Instance, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster) *cloud.Instance {
+	instances = slices.DeleteFunc(slices.Clone(instances), instanceDeleting)
+
+	if key := string(dataCrunchMachine.UID); key != "" {
+		for _, instance := range instances {
+			if instance.Tags[idempotencyKeyTag] == key {
+				return instance
+			}
+		}
+	}
+
+	if machine.Name == "" {
+		return nil
+	}
+	for _, instance := range instances {
+		if key, ok := instance.Tags[idempotencyKeyTag]; ok && key != string(dataCrunchMachine.UID) {
+			continue
+		}
+		if instance.Tags["cluster.x-k8s.io/machine-name"] == machine.Name && instance.Tags[clusterv1.ClusterNameLabel] == cluster.Name {
+			return instance
+		}
+	}
+
+	return nil
+}
+
+// instanceDeleting reports whether the instance is shutting down or terminated.
+func instanceDeleting(instance *cloud.Instance) bool {
+	switch infrav1beta1.InstanceState(instance.State) {
+	case infrav1beta1.InstanceStateShuttingDown, infrav1beta1.InstanceStateTerminated:
+		return true
+	}
+	return false
+}
+
+// providerInstanceID extracts the instance ID from a provider ID of the form datacrunch://instance-id.
+func providerInstanceID(providerID *string) string {
+	if providerID == nil || len(*providerID) <= 13 { // len("datacrunch://") = 13
+		return ""
+	}
+	return (*providerID)[13:]
+}
+
+func (r *DataCrunchMachineReconciler) createInstance(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) (*cloud.Instance, error) {
+	// Validate resource overrides against the instance type before doing any work
+	if err := r.validateResourceOverrides(ctx, dataCrunchClient, dataCrunchMachine); err != nil {
+		return nil, errors.Wrap(err, "invalid resource overrides")
+	}
+	if err := r.validateRegion(ctx, dataCrunchClient, dataCrunchMachine); err != nil {
+		return nil, errors.Wrap(err, "invalid region")
+	}
+	if err := r.validateHardwareGeneration(ctx, dataCrunchClient, dataCrunchMachine); err != nil {
+		return nil, errors.Wrap(err, "invalid hardware generation")
+	}
+	availabilityZone, err := failureDomainAvailabilityZone(machine, dataCrunchCluster)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid failure domain")
+	}
+	if err := r.checkBudget(ctx, dataCrunchClient, dataCrunchMachine, cluster, dataCrunchCluster); err != nil {
+		return nil, err
+	}
+
+	// Get bootstrap data
+	bootstrapData, err := r.getBootstrapData(ctx, machine)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get bootstrap data")
+	}
+	// Hashed before any transform, so the hash only changes with the bootstrap secret
+	bootstrapHash := bootstrapDataHash(bootstrapData)
+
+	if r.BootstrapDataTransform != nil {
+		bootstrapData, err = r.BootstrapDataTransform(ctx, machine, bootstrapData)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to transform bootstrap data")
+		}
+	}
+
+	if dataCrunchMachine.Spec.StartupScriptRef != nil {
+		startupScript, err := r.getStartupScript(ctx, dataCrunchMachine)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get startup script")
+		}
+		bootstrapData, err = mergeUserData(bootstrapData, startupScript)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to merge startup script into user data")
+		}
+	}
+	userData := base64.StdEncoding.EncodeToString(bootstrapData)
+
+	// Catch corrupted user data here rather than with an instance that never joins the cluster
+	validateUserDataFunc := validateUserData
+	if r.UserDataValidator != nil {
+		validateUserDataFunc = r.UserDataValidator
+	}
+	if err := validateUserDataFunc(userData); err != nil {
+		return nil, errors.Wrap(err, "invalid user data")
+	}
+	if !r.uncompressedUserData(dataCrunchMachine) {
+		compressed, err := gzipUserData(bootstrapData)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to compress user data")
+		}
+		userData = base64.StdEncoding.EncodeToString(compressed)
+	}
+
+	if err := r.ensureSSHKey(ctx, log, dataCrunchClient, dataCrunchMachine, dataCrunchCluster, cluster); err != nil {
+		return nil, err
+	}
+
+	// Prepare instance specification
+	instanceSpec := &cloud.InstanceSpec{
+		Name:         dataCrunchMachine.Name,
+		InstanceType: dataCrunchMachine.Spec.InstanceType,
+		ImageID:      dataCrunchMachine.Spec.Image,
+		SSHKeyName:   machineSSHKeyName(dataCrunchMachine, dataCrunchCluster),
+		UserData:     userData,
+		Metadata:     expandMetadata(dataCrunchMachine.Spec.AdditionalMetadata, machine, cluster),
+		Tags:         desiredInstanceTags(machine, dataCrunchMachine, cluster),
+		Labels:       dataCrunchMachine.Spec.AdditionalLabels,
+		PublicIP:     dataCrunchMachine.Spec.PublicIP != nil && *dataCrunchMachine.Spec.PublicIP,
+		Region:       machineRegion(dataCrunchMachine, dataCrunchCluster),
+	}
+
+	// The root volume is deleted with the instance unless explicitly retained
+	instanceSpec.RootVolume = &cloud.VolumeSpec{DeleteOnTermination: true}
+	if rootVolume := dataCrunchMachine.Spec.RootVolume; rootVolume != nil {
+		instanceSpec.RootVolume.SizeGB = rootVolume.Size
+		instanceSpec.RootVolume.Type = rootVolume.Type
+		if rootVolume.DeleteOnTermination != nil {
+			instanceSpec.RootVolume.DeleteOnTermination = *rootVolume.DeleteOnTermination
+		}
+	}
+
+	// Cluster API spreads machines across the failure domains, which are the availability zones of the cluster
+	instanceSpec.AvailabilityZone = availabilityZone
+
+	if dataCrunchMachine.Spec.AntiAffinityGroup != nil {
+		instanceSpec.AntiAffinityGroup = *dataCrunchMachine.Spec.AntiAffinityGroup
+	}
+
+	if dataCrunchMachine.Spec.HardwareGeneration != nil {
+		instanceSpec.HardwareGeneration = *dataCrunchMachine.Spec.HardwareGeneration
+	}
+
+	if spot := dataCrunchMachine.Spec.Spot; spot != nil {
+		instanceSpec.Spot = &cloud.SpotConfig{}
+		if spot.MaxPrice != nil {
+			instanceSpec.Spot.MaxPrice = *spot.MaxPrice
+		}
+	}
+
+	for _, nic := range dataCrunchMachine.Spec.NetworkInterfaces {
+		networkInterface := cloud.NetworkInterfaceSpec{
+			SubnetID:            nic.SubnetID,
+			DeviceIndex:         nic.DeviceIndex,
+			AssociatePublicIP:   nic.AssociatePublicIPAddress,
+			DeleteOnTermination: nic.DeleteOnTermination,
+			SecurityGroupIDs:    nic.SecurityGroupIDs,
+		}
+		if nic.SecondaryPrivateIPAddressCount != nil {
+			networkInterface.SecondaryPrivateIPCount = *nic.SecondaryPrivateIPAddressCount
+		}
+		instanceSpec.NetworkInterfaces = append(instanceSpec.NetworkInterfaces, networkInterface)
+	}
+
+	if dataCrunchMachine.Spec.VCPUs != nil {
+		instanceSpec.VCPUs = int(*dataCrunchMachine.Spec.VCPUs)
+	}
+	if dataCrunchMachine.Spec.MemoryGB != nil {
+		instanceSpec.MemoryGB = int(*dataCrunchMachine.Spec.MemoryGB)
+	}
+
+	// The defaulting webhook sets the image, so only machines admitted without it get here. Make the
+	// substitution visible to users.
+	if instanceSpec.ImageID == "" {
+		instanceSpec.ImageID = r.defaultImage()
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.ImageSpecifiedCondition, infrav1beta1.DefaultImageAppliedReason, clusterv1.ConditionSeverityInfo, "No image specified, using default image %s", instanceSpec.ImageID)
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.DefaultImageAppliedReason, "No image specified, using default image %s", instanceSpec.ImageID)
+	} else {
+		conditions.MarkTrue(dataCrunchMachine, infrav1beta1.ImageSpecifiedCondition)
+	}
+
+	// Try the instance types in order, moving on to the next one when a type has no capacity
+	instanceTypes := append([]string{dataCrunchMachine.Spec.InstanceType}, dataCrunchMachine.Spec.InstanceTypeFallbacks...)
+	for i, instanceType := range instanceTypes {
+		last := i == len(instanceTypes)-1
+
+		// Availability only matters when there is another type to fall back to
+		if len(instanceTypes) > 1 {
+			available, err := dataCrunchClient.IsInstanceTypeAvailable(ctx, instanceType, instanceSpec.Region)
+			if err != nil {
+				log.Error(err, "failed to check instance type availability, trying it anyway", "instanceType", instanceType)
+			} else if !available {
+				log.Info("Instance type is unavailable", "instanceType", instanceType)
+				continue
+			}
+		}
+
+		typeSpec := *instanceSpec
+		typeSpec.InstanceType = instanceType
+		rootVolume := *instanceSpec.RootVolume
+		if rootVolume.SizeGB == 0 {
+			rootVolume.SizeGB = defaultRootVolumeSizeGB(instanceType)
+		}
+		typeSpec.RootVolume = &rootVolume
+		if i > 0 {
+			typeSpec.VCPUs, typeSpec.MemoryGB = 0, 0
+		}
+
+		instance, err := r.submitInstance(ctx, log, dataCrunchClient, machine, dataCrunchMachine, cluster, &typeSpec)
+		if cloud.IsCapacityError(err) {
+			if last {
+				return nil, insufficientCapacity(ctx, log, dataCrunchClient, instanceTypes, instanceSpec.Region, err)
+			}
+			log.Info("Instance type has no capacity left", "instanceType", instanceType)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		dataCrunchMachine.Status.InstanceType = instanceType
+		dataCrunchMachine.Status.Region = instance.Region
+		dataCrunchMachine.Status.ImageID = instance.ImageID
+		dataCrunchMachine.Status.BootstrapDataHash = bootstrapHash
+		dataCrunchMachine.Status.CreateOperationID = instance.OperationID
+		if i > 0 {
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "InstanceTypeFallback", "Instance type %s is unavailable, created instance with %s", dataCrunchMachine.Spec.InstanceType, instanceType)
+		}
+		return instance, nil
+	}
+
+	return nil, insufficientCapacity(ctx, log, dataCrunchClient, instanceTypes, instanceSpec.Region, errors.Errorf("none of the instance types %v is available", instanceTypes))
+}
+
+// submitInstance requests the creation of an instance, looking it up by its tags if the API accepted
+// the request without returning the instance ID.
+func (r *DataCrunchMachineReconciler) submitInstance(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster, instanceSpec *cloud.InstanceSpec) (*cloud.Instance, error) {
+	instance, err := dataCrunchClient.CreateInstance(ctx, instanceSpec)
+	if errors.Is(err, cloud.ErrMissingInstanceID) {
+		// The instance was accepted, so look it up by the tags it was created with
+		log.Info("Create instance response did not include an instance ID, looking up the instance by its tags")
+		instances, err := dataCrunchClient.ListInstances(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to look up created DataCrunch instance")
+		}
+		instance := machineInstance(instances, machine, dataCrunchMachine, cluster)
+		if instance == nil {
+			return nil, errors.Wrap(cloud.ErrMissingInstanceID, "failed to find created DataCrunch instance")
+		}
+		return instance, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create DataCrunch instance")
+	}
+
+	return instance, nil
+}
+
+// reconcileStopping waits for a stopping instance with an increasing backoff, and requests a forced stop
+// once the instance has been stopping for longer than stoppingTimeout.
+func (r *DataCrunchMachineReconciler) reconcileStopping(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) (reconcile.Result, error) {
+	if dataCrunchMachine.Status.StoppingSince == nil {
+		now := metav1.Now()
+		dataCrunchMachine.Status.StoppingSince = &now
+	}
+	stoppingFor := time.Since(dataCrunchMachine.Status.StoppingSince.Time)
+
+	if stoppingFor < stoppingTimeout {
+		log.Info("DataCrunch instance is stopping", "instanceId", instance.ID, "stoppingFor", stoppingFor.Round(time.Second))
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceStoppingReason, clusterv1.ConditionSeverityInfo, "Instance is stopping")
+
+		// Back off the longer the instance has been stopping
+		requeueAfter := stoppingFor
+		if requeueAfter < minStoppingRequeueAfter {
+			requeueAfter = minStoppingRequeueAfter
+		}
+		if requeueAfter > maxStoppingRequeueAfter {
+			requeueAfter = maxStoppingRequeueAfter
+		}
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	log.Info("DataCrunch instance is stuck stopping, forcing it to stop", "instanceId", instance.ID, "stoppingFor", stoppingFor.Round(time.Second))
+	conditions.MarkFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition, infrav1beta1.InstanceStuckStoppingReason, clusterv1.ConditionSeverityWarning, "Instance has been stopping for %s, forcing it to stop", stoppingFor.Round(time.Second))
+	r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.InstanceStuckStoppingReason, "Instance %s has been stopping for %s, forcing it to stop", instance.ID, stoppingFor.Round(time.Second))
+
+	if err := dataCrunchClient.ForceStopInstance(ctx, instance.ID); err != nil {
+		log.Error(err, "failed to force stop instance")
+		return reconcile.Result{RequeueAfter: r.errorRequeueInterval()}, nil
+	}
+
+	return reconcile.Result{RequeueAfter: r.instancePollInterval()}, nil
+}
+
+// computeReady reports whether the machine is ready: its bootstrap data is available, the cluster
+// infrastructure is ready, the instance is running with its IP addresses assigned and, if GPU health
+// is reported, the GPUs are healthy.
+func computeReady(machine *clusterv1.Machine, cluster *clusterv1.Cluster, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) bool {
+	if machine.Spec.Bootstrap.DataSecretName == nil {
+		return false
+	}
+	if !cluster.Status.InfrastructureReady {
+		return false
+	}
+	if instance == nil || instance.State != "running" {
+		return false
+	}
+	if !hasRequiredAddresses(dataCrunchMachine, instance) {
+		return false
+	}
+	// GPU health is optional, an absent condition means it isn't checked
+	if conditions.Has(dataCrunchMachine, infrav1beta1.GPUHealthyCondition) && !conditions.IsTrue(dataCrunchMachine, infrav1beta1.GPUHealthyCondition) {
+		return false
+	}
+	return true
+}
+
+// hasRequiredAddresses reports whether the instance has a private IP and, if one was requested, a public IP.
+func hasRequiredAddresses(dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance) bool {
+	if instance.PrivateIP == "" {
+		return false
+	}
+	if dataCrunchMachine.Spec.PublicIP != nil && *dataCrunchMachine.Spec.PublicIP && instance.PublicIP == "" {
+		return false
+	}
+	return true
+}
+
+// desiredInstanceTags returns the tags an instance must carry: the additional tags from the spec plus
+// the tags identifying its cluster, machine and DataCrunchMachine.
+func desiredInstanceTags(machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster) map[string]string {
+	tags := make(map[string]string, len(dataCrunchMachine.Spec.AdditionalTags)+3)
+	for k, v := range dataCrunchMachine.Spec.AdditionalTags {
+		tags[k] = v
+	}
+	tags["cluster.x-k8s.io/cluster-name"] = cluster.Name
+	tags["cluster.x-k8s.io/machine-name"] = machine.Name
+
+	// Tag the instance with the machine UID so it can be found again if the provider ID is lost
+	if dataCrunchMachine.UID != "" {
+		tags[idempotencyKeyTag] = string(dataCrunchMachine.UID)
+	}
+
+	return tags
+}
+
+// expandMetadata returns the metadata with the ${machine.name}, ${machine.namespace} and ${cluster.name}
+// placeholders of its values replaced. Expanded values aren't expanded again, so a name containing a
+// placeholder is passed through literally, as is any other text.
+func expandMetadata(metadata map[string]string, machine *clusterv1.Machine, cluster *clusterv1.Cluster) map[string]string {
+	if len(metadata) == 0 {
+		return metadata
+	}
+
+	replacer := strings.NewReplacer(
+		"${machine.name}", machine.Name,
+		"${machine.namespace}", machine.Namespace,
+		"${cluster.name}", cluster.Name,
+	)
+	expanded := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		expanded[k] = replacer.Replace(v)
+	}
+	return expanded
+}
+
+// reconcileInstanceTags re-applies any desired tag that is missing from or differs on the instance.
+// Tags set on the instance outside of the spec are kept.
+func (r *DataCrunchMachineReconciler) reconcileInstanceTags(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster, instance *cloud.Instance) error {
+	desired := desiredInstanceTags(machine, dataCrunchMachine, cluster)
+
+	tags := make(map[string]string, len(instance.Tags)+len(desired))
+	for k, v := range instance.Tags {
+		tags[k] = v
+	}
+
+	changed := false
+	for k, v := range desired {
+		if current, ok := tags[k]; !ok || current != v {
+			tags[k] = v
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := dataCrunchClient.UpdateInstanceTags(ctx, instance.ID, tags); err != nil {
+		return errors.Wrapf(err, "failed to update tags of instance %s", instance.ID)
+	}
+	instance.Tags = tags
+
+	log.Info("Re-applied instance tags", "instanceId", instance.ID)
+	r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "InstanceTagsReapplied", "Re-applied tags on instance %s", instance.ID)
+	return nil
+}
+
+// machineAddresses builds the machine addresses from the current state of the instance.
+func machineAddresses(instance *cloud.Instance) []clusterv1.MachineAddress {
+	addresses := []clusterv1.MachineAddress{
+		{
+			Type:    clusterv1.MachineHostName,
+			Address: instance.Name,
+		},
+	}
+
+	if instance.PrivateIP != "" {
+		addresses = append(addresses, clusterv1.MachineAddress{
+			Type:    clusterv1.MachineInternalIP,
+			Address: instance.PrivateIP,
+		})
+	}
+
+	if instance.PublicIP != "" {
+		addresses = append(addresses, clusterv1.MachineAddress{
+			Type:    clusterv1.MachineExternalIP,
+			Address: instance.PublicIP,
+		})
+	}
+
+	return addresses
+}
+
+// instanceCreatedAt parses the RFC3339 creation timestamp of an instance, nil when DataCrunch didn't report a valid one
+func instanceCreatedAt(instance *cloud.Instance) *metav1.Time {
+	if instance.CreatedAt == "" {
+		return nil
+	}
+	createdAt, err := time.Parse(time.RFC3339, instance.CreatedAt)
+	if err != nil {
+		return nil
+	}
+	t := metav1.NewTime(createdAt)
+	return &t
+}
+
+// reconcileNodeLabels sets the GPU node labels derived from the instance type on the owning Machine,
+// from where Cluster API propagates them to the Node.
+func (r *DataCrunchMachineReconciler) reconcileNodeLabels(ctx context.Context, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine) error {
+	labels := gpuNodeLabels(machineInstanceType(dataCrunchMachine))
+	if len(labels) == 0 {
+		return nil
+	}
+
+	changed := false
+	for k, v := range labels {
+		if machine.Labels[k] != v {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	original := machine.DeepCopy()
+	if machine.Labels == nil {
+		machine.Labels = make(map[string]string, len(labels))
+	}
+	for k, v := range labels {
+		machine.Labels[k] = v
+	}
+	return r.Patch(ctx, machine, client.MergeFrom(original))
+}
+
+// reconcileNodeCordon cordons the Node of a paused machine and uncordons it once the machine is unpaused.
+// Only Nodes cordoned by the controller are uncordoned, so manual cordons are left alone.
+func (r *DataCrunchMachineReconciler) reconcileNodeCordon(ctx context.Context, log logr.Logger, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster) error {
+	paused := dataCrunchMachine.Spec.Paused != nil && *dataCrunchMachine.Spec.Paused
+	cordon := r.CordonPausedNodes && paused
+	if cordon == dataCrunchMachine.Status.NodeCordoned || machine.Status.NodeRef == nil {
+		return nil
+	}
+
+	workloadClient, err := r.workloadClusterClient(ctx, cluster)
+	if err != nil {
+		return errors.Wrap(err, "failed to create workload cluster client")
+	}
+
+	node := &corev1.Node{}
+	if err := workloadClient.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+		return errors.Wrapf(err, "failed to get Node %s", machine.Status.NodeRef.Name)
+	}
+
+	if node.Spec.Unschedulable != cordon {
+		original := node.DeepCopy()
+		node.Spec.Unschedulable = cordon
+		if err := workloadClient.Patch(ctx, node, client.MergeFrom(original)); err != nil {
+			return errors.Wrapf(err, "failed to patch Node %s", node.Name)
+		}
+	}
+	dataCrunchMachine.Status.NodeCordoned = cordon
+
+	if cordon {
+		log.Info("Cordoned Node of paused machine", "node", node.Name)
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "NodeCordoned", "Cordoned Node %s while the machine is paused", node.Name)
+	} else {
+		log.Info("Uncordoned Node of unpaused machine", "node", node.Name)
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "NodeUncordoned", "Uncordoned Node %s after the machine was unpaused", node.Name)
+	}
+	return nil
+}
+
+// workloadClusterClient returns a client for the workload cluster, built from its kubeconfig secret by default.
+func (r *DataCrunchMachineReconciler) workloadClusterClient(ctx context.Context, cluster *clusterv1.Cluster) (client.Client, error) {
+	if r.WorkloadClusterClient != nil {
+		return r.WorkloadClusterClient(ctx, cluster)
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name + kubeconfigSecretSuffix}
+	if err := r.Get(ctx, key, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to get kubeconfig secret %s", key)
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(secret.Data[kubeconfigSecretKey])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse kubeconfig secret %s", key)
+	}
+
+	return client.New(restConfig, client.Options{Scheme: r.Scheme})
+}
+
+// parseGPUInstanceType returns the GPU count and model of a DataCrunch instance type,
+// or zero and an empty model for CPU-only types.
+func parseGPUInstanceType(instanceType string) (int, string) {
+	match := gpuInstanceTypePattern.FindStringSubmatch(instanceType)
+	if match == nil {
+		return 0, ""
+	}
+	count, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, ""
+	}
+	return count, match[2]
+}
+
+// defaultRootVolumeSizeGB returns the root volume size used for an instance type when none is configured.
+func defaultRootVolumeSizeGB(instanceType string) int64 {
+	_, model := parseGPUInstanceType(instanceType)
+	if size, ok := defaultRootVolumeSizes[model]; ok {
+		return size
+	}
+	return fallbackRootVolumeSizeGB
+}
+
+// gpuNodeLabels computes the node labels advertising the GPU model and count of an instance type.
+func gpuNodeLabels(instanceType string) map[string]string {
+	count, model := parseGPUInstanceType(instanceType)
+	if count == 0 {
+		return nil
+	}
+	return map[string]string{
+		gpuModelNodeLabel: model,
+		gpuCountNodeLabel: strconv.Itoa(count),
+	}
+}
+
+// validateResourceOverrides checks the VCPUs/MemoryGB overrides against the ranges allowed by the instance type.
+func (r *DataCrunchMachineReconciler) validateResourceOverrides(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine) error {
+	vcpus := dataCrunchMachine.Spec.VCPUs
+	memoryGB := dataCrunchMachine.Spec.MemoryGB
+	if vcpus == nil && memoryGB == nil {
+		return nil
+	}
+
+	if dataCrunchClient == nil {
+		return errors.New("DataCrunch client is required to validate resource overrides")
+	}
+
+	instanceTypes, err := dataCrunchClient.ListInstanceTypes(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list instance types")
+	}
+
+	var instanceType *cloud.InstanceType
+	for _, it := range instanceTypes {
+		if it.Name == dataCrunchMachine.Spec.InstanceType {
+			instanceType = it
+			break
+		}
+	}
+	if instanceType == nil {
+		return errors.Errorf("instance type %s not found", dataCrunchMachine.Spec.InstanceType)
+	}
+
+	if !instanceType.Customizable {
+		return errors.Errorf("instance type %s does not support vCPU/memory customization", instanceType.Name)
+	}
+
+	if vcpus != nil && (int(*vcpus) < instanceType.MinVCPUs || int(*vcpus) > instanceType.MaxVCPUs) {
+		return errors.Errorf("vcpus %d is outside the allowed range [%d, %d] for instance type %s", *vcpus, instanceType.MinVCPUs, instanceType.MaxVCPUs, instanceType.Name)
+	}
+
+	if memoryGB != nil && (int(*memoryGB) < instanceType.MinMemoryGB || int(*memoryGB) > instanceType.MaxMemoryGB) {
+		return errors.Errorf("memoryGB %d is outside the allowed range [%d, %d] for instance type %s", *memoryGB, instanceType.MinMemoryGB, instanceType.MaxMemoryGB, instanceType.Name)
+	}
+
+	return nil
+}
+
+// validateHardwareGeneration checks that the hardware generation of a machine is one of the generations
+// its instance type is offered in.
+func (r *DataCrunchMachineReconciler) validateHardwareGeneration(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine) error {
+	if dataCrunchMachine.Spec.HardwareGeneration == nil {
+		return nil
+	}
+	generation := *dataCrunchMachine.Spec.HardwareGeneration
+
+	if dataCrunchClient == nil {
+		return errors.New("DataCrunch client is required to validate the hardware generation")
+	}
+
+	instanceTypes, err := dataCrunchClient.ListInstanceTypes(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list instance types")
+	}
+
+	for _, it := range instanceTypes {
+		if it.Name != dataCrunchMachine.Spec.InstanceType {
+			continue
+		}
+		if slices.Contains(it.HardwareGenerations, generation) {
+			return nil
+		}
+		return errors.Errorf("hardware generation %s is not available for instance type %s, must be one of %v", generation, it.Name, it.HardwareGenerations)
+	}
+
+	return errors.Errorf("instance type %s not found", dataCrunchMachine.Spec.InstanceType)
+}
+
+// validateRegion checks that the region override of a machine is one of the regions available to the account.
+func (r *DataCrunchMachineReconciler) validateRegion(ctx context.Context, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine) error {
+	region := dataCrunchMachine.Spec.Region
+	if region == "" {
+		return nil
+	}
+
+	if dataCrunchClient == nil {
+		return errors.New("DataCrunch client is required to validate the region")
+	}
+
+	locations, err := dataCrunchClient.ListLocations(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list locations")
+	}
+
+	codes := make([]string, 0, len(locations))
+	for _, location := range locations {
+		if location.Code == region {
+			return nil
+		}
+		codes = append(codes, location.Code)
+	}
+
+	return errors.Errorf("region %s is not available, must be one of %v", region, codes)
+}
+
+// machineRegion returns the region of a machine, which defaults to the region of its cluster.
+func machineRegion(dataCrunchMachine *infrav1beta1.DataCrunchMachine, dataCrunchCluster *infrav1beta1.DataCrunchCluster) string {
+	if dataCrunchMachine.Spec.Region != "" {
+		return dataCrunchMachine.Spec.Region
+	}
+	return dataCrunchCluster.Spec.Region
+}
+
+// failureDomainAvailabilityZone returns the availability zone of the failure domain Cluster API placed a
+// machine in, which is the name of the failure domain. It is empty for machines without a failure domain
+// or in the default failure domain, and fails for a failure domain the cluster doesn't offer.
+func failureDomainAvailabilityZone(machine *clusterv1.Machine, dataCrunchCluster *infrav1beta1.DataCrunchCluster) (string, error) {
+	if machine.Spec.FailureDomain == nil || *machine.Spec.FailureDomain == "" {
+		return "", nil
+	}
+	failureDomain := *machine.Spec.FailureDomain
+
+	if _, ok := dataCrunchCluster.Status.FailureDomains[failureDomain]; !ok {
+		failureDomains := make([]string, 0, len(dataCrunchCluster.Status.FailureDomains))
+		for name := range dataCrunchCluster.Status.FailureDomains {
+			failureDomains = append(failureDomains, name)
+		}
+		sort.Strings(failureDomains)
+		return "", errors.Errorf("failure domain %s is not one of the failure domains %v of the cluster", failureDomain, failureDomains)
+	}
+	if failureDomain == defaultFailureDomain {
+		return "", nil
+	}
+
+	return failureDomain, nil
+}
+
+// machineInstanceType returns the instance type a machine was created with, which is its spec instance
+// type unless one of the fallbacks was used.
+func machineInstanceType(dataCrunchMachine *infrav1beta1.DataCrunchMachine) string {
+	if dataCrunchMachine.Status.InstanceType != "" {
+		return dataCrunchMachine.Status.InstanceType
+	}
+	return dataCrunchMachine.Spec.InstanceType
+}
+
+// machineSecurityGroupIDs returns the sorted security groups of all network interfaces of a machine.
+func machineSecurityGroupIDs(dataCrunchMachine *infrav1beta1.DataCrunchMachine) []string {
+	var securityGroups []string
+	for _, nic := range dataCrunchMachine.Spec.NetworkInterfaces {
+		securityGroups = append(securityGroups, nic.SecurityGroupIDs...)
+	}
+	return uniqueSorted(securityGroups)
+}
+
+// uniqueSorted returns the sorted distinct values.
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// machineSSHKeyName returns the SSH key name of a machine, which defaults to the default SSH key name of its cluster.
+func machineSSHKeyName(dataCrunchMachine *infrav1beta1.DataCrunchMachine, dataCrunchCluster *infrav1beta1.DataCrunchCluster) string {
+	if dataCrunchMachine.Spec.SSHKeyName != "" {
+		return dataCrunchMachine.Spec.SSHKeyName
+	}
+	return dataCrunchCluster.Spec.DefaultSSHKeyName
+}
+
+func (r *DataCrunchMachineReconciler) getBootstrapData(ctx context.Context, machine *clusterv1.Machine) ([]byte, error) {
+	if machine.Spec.Bootstrap.DataSecretName == nil {
+		return nil, errors.Wrap(ErrBootstrapNotReady, "error retrieving bootstrap data: linked Machine's bootstrap.dataSecretName is nil")
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: machine.Namespace, Name: *machine.Spec.Bootstrap.DataSecretName}
+	if err := r.Get(ctx, key, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve bootstrap data secret for DataCrunchMachine %s/%s", machine.Namespace, machine.Name)
+	}
+
+	value, ok := secret.Data["value"]
+	if !ok {
+		return nil, errors.Wrap(ErrBootstrapDataMissing, "error retrieving bootstrap data")
+	}
+
+	return value, nil
+}
+
+// getStartupScript reads the startup script from the Secret referenced by the machine's StartupScriptRef.
+func (r *DataCrunchMachineReconciler) getStartupScript(ctx context.Context, dataCrunchMachine *infrav1beta1.DataCrunchMachine) ([]byte, error) {
+	ref := dataCrunchMachine.Spec.StartupScriptRef
+
+	if ref.Namespace != "" && ref.Namespace != dataCrunchMachine.Namespace {
+		return nil, errors.Errorf("startup script secret %s/%s must be in the DataCrunchMachine namespace %s", ref.Namespace, ref.Name, dataCrunchMachine.Namespace)
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: dataCrunchMachine.Namespace, Name: ref.Name}
+	if err := r.Get(ctx, key, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve startup script secret %s", key)
+	}
+
+	script, ok := secret.Data[startupScriptKey]
+	if !ok || len(script) == 0 {
+		return nil, errors.Errorf("startup script secret %s is missing the %q key", key, startupScriptKey)
+	}
+
+	// cloud-init only runs scripts that declare their interpreter
+	if !bytes.HasPrefix(script, []byte("#!")) {
+		return nil, errors.Errorf("startup script in secret %s must start with a shebang line", key)
+	}
+
+	return script, nil
+}
+
+// ensureSSHKey creates the SSH key of the machine from the public key in the Secret referenced by its
+// SSHPublicKeySecretRef, unless DataCrunch already has a key with that name. The created key is tagged for
+// the DataCrunchMachine so it is deleted with it.
+func (r *DataCrunchMachineReconciler) ensureSSHKey(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, dataCrunchCluster *infrav1beta1.DataCrunchCluster, cluster *clusterv1.Cluster) error {
+	ref := dataCrunchMachine.Spec.SSHPublicKeySecretRef
+	if ref == nil {
+		return nil
+	}
+	keyName := machineSSHKeyName(dataCrunchMachine, dataCrunchCluster)
+	if keyName == "" {
+		return errors.New("sshPublicKeySecretRef requires sshKeyName or the defaultSSHKeyName of the cluster")
+	}
+
+	keys, err := dataCrunchClient.ListSSHKeys(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list SSH keys")
+	}
+	for _, key := range keys {
+		if key.Name == keyName {
+			return nil
+		}
+	}
+
+	publicKey, err := r.getSSHPublicKey(ctx, dataCrunchMachine)
+	if err != nil {
+		return errors.Wrap(err, "failed to get SSH public key")
+	}
+
+	tags := map[string]string{clusterv1.ClusterNameLabel: cluster.Name}
+	if dataCrunchMachine.UID != "" {
+		tags[idempotencyKeyTag] = string(dataCrunchMachine.UID)
+	}
+	key, err := dataCrunchClient.CreateSSHKey(ctx, keyName, publicKey, tags)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create SSH key %s", keyName)
+	}
+
+	log.Info("Created SSH key from secret", "sshKeyId", key.ID, "sshKeyName", keyName, "secret", ref.Name)
+	r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "SSHKeyCreated", "Created SSH key %s from secret %s", keyName, ref.Name)
+	return nil
+}
+
+// getSSHPublicKey reads the SSH public key from the Secret referenced by the machine's SSHPublicKeySecretRef.
+func (r *DataCrunchMachineReconciler) getSSHPublicKey(ctx context.Context, dataCrunchMachine *infrav1beta1.DataCrunchMachine) (string, error) {
+	ref := dataCrunchMachine.Spec.SSHPublicKeySecretRef
+
+	if ref.Namespace != "" && ref.Namespace != dataCrunchMachine.Namespace {
+		return "", errors.Errorf("SSH public key secret %s/%s must be in the DataCrunchMachine namespace %s", ref.Namespace, ref.Name, dataCrunchMachine.Namespace)
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: dataCrunchMachine.Namespace, Name: ref.Name}
+	if err := r.Get(ctx, key, secret); err != nil {
+		return "", errors.Wrapf(err, "failed to retrieve SSH public key secret %s", key)
+	}
+
+	publicKey := strings.TrimSpace(string(secret.Data[sshPublicKeyKey]))
+	if publicKey == "" {
+		return "", errors.Errorf("SSH public key secret %s is missing the %q key", key, sshPublicKeyKey)
+	}
+
+	return publicKey, nil
+}
+
+// userDataPart is a part of a multi-part MIME user data document
+type userDataPart struct {
+	header  textproto.MIMEHeader
+	content []byte
+}
+
+// newUserDataPart returns a user data part for data, typed by its first line.
+func newUserDataPart(data []byte) userDataPart {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", userDataContentType(data)+"; charset=\"us-ascii\"")
+	return userDataPart{header: header, content: data}
+}
+
+// mergeUserData combines the bootstrap data and startup scripts into a multi-part MIME document, which
+// cloud-init processes part by part, so the startup scripts run after the bootstrap data is applied.
+// Bootstrap data that already is a multi-part MIME document contributes its parts as they are, since
+// cloud-init does not look into a multi-part document nested as a plain part.
+func mergeUserData(bootstrapData []byte, startupScripts ...[]byte) ([]byte, error) {
+	var parts []userDataPart
+	if bytes.HasPrefix(bootstrapData, []byte("MIME-Version:")) {
+		bootstrapParts, err := readMultipartUserData(bootstrapData)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to split bootstrap data")
+		}
+		parts = append(parts, bootstrapParts...)
+	} else {
+		parts = append(parts, newUserDataPart(bootstrapData))
+	}
+	for _, script := range startupScripts {
+		parts = append(parts, newUserDataPart(script))
+	}
+
+	// A part containing the boundary would be cut short, so fall back to numbered boundaries, which
+	// keeps the output deterministic
+	boundary := userDataBoundary
+	for i := 1; userDataPartsContain(parts, boundary); i++ {
+		boundary = fmt.Sprintf("==DATACRUNCH-USER-DATA-%d==", i)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("MIME-Version: 1.0\n")
+	buf.WriteString("Content-Type: multipart/mixed; boundary=\"" + boundary + "\"\n")
+	for _, part := range parts {
+		buf.WriteString("\n--" + boundary + "\n")
+		keys := make([]string, 0, len(part.header))
+		for key := range part.header {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, value := range part.header[key] {
+				buf.WriteString(key + ": " + value + "\n")
+			}
+		}
+		buf.WriteString("\n")
+		buf.Write(part.content)
+		if !bytes.HasSuffix(part.content, []byte("\n")) {
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString("\n--" + boundary + "--\n")
+
+	merged := buf.Bytes()
+	mergedParts, err := readMultipartUserData(merged)
+	if err != nil {
+		return nil, errors.Wrap(err, "merged user data is not a valid multi-part MIME document")
+	}
+	if len(mergedParts) != len(parts) {
+		return nil, errors.Errorf("merged user data has %d parts, expected %d", len(mergedParts), len(parts))
+	}
+	return merged, nil
+}
+
+// userDataPartsContain reports whether the header or content of any part contains the boundary.
+func userDataPartsContain(parts []userDataPart, boundary string) bool {
+	for _, part := range parts {
+		if bytes.Contains(part.content, []byte(boundary)) {
+			return true
+		}
+		for _, values := range part.header {
+			for _, value := range values {
+				if strings.Contains(value, boundary) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// readMultipartUserData splits a multi-part MIME document into its parts, leaving their content encoded.
+func readMultipartUserData(data []byte) ([]userDataPart, error) {
+	reader, err := newMultipartUserDataReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []userDataPart
+	for i := 0; ; i++ {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read part %d of MIME user data", i)
+		}
+
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read part %d of MIME user data", i)
+		}
+		parts = append(parts, userDataPart{header: part.Header, content: content})
+	}
+}
+
+// userDataContentType returns the cloud-init MIME type of a user data part.
+func userDataContentType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("#cloud-config")):
+		return "text/cloud-config"
+	case bytes.HasPrefix(data, []byte("#!")):
+		return "text/x-shellscript"
+	default:
+		return "text/plain"
+	}
+}
+
+// gzipUserData compresses user data for cloud-init, which detects and decompresses gzip data itself. The
+// header carries no modification time, so the same user data always compresses to the same bytes.
+func gzipUserData(userData []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(userData); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// validateUserData checks that the user data is valid base64 and that its content is well-formed:
+// cloud-config must be valid YAML and every part of a multi-part MIME document is checked in turn.
+func validateUserData(userData string) error {
+	data, err := base64.StdEncoding.Strict().DecodeString(userData)
+	if err != nil {
+		return errors.Wrap(err, "user data is not valid base64")
+	}
+	return validateUserDataContent(data)
+}
+
+func validateUserDataContent(data []byte) error {
+	if bytes.HasPrefix(data, []byte("MIME-Version:")) {
+		return validateMultipartUserData(data)
+	}
+
+	if userDataContentType(data) == "text/cloud-config" {
+		var cloudConfig map[string]interface{}
+		if err := yaml.Unmarshal(data, &cloudConfig); err != nil {
+			return errors.Wrap(err, "cloud-config is not valid YAML")
+		}
+	}
+
+	return nil
+}
+
+// newMultipartUserDataReader returns a reader for the parts of a multi-part MIME document.
+func newMultipartUserDataReader(data []byte) (*multipart.Reader, error) {
+	header, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read MIME header of user data")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" || params["boundary"] == "" {
+		return nil, errors.Errorf("MIME user data must have a multipart/mixed content type with a boundary, got %q", header.Get("Content-Type"))
+	}
+
+	// The header ends at the first blank line, which the multipart reader skips like a preamble
+	return multipart.NewReader(bytes.NewReader(data), params["boundary"]), nil
+}
+
+func validateMultipartUserData(data []byte) error {
+	reader, err := newMultipartUserDataReader(data)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; ; i++ {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read part %d of MIME user data", i)
+		}
+
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read part %d of MIME user data", i)
+		}
+		if err := validateUserDataContent(content); err != nil {
+			return errors.Wrapf(err, "invalid part %d of MIME user data", i)
+		}
+	}
+}
+
+func (r *DataCrunchMachineReconciler) createDataCrunchClient(ctx context.Context, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster, dataCrunchMachine *infrav1beta1.DataCrunchMachine) (cloud.Client, error) {
+	// Get credentials from environment variables (for testing) or secrets (for production)
+	clientID := os.Getenv("DATACRUNCH_CLIENT_ID")
+	clientSecret := os.Getenv("DATACRUNCH_CLIENT_SECRET")
+	apiURL := dataCrunchAPIURL(dataCrunchCluster)
+	if r.Credentials != nil {
+		clientID, clientSecret = r.Credentials.Get()
+	}
+
+	// Machine-level credentials take precedence over the controller-wide ones
+	if dataCrunchMachine != nil && dataCrunchMachine.Spec.CredentialsRef != nil {
+		var err error
+		clientID, clientSecret, err = r.getMachineCredentials(ctx, dataCrunchMachine)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if clientID == "" {
+		clientID = "your-datacrunch-client-id" // fallback for development
+	}
+	if clientSecret == "" {
+		clientSecret = "your-datacrunch-client-secret" // fallback for development
+	}
+
+	if r.ClientCache != nil {
+		return r.ClientCache.Get(clientID, clientSecret, apiURL, r.newDataCrunchClient), nil
+	}
+	return r.newDataCrunchClient(clientID, clientSecret, apiURL), nil
+}
+
+// newDataCrunchClient creates a DataCrunch client sharing the rate limiter, health tracker and concurrency
+// of the reconciler.
+func (r *DataCrunchMachineReconciler) newDataCrunchClient(clientID, clientSecret, apiURL string) *datacrunch.Client {
+	dataCrunchClient := datacrunch.NewClientWithOptions(clientID, clientSecret, datacrunch.ClientOptions{
+		BaseURL:    apiURL,
+		MaxRetries: datacrunch.DefaultMaxRetries,
+	})
+	dataCrunchClient.SetRateLimiter(r.APIRateLimiter)
+	dataCrunchClient.SetHealthTracker(r.APIHealth)
+	dataCrunchClient.SetAdaptiveConcurrency(r.Concurrency)
+
+	return dataCrunchClient
+}
+
+// getMachineCredentials reads the DataCrunch API credentials from the Secret referenced by the machine's CredentialsRef.
+func (r *DataCrunchMachineReconciler) getMachineCredentials(ctx context.Context, dataCrunchMachine *infrav1beta1.DataCrunchMachine) (string, string, error) {
+	ref := dataCrunchMachine.Spec.CredentialsRef
+
+	// Only allow Secrets from the machine's own namespace so tenants can't use each other's credentials
+	if ref.Namespace != "" && ref.Namespace != dataCrunchMachine.Namespace {
+		return "", "", errors.Errorf("credentials secret %s/%s must be in the DataCrunchMachine namespace %s", ref.Namespace, ref.Name, dataCrunchMachine.Namespace)
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: dataCrunchMachine.Namespace, Name: ref.Name}
+	if err := r.Get(ctx, key, secret); err != nil {
+		return "", "", errors.Wrapf(err, "failed to retrieve credentials secret %s", key)
+	}
+
+	clientID, ok := secret.Data[credentialsClientIDKey]
+	if !ok || len(clientID) == 0 {
+		return "", "", errors.Errorf("credentials secret %s is missing the %q key", key, credentialsClientIDKey)
 	}
 
-	if apiURL != "" {
-		return datacrunch.NewClientWithURL(clientID, clientSecret, apiURL), nil
+	clientSecret, ok := secret.Data[credentialsClientSecretKey]
+	if !ok || len(clientSecret) == 0 {
+		return "", "", errors.Errorf("credentials secret %s is missing the %q key", key, credentialsClientSecretKey)
 	}
 
-	return datacrunch.NewClient(clientID, clientSecret), nil
+	return string(clientID), string(clientSecret), nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -17,26 +17,426 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/go-logr/logr"
 	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud/datacrunch"
 )
 
-func TestDataCrunchMachineReconciler_Reconcile(t *testing.T) {
+// fakeCloudClient overrides the cloud.Client methods exercised by the tests.
+// Calling any other method panics on the nil embedded interface.
+type fakeCloudClient struct {
+	cloud.Client
+
+	instanceTypes  []*cloud.InstanceType
+	instances      []*cloud.Instance
+	deleted        []string
+	created        []*cloud.InstanceSpec
+	price          *cloud.InstanceTypePrice
+	vpcs           map[string]*cloud.VPC
+	subnets        map[string]*cloud.Subnet
+	createdVPCs    []*cloud.VPCSpec
+	createdSubnets []*cloud.SubnetSpec
+	deletedVPCs    []string
+	deletedSubnets []string
+	sshKeys        []*cloud.SSHKey
+	createdSSHKeys []*cloud.SSHKey
+	deletedSSHKeys []string
+	createErr      error
+	accountLimits  *cloud.AccountLimits
+	locations      []*cloud.Location
+
+	// updatedSSHKeys maps instance IDs to the SSH keys set by UpdateInstanceSSHKey, which fails with updateSSHKeyErr
+	updatedSSHKeys  map[string]string
+	updateSSHKeyErr error
+
+	// updatedSecurityGroups maps instance IDs to the security groups set by UpdateInstanceSecurityGroups,
+	// which fails with updateSecurityGroupsErr
+	updatedSecurityGroups   map[string][]string
+	updateSecurityGroupsErr error
+
+	// metrics are returned by GetInstanceMetrics, which fails with metricsErr
+	metrics    *cloud.InstanceMetrics
+	metricsErr error
+
+	// unavailableTypes have no capacity according to IsInstanceTypeAvailable, noCapacityTypes fail on create.
+	// availableRegions, if set for an instance type, are the only regions it has capacity in instead.
+	unavailableTypes map[string]bool
+	noCapacityTypes  map[string]bool
+	availableRegions map[string][]string
+
+	// deleteSubnetErr fails DeleteSubnet
+	deleteSubnetErr error
+
+	// loadBalancers are returned by GetLoadBalancer, CreateLoadBalancer records createdLoadBalancers
+	loadBalancers        map[string]*cloud.LoadBalancer
+	createdLoadBalancers []*cloud.LoadBalancerSpec
+}
+
+func (f *fakeCloudClient) CreateLoadBalancer(_ context.Context, spec *cloud.LoadBalancerSpec) (*cloud.LoadBalancer, error) {
+	f.createdLoadBalancers = append(f.createdLoadBalancers, spec)
+	return &cloud.LoadBalancer{ID: "lb-new", Name: spec.Name, Type: spec.Type, State: "provisioning"}, nil
+}
+
+func (f *fakeCloudClient) GetLoadBalancer(_ context.Context, lbID string) (*cloud.LoadBalancer, error) {
+	if lb, ok := f.loadBalancers[lbID]; ok {
+		return lb, nil
+	}
+	return nil, errors.New("load balancer not found: " + lbID)
+}
+
+func (f *fakeCloudClient) ListSSHKeys(_ context.Context) ([]*cloud.SSHKey, error) {
+	return f.sshKeys, nil
+}
+
+func (f *fakeCloudClient) CreateSSHKey(_ context.Context, name, publicKey string, tags map[string]string) (*cloud.SSHKey, error) {
+	key := &cloud.SSHKey{ID: "key-" + name, Name: name, PublicKey: publicKey, Tags: tags}
+	f.sshKeys = append(f.sshKeys, key)
+	f.createdSSHKeys = append(f.createdSSHKeys, key)
+	return key, nil
+}
+
+func (f *fakeCloudClient) DeleteSSHKey(_ context.Context, keyID string) error {
+	f.deletedSSHKeys = append(f.deletedSSHKeys, keyID)
+	return nil
+}
+
+func (f *fakeCloudClient) GetVPC(_ context.Context, vpcID string) (*cloud.VPC, error) {
+	if vpc, ok := f.vpcs[vpcID]; ok {
+		return vpc, nil
+	}
+	return nil, errors.New("VPC not found: " + vpcID)
+}
+
+func (f *fakeCloudClient) CreateVPC(_ context.Context, spec *cloud.VPCSpec) (*cloud.VPC, error) {
+	f.createdVPCs = append(f.createdVPCs, spec)
+	return &cloud.VPC{ID: "vpc-new", Name: spec.Name, CidrBlock: spec.CidrBlock, State: "available"}, nil
+}
+
+func (f *fakeCloudClient) GetSubnet(_ context.Context, subnetID string) (*cloud.Subnet, error) {
+	if subnet, ok := f.subnets[subnetID]; ok {
+		return subnet, nil
+	}
+	return nil, errors.New("subnet not found: " + subnetID)
+}
+
+func (f *fakeCloudClient) CreateSubnet(_ context.Context, spec *cloud.SubnetSpec) (*cloud.Subnet, error) {
+	f.createdSubnets = append(f.createdSubnets, spec)
+	return &cloud.Subnet{ID: "subnet-new", VPCID: spec.VPCID, CidrBlock: spec.CidrBlock, State: "available"}, nil
+}
+
+func (f *fakeCloudClient) DeleteVPC(_ context.Context, vpcID string) error {
+	f.deletedVPCs = append(f.deletedVPCs, vpcID)
+	return nil
+}
+
+func (f *fakeCloudClient) DeleteSubnet(_ context.Context, subnetID string) error {
+	if f.deleteSubnetErr != nil {
+		return f.deleteSubnetErr
+	}
+	f.deletedSubnets = append(f.deletedSubnets, subnetID)
+	return nil
+}
+
+func (f *fakeCloudClient) ValidateCredentials(_ context.Context) error {
+	return nil
+}
+
+func (f *fakeCloudClient) GetAccountLimits(_ context.Context) (*cloud.AccountLimits, error) {
+	if f.accountLimits == nil {
+		return nil, errors.New("account limits not available")
+	}
+	return f.accountLimits, nil
+}
+
+func (f *fakeCloudClient) ListLocations(_ context.Context) ([]*cloud.Location, error) {
+	return f.locations, nil
+}
+
+func (f *fakeCloudClient) GetInstanceTypePrice(_ context.Context, instanceType, region string) (*cloud.InstanceTypePrice, error) {
+	if f.price == nil {
+		return nil, errors.New("price not available")
+	}
+	return f.price, nil
+}
+
+func (f *fakeCloudClient) GetInstanceMetrics(_ context.Context, _ string) (*cloud.InstanceMetrics, error) {
+	return f.metrics, f.metricsErr
+}
+
+func (f *fakeCloudClient) IsInstanceTypeAvailable(_ context.Context, instanceType, region string) (bool, error) {
+	if regions, ok := f.availableRegions[instanceType]; ok {
+		return slices.Contains(regions, region), nil
+	}
+	return !f.unavailableTypes[instanceType], nil
+}
+
+func (f *fakeCloudClient) CreateInstance(_ context.Context, spec *cloud.InstanceSpec) (*cloud.Instance, error) {
+	f.created = append(f.created, spec)
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	if f.noCapacityTypes[spec.InstanceType] {
+		return nil, &cloud.APIError{StatusCode: http.StatusServiceUnavailable, Code: cloud.ErrorCodeInsufficientCapacity}
+	}
+	return &cloud.Instance{ID: "instance-123", Name: spec.Name, State: "pending", ImageID: spec.ImageID, OperationID: "operation-123"}, nil
+}
+
+func (f *fakeCloudClient) ListInstances(_ context.Context) ([]*cloud.Instance, error) {
+	return f.instances, nil
+}
+
+func (f *fakeCloudClient) WaitForInstanceState(_ context.Context, instanceID, target string, _ time.Duration) (*cloud.Instance, error) {
+	for _, instance := range f.instances {
+		if instance.ID == instanceID && instance.State == target {
+			return instance, nil
+		}
+	}
+	return nil, fmt.Errorf("instance %s is not %s", instanceID, target)
+}
+
+func (f *fakeCloudClient) DeleteInstance(_ context.Context, instanceID string) error {
+	f.deleted = append(f.deleted, instanceID)
+	return nil
+}
+
+func (f *fakeCloudClient) UpdateInstanceSSHKey(_ context.Context, instanceID, sshKeyName string) error {
+	if f.updateSSHKeyErr != nil {
+		return f.updateSSHKeyErr
+	}
+	if f.updatedSSHKeys == nil {
+		f.updatedSSHKeys = map[string]string{}
+	}
+	f.updatedSSHKeys[instanceID] = sshKeyName
+	return nil
+}
+
+func (f *fakeCloudClient) UpdateInstanceSecurityGroups(_ context.Context, instanceID string, securityGroupIDs []string) error {
+	if f.updateSecurityGroupsErr != nil {
+		return f.updateSecurityGroupsErr
+	}
+	if f.updatedSecurityGroups == nil {
+		f.updatedSecurityGroups = map[string][]string{}
+	}
+	f.updatedSecurityGroups[instanceID] = securityGroupIDs
+	return nil
+}
+
+func (f *fakeCloudClient) ListInstanceTypes(_ context.Context) ([]*cloud.InstanceType, error) {
+	return f.instanceTypes, nil
+}
+
+// hasEvent drains the recorder and reports whether any event contains the given reason.
+func hasEvent(recorder *record.FakeRecorder, reason string) bool {
+	found := false
+	for {
+		select {
+		case event := <-recorder.Events:
+			if strings.Contains(event, reason) {
+				found = true
+			}
+		default:
+			return found
+		}
+	}
+}
+
+// machineTestObjects are the objects the DataCrunchMachine reconciler tests start from: a DataCrunchMachine
+// that is owned by a Machine with bootstrap data, in a Cluster whose infrastructure is ready.
+type machineTestObjects struct {
+	bootstrapSecret   *corev1.Secret
+	machine           *clusterv1.Machine
+	dataCrunchMachine *infrav1beta1.DataCrunchMachine
+	cluster           *clusterv1.Cluster
+	dataCrunchCluster *infrav1beta1.DataCrunchCluster
+}
+
+func newMachineTestObjects() *machineTestObjects {
+	secretName := "test-machine-bootstrap"
+	return &machineTestObjects{
+		bootstrapSecret: &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: "default",
+			},
+			Data: map[string][]byte{
+				"value": []byte("#cloud-config\n"),
+			},
+		},
+		machine: &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-machine",
+				Namespace: "default",
+			},
+			Spec: clusterv1.MachineSpec{
+				Bootstrap: clusterv1.Bootstrap{DataSecretName: &secretName},
+			},
+		},
+		dataCrunchMachine: &infrav1beta1.DataCrunchMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "test-machine",
+				Namespace:  "default",
+				Finalizers: []string{infrav1beta1.MachineFinalizer},
+			},
+			Spec: infrav1beta1.DataCrunchMachineSpec{
+				InstanceType: "1xH100",
+			},
+		},
+		cluster: &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "default",
+			},
+			Status: clusterv1.ClusterStatus{InfrastructureReady: true},
+		},
+		dataCrunchCluster: &infrav1beta1.DataCrunchCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "default",
+			},
+		},
+	}
+}
+
+// linked wires the objects together the way Cluster API does before the DataCrunchMachine is reconciled: the
+// Machine owns and references the DataCrunchMachine, both carry the cluster label, and the Cluster references
+// the DataCrunchCluster.
+func (o *machineTestObjects) linked() *machineTestObjects {
+	o.machine.Labels = map[string]string{clusterv1.ClusterNameLabel: o.cluster.Name}
+	o.machine.Spec.InfrastructureRef = corev1.ObjectReference{
+		APIVersion: infrav1beta1.GroupVersion.String(),
+		Kind:       "DataCrunchMachine",
+		Name:       o.dataCrunchMachine.Name,
+	}
+	o.dataCrunchMachine.Labels = map[string]string{clusterv1.ClusterNameLabel: o.cluster.Name}
+	o.dataCrunchMachine.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       "Machine",
+		Name:       o.machine.Name,
+	}}
+	o.cluster.Spec.InfrastructureRef = &corev1.ObjectReference{
+		APIVersion: infrav1beta1.GroupVersion.String(),
+		Kind:       "DataCrunchCluster",
+		Name:       o.dataCrunchCluster.Name,
+	}
+	return o
+}
+
+// all returns the objects for seeding a fake client.
+func (o *machineTestObjects) all() []client.Object {
+	return []client.Object{o.bootstrapSecret, o.machine, o.dataCrunchMachine, o.cluster, o.dataCrunchCluster}
+}
+
+// newTestScheme returns a scheme with the types the DataCrunchMachine reconciler works with.
+func newTestScheme() *runtime.Scheme {
 	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
 	_ = clusterv1.AddToScheme(scheme)
 	_ = infrav1beta1.AddToScheme(scheme)
+	return scheme
+}
+
+// newTestClientBuilder returns a fake client builder holding objs, for tests that add interceptors.
+func newTestClientBuilder(objs ...client.Object) *fake.ClientBuilder {
+	return fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(objs...).
+		WithStatusSubresource(&infrav1beta1.DataCrunchMachine{})
+}
+
+// newTestReconciler returns a reconciler backed by a fake client holding objs.
+func newTestReconciler(objs ...client.Object) *DataCrunchMachineReconciler {
+	return newTestReconcilerWithClient(newTestClientBuilder(objs...).Build())
+}
+
+// newTestReconcilerWithClient returns a reconciler backed by c.
+func newTestReconcilerWithClient(c client.Client) *DataCrunchMachineReconciler {
+	return &DataCrunchMachineReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+}
+
+// newFakeAPI starts a DataCrunch API server for the tests going through a real client and points the client
+// at it. It issues tokens and serves routes keyed by http.ServeMux patterns such as "GET /instances/{id}".
+// Other requests are not found, unless a "/" route answers them.
+func newFakeAPI(t *testing.T, routes map[string]http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	if _, ok := routes["/"]; !ok {
+		mux.HandleFunc("/", respondStatus(http.StatusNotFound))
+	}
+	if _, ok := routes["/oauth/token"]; !ok {
+		mux.HandleFunc("/oauth/token", respondToken)
+	}
+	for pattern, route := range routes {
+		mux.HandleFunc(pattern, route)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DATACRUNCH_API_URL", server.URL)
+	return server
+}
+
+// respondToken issues an access token, for routes that inspect the token request before answering it.
+func respondToken(w http.ResponseWriter, _ *http.Request) {
+	_, _ = w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+}
+
+// respondJSON returns a route answering with body.
+func respondJSON(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+// respondStatus returns a route answering with an empty response with the status code.
+func respondStatus(code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(code)
+	}
+}
+
+func TestDataCrunchMachineReconciler_Reconcile(t *testing.T) {
+	scheme := newTestScheme()
 
 	tests := []struct {
 		name        string
@@ -145,9 +545,7 @@ func TestDataCrunchMachineReconciler_Reconcile(t *testing.T) {
 }
 
 func TestDataCrunchMachineReconciler_reconcileNormal(t *testing.T) {
-	scheme := runtime.NewScheme()
-	_ = infrav1beta1.AddToScheme(scheme)
-	_ = clusterv1.AddToScheme(scheme)
+	scheme := newTestScheme()
 
 	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
 		ObjectMeta: metav1.ObjectMeta{
@@ -206,9 +604,7 @@ func TestDataCrunchMachineReconciler_reconcileNormal(t *testing.T) {
 }
 
 func TestDataCrunchMachineReconciler_reconcileDelete(t *testing.T) {
-	scheme := runtime.NewScheme()
-	_ = infrav1beta1.AddToScheme(scheme)
-	_ = clusterv1.AddToScheme(scheme)
+	scheme := newTestScheme()
 
 	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
 		ObjectMeta: metav1.ObjectMeta{
@@ -265,96 +661,689 @@ func TestDataCrunchMachineReconciler_reconcileDelete(t *testing.T) {
 	}
 }
 
-func TestDataCrunchMachineReconciler_findInstance(t *testing.T) {
+func TestDataCrunchMachineReconciler_reconcileDelete_SnapshotOnDelete(t *testing.T) {
+	var calls []string
+	snapshotName := ""
+	snapshotState := "pending"
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"GET /instances/instance-123": respondJSON(`{"id":"instance-123","hostname":"test-machine","status":"running"}`),
+		"POST /instances/instance-123/snapshots": func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Name string `json:"name"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			snapshotName = req.Name
+			calls = append(calls, "snapshot")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"snapshot-456","instance_id":"instance-123","status":"pending"}`))
+		},
+		"GET /snapshots/snapshot-456": func(w http.ResponseWriter, _ *http.Request) {
+			calls = append(calls, "get-snapshot")
+			_, _ = fmt.Fprintf(w, `{"id":"snapshot-456","instance_id":"instance-123","status":%q}`, snapshotState)
+		},
+		"DELETE /instances/instance-123": func(w http.ResponseWriter, _ *http.Request) {
+			calls = append(calls, "delete")
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+	dataCrunchMachine.Spec.SnapshotOnDelete = ptrTo(true)
+
+	reconciler := newTestReconciler()
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+	reconcileDelete := func() reconcile.Result {
+		t.Helper()
+		result, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), &clusterv1.Machine{}, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		return result
+	}
+
+	// The snapshot ID is recorded and the reconcile requeues, so the ID is persisted before the deletion
+	result := reconcileDelete()
+	if !reflect.DeepEqual(calls, []string{"snapshot"}) {
+		t.Errorf("Expected only the snapshot to be created, got calls %v", calls)
+	}
+	if result.RequeueAfter != defaultInstancePollInterval {
+		t.Errorf("Expected requeue after %v, got %v", defaultInstancePollInterval, result.RequeueAfter)
+	}
+	if !strings.HasPrefix(snapshotName, "test-machine-") {
+		t.Errorf("Expected snapshot name to start with the machine name, got %q", snapshotName)
+	}
+	if dataCrunchMachine.Status.SnapshotID != "snapshot-456" {
+		t.Errorf("Expected snapshot ID snapshot-456 in status, got %q", dataCrunchMachine.Status.SnapshotID)
+	}
+	if !controllerutil.ContainsFinalizer(dataCrunchMachine, infrav1beta1.MachineFinalizer) {
+		t.Error("Expected the finalizer to be kept while the snapshot is not available")
+	}
+	if !hasEvent(recorder, "SnapshotCreated") {
+		t.Error("Expected a SnapshotCreated event")
+	}
+
+	// A pending snapshot keeps the instance
+	calls = nil
+	result = reconcileDelete()
+	if !reflect.DeepEqual(calls, []string{"get-snapshot"}) {
+		t.Errorf("Expected the instance to be kept while the snapshot is pending, got calls %v", calls)
+	}
+	if result.RequeueAfter != defaultInstancePollInterval {
+		t.Errorf("Expected requeue after %v, got %v", defaultInstancePollInterval, result.RequeueAfter)
+	}
+
+	// Once available the instance is deleted without taking another snapshot
+	calls = nil
+	snapshotState = "available"
+	reconcileDelete()
+	if !reflect.DeepEqual(calls, []string{"get-snapshot", "delete"}) {
+		t.Errorf("Expected the instance to be deleted once the snapshot is available, got calls %v", calls)
+	}
+	if controllerutil.ContainsFinalizer(dataCrunchMachine, infrav1beta1.MachineFinalizer) {
+		t.Error("Expected the finalizer to be removed")
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileDelete_SnapshotFailureKeepsInstance(t *testing.T) {
+	deletes := 0
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"GET /instances/instance-123":       respondJSON(`{"id":"instance-123","hostname":"test-machine","status":"running"}`),
+		"/instances/instance-123/snapshots": respondStatus(http.StatusInternalServerError),
+		"/snapshots/snapshot-456":           respondJSON(`{"id":"snapshot-456","instance_id":"instance-123","status":"error"}`),
+		"DELETE /instances/instance-123": func(w http.ResponseWriter, _ *http.Request) {
+			deletes++
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+
+	dataCrunchMachine := newMachineTestObjects().dataCrunchMachine
+	dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+	dataCrunchMachine.Spec.SnapshotOnDelete = ptrTo(true)
+
+	reconciler := newTestReconciler()
+	if _, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), &clusterv1.Machine{}, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err == nil {
+		t.Fatal("Expected an error when the snapshot fails")
+	}
+
+	dataCrunchMachine.Status.SnapshotID = "snapshot-456"
+	if _, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), &clusterv1.Machine{}, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err == nil {
+		t.Fatal("Expected an error when the snapshot is in the error state")
+	}
+
+	if deletes != 0 {
+		t.Errorf("Expected the instance not to be deleted without a snapshot, got %d deletes", deletes)
+	}
+	if !controllerutil.ContainsFinalizer(dataCrunchMachine, infrav1beta1.MachineFinalizer) {
+		t.Error("Expected the finalizer to be kept")
+	}
+}
+
+func TestDataCrunchMachineReconciler_instanceStateRequeueAfter(t *testing.T) {
 	tests := []struct {
-		name        string
-		machine     *infrav1beta1.DataCrunchMachine
-		expectFound bool
+		name          string
+		state         infrav1beta1.InstanceState
+		pollInterval  time.Duration
+		errorInterval time.Duration
+		want          time.Duration
 	}{
 		{
-			name: "machine without provider ID",
-			machine: &infrav1beta1.DataCrunchMachine{
-				Spec: infrav1beta1.DataCrunchMachineSpec{
-					InstanceType: "1xH100.80G",
-				},
-			},
-			expectFound: false,
+			name:  "pending at the default poll interval",
+			state: infrav1beta1.InstanceStatePending,
+			want:  defaultInstancePollInterval,
 		},
 		{
-			name: "machine with empty provider ID",
-			machine: &infrav1beta1.DataCrunchMachine{
-				Spec: infrav1beta1.DataCrunchMachineSpec{
-					InstanceType: "1xH100.80G",
-					ProviderID:   func() *string { s := ""; return &s }(),
-				},
-			},
-			expectFound: false,
+			name:         "pending at the configured poll interval",
+			state:        infrav1beta1.InstanceStatePending,
+			pollInterval: 5 * time.Second,
+			want:         5 * time.Second,
+		},
+		{
+			name:         "running is re-checked for its health",
+			state:        infrav1beta1.InstanceStateRunning,
+			pollInterval: 5 * time.Second,
+			want:         runningInstanceRequeueAfter,
+		},
+		{
+			name:         "stopped is checked again right after starting it",
+			state:        infrav1beta1.InstanceStateStopped,
+			pollInterval: 5 * time.Second,
+			want:         startedInstanceRequeueAfter,
+		},
+		{
+			name:  "stopping starts at the minimum stopping backoff",
+			state: infrav1beta1.InstanceStateStopping,
+			want:  minStoppingRequeueAfter,
+		},
+		{
+			name:  "terminated is not requeued",
+			state: infrav1beta1.InstanceStateTerminated,
+			want:  0,
+		},
+		{
+			name:  "unknown state at the default error interval",
+			state: infrav1beta1.InstanceState("rebooting"),
+			want:  defaultErrorRequeueInterval,
+		},
+		{
+			name:          "unknown state at the configured error interval",
+			state:         infrav1beta1.InstanceState("rebooting"),
+			errorInterval: time.Minute,
+			want:          time.Minute,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			reconciler := &DataCrunchMachineReconciler{}
-
-			instance, err := reconciler.findInstance(context.Background(), nil, tt.machine)
-
-			// With nil client and no provider ID, we should get nil instance and no error
-			if err != nil {
-				t.Errorf("Expected no error but got: %v", err)
+			reconciler := &DataCrunchMachineReconciler{
+				InstancePollInterval: tt.pollInterval,
+				ErrorRequeueInterval: tt.errorInterval,
 			}
-			if instance != nil {
-				t.Error("Expected no instance with nil client")
+			if got := reconciler.instanceStateRequeueAfter(tt.state); got != tt.want {
+				t.Errorf("Expected requeue after %v, got %v", tt.want, got)
 			}
 		})
 	}
 }
 
-func TestDataCrunchMachineReconciler_createInstance(t *testing.T) {
-	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-machine",
+func TestDataCrunchMachineReconciler_reconcileNormal_RequeueIntervals(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        string
+		startStatus   int
+		pollInterval  time.Duration
+		errorInterval time.Duration
+		want          time.Duration
+		wantReason    string
+	}{
+		{
+			name:   "pending instance is polled at the default interval",
+			status: "pending",
+			want:   defaultInstancePollInterval,
 		},
-		Spec: infrav1beta1.DataCrunchMachineSpec{
-			InstanceType: "1xH100.80G",
-			Image:        "ubuntu-20.04",
-			SSHKeyName:   "my-key",
+		{
+			name:         "pending instance is polled at the configured interval",
+			status:       "pending",
+			pollInterval: 5 * time.Second,
+			want:         5 * time.Second,
 		},
-	}
-
-	machine := &clusterv1.Machine{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-machine",
+		{
+			name:        "started instance is checked again shortly",
+			status:      "stopped",
+			startStatus: http.StatusOK,
+			want:        startedInstanceRequeueAfter,
 		},
-	}
-
-	cluster := &clusterv1.Cluster{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-cluster",
+		{
+			name:          "failed start backs off at the configured error interval",
+			status:        "stopped",
+			startStatus:   http.StatusConflict,
+			pollInterval:  5 * time.Second,
+			errorInterval: time.Minute,
+			want:          time.Minute,
+			wantReason:    infrav1beta1.InstanceNotReadyReason,
 		},
-	}
-
-	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-cluster",
+		{
+			name:         "failed start backs off at the default error interval",
+			status:       "stopped",
+			startStatus:  http.StatusConflict,
+			pollInterval: 5 * time.Second,
+			want:         defaultErrorRequeueInterval,
+			wantReason:   infrav1beta1.InstanceNotReadyReason,
+		},
+		{
+			name:          "unknown state backs off at the error interval",
+			status:        "rebooting",
+			errorInterval: time.Minute,
+			want:          time.Minute,
 		},
 	}
 
-	reconciler := &DataCrunchMachineReconciler{}
-	log := logr.Discard()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"GET /instances/instance-123":   respondJSON(`{"id":"instance-123","hostname":"test-machine","status":"` + tt.status + `"}`),
+				"/instances/instance-123/start": respondStatus(tt.startStatus),
+			})
 
-	// This will fail without a real client, but we're testing the method exists
-	_, err := reconciler.createInstance(context.Background(), log, nil, machine, dataCrunchMachine, cluster, dataCrunchCluster)
-	// We expect this to fail with nil client, so we check that it doesn't panic
-	if err == nil {
-		t.Error("Expected error with nil client")
+			objs := newMachineTestObjects()
+			machine, dataCrunchMachine := objs.machine, objs.dataCrunchMachine
+			dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+
+			reconciler := newTestReconciler(machine)
+			reconciler.InstancePollInterval = tt.pollInterval
+			reconciler.ErrorRequeueInterval = tt.errorInterval
+			result, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{})
+			if err != nil {
+				t.Fatalf("Expected no error, as it would discard the requeue interval, got: %v", err)
+			}
+			if result.RequeueAfter != tt.want {
+				t.Errorf("Expected requeue after %v, got %v", tt.want, result.RequeueAfter)
+			}
+			if tt.wantReason != "" && conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceReadyCondition) != tt.wantReason {
+				t.Errorf("Expected InstanceReady reason %s, got %q", tt.wantReason, conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceReadyCondition))
+			}
+		})
 	}
 }
 
-func TestDataCrunchMachineReconciler_getBootstrapData(t *testing.T) {
-	reconciler := &DataCrunchMachineReconciler{}
+func TestDataCrunchMachineReconciler_reconcileNormal_RestoresFinalizer(t *testing.T) {
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/instances/instance-123":      respondJSON(`{"id":"instance-123","hostname":"test-machine","status":"running","private_ip":"10.0.0.5"}`),
+		"/instances/instance-123/tags": respondStatus(http.StatusOK),
+	})
 
-	// Test with machine without bootstrap data ref
-	machine := &clusterv1.Machine{
+	tests := []struct {
+		name         string
+		providerID   *string
+		wantContinue bool
+	}{
+		{
+			name: "new machine persists the finalizer before creating an instance",
+		},
+		{
+			name:         "machine with an instance is reconciled",
+			providerID:   ptrTo("datacrunch://instance-123"),
+			wantContinue: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Finalizers = nil
+			dataCrunchMachine.Spec.ProviderID = tt.providerID
+
+			reconciler := newTestReconciler(objs.machine)
+			if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if !controllerutil.ContainsFinalizer(dataCrunchMachine, infrav1beta1.MachineFinalizer) {
+				t.Error("Expected the finalizer to be added")
+			}
+			if continued := dataCrunchMachine.Status.InstanceState != nil; continued != tt.wantContinue {
+				t.Errorf("Expected reconcile to continue=%v, got instance state %v", tt.wantContinue, dataCrunchMachine.Status.InstanceState)
+			}
+			if tt.wantContinue && !hasEvent(reconciler.Recorder.(*record.FakeRecorder), "FinalizerRestored") {
+				t.Error("Expected FinalizerRestored event")
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_deleteOwnedSSHKeys(t *testing.T) {
+	dataCrunchMachine := newMachineTestObjects().dataCrunchMachine
+	dataCrunchMachine.UID = "machine-uid"
+	dataCrunchMachine.Spec.SSHKeyName = "owned-key"
+	otherMachine := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-machine",
+			Namespace: "default",
+			UID:       "other-uid",
+		},
+		Spec: infrav1beta1.DataCrunchMachineSpec{
+			InstanceType: "1xH100",
+			SSHKeyName:   "shared-key",
+		},
+	}
+
+	fakeCloud := &fakeCloudClient{
+		sshKeys: []*cloud.SSHKey{
+			{ID: "key-owned", Name: "owned-key", Tags: map[string]string{idempotencyKeyTag: "machine-uid"}},
+			{ID: "key-shared", Name: "shared-key", Tags: map[string]string{idempotencyKeyTag: "machine-uid"}},
+			{ID: "key-other", Name: "other-key", Tags: map[string]string{idempotencyKeyTag: "other-uid"}},
+			{ID: "key-untagged", Name: "untagged-key"},
+		},
+	}
+
+	reconciler := newTestReconciler(dataCrunchMachine, otherMachine)
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+
+	if err := reconciler.deleteOwnedSSHKeys(context.Background(), logr.Discard(), fakeCloud, dataCrunchMachine, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if !reflect.DeepEqual(fakeCloud.deletedSSHKeys, []string{"key-owned"}) {
+		t.Errorf("Expected only the owned, unshared key to be deleted, got %v", fakeCloud.deletedSSHKeys)
+	}
+	if !hasEvent(recorder, "SSHKeyDeleted") {
+		t.Error("Expected an SSHKeyDeleted event")
+	}
+}
+
+func TestDataCrunchMachineReconciler_deleteOwnedSSHKeys_ClusterDefault(t *testing.T) {
+	// The first machine created the default SSH key of the cluster, which both machines use
+	objs := newMachineTestObjects()
+	objs.dataCrunchCluster.Spec.DefaultSSHKeyName = "cluster-key"
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.UID = "machine-uid"
+	otherMachine := newMachineTestObjects().dataCrunchMachine
+	otherMachine.Name = "other-machine"
+	otherMachine.UID = "other-uid"
+
+	fakeCloud := &fakeCloudClient{
+		sshKeys: []*cloud.SSHKey{
+			{ID: "key-cluster", Name: "cluster-key", Tags: map[string]string{idempotencyKeyTag: "machine-uid"}},
+		},
+	}
+
+	reconciler := newTestReconciler(dataCrunchMachine, otherMachine)
+	if err := reconciler.deleteOwnedSSHKeys(context.Background(), logr.Discard(), fakeCloud, dataCrunchMachine, objs.dataCrunchCluster); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if len(fakeCloud.deletedSSHKeys) != 0 {
+		t.Errorf("Expected the default SSH key of the cluster to be kept for the other machine, got %v deleted", fakeCloud.deletedSSHKeys)
+	}
+}
+
+func TestDataCrunchMachineReconciler_deleteOwnedSSHKeys_NoUID(t *testing.T) {
+	fakeCloud := &fakeCloudClient{
+		sshKeys: []*cloud.SSHKey{
+			{ID: "key-untagged", Name: "untagged-key"},
+		},
+	}
+
+	reconciler := &DataCrunchMachineReconciler{Recorder: record.NewFakeRecorder(10)}
+	if err := reconciler.deleteOwnedSSHKeys(context.Background(), logr.Discard(), fakeCloud, &infrav1beta1.DataCrunchMachine{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if len(fakeCloud.deletedSSHKeys) != 0 {
+		t.Errorf("Expected no keys to be deleted, got %v", fakeCloud.deletedSSHKeys)
+	}
+}
+
+func TestDataCrunchMachineReconciler_findInstance(t *testing.T) {
+	tests := []struct {
+		name        string
+		machine     *infrav1beta1.DataCrunchMachine
+		expectFound bool
+	}{
+		{
+			name: "machine without provider ID",
+			machine: &infrav1beta1.DataCrunchMachine{
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType: "1xH100.80G",
+				},
+			},
+			expectFound: false,
+		},
+		{
+			name: "machine with empty provider ID",
+			machine: &infrav1beta1.DataCrunchMachine{
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType: "1xH100.80G",
+					ProviderID:   func() *string { s := ""; return &s }(),
+				},
+			},
+			expectFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reconciler := &DataCrunchMachineReconciler{}
+
+			instance, err := reconciler.findInstance(context.Background(), nil, &clusterv1.Machine{}, tt.machine, &clusterv1.Cluster{})
+
+			// With nil client and no provider ID, we should get nil instance and no error
+			if err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+			if instance != nil {
+				t.Error("Expected no instance with nil client")
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_findInstance_NotFound(t *testing.T) {
+	server := newFakeAPI(t, map[string]http.HandlerFunc{
+		"/instances/failing-instance": respondStatus(http.StatusInternalServerError),
+	})
+	dataCrunchClient := datacrunch.NewClientWithURL("client-id", "client-secret", server.URL)
+
+	reconciler := &DataCrunchMachineReconciler{}
+	for _, tt := range []struct {
+		providerID string
+		wantErr    bool
+	}{
+		{providerID: "datacrunch://missing-instance"},
+		{providerID: "datacrunch://failing-instance", wantErr: true},
+	} {
+		dataCrunchMachine := &infrav1beta1.DataCrunchMachine{Spec: infrav1beta1.DataCrunchMachineSpec{ProviderID: &tt.providerID}}
+		instance, err := reconciler.findInstance(context.Background(), dataCrunchClient, &clusterv1.Machine{}, dataCrunchMachine, &clusterv1.Cluster{})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("findInstance(%s) error = %v, wantErr %v", tt.providerID, err, tt.wantErr)
+		}
+		if instance != nil {
+			t.Errorf("Expected no instance for %s, got %s", tt.providerID, instance.ID)
+		}
+	}
+}
+
+func TestDataCrunchMachineReconciler_findInstance_WithoutProviderID(t *testing.T) {
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	nameTags := func(machineName, clusterName string) map[string]string {
+		return map[string]string{"cluster.x-k8s.io/machine-name": machineName, "cluster.x-k8s.io/cluster-name": clusterName}
+	}
+
+	tests := []struct {
+		name      string
+		instances []*cloud.Instance
+		wantID    string
+	}{
+		{
+			name: "found by machine name tag",
+			instances: []*cloud.Instance{
+				{ID: "other-instance", Tags: nameTags("other-machine", "test-cluster")},
+				{ID: "instance-123", Tags: nameTags("test-machine", "test-cluster")},
+			},
+			wantID: "instance-123",
+		},
+		{
+			name: "UID tag takes precedence",
+			instances: []*cloud.Instance{
+				{ID: "instance-123", Tags: nameTags("test-machine", "test-cluster")},
+				{ID: "instance-456", Tags: map[string]string{idempotencyKeyTag: "machine-uid"}},
+			},
+			wantID: "instance-456",
+		},
+		{
+			name:      "machine of another cluster",
+			instances: []*cloud.Instance{{ID: "instance-123", Tags: nameTags("test-machine", "other-cluster")}},
+		},
+		{
+			name: "instance of another DataCrunchMachine with the same name",
+			instances: []*cloud.Instance{{ID: "instance-123", Tags: map[string]string{
+				"cluster.x-k8s.io/machine-name": "test-machine",
+				"cluster.x-k8s.io/cluster-name": "test-cluster",
+				idempotencyKeyTag:               "other-uid",
+			}}},
+		},
+		{
+			name: "terminated and shutting down instances",
+			instances: []*cloud.Instance{
+				{ID: "instance-123", State: "terminated", Tags: map[string]string{idempotencyKeyTag: "machine-uid"}},
+				{ID: "instance-456", State: "shutting-down", Tags: nameTags("test-machine", "test-cluster")},
+			},
+		},
+		{
+			name: "no instances",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default", UID: "machine-uid"},
+			}
+
+			reconciler := &DataCrunchMachineReconciler{}
+			instance, err := reconciler.findInstance(context.Background(), &fakeCloudClient{instances: tt.instances}, machine, dataCrunchMachine, cluster)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if tt.wantID == "" {
+				if instance != nil {
+					t.Errorf("Expected no instance, got %s", instance.ID)
+				}
+				return
+			}
+			if instance == nil || instance.ID != tt.wantID {
+				t.Errorf("Expected instance %s, got %v", tt.wantID, instance)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileDelete_WithoutProviderID(t *testing.T) {
+	var deleted []string
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"GET /instances": respondJSON(`{"instances":[
+			{"id":"instance-other","status":"running","tags":{"cluster.x-k8s.io/cluster-name":"test-cluster","cluster.x-k8s.io/machine-name":"other-machine"}},
+			{"id":"instance-123","status":"running","tags":{"cluster.x-k8s.io/cluster-name":"test-cluster","cluster.x-k8s.io/machine-name":"test-machine"}}
+		]}`),
+		"DELETE /instances/instance-123": func(w http.ResponseWriter, _ *http.Request) {
+			deleted = append(deleted, "instance-123")
+			w.WriteHeader(http.StatusNoContent)
+		},
+		"DELETE /instances/instance-other": func(w http.ResponseWriter, _ *http.Request) {
+			deleted = append(deleted, "instance-other")
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+
+	// The instance was created but the patch writing the provider ID failed
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+
+	reconciler := newTestReconciler()
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+	if _, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if !reflect.DeepEqual(deleted, []string{"instance-123"}) {
+		t.Errorf("Expected the tagged instance to be deleted, got %v", deleted)
+	}
+	if len(dataCrunchMachine.Finalizers) != 0 {
+		t.Errorf("Expected the finalizer to be removed, got %v", dataCrunchMachine.Finalizers)
+	}
+	if !hasEvent(recorder, "InstanceDeleted") {
+		t.Error("Expected an InstanceDeleted event")
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileDelete_LookupFailureKeepsFinalizer(t *testing.T) {
+	var deleted []string
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		// A Retry-After beyond the longest retry delay makes the client fail without retrying
+		"GET /instances": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+		"DELETE /instances/{id}": func(w http.ResponseWriter, r *http.Request) {
+			deleted = append(deleted, r.PathValue("id"))
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+
+	// Without a provider ID the instance is looked up by listing the instances
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+
+	reconciler := newTestReconciler()
+	result, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{})
+	if err == nil {
+		t.Fatal("Expected an error when the instances can't be listed")
+	}
+	if result.RequeueAfter == 0 {
+		t.Error("Expected a requeue to retry the lookup")
+	}
+
+	if !controllerutil.ContainsFinalizer(dataCrunchMachine, infrav1beta1.MachineFinalizer) {
+		t.Error("Expected the finalizer to be kept while the instance can't be looked up")
+	}
+	if len(deleted) != 0 {
+		t.Errorf("Expected no instance to be deleted, got %v", deleted)
+	}
+	if reason := conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceReadyCondition); reason != infrav1beta1.InstanceLookupFailedReason {
+		t.Errorf("Expected reason %s, got %q", infrav1beta1.InstanceLookupFailedReason, reason)
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileDelete_MissingCredentialsKeepsFinalizer(t *testing.T) {
+	// The credentials Secret was deleted along with the namespace before the machine
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+	dataCrunchMachine.Spec.CredentialsRef = &corev1.SecretReference{Name: "tenant-credentials"}
+
+	reconciler := newTestReconciler()
+	if _, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err == nil {
+		t.Fatal("Expected an error when the credentials Secret is missing")
+	}
+
+	if !controllerutil.ContainsFinalizer(dataCrunchMachine, infrav1beta1.MachineFinalizer) {
+		t.Error("Expected the finalizer to be kept while the instance can't be looked up")
+	}
+	if reason := conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceReadyCondition); reason != infrav1beta1.DataCrunchClientFailedReason {
+		t.Errorf("Expected reason %s, got %q", infrav1beta1.DataCrunchClientFailedReason, reason)
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance(t *testing.T) {
+	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-machine",
+		},
+		Spec: infrav1beta1.DataCrunchMachineSpec{
+			InstanceType: "1xH100.80G",
+			Image:        "ubuntu-20.04",
+			SSHKeyName:   "my-key",
+		},
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-machine",
+		},
+	}
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-cluster",
+		},
+	}
+
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-cluster",
+		},
+	}
+
+	reconciler := &DataCrunchMachineReconciler{}
+	log := logr.Discard()
+
+	// This will fail without a real client, but we're testing the method exists
+	_, err := reconciler.createInstance(context.Background(), log, nil, machine, dataCrunchMachine, cluster, dataCrunchCluster)
+	// We expect this to fail with nil client, so we check that it doesn't panic
+	if err == nil {
+		t.Error("Expected error with nil client")
+	}
+}
+
+func TestDataCrunchMachineReconciler_getBootstrapData(t *testing.T) {
+	reconciler := &DataCrunchMachineReconciler{}
+
+	// Test with machine without bootstrap data ref
+	machine := &clusterv1.Machine{
 		Spec: clusterv1.MachineSpec{
 			Bootstrap: clusterv1.Bootstrap{},
 		},
@@ -379,7 +1368,7 @@ func TestDataCrunchMachineReconciler_createDataCrunchClient(t *testing.T) {
 		},
 	}
 
-	client, err := reconciler.createDataCrunchClient(context.Background(), cluster)
+	client, err := reconciler.createDataCrunchClient(context.Background(), cluster, &infrav1beta1.DataCrunchCluster{}, nil)
 	// We expect this to fail without proper credentials, but testing method signature
 	if err != nil {
 		t.Logf("createDataCrunchClient completed with expected error: %v", err)
@@ -390,9 +1379,7 @@ func TestDataCrunchMachineReconciler_createDataCrunchClient(t *testing.T) {
 }
 
 func TestDataCrunchMachineReconciler_SetupWithManager(t *testing.T) {
-	scheme := runtime.NewScheme()
-	_ = infrav1beta1.AddToScheme(scheme)
-	_ = clusterv1.AddToScheme(scheme)
+	scheme := newTestScheme()
 
 	reconciler := &DataCrunchMachineReconciler{
 		Scheme: scheme,
@@ -410,3 +1397,3407 @@ func TestDataCrunchMachineReconciler_SetupWithManager(t *testing.T) {
 	// We expect this to fail since mgr is nil, so we don't check the error
 	_ = err
 }
+
+func TestDataCrunchMachineReconciler_validateResourceOverrides(t *testing.T) {
+	int32Ptr := func(i int32) *int32 { return &i }
+
+	fakeClient := &fakeCloudClient{
+		instanceTypes: []*cloud.InstanceType{
+			{
+				Name:         "CPU.FLEX",
+				Customizable: true,
+				MinVCPUs:     2,
+				MaxVCPUs:     64,
+				MinMemoryGB:  4,
+				MaxMemoryGB:  256,
+			},
+			{
+				Name: "1H100.80S.32V",
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		instanceType string
+		vcpus        *int32
+		memoryGB     *int32
+		wantErr      string
+	}{
+		{
+			name:         "no overrides",
+			instanceType: "1H100.80S.32V",
+		},
+		{
+			name:         "valid overrides",
+			instanceType: "CPU.FLEX",
+			vcpus:        int32Ptr(16),
+			memoryGB:     int32Ptr(64),
+		},
+		{
+			name:         "vcpus above range",
+			instanceType: "CPU.FLEX",
+			vcpus:        int32Ptr(128),
+			wantErr:      "vcpus 128 is outside the allowed range",
+		},
+		{
+			name:         "memory below range",
+			instanceType: "CPU.FLEX",
+			memoryGB:     int32Ptr(2),
+			wantErr:      "memoryGB 2 is outside the allowed range",
+		},
+		{
+			name:         "type without customization",
+			instanceType: "1H100.80S.32V",
+			vcpus:        int32Ptr(16),
+			wantErr:      "does not support vCPU/memory customization",
+		},
+		{
+			name:         "unknown type",
+			instanceType: "unknown",
+			vcpus:        int32Ptr(16),
+			wantErr:      "instance type unknown not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reconciler := &DataCrunchMachineReconciler{}
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType: tt.instanceType,
+					VCPUs:        tt.vcpus,
+					MemoryGB:     tt.memoryGB,
+				},
+			}
+
+			err := reconciler.validateResourceOverrides(context.Background(), fakeClient, dataCrunchMachine)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error but got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileSSHKeyDrift(t *testing.T) {
+	tests := []struct {
+		name          string
+		sshKeyName    string
+		defaultSSHKey string
+		instanceKey   string
+		updateErr     error
+		wantUpdated   string
+		wantErr       bool
+		wantReason    string
+		wantSynced    bool
+		wantEvent     string
+	}{
+		{
+			name:        "SSH key unchanged",
+			sshKeyName:  "key-a",
+			instanceKey: "key-a",
+			wantSynced:  true,
+		},
+		{
+			name:        "no SSH key in spec",
+			instanceKey: "key-a",
+		},
+		{
+			name:        "SSH key changed",
+			sshKeyName:  "key-b",
+			instanceKey: "key-a",
+			wantUpdated: "key-b",
+			wantSynced:  true,
+			wantEvent:   "SSHKeyUpdated",
+		},
+		{
+			name:          "cluster default SSH key changed",
+			defaultSSHKey: "key-b",
+			instanceKey:   "key-a",
+			wantUpdated:   "key-b",
+			wantSynced:    true,
+			wantEvent:     "SSHKeyUpdated",
+		},
+		{
+			name:        "SSH key update not supported",
+			sshKeyName:  "key-b",
+			instanceKey: "key-a",
+			updateErr:   fmt.Errorf("failed to update instance SSH key: %w", cloud.ErrOperationNotSupported),
+			wantReason:  infrav1beta1.SSHKeyReplacementRequiredReason,
+			wantEvent:   infrav1beta1.SSHKeyReplacementRequiredReason,
+		},
+		{
+			name:        "SSH key update failed",
+			sshKeyName:  "key-b",
+			instanceKey: "key-a",
+			updateErr:   errors.New("failed to update instance SSH key, status: 500"),
+			wantErr:     true,
+			wantReason:  infrav1beta1.SSHKeyUpdateFailedReason,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType: "1H100.80S.32V",
+					SSHKeyName:   tt.sshKeyName,
+				},
+			}
+			dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+				Spec: infrav1beta1.DataCrunchClusterSpec{DefaultSSHKeyName: tt.defaultSSHKey},
+			}
+			instance := &cloud.Instance{ID: "instance-123", SSHKeyName: tt.instanceKey, State: "running"}
+
+			fakeClient := &fakeCloudClient{updateSSHKeyErr: tt.updateErr}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &DataCrunchMachineReconciler{Recorder: recorder}
+
+			err := reconciler.reconcileSSHKeyDrift(context.Background(), logr.Discard(), fakeClient, dataCrunchMachine, dataCrunchCluster, instance)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Expected error %v, got: %v", tt.wantErr, err)
+			}
+
+			if got := fakeClient.updatedSSHKeys["instance-123"]; got != tt.wantUpdated {
+				t.Errorf("Expected SSH key update to %q, got %q", tt.wantUpdated, got)
+			}
+			if tt.wantSynced && !conditions.IsTrue(dataCrunchMachine, infrav1beta1.SSHKeySyncedCondition) {
+				t.Error("Expected SSHKeySynced condition to be true")
+			}
+			if tt.wantReason != "" {
+				if !conditions.IsFalse(dataCrunchMachine, infrav1beta1.SSHKeySyncedCondition) ||
+					conditions.GetReason(dataCrunchMachine, infrav1beta1.SSHKeySyncedCondition) != tt.wantReason {
+					t.Errorf("Expected SSHKeySynced condition false with reason %s", tt.wantReason)
+				}
+			}
+			if !tt.wantSynced && tt.wantReason == "" && conditions.Has(dataCrunchMachine, infrav1beta1.SSHKeySyncedCondition) {
+				t.Error("Expected no SSHKeySynced condition")
+			}
+			if tt.wantEvent != "" && !hasEvent(recorder, tt.wantEvent) {
+				t.Errorf("Expected %s event", tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileSecurityGroupDrift(t *testing.T) {
+	tests := []struct {
+		name             string
+		interfaces       []infrav1beta1.NetworkInterface
+		instanceGroups   []string
+		updateErr        error
+		wantUpdated      []string
+		wantErr          bool
+		wantReason       string
+		wantSynced       bool
+		wantNoUpdateCall bool
+	}{
+		{
+			name:             "security groups unchanged",
+			interfaces:       []infrav1beta1.NetworkInterface{{SecurityGroupIDs: []string{"sg-2", "sg-1"}}},
+			instanceGroups:   []string{"sg-1", "sg-2"},
+			wantSynced:       true,
+			wantNoUpdateCall: true,
+		},
+		{
+			name:             "no security groups in spec",
+			interfaces:       []infrav1beta1.NetworkInterface{{SubnetID: "subnet-a"}},
+			instanceGroups:   []string{"sg-1"},
+			wantNoUpdateCall: true,
+		},
+		{
+			name: "security groups changed",
+			interfaces: []infrav1beta1.NetworkInterface{
+				{SecurityGroupIDs: []string{"sg-3"}},
+				{SecurityGroupIDs: []string{"sg-1", "sg-3"}},
+			},
+			instanceGroups: []string{"sg-1", "sg-2"},
+			wantUpdated:    []string{"sg-1", "sg-3"},
+			wantSynced:     true,
+		},
+		{
+			name:             "security group update not supported",
+			interfaces:       []infrav1beta1.NetworkInterface{{SecurityGroupIDs: []string{"sg-3"}}},
+			instanceGroups:   []string{"sg-1"},
+			updateErr:        fmt.Errorf("failed to update instance security groups: %w", cloud.ErrOperationNotSupported),
+			wantReason:       infrav1beta1.SecurityGroupsReplacementRequiredReason,
+			wantNoUpdateCall: true,
+		},
+		{
+			name:             "security group update failed",
+			interfaces:       []infrav1beta1.NetworkInterface{{SecurityGroupIDs: []string{"sg-3"}}},
+			instanceGroups:   []string{"sg-1"},
+			updateErr:        errors.New("failed to update instance security groups, status: 500"),
+			wantErr:          true,
+			wantReason:       infrav1beta1.SecurityGroupsUpdateFailedReason,
+			wantNoUpdateCall: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType:      "1H100.80S.32V",
+					NetworkInterfaces: tt.interfaces,
+				},
+			}
+			instance := &cloud.Instance{ID: "instance-123", SecurityGroupIDs: tt.instanceGroups, State: "running"}
+
+			fakeClient := &fakeCloudClient{updateSecurityGroupsErr: tt.updateErr}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &DataCrunchMachineReconciler{Recorder: recorder}
+
+			err := reconciler.reconcileSecurityGroupDrift(context.Background(), logr.Discard(), fakeClient, dataCrunchMachine, instance)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Expected error %v, got: %v", tt.wantErr, err)
+			}
+
+			updated, called := fakeClient.updatedSecurityGroups["instance-123"]
+			if tt.wantNoUpdateCall && called {
+				t.Errorf("Expected no security group update, got %v", updated)
+			}
+			if tt.wantUpdated != nil && !reflect.DeepEqual(updated, tt.wantUpdated) {
+				t.Errorf("Expected security groups to be updated to %v, got %v", tt.wantUpdated, updated)
+			}
+			if tt.wantUpdated != nil && !hasEvent(recorder, "SecurityGroupsUpdated") {
+				t.Error("Expected SecurityGroupsUpdated event")
+			}
+			if tt.wantSynced && !conditions.IsTrue(dataCrunchMachine, infrav1beta1.SecurityGroupsSyncedCondition) {
+				t.Error("Expected SecurityGroupsSynced condition to be true")
+			}
+			if tt.wantReason != "" {
+				if !conditions.IsFalse(dataCrunchMachine, infrav1beta1.SecurityGroupsSyncedCondition) ||
+					conditions.GetReason(dataCrunchMachine, infrav1beta1.SecurityGroupsSyncedCondition) != tt.wantReason {
+					t.Errorf("Expected SecurityGroupsSynced condition false with reason %s", tt.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileImageDrift(t *testing.T) {
+	boolPtr := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		name         string
+		image        string
+		allowReplace *bool
+		instanceImg  string
+		wantReplaced bool
+	}{
+		{
+			name:        "image unchanged",
+			image:       "ubuntu-22.04",
+			instanceImg: "ubuntu-22.04",
+		},
+		{
+			name:        "default image unchanged",
+			instanceImg: infrav1beta1.DefaultImage,
+		},
+		{
+			name:        "image changed without opt-in",
+			image:       "ubuntu-24.04",
+			instanceImg: "ubuntu-22.04",
+		},
+		{
+			name:         "image changed with replacement disabled",
+			image:        "ubuntu-24.04",
+			allowReplace: boolPtr(false),
+			instanceImg:  "ubuntu-22.04",
+		},
+		{
+			name:         "image changed with replacement allowed",
+			image:        "ubuntu-24.04",
+			allowReplace: boolPtr(true),
+			instanceImg:  "ubuntu-22.04",
+			wantReplaced: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providerID := "datacrunch://instance-123"
+			state := infrav1beta1.InstanceStateRunning
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType:          "1H100.80S.32V",
+					Image:                 tt.image,
+					AllowImageReplacement: tt.allowReplace,
+					ProviderID:            &providerID,
+				},
+				Status: infrav1beta1.DataCrunchMachineStatus{
+					Ready:         true,
+					InstanceState: &state,
+				},
+			}
+			instance := &cloud.Instance{ID: "instance-123", ImageID: tt.instanceImg, State: "running"}
+
+			fakeClient := &fakeCloudClient{}
+			reconciler := &DataCrunchMachineReconciler{Recorder: record.NewFakeRecorder(10)}
+
+			replaced, err := reconciler.reconcileImageDrift(context.Background(), logr.Discard(), fakeClient, dataCrunchMachine, instance)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if replaced != tt.wantReplaced {
+				t.Fatalf("Expected replaced=%v, got %v", tt.wantReplaced, replaced)
+			}
+
+			if !tt.wantReplaced {
+				if len(fakeClient.deleted) != 0 {
+					t.Errorf("Expected no instance deletion, got %v", fakeClient.deleted)
+				}
+				if dataCrunchMachine.Spec.ProviderID == nil {
+					t.Error("Expected ProviderID to be preserved")
+				}
+				return
+			}
+
+			if len(fakeClient.deleted) != 1 || fakeClient.deleted[0] != "instance-123" {
+				t.Errorf("Expected instance-123 to be deleted, got %v", fakeClient.deleted)
+			}
+			if dataCrunchMachine.Spec.ProviderID != nil {
+				t.Error("Expected ProviderID to be cleared for re-creation")
+			}
+			if dataCrunchMachine.Status.Ready {
+				t.Error("Expected machine to be marked not ready during replacement")
+			}
+			if !conditions.IsFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition) ||
+				conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceReadyCondition) != infrav1beta1.InstanceReplacingReason {
+				t.Error("Expected InstanceReady condition to be false with InstanceReplacing reason")
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileImageDrift_ReplacedInstanceNotAdopted(t *testing.T) {
+	allowReplace := true
+	providerID := "datacrunch://instance-123"
+	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default", UID: "machine-uid"},
+		Spec: infrav1beta1.DataCrunchMachineSpec{
+			InstanceType:          "1H100.80S.32V",
+			Image:                 "ubuntu-24.04",
+			AllowImageReplacement: &allowReplace,
+			ProviderID:            &providerID,
+		},
+	}
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	instance := &cloud.Instance{
+		ID:      "instance-123",
+		ImageID: "ubuntu-22.04",
+		State:   "running",
+		Tags: map[string]string{
+			idempotencyKeyTag:               "machine-uid",
+			"cluster.x-k8s.io/machine-name": "test-machine",
+			"cluster.x-k8s.io/cluster-name": "test-cluster",
+		},
+	}
+	fakeClient := &fakeCloudClient{instances: []*cloud.Instance{instance}}
+	reconciler := &DataCrunchMachineReconciler{Recorder: record.NewFakeRecorder(10)}
+
+	replaced, err := reconciler.reconcileImageDrift(context.Background(), logr.Discard(), fakeClient, dataCrunchMachine, instance)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !replaced {
+		t.Fatal("Expected the instance to be replaced")
+	}
+
+	// The next reconcile still lists the old instance while the API terminates it
+	instance.State = "terminated"
+	found, err := reconciler.findInstance(context.Background(), fakeClient, machine, dataCrunchMachine, cluster)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if found != nil {
+		t.Errorf("Expected the terminated instance not to be adopted again, got %s", found.ID)
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileBootstrapDataDrift(t *testing.T) {
+	boolPtr := func(b bool) *bool { return &b }
+	currentHash := bootstrapDataHash([]byte("#cloud-config\n"))
+	previousHash := bootstrapDataHash([]byte("#cloud-config\nruncmd: [kubeadm join]\n"))
+
+	tests := []struct {
+		name         string
+		reBootstrap  *bool
+		recordedHash string
+		wantReplaced bool
+		wantHash     string
+	}{
+		{
+			name:         "bootstrap data changed without opt-in",
+			recordedHash: previousHash,
+			wantHash:     previousHash,
+		},
+		{
+			name:         "bootstrap data changed with re-bootstrap disabled",
+			reBootstrap:  boolPtr(false),
+			recordedHash: previousHash,
+			wantHash:     previousHash,
+		},
+		{
+			name:         "bootstrap data unchanged",
+			reBootstrap:  boolPtr(true),
+			recordedHash: currentHash,
+			wantHash:     currentHash,
+		},
+		{
+			name:        "no recorded hash",
+			reBootstrap: boolPtr(true),
+			wantHash:    currentHash,
+		},
+		{
+			name:         "bootstrap data changed with re-bootstrap enabled",
+			reBootstrap:  boolPtr(true),
+			recordedHash: previousHash,
+			wantReplaced: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.InstanceType = "1H100.80S.32V"
+			dataCrunchMachine.Spec.ReBootstrapOnChange = tt.reBootstrap
+			dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+			dataCrunchMachine.Status = infrav1beta1.DataCrunchMachineStatus{
+				Ready:             true,
+				InstanceState:     ptrTo(infrav1beta1.InstanceStateRunning),
+				BootstrapDataHash: tt.recordedHash,
+			}
+			instance := &cloud.Instance{ID: "instance-123", State: "running"}
+
+			fakeClient := &fakeCloudClient{}
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+			recorder := reconciler.Recorder.(*record.FakeRecorder)
+
+			replaced, err := reconciler.reconcileBootstrapDataDrift(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, instance)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if replaced != tt.wantReplaced {
+				t.Fatalf("Expected replaced=%v, got %v", tt.wantReplaced, replaced)
+			}
+			if got := dataCrunchMachine.Status.BootstrapDataHash; got != tt.wantHash {
+				t.Errorf("Expected bootstrap data hash %q, got %q", tt.wantHash, got)
+			}
+
+			if !tt.wantReplaced {
+				if len(fakeClient.deleted) != 0 {
+					t.Errorf("Expected no instance deletion, got %v", fakeClient.deleted)
+				}
+				if dataCrunchMachine.Spec.ProviderID == nil {
+					t.Error("Expected ProviderID to be preserved")
+				}
+				return
+			}
+
+			if len(fakeClient.deleted) != 1 || fakeClient.deleted[0] != "instance-123" {
+				t.Errorf("Expected instance-123 to be deleted, got %v", fakeClient.deleted)
+			}
+			if dataCrunchMachine.Spec.ProviderID != nil {
+				t.Error("Expected ProviderID to be cleared for re-creation")
+			}
+			if dataCrunchMachine.Status.Ready {
+				t.Error("Expected machine to be marked not ready during replacement")
+			}
+			if conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceReadyCondition) != infrav1beta1.InstanceReplacingReason {
+				t.Error("Expected InstanceReady condition to be false with InstanceReplacing reason")
+			}
+			if !hasEvent(recorder, "InstanceReplaced") {
+				t.Error("Expected InstanceReplaced event")
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_BootstrapDataTransform(t *testing.T) {
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.Spec.InstanceType = "1H100.80S.32V"
+
+	reconciler := newTestReconciler(objs.bootstrapSecret)
+	reconciler.BootstrapDataTransform = func(_ context.Context, _ *clusterv1.Machine, data []byte) ([]byte, error) {
+		return append(data, []byte("bootcmd: [echo proxy]\n")...), nil
+	}
+
+	fakeClient := &fakeCloudClient{}
+	if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if len(fakeClient.created) != 1 {
+		t.Fatalf("Expected 1 instance to be created, got %d", len(fakeClient.created))
+	}
+
+	userData, err := base64.StdEncoding.DecodeString(fakeClient.created[0].UserData)
+	if err != nil {
+		t.Fatalf("Expected base64 encoded user data: %v", err)
+	}
+	if string(userData) != "#cloud-config\nbootcmd: [echo proxy]\n" {
+		t.Errorf("Expected transformed user data, got %q", string(userData))
+	}
+	if got := dataCrunchMachine.Status.BootstrapDataHash; got != bootstrapDataHash([]byte("#cloud-config\n")) {
+		t.Errorf("Expected the hash of the untransformed bootstrap data to be recorded, got %q", got)
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_StartupScript(t *testing.T) {
+	objs := newMachineTestObjects()
+	bootstrapSecret := objs.bootstrapSecret
+	bootstrapSecret.Data["value"] = []byte("#cloud-config\nruncmd: [kubeadm join]\n")
+	scriptSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hardening",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"value": []byte("#!/bin/sh\nsysctl -w kernel.kptr_restrict=2\n"),
+		},
+	}
+
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.Spec.InstanceType = "1H100.80S.32V"
+	dataCrunchMachine.Spec.StartupScriptRef = &corev1.SecretReference{Name: "hardening"}
+
+	reconciler := newTestReconciler(bootstrapSecret, scriptSecret)
+
+	fakeClient := &fakeCloudClient{}
+	if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	userData, err := base64.StdEncoding.DecodeString(fakeClient.created[0].UserData)
+	if err != nil {
+		t.Fatalf("Expected base64 encoded user data: %v", err)
+	}
+	want := mustMergeUserData(t, bootstrapSecret.Data["value"], scriptSecret.Data["value"])
+	if string(userData) != string(want) {
+		t.Errorf("Expected merged user data %q, got %q", string(want), string(userData))
+	}
+}
+
+func TestDataCrunchMachineReconciler_getStartupScript(t *testing.T) {
+	newSecret := func(name string, data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       data,
+		}
+	}
+
+	reconciler := newTestReconciler(
+		newSecret("valid", map[string][]byte{"value": []byte("#!/bin/bash\necho hardened\n")}),
+		newSecret("missing-key", map[string][]byte{"script": []byte("#!/bin/bash\n")}),
+		newSecret("no-shebang", map[string][]byte{"value": []byte("echo hardened\n")}),
+	)
+
+	tests := []struct {
+		name    string
+		ref     corev1.SecretReference
+		wantErr string
+	}{
+		{
+			name: "valid script",
+			ref:  corev1.SecretReference{Name: "valid"},
+		},
+		{
+			name: "valid script in the machine namespace",
+			ref:  corev1.SecretReference{Name: "valid", Namespace: "default"},
+		},
+		{
+			name:    "secret in another namespace",
+			ref:     corev1.SecretReference{Name: "valid", Namespace: "other"},
+			wantErr: "must be in the DataCrunchMachine namespace",
+		},
+		{
+			name:    "secret not found",
+			ref:     corev1.SecretReference{Name: "missing"},
+			wantErr: "failed to retrieve startup script secret",
+		},
+		{
+			name:    "missing key",
+			ref:     corev1.SecretReference{Name: "missing-key"},
+			wantErr: `missing the "value" key`,
+		},
+		{
+			name:    "script without shebang",
+			ref:     corev1.SecretReference{Name: "no-shebang"},
+			wantErr: "must start with a shebang line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := tt.ref
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+				Spec:       infrav1beta1.DataCrunchMachineSpec{StartupScriptRef: &ref},
+			}
+
+			script, err := reconciler.getStartupScript(context.Background(), dataCrunchMachine)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if string(script) != "#!/bin/bash\necho hardened\n" {
+				t.Errorf("Unexpected script %q", string(script))
+			}
+		})
+	}
+}
+
+func mustMergeUserData(t *testing.T, bootstrapData []byte, startupScripts ...[]byte) []byte {
+	t.Helper()
+	merged, err := mergeUserData(bootstrapData, startupScripts...)
+	if err != nil {
+		t.Fatalf("mergeUserData() error = %v", err)
+	}
+	return merged
+}
+
+func TestMergeUserData(t *testing.T) {
+	bootstrapData := []byte("#cloud-config\nruncmd: [kubeadm join]")
+	startupScript := []byte("#!/bin/sh\necho hardened\n")
+
+	merged := string(mustMergeUserData(t, bootstrapData, startupScript))
+	want := "MIME-Version: 1.0\n" +
+		"Content-Type: multipart/mixed; boundary=\"==DATACRUNCH-USER-DATA==\"\n" +
+		"\n--==DATACRUNCH-USER-DATA==\n" +
+		"Content-Type: text/cloud-config; charset=\"us-ascii\"\n\n" +
+		"#cloud-config\nruncmd: [kubeadm join]\n" +
+		"\n--==DATACRUNCH-USER-DATA==\n" +
+		"Content-Type: text/x-shellscript; charset=\"us-ascii\"\n\n" +
+		"#!/bin/sh\necho hardened\n" +
+		"\n--==DATACRUNCH-USER-DATA==--\n"
+	if merged != want {
+		t.Errorf("Unexpected merged user data:\n%s\nwant:\n%s", merged, want)
+	}
+
+	if again := string(mustMergeUserData(t, bootstrapData, startupScript)); again != merged {
+		t.Error("Expected merging the same inputs to be deterministic")
+	}
+
+	shellBootstrap := string(mustMergeUserData(t, []byte("#!/bin/bash\nkubeadm join\n"), startupScript))
+	if strings.Count(shellBootstrap, "Content-Type: text/x-shellscript") != 2 {
+		t.Errorf("Expected both parts to be shell scripts, got:\n%s", shellBootstrap)
+	}
+}
+
+func TestMergeUserData_Multipart(t *testing.T) {
+	startupScript := []byte("#!/bin/sh\necho hardened\n")
+	multipartBootstrap := []byte("MIME-Version: 1.0\n" +
+		"Content-Type: multipart/mixed; boundary=\"BOOTSTRAP\"\n" +
+		"\n--BOOTSTRAP\n" +
+		"Content-Type: text/cloud-config\n\n" +
+		"#cloud-config\nruncmd: [kubeadm join]\n" +
+		"\n--BOOTSTRAP\n" +
+		"Content-Disposition: attachment; filename=\"join.sh\"\n" +
+		"Content-Transfer-Encoding: base64\n" +
+		"Content-Type: text/x-shellscript\n\n" +
+		base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\nkubeadm join\n")) + "\n" +
+		"\n--BOOTSTRAP--\n")
+
+	tests := []struct {
+		name           string
+		bootstrapData  []byte
+		startupScripts [][]byte
+		wantBoundary   string
+		wantTypes      []string
+	}{
+		{
+			name:           "several startup scripts",
+			bootstrapData:  []byte("#cloud-config\nruncmd: [kubeadm join]\n"),
+			startupScripts: [][]byte{startupScript, []byte("#!/bin/bash\necho second\n")},
+			wantBoundary:   userDataBoundary,
+			wantTypes:      []string{"text/cloud-config", "text/x-shellscript", "text/x-shellscript"},
+		},
+		{
+			name:           "multi-part bootstrap data is flattened",
+			bootstrapData:  multipartBootstrap,
+			startupScripts: [][]byte{startupScript},
+			wantBoundary:   userDataBoundary,
+			wantTypes:      []string{"text/cloud-config", "text/x-shellscript", "text/x-shellscript"},
+		},
+		{
+			name:           "part containing the boundary",
+			bootstrapData:  []byte("#!/bin/bash\necho --" + userDataBoundary + "\n"),
+			startupScripts: [][]byte{startupScript},
+			wantBoundary:   "==DATACRUNCH-USER-DATA-1==",
+			wantTypes:      []string{"text/x-shellscript", "text/x-shellscript"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mustMergeUserData(t, tt.bootstrapData, tt.startupScripts...)
+
+			msg, err := mail.ReadMessage(bytes.NewReader(merged))
+			if err != nil {
+				t.Fatalf("Expected a MIME document: %v", err)
+			}
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/mixed" {
+				t.Fatalf("Expected a multipart/mixed document, got %q", msg.Header.Get("Content-Type"))
+			}
+			if params["boundary"] != tt.wantBoundary {
+				t.Errorf("Expected boundary %q, got %q", tt.wantBoundary, params["boundary"])
+			}
+
+			var types []string
+			reader := multipart.NewReader(msg.Body, params["boundary"])
+			for {
+				part, err := reader.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Failed to read part: %v", err)
+				}
+				partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+				types = append(types, partType)
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("Expected parts %v, got %v", tt.wantTypes, types)
+			}
+
+			if err := validateUserData(base64.StdEncoding.EncodeToString(merged)); err != nil {
+				t.Errorf("Expected merged user data to be valid: %v", err)
+			}
+		})
+	}
+
+	if !strings.Contains(string(mustMergeUserData(t, multipartBootstrap, startupScript)), "Content-Disposition: attachment; filename=\"join.sh\"\nContent-Transfer-Encoding: base64\n") {
+		t.Error("Expected the headers of bootstrap parts to be kept")
+	}
+
+	if _, err := mergeUserData([]byte("MIME-Version: 1.0\nContent-Type: text/plain\n\nhello\n"), startupScript); err == nil {
+		t.Error("Expected an error for MIME bootstrap data that is not multi-part")
+	}
+}
+
+func TestValidateUserData(t *testing.T) {
+	encode := func(data []byte) string {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	cloudConfig := []byte("#cloud-config\nruncmd:\n  - echo hello\n")
+	invalidCloudConfig := []byte("#cloud-config\nruncmd: [unterminated\n")
+	script := []byte("#!/bin/bash\necho hello\n")
+
+	tests := []struct {
+		name     string
+		userData string
+		wantErr  string
+	}{
+		{
+			name:     "cloud-config",
+			userData: encode(cloudConfig),
+		},
+		{
+			name:     "shell script",
+			userData: encode(script),
+		},
+		{
+			name:     "merged startup script",
+			userData: encode(mustMergeUserData(t, cloudConfig, script)),
+		},
+		{
+			name:     "not base64",
+			userData: "#cloud-config\n",
+			wantErr:  "not valid base64",
+		},
+		{
+			name:     "truncated base64",
+			userData: encode(cloudConfig)[:10],
+			wantErr:  "not valid base64",
+		},
+		{
+			name:     "invalid cloud-config",
+			userData: encode(invalidCloudConfig),
+			wantErr:  "cloud-config is not valid YAML",
+		},
+		{
+			name:     "invalid cloud-config part",
+			userData: encode(mustMergeUserData(t, invalidCloudConfig, script)),
+			wantErr:  "invalid part 0 of MIME user data",
+		},
+		{
+			name:     "MIME without boundary",
+			userData: encode([]byte("MIME-Version: 1.0\nContent-Type: text/plain\n\nhello\n")),
+			wantErr:  "multipart/mixed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUserData(tt.userData)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error but got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_InvalidUserData(t *testing.T) {
+	objs := newMachineTestObjects()
+	objs.bootstrapSecret.Data["value"] = []byte("#cloud-config\nruncmd: [unterminated\n")
+	dataCrunchMachine := objs.dataCrunchMachine
+
+	reconciler := newTestReconciler(objs.bootstrapSecret)
+
+	fakeClient := &fakeCloudClient{}
+	_, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{})
+	if err == nil || !strings.Contains(err.Error(), "invalid user data") {
+		t.Errorf("Expected invalid user data error, got: %v", err)
+	}
+	if len(fakeClient.created) != 0 {
+		t.Error("Expected no instance to be created")
+	}
+
+	// A custom validator replaces the default one
+	var validated string
+	reconciler.UserDataValidator = func(userData string) error {
+		validated = userData
+		return nil
+	}
+	if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if validated == "" || validated != fakeClient.created[0].UserData {
+		t.Errorf("Expected the custom validator to receive the user data, got %q", validated)
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcilePricing(t *testing.T) {
+	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+		Spec: infrav1beta1.DataCrunchMachineSpec{
+			InstanceType: "1H100.80S.32V",
+		},
+	}
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		Spec: infrav1beta1.DataCrunchClusterSpec{
+			Region: "FIN-01",
+		},
+	}
+
+	reconciler := &DataCrunchMachineReconciler{}
+
+	// Pricing errors leave the status untouched
+	if err := reconciler.reconcilePricing(context.Background(), &fakeCloudClient{}, dataCrunchMachine, dataCrunchCluster); err == nil {
+		t.Error("Expected error when pricing is unavailable")
+	}
+	if dataCrunchMachine.Status.Pricing != nil {
+		t.Error("Expected pricing status to remain unset")
+	}
+
+	fakeClient := &fakeCloudClient{
+		price: &cloud.InstanceTypePrice{
+			InstanceType:  "1H100.80S.32V",
+			Region:        "FIN-01",
+			Currency:      "USD",
+			OnDemandPrice: 2.19,
+			SpotPrice:     0.99,
+		},
+	}
+	if err := reconciler.reconcilePricing(context.Background(), fakeClient, dataCrunchMachine, dataCrunchCluster); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	pricing := dataCrunchMachine.Status.Pricing
+	if pricing == nil {
+		t.Fatal("Expected pricing status to be set")
+	}
+	if pricing.OnDemandPrice != "2.19" || pricing.SpotPrice != "0.99" || pricing.Currency != "USD" {
+		t.Errorf("Unexpected pricing status: %+v", pricing)
+	}
+	if pricing.LastUpdated == nil {
+		t.Error("Expected LastUpdated to be set")
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       string
+		fakeClient  *fakeCloudClient
+		wantMetrics *infrav1beta1.InstanceMetrics
+		wantErr     bool
+	}{
+		{
+			name:  "running instance with metrics",
+			state: "running",
+			fakeClient: &fakeCloudClient{metrics: &cloud.InstanceMetrics{
+				InstanceID:        "instance-123",
+				GPUUtilization:    87.5,
+				CPUUtilization:    12.25,
+				MemoryUtilization: 40,
+				Timestamp:         "2024-06-28T12:00:00Z",
+			}},
+			wantMetrics: &infrav1beta1.InstanceMetrics{
+				GPUUtilization:    "87.5",
+				CPUUtilization:    "12.25",
+				MemoryUtilization: "40",
+				LastUpdated:       &metav1.Time{Time: time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:       "running instance without metrics yet",
+			state:      "running",
+			fakeClient: &fakeCloudClient{},
+		},
+		{
+			name:       "metrics not supported",
+			state:      "running",
+			fakeClient: &fakeCloudClient{metricsErr: cloud.ErrOperationNotSupported},
+		},
+		{
+			name:       "metrics request failed",
+			state:      "running",
+			fakeClient: &fakeCloudClient{metricsErr: errors.New("service unavailable")},
+			wantErr:    true,
+		},
+		{
+			name:       "stopped instance",
+			state:      "offline",
+			fakeClient: &fakeCloudClient{metrics: &cloud.InstanceMetrics{GPUUtilization: 50}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				Status: infrav1beta1.DataCrunchMachineStatus{
+					Metrics: &infrav1beta1.InstanceMetrics{GPUUtilization: "10"},
+				},
+			}
+			instance := &cloud.Instance{ID: "instance-123", State: tt.state}
+
+			reconciler := &DataCrunchMachineReconciler{}
+			err := reconciler.reconcileMetrics(context.Background(), tt.fakeClient, dataCrunchMachine, instance)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got: %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				if dataCrunchMachine.Status.Metrics == nil {
+					t.Error("Expected the previous metrics to be kept when the request fails")
+				}
+				return
+			}
+
+			if got := dataCrunchMachine.Status.Metrics; !reflect.DeepEqual(got, tt.wantMetrics) {
+				t.Errorf("Expected metrics %+v, got %+v", tt.wantMetrics, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_ReconcileTimeout(t *testing.T) {
+	// Simulate a hung API server by blocking until the request context is done
+	fakeClient := newTestClientBuilder().
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, _ client.WithWatch, _ client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}).
+		Build()
+
+	reconciler := newTestReconcilerWithClient(fakeClient)
+	reconciler.ReconcileTimeout = 50 * time.Millisecond
+
+	req := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "test-machine",
+			Namespace: "default",
+		},
+	}
+
+	_, err := reconciler.Reconcile(context.Background(), req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context deadline exceeded error, got: %v", err)
+	}
+}
+
+func TestDataCrunchMachineReconciler_Reconcile_MismatchedInfrastructureRef(t *testing.T) {
+	objs := newMachineTestObjects().linked()
+	// A DataCrunchCluster that happens to share the name must not be picked up
+	objs.cluster.Spec.InfrastructureRef = &corev1.ObjectReference{
+		APIVersion: "infrastructure.cluster.x-k8s.io/v1beta2",
+		Kind:       "AWSCluster",
+		Name:       "test-cluster",
+	}
+	dataCrunchMachine := objs.dataCrunchMachine
+
+	fakeClient := newTestClientBuilder(objs.all()...).Build()
+	reconciler := newTestReconcilerWithClient(fakeClient)
+
+	result, err := reconciler.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(dataCrunchMachine)})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if result.Requeue || result.RequeueAfter != 0 {
+		t.Errorf("Expected no requeue until the Cluster changes, got %+v", result)
+	}
+
+	got := &infrav1beta1.DataCrunchMachine{}
+	if err := fakeClient.Get(context.Background(), client.ObjectKeyFromObject(dataCrunchMachine), got); err != nil {
+		t.Fatalf("Failed to get DataCrunchMachine: %v", err)
+	}
+	condition := conditions.Get(got, infrav1beta1.InstanceReadyCondition)
+	if condition == nil || condition.Reason != infrav1beta1.InvalidInfrastructureRefReason {
+		t.Fatalf("Expected InstanceReady condition with reason %s, got %+v", infrav1beta1.InvalidInfrastructureRefReason, condition)
+	}
+	if !strings.Contains(condition.Message, "AWSCluster") {
+		t.Errorf("Expected the condition message to name the referenced kind, got %q", condition.Message)
+	}
+}
+
+func TestDataCrunchMachineReconciler_Reconcile_NilInfrastructureRef(t *testing.T) {
+	objs := newMachineTestObjects().linked()
+	objs.cluster.Spec.InfrastructureRef = nil
+	dataCrunchMachine := objs.dataCrunchMachine
+
+	fakeClient := newTestClientBuilder(objs.all()...).Build()
+	reconciler := newTestReconcilerWithClient(fakeClient)
+
+	result, err := reconciler.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(dataCrunchMachine)})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if result.RequeueAfter != 30*time.Second {
+		t.Errorf("Expected requeue after 30s while the reference is not set, got %+v", result)
+	}
+
+	got := &infrav1beta1.DataCrunchMachine{}
+	if err := fakeClient.Get(context.Background(), client.ObjectKeyFromObject(dataCrunchMachine), got); err != nil {
+		t.Fatalf("Failed to get DataCrunchMachine: %v", err)
+	}
+	condition := conditions.Get(got, infrav1beta1.InstanceReadyCondition)
+	if condition == nil || condition.Reason != infrav1beta1.WaitingForClusterInfrastructureReason {
+		t.Fatalf("Expected InstanceReady condition with reason %s, got %+v", infrav1beta1.WaitingForClusterInfrastructureReason, condition)
+	}
+	if condition.Message != "cluster infrastructure reference not set" {
+		t.Errorf("Expected the condition message to explain the missing reference, got %q", condition.Message)
+	}
+}
+
+func TestDataCrunchMachineReconciler_Reconcile_DeleteWithoutDataCrunchCluster(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  *corev1.ObjectReference
+	}{
+		{
+			name: "infrastructure reference not set",
+		},
+		{
+			name: "other provider's cluster",
+			ref:  &corev1.ObjectReference{APIVersion: "infrastructure.cluster.x-k8s.io/v1beta2", Kind: "AWSCluster", Name: "test-cluster"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed := false
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"GET /instances": func(w http.ResponseWriter, _ *http.Request) {
+					listed = true
+					_, _ = w.Write([]byte(`{"instances":[]}`))
+				},
+			})
+
+			objs := newMachineTestObjects().linked()
+			objs.cluster.Spec.InfrastructureRef = tt.ref
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+			fakeClient := newTestClientBuilder(objs.all()...).Build()
+			reconciler := newTestReconcilerWithClient(fakeClient)
+
+			if _, err := reconciler.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(dataCrunchMachine)}); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if !listed {
+				t.Error("Expected the instance of the machine to be looked up")
+			}
+			got := &infrav1beta1.DataCrunchMachine{}
+			if err := fakeClient.Get(context.Background(), client.ObjectKeyFromObject(dataCrunchMachine), got); err == nil {
+				t.Errorf("Expected the DataCrunchMachine to be deleted once the finalizer is removed, got finalizers %v", got.Finalizers)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_Reconcile_ForeignMachineOwner(t *testing.T) {
+	// The owner Machine is backed by another provider's machine that happens to share the name
+	objs := newMachineTestObjects().linked()
+	objs.machine.Spec.InfrastructureRef = corev1.ObjectReference{
+		APIVersion: "infrastructure.cluster.x-k8s.io/v1beta2",
+		Kind:       "AWSMachine",
+		Name:       "test-machine",
+	}
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.Finalizers = nil
+
+	fakeClient := newTestClientBuilder(objs.all()...).Build()
+	reconciler := newTestReconcilerWithClient(fakeClient)
+
+	result, err := reconciler.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(dataCrunchMachine)})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if result.Requeue || result.RequeueAfter != 0 {
+		t.Errorf("Expected no requeue until the Machine changes, got %+v", result)
+	}
+
+	got := &infrav1beta1.DataCrunchMachine{}
+	if err := fakeClient.Get(context.Background(), client.ObjectKeyFromObject(dataCrunchMachine), got); err != nil {
+		t.Fatalf("Failed to get DataCrunchMachine: %v", err)
+	}
+	condition := conditions.Get(got, infrav1beta1.InstanceReadyCondition)
+	if condition == nil || condition.Reason != infrav1beta1.ForeignMachineOwnerReason {
+		t.Fatalf("Expected InstanceReady condition with reason %s, got %+v", infrav1beta1.ForeignMachineOwnerReason, condition)
+	}
+	if !strings.Contains(condition.Message, "AWSMachine") {
+		t.Errorf("Expected the condition message to name the referenced kind, got %q", condition.Message)
+	}
+	if controllerutil.ContainsFinalizer(got, infrav1beta1.MachineFinalizer) {
+		t.Error("Expected no finalizer to be added to a machine that is not provisioned")
+	}
+}
+
+func TestValidateMachineInfrastructureRef(t *testing.T) {
+	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+	}
+
+	tests := []struct {
+		name    string
+		ref     corev1.ObjectReference
+		wantErr bool
+	}{
+		{
+			name: "this DataCrunchMachine",
+			ref:  corev1.ObjectReference{APIVersion: infrav1beta1.GroupVersion.String(), Kind: "DataCrunchMachine", Name: "test-machine", Namespace: "default"},
+		},
+		{
+			name: "without API version and namespace",
+			ref:  corev1.ObjectReference{Kind: "DataCrunchMachine", Name: "test-machine"},
+		},
+		{
+			name:    "another DataCrunchMachine",
+			ref:     corev1.ObjectReference{Kind: "DataCrunchMachine", Name: "other-machine"},
+			wantErr: true,
+		},
+		{
+			name:    "DataCrunchMachine in another namespace",
+			ref:     corev1.ObjectReference{Kind: "DataCrunchMachine", Name: "test-machine", Namespace: "other"},
+			wantErr: true,
+		},
+		{
+			name:    "other provider",
+			ref:     corev1.ObjectReference{APIVersion: "infrastructure.cluster.x-k8s.io/v1beta2", Kind: "AWSMachine", Name: "test-machine"},
+			wantErr: true,
+		},
+		{
+			name:    "same kind in another group",
+			ref:     corev1.ObjectReference{APIVersion: "example.com/v1", Kind: "DataCrunchMachine", Name: "test-machine"},
+			wantErr: true,
+		},
+		{
+			name:    "not set",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+				Spec:       clusterv1.MachineSpec{InfrastructureRef: tt.ref},
+			}
+			if err := validateMachineInfrastructureRef(machine, dataCrunchMachine); (err != nil) != tt.wantErr {
+				t.Errorf("validateMachineInfrastructureRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateInfrastructureRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     *corev1.ObjectReference
+		wantErr bool
+	}{
+		{
+			name: "DataCrunchCluster",
+			ref:  &corev1.ObjectReference{APIVersion: infrav1beta1.GroupVersion.String(), Kind: "DataCrunchCluster", Name: "test"},
+		},
+		{
+			name: "DataCrunchCluster without API version",
+			ref:  &corev1.ObjectReference{Kind: "DataCrunchCluster", Name: "test"},
+		},
+		{
+			name:    "no infrastructure reference",
+			wantErr: true,
+		},
+		{
+			name:    "other kind",
+			ref:     &corev1.ObjectReference{APIVersion: infrav1beta1.GroupVersion.String(), Kind: "DockerCluster", Name: "test"},
+			wantErr: true,
+		},
+		{
+			name:    "other API group",
+			ref:     &corev1.ObjectReference{APIVersion: "example.com/v1", Kind: "DataCrunchCluster", Name: "test"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &clusterv1.Cluster{Spec: clusterv1.ClusterSpec{InfrastructureRef: tt.ref}}
+			if err := validateInfrastructureRef(cluster); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_Reconcile_SkipsNoopPatch(t *testing.T) {
+	failureMessage := "Instance creation was rejected"
+
+	tests := []struct {
+		name       string
+		finalizers []string
+		wantWrite  bool
+	}{
+		{
+			name:       "no-op reconcile of a failed machine",
+			finalizers: []string{infrav1beta1.MachineFinalizer},
+			wantWrite:  false,
+		},
+		{
+			name:      "finalizer added",
+			wantWrite: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects().linked()
+			objs.dataCrunchMachine.Finalizers = tt.finalizers
+			if len(tt.finalizers) != 0 {
+				objs.dataCrunchMachine.Status.FailureMessage = &failureMessage
+			}
+
+			writes := 0
+			fakeClient := newTestClientBuilder(objs.all()...).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+						writes++
+						return c.Patch(ctx, obj, patch, opts...)
+					},
+					SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+						writes++
+						return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+					},
+				}).
+				Build()
+
+			reconciler := newTestReconcilerWithClient(fakeClient)
+
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "test-machine",
+					Namespace: "default",
+				},
+			}
+
+			if _, err := reconciler.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if got := writes > 0; got != tt.wantWrite {
+				t.Errorf("Expected write %v, got %d writes", tt.wantWrite, writes)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineChanged(t *testing.T) {
+	earlier := metav1.NewTime(time.Now().Add(-time.Minute))
+	later := metav1.Now()
+
+	before := &infrav1beta1.DataCrunchMachine{
+		Status: infrav1beta1.DataCrunchMachineStatus{
+			Pricing: &infrav1beta1.InstancePricing{Currency: "USD", OnDemandPrice: "2.19", LastUpdated: &earlier},
+		},
+	}
+
+	refreshed := before.DeepCopy()
+	refreshed.Status.Pricing.LastUpdated = &later
+	if dataCrunchMachineChanged(before, refreshed) {
+		t.Error("Expected a pricing timestamp refresh not to count as a change")
+	}
+
+	repriced := refreshed.DeepCopy()
+	repriced.Status.Pricing.OnDemandPrice = "2.49"
+	if !dataCrunchMachineChanged(before, repriced) {
+		t.Error("Expected a price change to count as a change")
+	}
+
+	ready := before.DeepCopy()
+	ready.Status.Ready = true
+	if !dataCrunchMachineChanged(before, ready) {
+		t.Error("Expected a status change to count as a change")
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_QuotaExceeded(t *testing.T) {
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"GET /instances": respondJSON(`{"instances":[]}`),
+		"POST /instances": func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"code":"quota_exceeded","message":"GPU quota exceeded for 1xH100"}`))
+		},
+	})
+
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+
+	reconciler := newTestReconciler(objs.bootstrapSecret)
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+
+	result, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{})
+	if err != nil {
+		t.Fatalf("Expected quota errors not to be returned, got: %v", err)
+	}
+	if result.RequeueAfter != quotaExceededRequeueAfter {
+		t.Errorf("Expected requeue after %s, got %s", quotaExceededRequeueAfter, result.RequeueAfter)
+	}
+
+	if !conditions.IsFalse(dataCrunchMachine, infrav1beta1.InstanceReadyCondition) {
+		t.Fatal("Expected InstanceReady condition to be false")
+	}
+	if reason := conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceReadyCondition); reason != infrav1beta1.QuotaExceededReason {
+		t.Errorf("Expected reason %s, got %s", infrav1beta1.QuotaExceededReason, reason)
+	}
+	if severity := conditions.GetSeverity(dataCrunchMachine, infrav1beta1.InstanceReadyCondition); severity == nil || *severity != clusterv1.ConditionSeverityWarning {
+		t.Errorf("Expected warning severity, got %v", severity)
+	}
+	if dataCrunchMachine.Status.FailureReason != nil || dataCrunchMachine.Status.FailureMessage != nil {
+		t.Error("Expected quota errors not to set a terminal failure")
+	}
+
+	if !hasEvent(recorder, infrav1beta1.QuotaExceededReason) {
+		t.Error("Expected a QuotaExceeded event to be recorded")
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_CreateErrorClassification(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		wantTerminal bool
+	}{
+		{
+			name:         "invalid spec",
+			status:       http.StatusBadRequest,
+			body:         `{"code":"invalid_request","message":"unknown instance type"}`,
+			wantTerminal: true,
+		},
+		{
+			name:         "unprocessable spec",
+			status:       http.StatusUnprocessableEntity,
+			wantTerminal: true,
+		},
+		{
+			name:   "server error",
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "rate limited",
+			status: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"GET /instances": respondJSON(`{"instances":[]}`),
+				"POST /instances": func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(tt.body))
+				},
+			})
+
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+			recorder := reconciler.Recorder.(*record.FakeRecorder)
+
+			result, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{})
+
+			if reason := conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceReadyCondition); reason != infrav1beta1.InstanceCreationFailedReason {
+				t.Errorf("Expected reason %s, got %s", infrav1beta1.InstanceCreationFailedReason, reason)
+			}
+
+			if tt.wantTerminal {
+				if err != nil {
+					t.Errorf("Expected terminal errors not to be returned, got: %v", err)
+				}
+				if result.Requeue || result.RequeueAfter != 0 {
+					t.Errorf("Expected no requeue for terminal errors, got %+v", result)
+				}
+				if dataCrunchMachine.Status.FailureReason == nil || *dataCrunchMachine.Status.FailureReason != capierrors.CreateMachineError {
+					t.Errorf("Expected failure reason %s, got %v", capierrors.CreateMachineError, dataCrunchMachine.Status.FailureReason)
+				}
+				if dataCrunchMachine.Status.FailureMessage == nil {
+					t.Error("Expected failure message to be set")
+				}
+				if !hasEvent(recorder, infrav1beta1.InstanceCreationFailedReason) {
+					t.Error("Expected an InstanceCreationFailed event to be recorded")
+				}
+				return
+			}
+
+			if err == nil {
+				t.Error("Expected retryable errors to be returned for requeue")
+			}
+			if dataCrunchMachine.Status.FailureReason != nil || dataCrunchMachine.Status.FailureMessage != nil {
+				t.Error("Expected retryable errors not to set a terminal failure")
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_InstanceLabels(t *testing.T) {
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/instances/instance-123":      respondJSON(`{"id":"instance-123","hostname":"test-machine","status":"running","image":"ubuntu-22.04-cuda-12.1","labels":{"team":"ml"},"created_at":"2024-06-28T12:00:00Z"}`),
+		"/instances/instance-123/tags": respondStatus(http.StatusOK),
+	})
+
+	objs := newMachineTestObjects()
+	machine, dataCrunchMachine := objs.machine, objs.dataCrunchMachine
+	dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+	dataCrunchMachine.Spec.AdditionalLabels = map[string]string{"team": "ml"}
+
+	reconciler := newTestReconciler(machine)
+	if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if got := dataCrunchMachine.Status.Labels["team"]; got != "ml" {
+		t.Errorf("Expected status label team=ml, got %v", dataCrunchMachine.Status.Labels)
+	}
+	wantCreatedAt := time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC)
+	if got := dataCrunchMachine.Status.InstanceCreatedAt; got == nil || !got.Time.Equal(wantCreatedAt) {
+		t.Errorf("Expected status instanceCreatedAt %s, got %v", wantCreatedAt, got)
+	}
+
+	updated := &clusterv1.Machine{}
+	if err := reconciler.Get(context.Background(), client.ObjectKeyFromObject(machine), updated); err != nil {
+		t.Fatalf("Failed to get Machine: %v", err)
+	}
+	if got := updated.Labels[gpuModelNodeLabel]; got != "H100" {
+		t.Errorf("Expected Machine label %s=H100, got %v", gpuModelNodeLabel, updated.Labels)
+	}
+	if got := updated.Labels[gpuCountNodeLabel]; got != "1" {
+		t.Errorf("Expected Machine label %s=1, got %v", gpuCountNodeLabel, updated.Labels)
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_BackfillsStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		state  string
+		status infrav1beta1.DataCrunchMachineStatus
+		want   infrav1beta1.DataCrunchMachineStatus
+	}{
+		{
+			name:  "empty fields of a running instance are backfilled",
+			state: "running",
+			want:  infrav1beta1.DataCrunchMachineStatus{InstanceType: "1xH100", Region: "FIN-01", ImageID: "ubuntu-22.04-cuda-12.1"},
+		},
+		{
+			name:   "recorded fields are kept",
+			state:  "running",
+			status: infrav1beta1.DataCrunchMachineStatus{InstanceType: "1xA100", Region: "ICE-01"},
+			want:   infrav1beta1.DataCrunchMachineStatus{InstanceType: "1xA100", Region: "ICE-01", ImageID: "ubuntu-22.04-cuda-12.1"},
+		},
+		{
+			name:  "instances that aren't running are not backfilled",
+			state: "provisioning",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"/instances/instance-123": func(w http.ResponseWriter, _ *http.Request) {
+					_, _ = fmt.Fprintf(w, `{"id":"instance-123","hostname":"test-machine","status":%q,"instance_type":"1xH100","image":"ubuntu-22.04-cuda-12.1","location_code":"FIN-01","ip":"1.2.3.4"}`, tt.state)
+				},
+				"/instances/instance-123/tags": respondStatus(http.StatusOK),
+			})
+
+			// A machine created before the controller recorded these status fields
+			objs := newMachineTestObjects()
+			machine, dataCrunchMachine := objs.machine, objs.dataCrunchMachine
+			dataCrunchMachine.Spec.Image = "ubuntu-22.04-cuda-12.1"
+			dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+			dataCrunchMachine.Status = tt.status
+
+			reconciler := newTestReconciler(machine)
+			if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			got := dataCrunchMachine.Status
+			if got.InstanceType != tt.want.InstanceType || got.Region != tt.want.Region || got.ImageID != tt.want.ImageID {
+				t.Errorf("Expected status instanceType=%q region=%q imageID=%q, got instanceType=%q region=%q imageID=%q",
+					tt.want.InstanceType, tt.want.Region, tt.want.ImageID, got.InstanceType, got.Region, got.ImageID)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_InstanceTypeMismatch(t *testing.T) {
+	tests := []struct {
+		name                  string
+		instanceType          string
+		statusInstanceType    string
+		instanceTypeFallbacks []string
+		wantMatch             bool
+	}{
+		{
+			name:               "requested instance type",
+			instanceType:       "1xH100",
+			statusInstanceType: "1xH100",
+			wantMatch:          true,
+		},
+		{
+			name:               "fallback instance type chosen by the controller",
+			instanceType:       "1xA100",
+			statusInstanceType: "1xA100",
+			wantMatch:          true,
+		},
+		{
+			name:               "other instance type provisioned by the API",
+			instanceType:       "1xA100",
+			statusInstanceType: "1xH100",
+		},
+		{
+			name:                  "machine without recorded instance type running a fallback",
+			instanceType:          "1xA100",
+			instanceTypeFallbacks: []string{"1xA100"},
+			wantMatch:             true,
+		},
+		{
+			name:         "machine without recorded instance type running another type",
+			instanceType: "1xA100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"/instances/instance-123": func(w http.ResponseWriter, _ *http.Request) {
+					_, _ = fmt.Fprintf(w, `{"id":"instance-123","hostname":"test-machine","status":"running","instance_type":%q,"private_ip":"10.0.0.1"}`, tt.instanceType)
+				},
+				"/instances/instance-123/tags": respondStatus(http.StatusOK),
+			})
+
+			objs := newMachineTestObjects()
+			machine, dataCrunchMachine := objs.machine, objs.dataCrunchMachine
+			dataCrunchMachine.Spec.InstanceTypeFallbacks = tt.instanceTypeFallbacks
+			dataCrunchMachine.Spec.Image = "ubuntu-22.04-cuda-12.1"
+			dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+			dataCrunchMachine.Status.InstanceType = tt.statusInstanceType
+
+			reconciler := newTestReconciler(machine)
+			recorder := reconciler.Recorder.(*record.FakeRecorder)
+			if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if tt.wantMatch {
+				if !conditions.IsTrue(dataCrunchMachine, infrav1beta1.InstanceTypeMatchesCondition) {
+					t.Errorf("Expected InstanceTypeMatches to be true, got %v", conditions.Get(dataCrunchMachine, infrav1beta1.InstanceTypeMatchesCondition))
+				}
+				if hasEvent(recorder, infrav1beta1.InstanceTypeMismatchReason) {
+					t.Error("Expected no InstanceTypeMismatch event")
+				}
+				return
+			}
+			if conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceTypeMatchesCondition) != infrav1beta1.InstanceTypeMismatchReason {
+				t.Errorf("Expected InstanceTypeMatches to be false with reason %s, got %v", infrav1beta1.InstanceTypeMismatchReason, conditions.Get(dataCrunchMachine, infrav1beta1.InstanceTypeMatchesCondition))
+			}
+			if !hasEvent(recorder, infrav1beta1.InstanceTypeMismatchReason) {
+				t.Error("Expected an InstanceTypeMismatch event")
+			}
+			if dataCrunchMachine.Status.InstanceType == "1xA100" {
+				t.Error("Expected the provisioned instance type not to be recorded as the requested one")
+			}
+		})
+	}
+}
+
+func TestInstanceCreatedAt(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt string
+		want      time.Time
+	}{
+		{
+			name:      "UTC timestamp",
+			createdAt: "2024-06-28T12:00:00Z",
+			want:      time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "timestamp with offset",
+			createdAt: "2024-06-28T14:00:00+02:00",
+			want:      time.Date(2024, 6, 28, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "not reported",
+		},
+		{
+			name:      "invalid timestamp",
+			createdAt: "28/06/2024",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := instanceCreatedAt(&cloud.Instance{CreatedAt: tt.createdAt})
+			if tt.want.IsZero() {
+				if got != nil {
+					t.Errorf("Expected no timestamp, got %v", got)
+				}
+				return
+			}
+			if got == nil || !got.Time.Equal(tt.want) {
+				t.Errorf("Expected %s, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_RunningWithoutIP(t *testing.T) {
+	privateIP := ""
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/instances/instance-123": func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"id":"instance-123","hostname":"test-machine","status":"running","image":"ubuntu-22.04-cuda-12.1","private_ip":"` + privateIP + `"}`))
+		},
+		"/instances/instance-123/tags": respondStatus(http.StatusOK),
+	})
+
+	objs := newMachineTestObjects()
+	machine, dataCrunchMachine, cluster := objs.machine, objs.dataCrunchMachine, objs.cluster
+	dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+
+	reconciler := newTestReconciler(machine)
+	result, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), machine, dataCrunchMachine, cluster, &infrav1beta1.DataCrunchCluster{})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if result.RequeueAfter != defaultInstancePollInterval {
+		t.Errorf("Expected requeue after %v while waiting for an IP, got %v", defaultInstancePollInterval, result.RequeueAfter)
+	}
+	if dataCrunchMachine.Status.Ready {
+		t.Error("Expected machine without private IP not to be ready")
+	}
+	if got := conditions.GetReason(dataCrunchMachine, infrav1beta1.InstanceReadyCondition); got != infrav1beta1.WaitingForInstanceAddressReason {
+		t.Errorf("Expected InstanceReady reason %s, got %q", infrav1beta1.WaitingForInstanceAddressReason, got)
+	}
+
+	privateIP = "10.0.0.2"
+	if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), machine, dataCrunchMachine, cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !dataCrunchMachine.Status.Ready {
+		t.Error("Expected machine with private IP to be ready")
+	}
+	if !conditions.IsTrue(dataCrunchMachine, infrav1beta1.InstanceReadyCondition) {
+		t.Error("Expected InstanceReady condition to be true")
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_StoppedClearsExternalAddress(t *testing.T) {
+	starts := 0
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/instances/instance-123": respondJSON(`{"id":"instance-123","hostname":"test-machine","status":"stopped","image":"ubuntu-22.04-cuda-12.1","private_ip":"10.0.0.5"}`),
+		"/instances/instance-123/start": func(http.ResponseWriter, *http.Request) {
+			starts++
+		},
+	})
+
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.Spec.InstanceType = "CPU.4V.16G"
+	dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+	dataCrunchMachine.Status.Addresses = []clusterv1.MachineAddress{
+		{Type: clusterv1.MachineHostName, Address: "test-machine"},
+		{Type: clusterv1.MachineInternalIP, Address: "10.0.0.5"},
+		{Type: clusterv1.MachineExternalIP, Address: "203.0.113.10"},
+	}
+
+	reconciler := newTestReconciler()
+	if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if starts != 1 {
+		t.Errorf("Expected the stopped instance to be started once, got %d", starts)
+	}
+
+	expected := []clusterv1.MachineAddress{
+		{Type: clusterv1.MachineHostName, Address: "test-machine"},
+		{Type: clusterv1.MachineInternalIP, Address: "10.0.0.5"},
+	}
+	if !reflect.DeepEqual(dataCrunchMachine.Status.Addresses, expected) {
+		t.Errorf("Expected addresses %v, got %v", expected, dataCrunchMachine.Status.Addresses)
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_ReappliesTagsAfterRestart(t *testing.T) {
+	// The instance lost the provider's tags while stopped, only a tag set outside the provider is left
+	state := "stopped"
+	tags := map[string]string{"external": "kept"}
+	tagUpdates := 0
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/instances/instance-123": func(w http.ResponseWriter, _ *http.Request) {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":       "instance-123",
+				"hostname": "test-machine",
+				"status":   state,
+				"image":    "ubuntu-22.04-cuda-12.1",
+				"tags":     tags,
+			})
+		},
+		"/instances/instance-123/start": func(http.ResponseWriter, *http.Request) {
+			state = "running"
+		},
+		"/instances/instance-123/tags": func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Tags map[string]string `json:"tags"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Method != http.MethodPut {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			tags = req.Tags
+			tagUpdates++
+		},
+	})
+
+	objs := newMachineTestObjects()
+	machine, dataCrunchMachine, cluster := objs.machine, objs.dataCrunchMachine, objs.cluster
+	dataCrunchMachine.UID = "machine-uid"
+	dataCrunchMachine.Spec.InstanceType = "CPU.4V.16G"
+	dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+	dataCrunchMachine.Spec.AdditionalTags = map[string]string{"team": "ml"}
+
+	reconciler := newTestReconciler()
+	recorder := reconciler.Recorder.(*record.FakeRecorder)
+	// The first reconcile starts the stopped instance, the second one sees it running again
+	for i := 0; i < 2; i++ {
+		if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), machine, dataCrunchMachine, cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+	}
+
+	if tagUpdates != 1 {
+		t.Fatalf("Expected tags to be updated once, got %d", tagUpdates)
+	}
+	expected := map[string]string{
+		"external":                      "kept",
+		"team":                          "ml",
+		"cluster.x-k8s.io/cluster-name": "test-cluster",
+		"cluster.x-k8s.io/machine-name": "test-machine",
+		idempotencyKeyTag:               "machine-uid",
+	}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Expected tags %v, got %v", expected, tags)
+	}
+	if !hasEvent(recorder, "InstanceTagsReapplied") {
+		t.Error("Expected an InstanceTagsReapplied event")
+	}
+
+	// Once the tags are back, they are not updated again
+	if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), machine, dataCrunchMachine, cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if tagUpdates != 1 {
+		t.Errorf("Expected no further tag updates, got %d", tagUpdates)
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_Stopping(t *testing.T) {
+	tests := []struct {
+		name             string
+		stoppingFor      *time.Duration
+		expectForceStop  bool
+		expectedReason   string
+		expectedRequeue  time.Duration
+		expectStuckEvent bool
+	}{
+		{
+			name:            "first observed stopping",
+			expectedReason:  infrav1beta1.InstanceStoppingReason,
+			expectedRequeue: minStoppingRequeueAfter,
+		},
+		{
+			name:            "stopping for a while backs off",
+			stoppingFor:     durationPtr(time.Minute),
+			expectedReason:  infrav1beta1.InstanceStoppingReason,
+			expectedRequeue: time.Minute,
+		},
+		{
+			name:             "stuck stopping is forced to stop",
+			stoppingFor:      durationPtr(stoppingTimeout + time.Minute),
+			expectForceStop:  true,
+			expectedReason:   infrav1beta1.InstanceStuckStoppingReason,
+			expectedRequeue:  defaultInstancePollInterval,
+			expectStuckEvent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forceStops := 0
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"/instances/instance-123": respondJSON(`{"id":"instance-123","hostname":"test-machine","status":"stopping","image":"ubuntu-22.04-cuda-12.1"}`),
+				"/instances/instance-123/force-stop": func(http.ResponseWriter, *http.Request) {
+					forceStops++
+				},
+			})
+
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.InstanceType = "CPU.4V.16G"
+			dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+			if tt.stoppingFor != nil {
+				since := metav1.NewTime(time.Now().Add(-*tt.stoppingFor))
+				dataCrunchMachine.Status.StoppingSince = &since
+			}
+
+			reconciler := newTestReconciler()
+			recorder := reconciler.Recorder.(*record.FakeRecorder)
+			result, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{})
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if tt.expectForceStop != (forceStops == 1) {
+				t.Errorf("Expected force stop %v, got %d force stops", tt.expectForceStop, forceStops)
+			}
+			if result.RequeueAfter.Round(time.Second) != tt.expectedRequeue {
+				t.Errorf("Expected requeue after %v, got %v", tt.expectedRequeue, result.RequeueAfter)
+			}
+			if dataCrunchMachine.Status.StoppingSince == nil {
+				t.Error("Expected StoppingSince to be set")
+			}
+			if dataCrunchMachine.Status.Ready {
+				t.Error("Expected machine not to be ready while stopping")
+			}
+
+			condition := conditions.Get(dataCrunchMachine, infrav1beta1.InstanceReadyCondition)
+			if condition == nil || condition.Reason != tt.expectedReason {
+				t.Errorf("Expected InstanceReady reason %s, got %v", tt.expectedReason, condition)
+			}
+			if got := hasEvent(recorder, infrav1beta1.InstanceStuckStoppingReason); got != tt.expectStuckEvent {
+				t.Errorf("Expected %s event %v, got %v", infrav1beta1.InstanceStuckStoppingReason, tt.expectStuckEvent, got)
+			}
+		})
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
+func TestComputeReady(t *testing.T) {
+	secretName := "test-machine-bootstrap"
+	boolPtr := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		name                string
+		bootstrapDataSecret *string
+		infrastructureReady bool
+		instance            *cloud.Instance
+		gpuHealthy          *bool
+		publicIP            *bool
+		expectedReady       bool
+	}{
+		{
+			name:                "all requirements met",
+			bootstrapDataSecret: &secretName,
+			infrastructureReady: true,
+			instance:            &cloud.Instance{State: "running", PrivateIP: "10.0.0.2"},
+			expectedReady:       true,
+		},
+		{
+			name:                "bootstrap data missing",
+			infrastructureReady: true,
+			instance:            &cloud.Instance{State: "running", PrivateIP: "10.0.0.2"},
+		},
+		{
+			name:                "cluster infrastructure not ready",
+			bootstrapDataSecret: &secretName,
+			instance:            &cloud.Instance{State: "running", PrivateIP: "10.0.0.2"},
+		},
+		{
+			name:                "instance pending",
+			bootstrapDataSecret: &secretName,
+			infrastructureReady: true,
+			instance:            &cloud.Instance{State: "pending"},
+		},
+		{
+			name:                "instance stopped",
+			bootstrapDataSecret: &secretName,
+			infrastructureReady: true,
+			instance:            &cloud.Instance{State: "stopped"},
+		},
+		{
+			name:                "no instance",
+			bootstrapDataSecret: &secretName,
+			infrastructureReady: true,
+		},
+		{
+			name:                "running without private IP",
+			bootstrapDataSecret: &secretName,
+			infrastructureReady: true,
+			instance:            &cloud.Instance{State: "running"},
+		},
+		{
+			name:                "running without requested public IP",
+			bootstrapDataSecret: &secretName,
+			infrastructureReady: true,
+			instance:            &cloud.Instance{State: "running", PrivateIP: "10.0.0.2"},
+			publicIP:            boolPtr(true),
+		},
+		{
+			name:                "running with requested public IP",
+			bootstrapDataSecret: &secretName,
+			infrastructureReady: true,
+			instance:            &cloud.Instance{State: "running", PrivateIP: "10.0.0.2", PublicIP: "203.0.113.10"},
+			publicIP:            boolPtr(true),
+			expectedReady:       true,
+		},
+		{
+			name:                "GPUs healthy",
+			bootstrapDataSecret: &secretName,
+			infrastructureReady: true,
+			instance:            &cloud.Instance{State: "running", PrivateIP: "10.0.0.2"},
+			gpuHealthy:          boolPtr(true),
+			expectedReady:       true,
+		},
+		{
+			name:                "GPUs unhealthy",
+			bootstrapDataSecret: &secretName,
+			infrastructureReady: true,
+			instance:            &cloud.Instance{State: "running", PrivateIP: "10.0.0.2"},
+			gpuHealthy:          boolPtr(false),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &clusterv1.Machine{
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{DataSecretName: tt.bootstrapDataSecret},
+				},
+			}
+			cluster := &clusterv1.Cluster{
+				Status: clusterv1.ClusterStatus{InfrastructureReady: tt.infrastructureReady},
+			}
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				Spec: infrav1beta1.DataCrunchMachineSpec{PublicIP: tt.publicIP},
+			}
+			if tt.gpuHealthy != nil {
+				if *tt.gpuHealthy {
+					conditions.MarkTrue(dataCrunchMachine, infrav1beta1.GPUHealthyCondition)
+				} else {
+					conditions.MarkFalse(dataCrunchMachine, infrav1beta1.GPUHealthyCondition, "GPUUnhealthy", clusterv1.ConditionSeverityWarning, "")
+				}
+			}
+
+			if got := computeReady(machine, cluster, dataCrunchMachine, tt.instance); got != tt.expectedReady {
+				t.Errorf("Expected ready %v, got %v", tt.expectedReady, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNodeCordon(t *testing.T) {
+	paused := true
+	unpaused := false
+	tests := []struct {
+		name              string
+		cordonPausedNodes bool
+		paused            *bool
+		nodeCordoned      bool
+		unschedulable     bool
+		noNodeRef         bool
+		wantUnschedulable bool
+		wantNodeCordoned  bool
+		wantEvent         string
+	}{
+		{
+			name:              "paused machine is cordoned",
+			cordonPausedNodes: true,
+			paused:            &paused,
+			wantUnschedulable: true,
+			wantNodeCordoned:  true,
+			wantEvent:         "NodeCordoned",
+		},
+		{
+			name:              "unpaused machine is uncordoned",
+			cordonPausedNodes: true,
+			paused:            &unpaused,
+			nodeCordoned:      true,
+			unschedulable:     true,
+			wantEvent:         "NodeUncordoned",
+		},
+		{
+			name:              "cordoned node is uncordoned once disabled",
+			paused:            &paused,
+			nodeCordoned:      true,
+			unschedulable:     true,
+			wantUnschedulable: false,
+			wantEvent:         "NodeUncordoned",
+		},
+		{
+			name:   "cordoning disabled",
+			paused: &paused,
+		},
+		{
+			name:              "manually cordoned node is left alone",
+			cordonPausedNodes: true,
+			unschedulable:     true,
+			wantUnschedulable: true,
+		},
+		{
+			name:              "machine without node",
+			cordonPausedNodes: true,
+			paused:            &paused,
+			noNodeRef:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+				Spec:       corev1.NodeSpec{Unschedulable: tt.unschedulable},
+			}
+			workloadClient := newTestClientBuilder(node).Build()
+
+			objs := newMachineTestObjects()
+			if !tt.noNodeRef {
+				objs.machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: "test-node"}
+			}
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.Paused = tt.paused
+			dataCrunchMachine.Status.NodeCordoned = tt.nodeCordoned
+
+			reconciler := newTestReconciler()
+			recorder := reconciler.Recorder.(*record.FakeRecorder)
+			reconciler.CordonPausedNodes = tt.cordonPausedNodes
+			reconciler.WorkloadClusterClient = func(_ context.Context, _ *clusterv1.Cluster) (client.Client, error) {
+				return workloadClient, nil
+			}
+
+			if err := reconciler.reconcileNodeCordon(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			updated := &corev1.Node{}
+			if err := workloadClient.Get(context.Background(), client.ObjectKey{Name: "test-node"}, updated); err != nil {
+				t.Fatalf("Failed to get Node: %v", err)
+			}
+			if updated.Spec.Unschedulable != tt.wantUnschedulable {
+				t.Errorf("Expected Node unschedulable %v, got %v", tt.wantUnschedulable, updated.Spec.Unschedulable)
+			}
+			if dataCrunchMachine.Status.NodeCordoned != tt.wantNodeCordoned {
+				t.Errorf("Expected NodeCordoned %v, got %v", tt.wantNodeCordoned, dataCrunchMachine.Status.NodeCordoned)
+			}
+			if tt.wantEvent != "" && !hasEvent(recorder, tt.wantEvent) {
+				t.Errorf("Expected %s event", tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_workloadClusterClient_MissingKubeconfig(t *testing.T) {
+	reconciler := newTestReconciler()
+	if _, err := reconciler.workloadClusterClient(context.Background(), newMachineTestObjects().cluster); err == nil {
+		t.Error("Expected error without a kubeconfig secret")
+	}
+}
+
+func TestGPUNodeLabels(t *testing.T) {
+	tests := []struct {
+		name         string
+		instanceType string
+		expected     map[string]string
+	}{
+		{
+			name:         "multi-GPU instance type",
+			instanceType: "8H100.80S.176V",
+			expected:     map[string]string{gpuModelNodeLabel: "H100", gpuCountNodeLabel: "8"},
+		},
+		{
+			name:         "multi-GPU instance type with x separator",
+			instanceType: "4xA100",
+			expected:     map[string]string{gpuModelNodeLabel: "A100", gpuCountNodeLabel: "4"},
+		},
+		{
+			name:         "single GPU instance type",
+			instanceType: "1V100.6V",
+			expected:     map[string]string{gpuModelNodeLabel: "V100", gpuCountNodeLabel: "1"},
+		},
+		{
+			name:         "CPU instance type",
+			instanceType: "CPU.4V.16G",
+			expected:     nil,
+		},
+		{
+			name:         "empty instance type",
+			instanceType: "",
+			expected:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := gpuNodeLabels(tt.instanceType)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected labels %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_Labels(t *testing.T) {
+	objs := newMachineTestObjects()
+	objs.dataCrunchMachine.Spec.AdditionalTags = map[string]string{"cost-center": "research"}
+	objs.dataCrunchMachine.Spec.AdditionalLabels = map[string]string{"team": "ml"}
+
+	reconciler := newTestReconciler(objs.bootstrapSecret)
+
+	fakeClient := &fakeCloudClient{}
+	if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, objs.dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	spec := fakeClient.created[0]
+	if spec.Labels["team"] != "ml" {
+		t.Errorf("Expected label team=ml, got %v", spec.Labels)
+	}
+	if _, ok := spec.Labels["cost-center"]; ok {
+		t.Errorf("Expected tags not to be sent as labels, got %v", spec.Labels)
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_AntiAffinityGroup(t *testing.T) {
+	group := "test-cluster-control-plane"
+	tests := []struct {
+		name  string
+		group *string
+		want  string
+	}{
+		{
+			name: "no anti-affinity group",
+		},
+		{
+			name:  "anti-affinity group",
+			group: &group,
+			want:  group,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			objs.dataCrunchMachine.Spec.AntiAffinityGroup = tt.group
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+
+			fakeClient := &fakeCloudClient{}
+			if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, objs.dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if got := fakeClient.created[0].AntiAffinityGroup; got != tt.want {
+				t.Errorf("Expected anti-affinity group %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_HardwareGeneration(t *testing.T) {
+	tests := []struct {
+		name         string
+		instanceType string
+		generation   *string
+		want         string
+		wantErr      string
+	}{
+		{
+			name:         "no hardware generation",
+			instanceType: "1xH100",
+		},
+		{
+			name:         "available hardware generation",
+			instanceType: "1xH100",
+			generation:   ptrTo("sxm5-rev2"),
+			want:         "sxm5-rev2",
+		},
+		{
+			name:         "unavailable hardware generation",
+			instanceType: "1xH100",
+			generation:   ptrTo("sxm4-rev1"),
+			wantErr:      "hardware generation sxm4-rev1 is not available for instance type 1xH100",
+		},
+		{
+			name:         "instance type without hardware generations",
+			instanceType: "CPU.FLEX",
+			generation:   ptrTo("sxm5-rev2"),
+			wantErr:      "hardware generation sxm5-rev2 is not available for instance type CPU.FLEX",
+		},
+		{
+			name:         "unknown instance type",
+			instanceType: "8xB200",
+			generation:   ptrTo("sxm5-rev2"),
+			wantErr:      "instance type 8xB200 not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.InstanceType = tt.instanceType
+			dataCrunchMachine.Spec.HardwareGeneration = tt.generation
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+
+			fakeClient := &fakeCloudClient{
+				instanceTypes: []*cloud.InstanceType{
+					{Name: "1xH100", HardwareGenerations: []string{"sxm5-rev1", "sxm5-rev2"}},
+					{Name: "CPU.FLEX"},
+				},
+			}
+			_, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				if len(fakeClient.created) != 0 {
+					t.Errorf("Expected no instance to be created, got %d", len(fakeClient.created))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if got := fakeClient.created[0].HardwareGeneration; got != tt.want {
+				t.Errorf("Expected hardware generation %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_Spot(t *testing.T) {
+	maxPrice := "1.25"
+	tests := []struct {
+		name string
+		spot *infrav1beta1.SpotMachineOptions
+		want *cloud.SpotConfig
+	}{
+		{
+			name: "on-demand",
+		},
+		{
+			name: "spot with a max price",
+			spot: &infrav1beta1.SpotMachineOptions{MaxPrice: &maxPrice},
+			want: &cloud.SpotConfig{MaxPrice: "1.25"},
+		},
+		{
+			name: "spot without a max price",
+			spot: &infrav1beta1.SpotMachineOptions{},
+			want: &cloud.SpotConfig{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.Spot = tt.spot
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+
+			fakeClient := &fakeCloudClient{instanceTypes: []*cloud.InstanceType{{Name: "1xH100"}}}
+			if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if got := fakeClient.created[0].Spot; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected spot config %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_SpotInterrupted(t *testing.T) {
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/instances/instance-123": respondJSON(`{"id":"instance-123","hostname":"test-machine","status":"terminated","interruption_reason":"capacity reclaimed"}`),
+	})
+
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.Spec.ProviderID = ptrTo("datacrunch://instance-123")
+	dataCrunchMachine.Spec.Spot = &infrav1beta1.SpotMachineOptions{}
+
+	reconciler := newTestReconciler(objs.machine)
+	if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if got := dataCrunchMachine.Status.InterruptionReason; got == nil || *got != "capacity reclaimed" {
+		t.Errorf("Expected status interruptionReason %q, got %v", "capacity reclaimed", got)
+	}
+	if got := dataCrunchMachine.Status.FailureMessage; got == nil || !strings.Contains(*got, "capacity reclaimed") {
+		t.Errorf("Expected the failure message to contain the interruption reason, got %v", got)
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileNormal_AdoptsInstanceAfterRestart(t *testing.T) {
+	var creates int
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"GET /instances": respondJSON(`{"instances":[
+			{"id":"instance-other","status":"running","tags":{"` + idempotencyKeyTag + `":"other-uid"}},
+			{"id":"instance-abc","status":"pending","image":"ubuntu-22.04-cuda-12.1","tags":{"` + idempotencyKeyTag + `":"machine-uid"}}
+		]}`),
+		"POST /instances": func(w http.ResponseWriter, _ *http.Request) {
+			creates++
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"instance-new"}`))
+		},
+	})
+
+	// The previous controller created the instance but restarted before persisting the provider ID
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.UID = "machine-uid"
+
+	reconciler := newTestReconciler(objs.machine)
+	if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if creates != 0 {
+		t.Errorf("Expected the existing instance to be adopted, but %d instances were created", creates)
+	}
+	if dataCrunchMachine.Spec.ProviderID == nil || *dataCrunchMachine.Spec.ProviderID != "datacrunch://instance-abc" {
+		t.Errorf("Expected provider ID of the adopted instance, got %v", dataCrunchMachine.Spec.ProviderID)
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_IdempotencyKeyTag(t *testing.T) {
+	additionalTags := map[string]string{"cost-center": "research"}
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.UID = "machine-uid"
+	dataCrunchMachine.Spec.AdditionalTags = additionalTags
+
+	reconciler := newTestReconciler(objs.bootstrapSecret)
+
+	fakeClient := &fakeCloudClient{}
+	if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	tags := fakeClient.created[0].Tags
+	if tags[idempotencyKeyTag] != "machine-uid" {
+		t.Errorf("Expected idempotency key tag, got %v", tags)
+	}
+	if tags["cost-center"] != "research" {
+		t.Errorf("Expected additional tags to be kept, got %v", tags)
+	}
+	if len(additionalTags) != 1 {
+		t.Errorf("Expected spec tags not to be modified, got %v", additionalTags)
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_MissingInstanceID(t *testing.T) {
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.UID = "machine-uid"
+
+	tests := []struct {
+		name      string
+		instances []*cloud.Instance
+		wantID    string
+	}{
+		{
+			name: "created instance found by tag",
+			instances: []*cloud.Instance{
+				{ID: "other-instance", Tags: map[string]string{idempotencyKeyTag: "other-uid"}},
+				{ID: "instance-456", Tags: map[string]string{idempotencyKeyTag: "machine-uid"}},
+			},
+			wantID: "instance-456",
+		},
+		{
+			name: "terminated instance with the tag is not matched",
+			instances: []*cloud.Instance{
+				{ID: "old-instance", State: string(infrav1beta1.InstanceStateTerminated), Tags: map[string]string{idempotencyKeyTag: "machine-uid"}},
+			},
+		},
+		{
+			name:      "created instance not found",
+			instances: []*cloud.Instance{{ID: "other-instance"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+
+			fakeClient := &fakeCloudClient{
+				instances: tt.instances,
+				createErr: fmt.Errorf("failed to create instance: %w", cloud.ErrMissingInstanceID),
+			}
+			instance, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{})
+
+			if tt.wantID == "" {
+				if !errors.Is(err, cloud.ErrMissingInstanceID) {
+					t.Fatalf("Expected missing instance ID error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if instance.ID != tt.wantID {
+				t.Errorf("Expected instance %s, got %s", tt.wantID, instance.ID)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_CreateOperationID(t *testing.T) {
+	objs := newMachineTestObjects()
+	reconciler := newTestReconciler(objs.bootstrapSecret)
+
+	fakeClient := &fakeCloudClient{}
+	if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, objs.dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if got := objs.dataCrunchMachine.Status.CreateOperationID; got != "operation-123" {
+		t.Errorf("Expected the create operation ID in the status, got %q", got)
+	}
+}
+
+func TestDataCrunchMachineReconciler_createDataCrunchClient_ClientCache(t *testing.T) {
+	authentications := map[string]int{}
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/oauth/token": func(w http.ResponseWriter, r *http.Request) {
+			var payload map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			authentications[payload["client_id"]]++
+			respondToken(w, r)
+		},
+		"/instances": respondJSON(`{"instances":[]}`),
+	})
+	t.Setenv("DATACRUNCH_CLIENT_ID", "controller-client-id")
+	t.Setenv("DATACRUNCH_CLIENT_SECRET", "controller-client-secret")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			"clientID":     []byte("tenant-client-id"),
+			"clientSecret": []byte("tenant-client-secret"),
+		},
+	}
+
+	clientCache := datacrunch.NewClientCache()
+	machineReconciler := newTestReconciler(secret)
+	machineReconciler.ClientCache = clientCache
+	clusterReconciler := &DataCrunchClusterReconciler{ClientCache: clientCache}
+
+	controllerMachine := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+	}
+	tenantMachine := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-machine", Namespace: "default"},
+		Spec:       infrav1beta1.DataCrunchMachineSpec{CredentialsRef: &corev1.SecretReference{Name: "tenant-credentials"}},
+	}
+
+	// Repeated reconciles of both reconcilers reuse the token of each set of credentials
+	for i := 0; i < 3; i++ {
+		var clients []cloud.Client
+		for _, dataCrunchMachine := range []*infrav1beta1.DataCrunchMachine{controllerMachine, tenantMachine} {
+			dataCrunchClient, err := machineReconciler.createDataCrunchClient(context.Background(), &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}, dataCrunchMachine)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			clients = append(clients, dataCrunchClient)
+		}
+		dataCrunchClient, err := clusterReconciler.createDataCrunchClient(context.Background(), &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		clients = append(clients, dataCrunchClient)
+
+		for _, dataCrunchClient := range clients {
+			if _, err := dataCrunchClient.ListInstances(context.Background()); err != nil {
+				t.Fatalf("ListInstances failed: %v", err)
+			}
+		}
+	}
+
+	want := map[string]int{"controller-client-id": 1, "tenant-client-id": 1}
+	if !reflect.DeepEqual(authentications, want) {
+		t.Errorf("Expected one authentication per set of credentials, got %v", authentications)
+	}
+}
+
+func TestDataCrunchMachineReconciler_createDataCrunchClient_CredentialsRef(t *testing.T) {
+	var usedClientID string
+	newFakeAPI(t, map[string]http.HandlerFunc{
+		"/oauth/token": func(w http.ResponseWriter, r *http.Request) {
+			var payload map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			usedClientID = payload["client_id"]
+			respondToken(w, r)
+		},
+		"/instances": respondJSON(`{"instances":[]}`),
+	})
+	t.Setenv("DATACRUNCH_CLIENT_ID", "controller-client-id")
+	t.Setenv("DATACRUNCH_CLIENT_SECRET", "controller-client-secret")
+
+	secrets := []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant-credentials", Namespace: "default"},
+			Data: map[string][]byte{
+				"clientID":     []byte("tenant-client-id"),
+				"clientSecret": []byte("tenant-client-secret"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "incomplete-credentials", Namespace: "default"},
+			Data: map[string][]byte{
+				"clientID": []byte("tenant-client-id"),
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		credentialsRef *corev1.SecretReference
+		wantClientID   string
+		wantErr        string
+	}{
+		{
+			name:         "controller credentials without override",
+			wantClientID: "controller-client-id",
+		},
+		{
+			name:           "machine credentials take precedence",
+			credentialsRef: &corev1.SecretReference{Name: "tenant-credentials"},
+			wantClientID:   "tenant-client-id",
+		},
+		{
+			name:           "machine credentials in the same namespace",
+			credentialsRef: &corev1.SecretReference{Name: "tenant-credentials", Namespace: "default"},
+			wantClientID:   "tenant-client-id",
+		},
+		{
+			name:           "secret in another namespace",
+			credentialsRef: &corev1.SecretReference{Name: "tenant-credentials", Namespace: "other"},
+			wantErr:        "must be in the DataCrunchMachine namespace",
+		},
+		{
+			name:           "missing secret",
+			credentialsRef: &corev1.SecretReference{Name: "missing"},
+			wantErr:        "failed to retrieve credentials secret",
+		},
+		{
+			name:           "secret without client secret",
+			credentialsRef: &corev1.SecretReference{Name: "incomplete-credentials"},
+			wantErr:        `missing the "clientSecret" key`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usedClientID = ""
+			reconciler := newTestReconciler(secrets...)
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+				Spec:       infrav1beta1.DataCrunchMachineSpec{CredentialsRef: tt.credentialsRef},
+			}
+
+			dataCrunchClient, err := reconciler.createDataCrunchClient(context.Background(), &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}, dataCrunchMachine)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if _, err := dataCrunchClient.ListInstances(context.Background()); err != nil {
+				t.Fatalf("ListInstances failed: %v", err)
+			}
+			if usedClientID != tt.wantClientID {
+				t.Errorf("Expected client ID %q to be used, got %q", tt.wantClientID, usedClientID)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_RootVolumeDeleteOnTermination(t *testing.T) {
+	retain := false
+	remove := true
+	tests := []struct {
+		name       string
+		rootVolume *infrav1beta1.Volume
+		wantDelete bool
+		wantSize   int64
+	}{
+		{
+			name:       "no root volume configuration",
+			wantDelete: true,
+			wantSize:   200,
+		},
+		{
+			name:       "root volume without deletion setting",
+			rootVolume: &infrav1beta1.Volume{Size: 300},
+			wantDelete: true,
+			wantSize:   300,
+		},
+		{
+			name:       "delete root volume",
+			rootVolume: &infrav1beta1.Volume{DeleteOnTermination: &remove},
+			wantDelete: true,
+			wantSize:   200,
+		},
+		{
+			name:       "retain root volume",
+			rootVolume: &infrav1beta1.Volume{Size: 500, DeleteOnTermination: &retain},
+			wantDelete: false,
+			wantSize:   500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.RootVolume = tt.rootVolume
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+
+			fakeClient := &fakeCloudClient{}
+			if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			rootVolume := fakeClient.created[0].RootVolume
+			if rootVolume == nil {
+				t.Fatal("Expected root volume to be set")
+			}
+			if rootVolume.DeleteOnTermination != tt.wantDelete {
+				t.Errorf("Expected DeleteOnTermination %v, got %v", tt.wantDelete, rootVolume.DeleteOnTermination)
+			}
+			if rootVolume.SizeGB != tt.wantSize {
+				t.Errorf("Expected size %d, got %d", tt.wantSize, rootVolume.SizeGB)
+			}
+		})
+	}
+}
+
+func TestDefaultRootVolumeSizeGB(t *testing.T) {
+	tests := []struct {
+		instanceType string
+		want         int64
+	}{
+		{instanceType: "1xH100", want: 200},
+		{instanceType: "8H100.80S.176V", want: 200},
+		{instanceType: "8H200.141S.176V", want: 250},
+		{instanceType: "4A100.88V", want: 200},
+		{instanceType: "1L40S.20V", want: 150},
+		{instanceType: "1RTX6000ADA.10V", want: 150},
+		{instanceType: "1A6000.10V", want: 100},
+		{instanceType: "1V100.6V", want: 100},
+		{instanceType: "1B300.30V", want: fallbackRootVolumeSizeGB},
+		{instanceType: "CPU.4V.16G", want: fallbackRootVolumeSizeGB},
+		{instanceType: "", want: fallbackRootVolumeSizeGB},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.instanceType, func(t *testing.T) {
+			if got := defaultRootVolumeSizeGB(tt.instanceType); got != tt.want {
+				t.Errorf("defaultRootVolumeSizeGB(%q) = %d, want %d", tt.instanceType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_NetworkInterfaces(t *testing.T) {
+	objs := newMachineTestObjects()
+	dataCrunchMachine := objs.dataCrunchMachine
+	dataCrunchMachine.Spec.NetworkInterfaces = []infrav1beta1.NetworkInterface{
+		{SubnetID: "subnet-pods", SecondaryPrivateIPAddressCount: ptrTo(int64(4)), SecurityGroupIDs: []string{"sg-1"}},
+	}
+
+	reconciler := newTestReconciler(objs.bootstrapSecret)
+
+	fakeClient := &fakeCloudClient{}
+	if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if len(fakeClient.created) != 1 {
+		t.Fatalf("Expected one instance to be created, got %d", len(fakeClient.created))
+	}
+	want := []cloud.NetworkInterfaceSpec{{SubnetID: "subnet-pods", SecondaryPrivateIPCount: 4, SecurityGroupIDs: []string{"sg-1"}}}
+	if got := fakeClient.created[0].NetworkInterfaces; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected network interfaces %+v, got %+v", want, got)
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_Region(t *testing.T) {
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		Spec: infrav1beta1.DataCrunchClusterSpec{Region: "FIN-01"},
+	}
+
+	locations := []*cloud.Location{
+		{Code: "FIN-01", Name: "Finland 1"},
+		{Code: "ICE-01", Name: "Iceland 1"},
+	}
+
+	tests := []struct {
+		name       string
+		region     string
+		wantRegion string
+		wantErr    string
+	}{
+		{
+			name:       "cluster region",
+			wantRegion: "FIN-01",
+		},
+		{
+			name:       "region override",
+			region:     "ICE-01",
+			wantRegion: "ICE-01",
+		},
+		{
+			name:    "unavailable region",
+			region:  "US-01",
+			wantErr: "region US-01 is not available",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.Region = tt.region
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+
+			fakeClient := &fakeCloudClient{locations: locations}
+			_, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, dataCrunchCluster)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				if len(fakeClient.created) != 0 {
+					t.Error("Expected no instance to be created")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if got := fakeClient.created[0].Region; got != tt.wantRegion {
+				t.Errorf("Expected region %q, got %q", tt.wantRegion, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_FailureDomain(t *testing.T) {
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		Spec: infrav1beta1.DataCrunchClusterSpec{Region: "FIN-01"},
+		Status: infrav1beta1.DataCrunchClusterStatus{
+			FailureDomains: clusterv1.FailureDomains{
+				"FIN-01a": clusterv1.FailureDomainSpec{ControlPlane: true},
+				"FIN-01b": clusterv1.FailureDomainSpec{},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		failureDomain *string
+		wantZone      string
+		wantErr       string
+	}{
+		{
+			name: "no failure domain",
+		},
+		{
+			name:          "failure domain",
+			failureDomain: ptrTo("FIN-01b"),
+			wantZone:      "FIN-01b",
+		},
+		{
+			name:          "unknown failure domain",
+			failureDomain: ptrTo("FIN-01c"),
+			wantErr:       "failure domain FIN-01c is not one of the failure domains [FIN-01a FIN-01b] of the cluster",
+		},
+		{
+			name:          "default failure domain of a cluster with availability zones",
+			failureDomain: ptrTo(defaultFailureDomain),
+			wantErr:       "failure domain default is not one of the failure domains",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			objs.machine.Spec.FailureDomain = tt.failureDomain
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+
+			fakeClient := &fakeCloudClient{}
+			_, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, objs.dataCrunchMachine, &clusterv1.Cluster{}, dataCrunchCluster)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				if len(fakeClient.created) != 0 {
+					t.Error("Expected no instance to be created")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if got := fakeClient.created[0].AvailabilityZone; got != tt.wantZone {
+				t.Errorf("Expected availability zone %q, got %q", tt.wantZone, got)
+			}
+			if got := fakeClient.created[0].Region; got != "FIN-01" {
+				t.Errorf("Expected the region of the cluster, got %q", got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_DefaultSSHKeyName(t *testing.T) {
+	tests := []struct {
+		name              string
+		sshKeyName        string
+		defaultSSHKeyName string
+		wantSSHKeyName    string
+	}{
+		{
+			name:              "cluster default applied",
+			defaultSSHKeyName: "team-key",
+			wantSSHKeyName:    "team-key",
+		},
+		{
+			name:              "machine key overrides the cluster default",
+			sshKeyName:        "machine-key",
+			defaultSSHKeyName: "team-key",
+			wantSSHKeyName:    "machine-key",
+		},
+		{
+			name: "no SSH key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.SSHKeyName = tt.sshKeyName
+			dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+				Spec: infrav1beta1.DataCrunchClusterSpec{DefaultSSHKeyName: tt.defaultSSHKeyName},
+			}
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+
+			fakeClient := &fakeCloudClient{}
+			if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, dataCrunchCluster); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if got := fakeClient.created[0].SSHKeyName; got != tt.wantSSHKeyName {
+				t.Errorf("Expected SSH key name %q, got %q", tt.wantSSHKeyName, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_InstanceTypeFallbacks(t *testing.T) {
+	fallbacks := []string{"1A100.22V", "1V100.6V"}
+
+	tests := []struct {
+		name             string
+		fallbacks        []string
+		unavailableTypes map[string]bool
+		noCapacityTypes  map[string]bool
+		wantAttempts     []string
+		wantInstanceType string
+		wantFallback     bool
+		wantErr          string
+	}{
+		{
+			name:             "primary type available",
+			fallbacks:        fallbacks,
+			wantAttempts:     []string{"1H100.80S.32V"},
+			wantInstanceType: "1H100.80S.32V",
+		},
+		{
+			name:             "primary type unavailable",
+			fallbacks:        fallbacks,
+			unavailableTypes: map[string]bool{"1H100.80S.32V": true},
+			wantAttempts:     []string{"1A100.22V"},
+			wantInstanceType: "1A100.22V",
+			wantFallback:     true,
+		},
+		{
+			name:             "primary type out of capacity on create",
+			fallbacks:        fallbacks,
+			noCapacityTypes:  map[string]bool{"1H100.80S.32V": true},
+			wantAttempts:     []string{"1H100.80S.32V", "1A100.22V"},
+			wantInstanceType: "1A100.22V",
+			wantFallback:     true,
+		},
+		{
+			name:             "cascade to the last fallback",
+			fallbacks:        fallbacks,
+			unavailableTypes: map[string]bool{"1H100.80S.32V": true},
+			noCapacityTypes:  map[string]bool{"1A100.22V": true},
+			wantAttempts:     []string{"1A100.22V", "1V100.6V"},
+			wantInstanceType: "1V100.6V",
+			wantFallback:     true,
+		},
+		{
+			name:             "all types unavailable",
+			fallbacks:        fallbacks,
+			unavailableTypes: map[string]bool{"1H100.80S.32V": true, "1A100.22V": true, "1V100.6V": true},
+			wantErr:          "none of the instance types",
+		},
+		{
+			name:            "last fallback out of capacity",
+			fallbacks:       fallbacks,
+			noCapacityTypes: map[string]bool{"1H100.80S.32V": true, "1A100.22V": true, "1V100.6V": true},
+			wantAttempts:    []string{"1H100.80S.32V", "1A100.22V", "1V100.6V"},
+			wantErr:         "insufficient_capacity",
+		},
+		{
+			name:             "no fallbacks skips the availability check",
+			unavailableTypes: map[string]bool{"1H100.80S.32V": true},
+			wantAttempts:     []string{"1H100.80S.32V"},
+			wantInstanceType: "1H100.80S.32V",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.InstanceType = "1H100.80S.32V"
+			dataCrunchMachine.Spec.InstanceTypeFallbacks = tt.fallbacks
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+			recorder := reconciler.Recorder.(*record.FakeRecorder)
+
+			fakeClient := &fakeCloudClient{unavailableTypes: tt.unavailableTypes, noCapacityTypes: tt.noCapacityTypes}
+			_, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			var attempts []string
+			for _, spec := range fakeClient.created {
+				attempts = append(attempts, spec.InstanceType)
+			}
+			if !reflect.DeepEqual(attempts, tt.wantAttempts) {
+				t.Errorf("Expected create attempts %v, got %v", tt.wantAttempts, attempts)
+			}
+
+			if got := dataCrunchMachine.Status.InstanceType; got != tt.wantInstanceType {
+				t.Errorf("Expected status instance type %q, got %q", tt.wantInstanceType, got)
+			}
+			if got := hasEvent(recorder, "InstanceTypeFallback"); got != tt.wantFallback {
+				t.Errorf("Expected InstanceTypeFallback event %v, got %v", tt.wantFallback, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_DefaultImage(t *testing.T) {
+	tests := []struct {
+		name         string
+		image        string
+		defaultImage string
+		wantImage    string
+		wantEvent    bool
+	}{
+		{
+			name:      "image not specified",
+			wantImage: infrav1beta1.DefaultImage,
+			wantEvent: true,
+		},
+		{
+			name:         "image not specified with a configured default image",
+			defaultImage: "ubuntu-24.04-cuda-12.4",
+			wantImage:    "ubuntu-24.04-cuda-12.4",
+			wantEvent:    true,
+		},
+		{
+			name:      "image specified",
+			image:     "ubuntu-20.04",
+			wantImage: "ubuntu-20.04",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.Image = tt.image
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+			reconciler.DefaultImage = tt.defaultImage
+			recorder := reconciler.Recorder.(*record.FakeRecorder)
+
+			fakeClient := &fakeCloudClient{}
+			if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if got := fakeClient.created[0].ImageID; got != tt.wantImage {
+				t.Errorf("Expected image %s, got %s", tt.wantImage, got)
+			}
+			if got := hasEvent(recorder, infrav1beta1.DefaultImageAppliedReason); got != tt.wantEvent {
+				t.Errorf("Expected DefaultImageApplied event %v, got %v", tt.wantEvent, got)
+			}
+
+			if tt.wantEvent {
+				if !conditions.IsFalse(dataCrunchMachine, infrav1beta1.ImageSpecifiedCondition) {
+					t.Error("Expected ImageSpecified condition to be false")
+				}
+				if reason := conditions.GetReason(dataCrunchMachine, infrav1beta1.ImageSpecifiedCondition); reason != infrav1beta1.DefaultImageAppliedReason {
+					t.Errorf("Expected reason %s, got %s", infrav1beta1.DefaultImageAppliedReason, reason)
+				}
+				if severity := conditions.GetSeverity(dataCrunchMachine, infrav1beta1.ImageSpecifiedCondition); severity == nil || *severity != clusterv1.ConditionSeverityInfo {
+					t.Errorf("Expected info severity, got %v", severity)
+				}
+			} else if !conditions.IsTrue(dataCrunchMachine, infrav1beta1.ImageSpecifiedCondition) {
+				t.Error("Expected ImageSpecified condition to be true")
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_UncompressedUserData(t *testing.T) {
+	bootstrapData := []byte("#cloud-config\nruncmd:\n- kubeadm join\n")
+	uncompressed, compressed := true, false
+
+	tests := []struct {
+		name           string
+		machineSetting *bool
+		defaultSetting *bool
+		wantCompressed bool
+	}{
+		{
+			name: "no setting and no default",
+		},
+		{
+			name:           "default compresses",
+			defaultSetting: &compressed,
+			wantCompressed: true,
+		},
+		{
+			name:           "default leaves uncompressed",
+			defaultSetting: &uncompressed,
+		},
+		{
+			name:           "machine setting overrides the default to compress",
+			machineSetting: &compressed,
+			defaultSetting: &uncompressed,
+			wantCompressed: true,
+		},
+		{
+			name:           "machine setting overrides the default to leave uncompressed",
+			machineSetting: &uncompressed,
+			defaultSetting: &compressed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			objs.bootstrapSecret.Data["value"] = bootstrapData
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.Spec.Image = "ubuntu-22.04"
+			dataCrunchMachine.Spec.UncompressedUserData = tt.machineSetting
+
+			reconciler := newTestReconciler(objs.bootstrapSecret)
+			reconciler.DefaultUncompressedUserData = tt.defaultSetting
+
+			fakeClient := &fakeCloudClient{}
+			if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{}); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			userData, err := base64.StdEncoding.DecodeString(fakeClient.created[0].UserData)
+			if err != nil {
+				t.Fatalf("Expected base64 user data, got %v", err)
+			}
+			if !tt.wantCompressed {
+				if !bytes.Equal(userData, bootstrapData) {
+					t.Errorf("Expected uncompressed user data %q, got %q", bootstrapData, userData)
+				}
+				return
+			}
+
+			reader, err := gzip.NewReader(bytes.NewReader(userData))
+			if err != nil {
+				t.Fatalf("Expected gzipped user data, got %v", err)
+			}
+			decompressed, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to decompress user data: %v", err)
+			}
+			if !bytes.Equal(decompressed, bootstrapData) {
+				t.Errorf("Expected user data %q, got %q", bootstrapData, decompressed)
+			}
+
+			// Compression is deterministic, so retried creates send the same user data
+			again, err := gzipUserData(bootstrapData)
+			if err != nil || !bytes.Equal(again, userData) {
+				t.Errorf("Expected compressing the same user data to yield the same bytes, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_SSHPublicKeySecretRef(t *testing.T) {
+	publicKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl cluster@example.com"
+	sshKeySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-ssh-key", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte(publicKey + "\n")},
+	}
+	emptySSHKeySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "empty-ssh-key", Namespace: "default"},
+	}
+
+	tests := []struct {
+		name        string
+		secretRef   string
+		sshKeys     []*cloud.SSHKey
+		wantCreated bool
+		wantErr     string
+	}{
+		{
+			name:        "missing key is created from the secret",
+			secretRef:   "cluster-ssh-key",
+			wantCreated: true,
+		},
+		{
+			name:      "existing key is reused",
+			secretRef: "cluster-ssh-key",
+			sshKeys:   []*cloud.SSHKey{{ID: "key-existing", Name: "cluster-key"}},
+		},
+		{
+			name:      "secret without public key",
+			secretRef: "empty-ssh-key",
+			wantErr:   `is missing the "value" key`,
+		},
+		{
+			name:      "missing secret",
+			secretRef: "missing-ssh-key",
+			wantErr:   "failed to retrieve SSH public key secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := newMachineTestObjects()
+			dataCrunchMachine := objs.dataCrunchMachine
+			dataCrunchMachine.UID = "machine-uid"
+			dataCrunchMachine.Spec.SSHKeyName = "cluster-key"
+			dataCrunchMachine.Spec.SSHPublicKeySecretRef = &corev1.SecretReference{Name: tt.secretRef}
+
+			reconciler := newTestReconciler(objs.bootstrapSecret, sshKeySecret, emptySSHKeySecret)
+			recorder := reconciler.Recorder.(*record.FakeRecorder)
+
+			fakeClient := &fakeCloudClient{sshKeys: tt.sshKeys}
+			_, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				if len(fakeClient.created) != 0 || len(fakeClient.createdSSHKeys) != 0 {
+					t.Error("Expected neither an SSH key nor an instance to be created")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if !tt.wantCreated {
+				if len(fakeClient.createdSSHKeys) != 0 {
+					t.Errorf("Expected the existing SSH key to be reused, got %d created", len(fakeClient.createdSSHKeys))
+				}
+			} else {
+				if len(fakeClient.createdSSHKeys) != 1 {
+					t.Fatalf("Expected one SSH key to be created, got %d", len(fakeClient.createdSSHKeys))
+				}
+				key := fakeClient.createdSSHKeys[0]
+				if key.Name != "cluster-key" || key.PublicKey != publicKey {
+					t.Errorf("Expected SSH key cluster-key with the public key of the secret, got %q with %q", key.Name, key.PublicKey)
+				}
+				if key.Tags[idempotencyKeyTag] != "machine-uid" || key.Tags[clusterv1.ClusterNameLabel] != "test-cluster" {
+					t.Errorf("Expected the SSH key to be tagged for the machine and cluster, got %v", key.Tags)
+				}
+				if !hasEvent(recorder, "SSHKeyCreated") {
+					t.Error("Expected an SSHKeyCreated event")
+				}
+			}
+
+			if got := fakeClient.created[0].SSHKeyName; got != "cluster-key" {
+				t.Errorf("Expected the instance to use SSH key cluster-key, got %q", got)
+			}
+		})
+	}
+}
+
+func TestExpandMetadata(t *testing.T) {
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-abc12", Namespace: "team-a"}}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "gpu-cluster"}}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     map[string]string
+	}{
+		{
+			name: "no metadata",
+		},
+		{
+			name: "placeholders",
+			metadata: map[string]string{
+				"hostname": "${cluster.name}-${machine.name}",
+				"owner":    "${machine.namespace}/${machine.name}",
+			},
+			want: map[string]string{
+				"hostname": "gpu-cluster-worker-abc12",
+				"owner":    "team-a/worker-abc12",
+			},
+		},
+		{
+			name: "literals are passed through",
+			metadata: map[string]string{
+				"project": "test-project",
+				"shell":   "$HOME and ${HOME}",
+				"unknown": "${cluster.namespace} ${machine.uid}",
+				"partial": "${cluster.name",
+			},
+			want: map[string]string{
+				"project": "test-project",
+				"shell":   "$HOME and ${HOME}",
+				"unknown": "${cluster.namespace} ${machine.uid}",
+				"partial": "${cluster.name",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandMetadata(tt.metadata, machine, cluster); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandMetadata_ExpandedValuesArentExpandedAgain(t *testing.T) {
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "${cluster.name}"}}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "gpu-cluster"}}
+	metadata := map[string]string{"hostname": "${machine.name}"}
+
+	got := expandMetadata(metadata, machine, cluster)
+	if got["hostname"] != "${cluster.name}" {
+		t.Errorf("Expected the machine name to be inserted literally, got %q", got["hostname"])
+	}
+	if metadata["hostname"] != "${machine.name}" {
+		t.Errorf("Expected the spec metadata to be left unchanged, got %q", metadata["hostname"])
+	}
+}
+
+func TestDataCrunchMachineReconciler_createInstance_AdditionalMetadata(t *testing.T) {
+	objs := newMachineTestObjects()
+	objs.dataCrunchMachine.Spec.AdditionalMetadata = map[string]string{
+		"node":    "${cluster.name}/${machine.name}",
+		"project": "test-project",
+	}
+
+	reconciler := newTestReconciler(objs.bootstrapSecret)
+	fakeClient := &fakeCloudClient{}
+	if _, err := reconciler.createInstance(context.Background(), logr.Discard(), fakeClient, objs.machine, objs.dataCrunchMachine, objs.cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	want := map[string]string{"node": "test-cluster/test-machine", "project": "test-project"}
+	if got := fakeClient.created[0].Metadata; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected metadata %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// ErrClusterInfraNotReady is returned when a machine can't be reconciled yet because the infrastructure
+// of its cluster is not ready
+var ErrClusterInfraNotReady = errors.New("cluster infrastructure is not ready")
+
+// ErrBootstrapNotReady is returned when a machine can't be reconciled yet because the bootstrap provider
+// has not set the bootstrap data secret of its Machine
+var ErrBootstrapNotReady = errors.New("bootstrap data is not ready")
+
+// ErrBootstrapDataMissing is returned when the bootstrap data secret of a Machine has no value
+var ErrBootstrapDataMissing = errors.New("bootstrap data secret value key is missing")
+
+// ErrBudgetExceeded is returned when the instance of a machine would exceed the hourly cost budget of its cluster
+var ErrBudgetExceeded = errors.New("hourly cost budget of the cluster exceeded")
+
+// ErrInsufficientCapacity is returned when none of the instance types of a machine has capacity in its region
+var ErrInsufficientCapacity = errors.New("insufficient capacity")
+
+// machinePreconditions returns ErrClusterInfraNotReady or ErrBootstrapNotReady until a machine can be reconciled
+func machinePreconditions(machine *clusterv1.Machine, cluster *clusterv1.Cluster) error {
+	if !cluster.Status.InfrastructureReady {
+		return ErrClusterInfraNotReady
+	}
+	if machine.Spec.Bootstrap.DataSecretName == nil {
+		return ErrBootstrapNotReady
+	}
+	return nil
+}
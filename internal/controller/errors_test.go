@@ -0,0 +1,103 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMachinePreconditions(t *testing.T) {
+	dataSecretName := "bootstrap-data"
+
+	tests := []struct {
+		name                string
+		infrastructureReady bool
+		dataSecretName      *string
+		wantErr             error
+	}{
+		{
+			name:           "cluster infrastructure not ready",
+			dataSecretName: &dataSecretName,
+			wantErr:        ErrClusterInfraNotReady,
+		},
+		{
+			name:                "bootstrap data not ready",
+			infrastructureReady: true,
+			wantErr:             ErrBootstrapNotReady,
+		},
+		{
+			name:                "ready",
+			infrastructureReady: true,
+			dataSecretName:      &dataSecretName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &clusterv1.Machine{
+				Spec: clusterv1.MachineSpec{Bootstrap: clusterv1.Bootstrap{DataSecretName: tt.dataSecretName}},
+			}
+			cluster := &clusterv1.Cluster{
+				Status: clusterv1.ClusterStatus{InfrastructureReady: tt.infrastructureReady},
+			}
+
+			err := machinePreconditions(machine, cluster)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_getBootstrapData_Errors(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	dataSecretName := "bootstrap-data"
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: dataSecretName, Namespace: "default"},
+		Data:       map[string][]byte{"format": []byte("cloud-config")},
+	}
+	reconciler := &DataCrunchMachineReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+	}
+
+	_, err := reconciler.getBootstrapData(context.Background(), &clusterv1.Machine{})
+	if !errors.Is(err, ErrBootstrapNotReady) {
+		t.Errorf("Expected ErrBootstrapNotReady, got: %v", err)
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"},
+		Spec:       clusterv1.MachineSpec{Bootstrap: clusterv1.Bootstrap{DataSecretName: &dataSecretName}},
+	}
+	_, err = reconciler.getBootstrapData(context.Background(), machine)
+	if !errors.Is(err, ErrBootstrapDataMissing) {
+		t.Errorf("Expected ErrBootstrapDataMissing, got: %v", err)
+	}
+}
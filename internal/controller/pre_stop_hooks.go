@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+)
+
+const (
+	// defaultPreStopTimeout is how long the deletion waits for PreStopCommands when PreStopTimeout is not set
+	defaultPreStopTimeout = 5 * time.Minute
+
+	// preStopHookNamespace is the workload cluster namespace the pre-stop Jobs run in
+	preStopHookNamespace = "kube-system"
+
+	// preStopHookImage runs the pre-stop commands, it only needs nsenter and a shell
+	preStopHookImage = "busybox:1.36"
+
+	// preStopHookTTLSeconds is how long finished pre-stop Jobs are kept for inspection
+	preStopHookTTLSeconds int32 = 600
+)
+
+// reconcilePreStopHooks runs the PreStopCommands of a machine on its Node before the instance is deleted.
+// It returns true once the instance can be deleted: the hooks completed, failed or timed out, or there
+// are none to run. The hooks are best-effort, so an error only delays the deletion until the timeout.
+func (r *DataCrunchMachineReconciler) reconcilePreStopHooks(ctx context.Context, log logr.Logger, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster) (bool, error) {
+	if len(dataCrunchMachine.Spec.PreStopCommands) == 0 || machine.Status.NodeRef == nil {
+		return true, nil
+	}
+
+	switch {
+	case conditions.IsTrue(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition):
+		return true, nil
+	case conditions.GetReason(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition) == infrav1beta1.PreStopHooksFailedReason,
+		conditions.GetReason(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition) == infrav1beta1.PreStopHooksTimedOutReason:
+		return true, nil
+	}
+
+	nodeName := machine.Status.NodeRef.Name
+	if !conditions.Has(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition) {
+		log.Info("Running pre-stop commands before deleting the instance", "node", nodeName)
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition, infrav1beta1.PreStopHooksRunningReason, clusterv1.ConditionSeverityInfo, "Running pre-stop commands on Node %s", nodeName)
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "PreStopHooksStarted", "Running pre-stop commands on Node %s", nodeName)
+	}
+
+	// The condition is only set once, so its transition time is when the hooks started
+	timeout := defaultPreStopTimeout
+	if dataCrunchMachine.Spec.PreStopTimeout != nil {
+		timeout = dataCrunchMachine.Spec.PreStopTimeout.Duration
+	}
+	started := conditions.GetLastTransitionTime(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition)
+	if started != nil && time.Since(started.Time) > timeout {
+		log.Info("Pre-stop commands timed out, deleting the instance", "node", nodeName, "timeout", timeout)
+		conditions.MarkFalse(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition, infrav1beta1.PreStopHooksTimedOutReason, clusterv1.ConditionSeverityWarning, "Pre-stop commands didn't complete within %s", timeout)
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.PreStopHooksTimedOutReason, "Pre-stop commands on Node %s didn't complete within %s", nodeName, timeout)
+		return true, nil
+	}
+
+	workloadClient, err := r.workloadClusterClient(ctx, cluster)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create workload cluster client")
+	}
+
+	job := &batchv1.Job{}
+	key := client.ObjectKey{Namespace: preStopHookNamespace, Name: preStopHookJobName(dataCrunchMachine)}
+	if err := workloadClient.Get(ctx, key, job); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "failed to get pre-stop Job %s", key)
+		}
+
+		job = newPreStopHookJob(key, nodeName, dataCrunchMachine.Spec.PreStopCommands, timeout)
+		if err := workloadClient.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+			return false, errors.Wrapf(err, "failed to create pre-stop Job %s", key)
+		}
+		return false, nil
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			log.Info("Pre-stop commands completed", "node", nodeName)
+			conditions.MarkTrue(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition)
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "PreStopHooksCompleted", "Pre-stop commands completed on Node %s", nodeName)
+			return true, nil
+		case batchv1.JobFailed:
+			log.Info("Pre-stop commands failed, deleting the instance", "node", nodeName, "reason", condition.Reason)
+			conditions.MarkFalse(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition, infrav1beta1.PreStopHooksFailedReason, clusterv1.ConditionSeverityWarning, "Pre-stop Job %s failed: %s", key, condition.Message)
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, infrav1beta1.PreStopHooksFailedReason, "Pre-stop commands failed on Node %s: %s", nodeName, condition.Message)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// preStopHookJobName returns the name of the pre-stop Job of a machine, which must be a valid label value
+func preStopHookJobName(dataCrunchMachine *infrav1beta1.DataCrunchMachine) string {
+	const suffix = "-pre-stop"
+	name := dataCrunchMachine.Name
+	if maxLength := 63 - len(suffix); len(name) > maxLength {
+		name = strings.TrimRight(name[:maxLength], "-.")
+	}
+	return name + suffix
+}
+
+// newPreStopHookJob returns a Job running the commands on the host of a Node, one after the other,
+// stopping at the first failure.
+func newPreStopHookJob(key client.ObjectKey, nodeName string, commands []string, timeout time.Duration) *batchv1.Job {
+	backoffLimit := int32(0)
+	activeDeadlineSeconds := int64(timeout.Seconds())
+	ttlSeconds := preStopHookTTLSeconds
+	privileged := true
+	script := "set -e\n" + strings.Join(commands, "\n")
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &activeDeadlineSeconds,
+			TTLSecondsAfterFinished: &ttlSeconds,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					NodeName:      nodeName,
+					HostPID:       true,
+					RestartPolicy: corev1.RestartPolicyNever,
+					// The Node is usually cordoned and tainted by the time the machine is deleted
+					Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					Containers: []corev1.Container{{
+						Name:            "pre-stop",
+						Image:           preStopHookImage,
+						Command:         []string{"nsenter", "--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid", "--", "sh", "-c", script},
+						SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+					}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+)
+
+func TestDataCrunchMachineReconciler_reconcileDelete_PreStopHooks(t *testing.T) {
+	tests := []struct {
+		name         string
+		jobCondition batchv1.JobConditionType
+		noJob        bool
+		startedAgo   time.Duration
+		wantCalls    []string
+		wantReason   string
+		wantEvent    string
+	}{
+		{
+			name:      "starts the pre-stop Job and waits before deleting",
+			noJob:     true,
+			wantEvent: "PreStopHooksStarted",
+		},
+		{
+			name:       "waits for the running pre-stop Job",
+			startedAgo: time.Minute,
+			wantReason: infrav1beta1.PreStopHooksRunningReason,
+		},
+		{
+			name:         "snapshots after the pre-stop Job completed",
+			jobCondition: batchv1.JobComplete,
+			startedAgo:   time.Minute,
+			wantCalls:    []string{"snapshot"},
+			wantEvent:    "PreStopHooksCompleted",
+		},
+		{
+			name:         "snapshots after the pre-stop Job failed",
+			jobCondition: batchv1.JobFailed,
+			startedAgo:   time.Minute,
+			wantCalls:    []string{"snapshot"},
+			wantReason:   infrav1beta1.PreStopHooksFailedReason,
+			wantEvent:    infrav1beta1.PreStopHooksFailedReason,
+		},
+		{
+			name:       "snapshots after the pre-stop timeout",
+			startedAgo: 10 * time.Minute,
+			wantCalls:  []string{"snapshot"},
+			wantReason: infrav1beta1.PreStopHooksTimedOutReason,
+			wantEvent:  infrav1beta1.PreStopHooksTimedOutReason,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"GET /instances/instance-123": respondJSON(`{"id":"instance-123","hostname":"test-machine","status":"running"}`),
+				"POST /instances/instance-123/snapshots": func(w http.ResponseWriter, _ *http.Request) {
+					calls = append(calls, "snapshot")
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(`{"id":"snapshot-456","instance_id":"instance-123","status":"pending"}`))
+				},
+				"DELETE /instances/instance-123": func(w http.ResponseWriter, _ *http.Request) {
+					calls = append(calls, "delete")
+					w.WriteHeader(http.StatusNoContent)
+				},
+			})
+
+			snapshotOnDelete := true
+			providerID := "datacrunch://instance-123"
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test-machine",
+					Namespace:  "default",
+					Finalizers: []string{infrav1beta1.MachineFinalizer},
+				},
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType:     "1xH100",
+					ProviderID:       &providerID,
+					SnapshotOnDelete: &snapshotOnDelete,
+					PreStopCommands:  []string{"systemctl kill --signal=SIGUSR1 trainer", "sleep 30"},
+				},
+			}
+			if tt.startedAgo != 0 {
+				dataCrunchMachine.Status.Conditions = clusterv1.Conditions{{
+					Type:               infrav1beta1.PreStopHooksCompletedCondition,
+					Status:             corev1.ConditionFalse,
+					Severity:           clusterv1.ConditionSeverityInfo,
+					Reason:             infrav1beta1.PreStopHooksRunningReason,
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-tt.startedAgo)),
+				}}
+			}
+			machine := &clusterv1.Machine{
+				Status: clusterv1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Kind: "Node", Name: "test-node"},
+				},
+			}
+
+			scheme := runtime.NewScheme()
+			_ = batchv1.AddToScheme(scheme)
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if !tt.noJob {
+				job := &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-machine-pre-stop", Namespace: preStopHookNamespace},
+				}
+				if tt.jobCondition != "" {
+					job.Status.Conditions = []batchv1.JobCondition{{Type: tt.jobCondition, Status: corev1.ConditionTrue, Message: "exit code 1"}}
+				}
+				builder = builder.WithObjects(job)
+			}
+			workloadClient := builder.Build()
+
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &DataCrunchMachineReconciler{
+				Recorder: recorder,
+				WorkloadClusterClient: func(_ context.Context, _ *clusterv1.Cluster) (client.Client, error) {
+					return workloadClient, nil
+				},
+			}
+
+			result, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), machine, dataCrunchMachine, &clusterv1.Cluster{}, &infrav1beta1.DataCrunchCluster{})
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("Expected calls %v, got %v", tt.wantCalls, calls)
+			}
+			if len(tt.wantCalls) == 0 && result.RequeueAfter == 0 {
+				t.Error("Expected a requeue while the pre-stop commands run")
+			}
+
+			if tt.wantReason == "" && len(tt.wantCalls) != 0 && !conditions.IsTrue(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition) {
+				t.Error("Expected PreStopHooksCompleted condition to be true")
+			}
+			if tt.wantReason != "" && conditions.GetReason(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition) != tt.wantReason {
+				t.Errorf("Expected PreStopHooksCompleted reason %s, got %q", tt.wantReason, conditions.GetReason(dataCrunchMachine, infrav1beta1.PreStopHooksCompletedCondition))
+			}
+			if tt.wantEvent != "" && !hasEvent(recorder, tt.wantEvent) {
+				t.Errorf("Expected %s event", tt.wantEvent)
+			}
+
+			if tt.noJob {
+				job := &batchv1.Job{}
+				if err := workloadClient.Get(context.Background(), client.ObjectKey{Namespace: preStopHookNamespace, Name: "test-machine-pre-stop"}, job); err != nil {
+					t.Fatalf("Expected the pre-stop Job to be created: %v", err)
+				}
+				podSpec := job.Spec.Template.Spec
+				if podSpec.NodeName != "test-node" {
+					t.Errorf("Expected the Job to run on test-node, got %q", podSpec.NodeName)
+				}
+				script := podSpec.Containers[0].Command[len(podSpec.Containers[0].Command)-1]
+				if !strings.HasSuffix(script, "systemctl kill --signal=SIGUSR1 trainer\nsleep 30") {
+					t.Errorf("Expected the commands to run in order, got script %q", script)
+				}
+			}
+		})
+	}
+}
+
+func TestPreStopHookJobName(t *testing.T) {
+	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 53) + "-" + strings.Repeat("b", 20)},
+	}
+
+	name := preStopHookJobName(dataCrunchMachine)
+	if len(name) > 63 {
+		t.Errorf("Expected a name of at most 63 characters, got %d", len(name))
+	}
+	if name != strings.Repeat("a", 53)+"-pre-stop" {
+		t.Errorf("Expected the truncated name without a dangling dash, got %q", name)
+	}
+}
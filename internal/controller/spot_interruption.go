@@ -0,0 +1,235 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+)
+
+const (
+	// defaultSpotInterruptionPollInterval is how often spot instances are checked for interruption notices.
+	// It leaves most of the two minute notice window for draining the Node.
+	defaultSpotInterruptionPollInterval = 15 * time.Second
+
+	// spotDrainGracePeriodSeconds bounds the termination grace period of pods evicted from an interrupted
+	// spot instance, so they shut down before the instance is reclaimed
+	spotDrainGracePeriodSeconds int64 = 60
+
+	// podNodeNameField is the field selector used to list the pods scheduled on a Node
+	podNodeNameField = "spec.nodeName"
+)
+
+// SpotInterruptionPoller periodically checks the instances of spot DataCrunchMachines for interruption
+// notices. The Node of an interrupted machine is drained and its Machine deleted, so a replacement can
+// be scheduled before the instance is reclaimed.
+type SpotInterruptionPoller struct {
+	// Reconciler provides the clients for the management cluster, the DataCrunch API and the workload clusters.
+	Reconciler *DataCrunchMachineReconciler
+
+	// Interval is how often to poll. Zero means defaultSpotInterruptionPollInterval.
+	Interval time.Duration
+}
+
+var _ manager.LeaderElectionRunnable = &SpotInterruptionPoller{}
+
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=delete
+
+// Start implements manager.Runnable and polls until the context is cancelled.
+func (p *SpotInterruptionPoller) Start(ctx context.Context) error {
+	interval := p.Interval
+	if interval == 0 {
+		interval = defaultSpotInterruptionPollInterval
+	}
+
+	wait.UntilWithContext(ctx, p.poll, interval)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable so only the leader drains Nodes.
+func (p *SpotInterruptionPoller) NeedLeaderElection() bool {
+	return true
+}
+
+func (p *SpotInterruptionPoller) poll(ctx context.Context) {
+	r := p.Reconciler
+	log := r.Log.WithName("spot-interruption")
+
+	var listOpts []client.ListOption
+	if r.WatchFilterValue != "" {
+		listOpts = append(listOpts, client.MatchingLabels{clusterv1.WatchLabel: r.WatchFilterValue})
+	}
+
+	dataCrunchMachines := &infrav1beta1.DataCrunchMachineList{}
+	if err := r.List(ctx, dataCrunchMachines, listOpts...); err != nil {
+		log.Error(err, "failed to list DataCrunchMachines")
+		return
+	}
+
+	for i := range dataCrunchMachines.Items {
+		dataCrunchMachine := &dataCrunchMachines.Items[i]
+		if dataCrunchMachine.Spec.Spot == nil || providerInstanceID(dataCrunchMachine.Spec.ProviderID) == "" || !dataCrunchMachine.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		machineLog := log.WithValues("namespace", dataCrunchMachine.Namespace, "datacrunchMachine", dataCrunchMachine.Name)
+		if err := r.reconcileSpotInterruption(ctx, machineLog, dataCrunchMachine); err != nil {
+			machineLog.Error(err, "failed to handle spot interruption")
+		}
+	}
+}
+
+// reconcileSpotInterruption drains the Node and deletes the Machine of a spot DataCrunchMachine whose
+// instance received an interruption notice.
+func (r *DataCrunchMachineReconciler) reconcileSpotInterruption(ctx context.Context, log logr.Logger, dataCrunchMachine *infrav1beta1.DataCrunchMachine) error {
+	machine, err := util.GetOwnerMachine(ctx, r.Client, dataCrunchMachine.ObjectMeta)
+	if err != nil {
+		return errors.Wrap(err, "failed to get owner Machine")
+	}
+	if machine == nil || !machine.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, machine.ObjectMeta)
+	if err != nil {
+		return errors.Wrap(err, "failed to get Cluster")
+	}
+	if annotations.IsPaused(cluster, dataCrunchMachine) {
+		return nil
+	}
+
+	// The DataCrunchCluster selects the API endpoint, clusters without one use the default endpoint
+	var dataCrunchCluster *infrav1beta1.DataCrunchCluster
+	if ref := cluster.Spec.InfrastructureRef; ref != nil {
+		dataCrunchCluster = &infrav1beta1.DataCrunchCluster{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: dataCrunchMachine.Namespace, Name: ref.Name}, dataCrunchCluster); err != nil {
+			return errors.Wrapf(err, "failed to get DataCrunchCluster %s", ref.Name)
+		}
+	}
+
+	dataCrunchClient, err := r.createDataCrunchClient(ctx, cluster, dataCrunchCluster, dataCrunchMachine)
+	if err != nil {
+		return errors.Wrap(err, "failed to create DataCrunch client")
+	}
+
+	instanceID := providerInstanceID(dataCrunchMachine.Spec.ProviderID)
+	notice, err := dataCrunchClient.GetSpotInterruptionNotice(ctx, instanceID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get spot interruption notice of instance %s", instanceID)
+	}
+	if notice == nil {
+		return nil
+	}
+
+	log.Info("Spot instance is being interrupted", "instanceId", instanceID, "terminationTime", notice.TerminationTime)
+	r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, "SpotInterruption", "Spot instance %s will be reclaimed at %s", instanceID, notice.TerminationTime)
+
+	// A failed drain must not keep the Machine around, Cluster API drains it again on deletion
+	if machine.Status.NodeRef != nil {
+		if err := r.drainNode(ctx, cluster, machine.Status.NodeRef.Name); err != nil {
+			log.Error(err, "failed to drain Node of interrupted spot instance", "node", machine.Status.NodeRef.Name)
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeWarning, "SpotInterruptionDrainFailed", "Failed to drain Node %s: %v", machine.Status.NodeRef.Name, err)
+		} else {
+			r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "SpotInterruptionDrained", "Drained Node %s", machine.Status.NodeRef.Name)
+		}
+	}
+
+	if err := r.Delete(ctx, machine); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete Machine %s", machine.Name)
+	}
+	log.Info("Deleted Machine of interrupted spot instance", "machine", machine.Name)
+
+	return nil
+}
+
+// drainNode cordons a Node and evicts its pods, leaving out DaemonSet and mirror pods which
+// would be recreated on the same Node.
+func (r *DataCrunchMachineReconciler) drainNode(ctx context.Context, cluster *clusterv1.Cluster, nodeName string) error {
+	workloadClient, err := r.workloadClusterClient(ctx, cluster)
+	if err != nil {
+		return errors.Wrap(err, "failed to create workload cluster client")
+	}
+
+	node := &corev1.Node{}
+	if err := workloadClient.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
+		return errors.Wrapf(err, "failed to get Node %s", nodeName)
+	}
+
+	if !node.Spec.Unschedulable {
+		original := node.DeepCopy()
+		node.Spec.Unschedulable = true
+		if err := workloadClient.Patch(ctx, node, client.MergeFrom(original)); err != nil {
+			return errors.Wrapf(err, "failed to cordon Node %s", nodeName)
+		}
+	}
+
+	pods := &corev1.PodList{}
+	if err := workloadClient.List(ctx, pods, client.MatchingFields{podNodeNameField: nodeName}); err != nil {
+		return errors.Wrapf(err, "failed to list pods of Node %s", nodeName)
+	}
+
+	gracePeriodSeconds := spotDrainGracePeriodSeconds
+	var errs []error
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isEvictablePod(pod) {
+			continue
+		}
+
+		eviction := &policyv1.Eviction{
+			ObjectMeta:    metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+			DeleteOptions: &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriodSeconds},
+		}
+		if err := workloadClient.SubResource("eviction").Create(ctx, pod, eviction); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "failed to evict pod %s/%s", pod.Namespace, pod.Name))
+		}
+	}
+
+	return kerrors.NewAggregate(errs)
+}
+
+// isEvictablePod reports whether a pod should be evicted when draining its Node.
+func isEvictablePod(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	for _, ownerRef := range pod.OwnerReferences {
+		if ownerRef.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+)
+
+func TestSpotInterruptionPoller_DrainsInterruptedMachine(t *testing.T) {
+	tests := []struct {
+		name            string
+		interrupted     bool
+		wantDrained     bool
+		wantEvent       string
+		wantMachineKept bool
+	}{
+		{
+			name:        "interruption notice drains the node and deletes the machine",
+			interrupted: true,
+			wantDrained: true,
+			wantEvent:   "SpotInterruption",
+		},
+		{
+			name:            "no interruption notice",
+			wantMachineKept: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeAPI(t, map[string]http.HandlerFunc{
+				"/instances/instance-123/interruption-notice": func(w http.ResponseWriter, _ *http.Request) {
+					if !tt.interrupted {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					_, _ = w.Write([]byte(`{"instance_id":"instance-123","action":"terminate","terminate_at":"2024-01-01T00:02:00Z"}`))
+				},
+			})
+
+			scheme := newTestScheme()
+
+			providerID := "datacrunch://instance-123"
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+			}
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machine",
+					Namespace: "default",
+					Labels:    map[string]string{clusterv1.ClusterNameLabel: "test-cluster"},
+				},
+				Status: clusterv1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Kind: "Node", Name: "test-node"},
+				},
+			}
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machine",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: clusterv1.GroupVersion.String(),
+						Kind:       "Machine",
+						Name:       "test-machine",
+					}},
+				},
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType: "1xH100",
+					ProviderID:   &providerID,
+					Spot:         &infrav1beta1.SpotMachineOptions{},
+				},
+			}
+			// On-demand machines are never checked for interruption notices
+			onDemandProviderID := "datacrunch://instance-456"
+			onDemandMachine := &infrav1beta1.DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "on-demand-machine", Namespace: "default"},
+				Spec: infrav1beta1.DataCrunchMachineSpec{
+					InstanceType: "1xH100",
+					ProviderID:   &onDemandProviderID,
+				},
+			}
+			managementClient := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(cluster, machine, dataCrunchMachine, onDemandMachine).
+				Build()
+
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+			appPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+				Spec:       corev1.PodSpec{NodeName: "test-node"},
+			}
+			daemonSetPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "agent",
+					Namespace:       "kube-system",
+					OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "agent"}},
+				},
+				Spec: corev1.PodSpec{NodeName: "test-node"},
+			}
+			otherNodePod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+				Spec:       corev1.PodSpec{NodeName: "other-node"},
+			}
+			workloadClient := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(node, appPod, daemonSetPod, otherNodePod).
+				WithIndex(&corev1.Pod{}, podNodeNameField, func(obj client.Object) []string {
+					return []string{obj.(*corev1.Pod).Spec.NodeName}
+				}).
+				Build()
+
+			recorder := record.NewFakeRecorder(10)
+			poller := &SpotInterruptionPoller{
+				Reconciler: &DataCrunchMachineReconciler{
+					Client:   managementClient,
+					Scheme:   scheme,
+					Recorder: recorder,
+					Log:      logr.Discard(),
+					WorkloadClusterClient: func(_ context.Context, _ *clusterv1.Cluster) (client.Client, error) {
+						return workloadClient, nil
+					},
+				},
+			}
+			poller.poll(context.Background())
+
+			err := managementClient.Get(context.Background(), client.ObjectKeyFromObject(machine), &clusterv1.Machine{})
+			if tt.wantMachineKept && err != nil {
+				t.Errorf("Expected Machine to remain, got: %v", err)
+			}
+			if !tt.wantMachineKept && !apierrors.IsNotFound(err) {
+				t.Errorf("Expected Machine to be deleted, got: %v", err)
+			}
+
+			gotNode := &corev1.Node{}
+			if err := workloadClient.Get(context.Background(), client.ObjectKeyFromObject(node), gotNode); err != nil {
+				t.Fatalf("Failed to get Node: %v", err)
+			}
+			if gotNode.Spec.Unschedulable != tt.wantDrained {
+				t.Errorf("Expected Node unschedulable %v, got %v", tt.wantDrained, gotNode.Spec.Unschedulable)
+			}
+
+			err = workloadClient.Get(context.Background(), client.ObjectKeyFromObject(appPod), &corev1.Pod{})
+			if tt.wantDrained && !apierrors.IsNotFound(err) {
+				t.Errorf("Expected pod to be evicted, got: %v", err)
+			}
+			if !tt.wantDrained && err != nil {
+				t.Errorf("Expected pod to remain, got: %v", err)
+			}
+			for _, pod := range []*corev1.Pod{daemonSetPod, otherNodePod} {
+				if err := workloadClient.Get(context.Background(), client.ObjectKeyFromObject(pod), &corev1.Pod{}); err != nil {
+					t.Errorf("Expected pod %s to remain, got: %v", pod.Name, err)
+				}
+			}
+
+			if tt.wantEvent != "" && !hasEvent(recorder, tt.wantEvent) {
+				t.Errorf("Expected %s event", tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestIsEvictablePod(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want bool
+	}{
+		{
+			name: "running pod",
+			pod:  &corev1.Pod{},
+			want: true,
+		},
+		{
+			name: "completed pod",
+			pod:  &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodSucceeded}},
+		},
+		{
+			name: "mirror pod",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{corev1.MirrorPodAnnotationKey: "hash"}}},
+		},
+		{
+			name: "DaemonSet pod",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent"}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEvictablePod(tt.pod); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+)
+
+// stoppedForMachineSetTag is the instance tag holding the name of the MachineSet a stopped instance is kept for
+const stoppedForMachineSetTag = "infrastructure.cluster.x-k8s.io/stopped-for-machineset"
+
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinesets;machinedeployments,verbs=get;list;watch
+
+// scaledToZeroMachineSet returns the MachineSet whose scale to zero deletes the machine when its instance
+// is to be stopped instead of deleted, and nil when the instance must be deleted. A machine deleted while
+// its MachineSet or MachineDeployment still wants replicas, e.g. by a rollout or a remediation, and the
+// machines of a cluster or MachineSet being deleted get their instance deleted.
+func (r *DataCrunchMachineReconciler) scaledToZeroMachineSet(ctx context.Context, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster) (*clusterv1.MachineSet, error) {
+	if dataCrunchMachine.Spec.StopInsteadOfDelete == nil || !*dataCrunchMachine.Spec.StopInsteadOfDelete {
+		return nil, nil
+	}
+	if !cluster.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+
+	machineSetName := ownerName(machine, "MachineSet")
+	if machineSetName == "" {
+		return nil, nil
+	}
+
+	machineSet := &clusterv1.MachineSet{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: machine.Namespace, Name: machineSetName}, machineSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get MachineSet %s", machineSetName)
+	}
+	if !machineSet.DeletionTimestamp.IsZero() || !scaledToZero(machineSet.Spec.Replicas) {
+		return nil, nil
+	}
+
+	// The old MachineSets of a rollout are scaled to zero as well, only stop when the MachineDeployment is
+	if machineDeploymentName := ownerName(machineSet, "MachineDeployment"); machineDeploymentName != "" {
+		machineDeployment := &clusterv1.MachineDeployment{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: machine.Namespace, Name: machineDeploymentName}, machineDeployment); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, errors.Wrapf(err, "failed to get MachineDeployment %s", machineDeploymentName)
+		}
+		if !machineDeployment.DeletionTimestamp.IsZero() || !scaledToZero(machineDeployment.Spec.Replicas) {
+			return nil, nil
+		}
+	}
+
+	return machineSet, nil
+}
+
+// ownerName returns the name of the owner of the object of the given kind in the Cluster API group
+func ownerName(obj client.Object, kind string) string {
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if ownerRef.Kind == kind && ownerRef.APIVersion == clusterv1.GroupVersion.String() {
+			return ownerRef.Name
+		}
+	}
+	return ""
+}
+
+// scaledToZero reports whether the replicas of a MachineSet or MachineDeployment are explicitly set to zero
+func scaledToZero(replicas *int32) bool {
+	return replicas != nil && *replicas == 0
+}
+
+// stopInstance stops the instance of a machine deleted by scaling its MachineSet to zero and tags it for
+// the MachineSet, so that it is started again instead of creating a new instance when the MachineSet is
+// scaled up.
+func (r *DataCrunchMachineReconciler) stopInstance(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, dataCrunchMachine *infrav1beta1.DataCrunchMachine, instance *cloud.Instance, machineSet *clusterv1.MachineSet) error {
+	// The instance no longer belongs to the machine, only to its cluster and MachineSet
+	tags := make(map[string]string, len(instance.Tags)+1)
+	for k, v := range instance.Tags {
+		tags[k] = v
+	}
+	delete(tags, idempotencyKeyTag)
+	delete(tags, "cluster.x-k8s.io/machine-name")
+	tags[stoppedForMachineSetTag] = machineSet.Name
+
+	if err := dataCrunchClient.UpdateInstanceTags(ctx, instance.ID, tags); err != nil {
+		return errors.Wrapf(err, "failed to tag instance %s for MachineSet %s", instance.ID, machineSet.Name)
+	}
+
+	if instance.State != "stopped" && instance.State != "stopping" {
+		log.Info("Stopping DataCrunch instance of MachineSet scaled to zero", "instanceId", instance.ID, "machineSet", machineSet.Name)
+		if err := dataCrunchClient.StopInstance(ctx, instance.ID); err != nil {
+			return errors.Wrapf(err, "failed to stop instance %s", instance.ID)
+		}
+	}
+	r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "InstanceStopped", "Stopped DataCrunch instance %s until MachineSet %s is scaled up again", instance.ID, machineSet.Name)
+
+	return nil
+}
+
+// claimStoppedInstance returns a stopped instance kept for the MachineSet of the machine, tagged for the
+// DataCrunchMachine, or nil if there is none. The instance is started by the caller like any other stopped
+// instance of a machine.
+func (r *DataCrunchMachineReconciler) claimStoppedInstance(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, machine *clusterv1.Machine, dataCrunchMachine *infrav1beta1.DataCrunchMachine, cluster *clusterv1.Cluster) (*cloud.Instance, error) {
+	if dataCrunchMachine.Spec.StopInsteadOfDelete == nil || !*dataCrunchMachine.Spec.StopInsteadOfDelete || dataCrunchMachine.UID == "" {
+		return nil, nil
+	}
+	machineSetName := ownerName(machine, "MachineSet")
+	if machineSetName == "" {
+		return nil, nil
+	}
+
+	unlock := r.lockStoppedInstanceClaims(machine.Namespace, machineSetName)
+	defer unlock()
+
+	instances, err := dataCrunchClient.ListInstances(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list instances")
+	}
+	candidates := cloud.FilterInstancesByTags(instances, map[string]string{
+		clusterv1.ClusterNameLabel: cluster.Name,
+		stoppedForMachineSetTag:    machineSetName,
+	})
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+
+	for _, candidate := range candidates {
+		if candidate.State != "stopped" && candidate.State != "stopping" {
+			continue
+		}
+
+		tags := make(map[string]string, len(candidate.Tags)+len(dataCrunchMachine.Spec.AdditionalTags)+2)
+		for k, v := range candidate.Tags {
+			tags[k] = v
+		}
+		delete(tags, stoppedForMachineSetTag)
+		for k, v := range desiredInstanceTags(machine, dataCrunchMachine, cluster) {
+			tags[k] = v
+		}
+		if err := dataCrunchClient.UpdateInstanceTags(ctx, candidate.ID, tags); err != nil {
+			return nil, errors.Wrapf(err, "failed to claim stopped instance %s", candidate.ID)
+		}
+
+		// Claims of this controller are serialized, but another controller instance may claim the
+		// instance at the same time, in which case the last claim wins
+		instance, err := dataCrunchClient.GetInstance(ctx, candidate.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get claimed instance %s", candidate.ID)
+		}
+		if instance.Tags[idempotencyKeyTag] != string(dataCrunchMachine.UID) {
+			log.Info("Stopped instance was claimed by another machine", "instanceId", candidate.ID)
+			continue
+		}
+
+		log.Info("Claimed stopped DataCrunch instance of MachineSet", "instanceId", instance.ID, "machineSet", machineSetName)
+		r.Recorder.Eventf(dataCrunchMachine, corev1.EventTypeNormal, "StoppedInstanceClaimed", "Claimed DataCrunch instance %s stopped when MachineSet %s was scaled to zero", instance.ID, machineSetName)
+		dataCrunchMachine.Status.InstanceType = instance.InstanceType
+		dataCrunchMachine.Status.Region = instance.Region
+		dataCrunchMachine.Status.ImageID = instance.ImageID
+		return instance, nil
+	}
+
+	return nil, nil
+}
+
+// lockStoppedInstanceClaims serializes the claims of the stopped instances of a MachineSet, so that its
+// machines reconciled in parallel don't claim the same instance. It returns the function releasing the lock.
+func (r *DataCrunchMachineReconciler) lockStoppedInstanceClaims(namespace, machineSetName string) func() {
+	value, _ := r.stoppedInstanceClaims.LoadOrStore(namespace+"/"+machineSetName, &sync.Mutex{})
+	mutex := value.(*sync.Mutex)
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// deleteStoppedInstances deletes the instances of the cluster kept stopped for MachineSets scaled to zero,
+// as they have no DataCrunchMachine deleting them with the cluster.
+func (r *DataCrunchClusterReconciler) deleteStoppedInstances(ctx context.Context, log logr.Logger, dataCrunchClient cloud.Client, cluster *clusterv1.Cluster, dataCrunchCluster *infrav1beta1.DataCrunchCluster) error {
+	instances, err := dataCrunchClient.ListInstances(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list instances")
+	}
+
+	var errs []error
+	for _, instance := range cloud.FilterInstancesByTags(instances, map[string]string{clusterv1.ClusterNameLabel: cluster.Name}) {
+		if _, ok := instance.Tags[stoppedForMachineSetTag]; !ok {
+			continue
+		}
+		if err := dataCrunchClient.DeleteInstance(ctx, instance.ID); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to delete stopped instance %s", instance.ID))
+			continue
+		}
+		log.Info("Deleted stopped instance of MachineSet", "instanceId", instance.ID, "machineSet", instance.Tags[stoppedForMachineSetTag])
+		r.Recorder.Eventf(dataCrunchCluster, corev1.EventTypeNormal, "InstanceDeleted", "Deleted DataCrunch instance %s stopped for MachineSet %s", instance.ID, instance.Tags[stoppedForMachineSetTag])
+	}
+
+	return kerrors.NewAggregate(errs)
+}
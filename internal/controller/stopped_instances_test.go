@@ -0,0 +1,467 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1beta1 "github.com/rusik69/cluster-api-provider-datacrunch/api/v1beta1"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud/datacrunch"
+)
+
+// fakeInstanceAPI serves a single instance whose state and tags follow the stop, start and tag requests.
+// Instance lists take listDelay and tag updates for a machine UID the tagDelays of the UID, so that
+// concurrent claims interleave.
+type fakeInstanceAPI struct {
+	mutex     sync.Mutex
+	state     string
+	tags      map[string]string
+	calls     []string
+	listDelay time.Duration
+	tagDelays map[string]time.Duration
+}
+
+func (f *fakeInstanceAPI) instance() map[string]interface{} {
+	return map[string]interface{}{
+		"id":            "instance-123",
+		"hostname":      "worker-a",
+		"status":        f.state,
+		"instance_type": "CPU.4V.16G",
+		"location_code": "FIN-01",
+		"image":         "ubuntu-22.04-cuda-12.1",
+		"private_ip":    "10.0.0.5",
+		"tags":          f.tags,
+	}
+}
+
+func (f *fakeInstanceAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/instances" && r.Method == http.MethodGet:
+		time.Sleep(f.listDelay)
+	case r.URL.Path == "/instances/instance-123/tags":
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var req struct {
+			Tags map[string]string `json:"tags"`
+		}
+		_ = json.Unmarshal(body, &req)
+		time.Sleep(f.tagDelays[req.Tags[idempotencyKeyTag]])
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/oauth/token":
+		_, _ = w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+	case r.URL.Path == "/instances" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"instances": []interface{}{f.instance()}})
+	case r.URL.Path == "/instances" && r.Method == http.MethodPost:
+		f.calls = append(f.calls, "create")
+		w.WriteHeader(http.StatusBadRequest)
+	case r.URL.Path == "/instances/instance-123" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(f.instance())
+	case r.URL.Path == "/instances/instance-123" && r.Method == http.MethodDelete:
+		f.calls = append(f.calls, "delete")
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/instances/instance-123/stop":
+		f.calls = append(f.calls, "stop")
+		f.state = "stopped"
+	case r.URL.Path == "/instances/instance-123/start":
+		f.calls = append(f.calls, "start")
+		f.state = "running"
+	case r.URL.Path == "/instances/instance-123/tags":
+		var req struct {
+			Tags map[string]string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.tags = req.Tags
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newScalingObjects(machineSetReplicas, machineDeploymentReplicas int32) (*clusterv1.MachineDeployment, *clusterv1.MachineSet) {
+	machineDeployment := &clusterv1.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "default"},
+		Spec:       clusterv1.MachineDeploymentSpec{ClusterName: "test-cluster", Replicas: &machineDeploymentReplicas},
+	}
+	machineSet := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "workers-abc",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "MachineDeployment",
+				Name:       "workers",
+			}},
+		},
+		Spec: clusterv1.MachineSetSpec{ClusterName: "test-cluster", Replicas: &machineSetReplicas},
+	}
+	return machineDeployment, machineSet
+}
+
+func newMachineSetMachine(name string) *clusterv1.Machine {
+	secretName := name + "-bootstrap"
+	return &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "MachineSet",
+				Name:       "workers-abc",
+			}},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			Bootstrap:   clusterv1.Bootstrap{DataSecretName: &secretName},
+		},
+	}
+}
+
+func TestDataCrunchMachineReconciler_scaledToZeroMachineSet(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name                      string
+		stopInsteadOfDelete       *bool
+		machineSetReplicas        int32
+		machineDeploymentReplicas int32
+		clusterDeleting           bool
+		noOwner                   bool
+		want                      bool
+	}{
+		{
+			name:                "scaled to zero",
+			stopInsteadOfDelete: &enabled,
+			want:                true,
+		},
+		{
+			name: "stop instead of delete not set",
+		},
+		{
+			name:                "stop instead of delete disabled",
+			stopInsteadOfDelete: &disabled,
+		},
+		{
+			name:                "scaled down but not to zero",
+			stopInsteadOfDelete: &enabled,
+			machineSetReplicas:  1,
+		},
+		{
+			name:                      "old MachineSet of a rollout",
+			stopInsteadOfDelete:       &enabled,
+			machineDeploymentReplicas: 2,
+		},
+		{
+			name:                "cluster deleted",
+			stopInsteadOfDelete: &enabled,
+			clusterDeleting:     true,
+		},
+		{
+			name:                "machine without MachineSet",
+			stopInsteadOfDelete: &enabled,
+			noOwner:             true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = clusterv1.AddToScheme(scheme)
+
+			machineDeployment, machineSet := newScalingObjects(tt.machineSetReplicas, tt.machineDeploymentReplicas)
+			machine := newMachineSetMachine("worker-a")
+			if tt.noOwner {
+				machine.OwnerReferences = nil
+			}
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+			if tt.clusterDeleting {
+				now := metav1.Now()
+				cluster.DeletionTimestamp = &now
+			}
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				Spec: infrav1beta1.DataCrunchMachineSpec{StopInsteadOfDelete: tt.stopInsteadOfDelete},
+			}
+
+			reconciler := &DataCrunchMachineReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(machineDeployment, machineSet).Build(),
+			}
+			got, err := reconciler.scaledToZeroMachineSet(context.Background(), machine, dataCrunchMachine, cluster)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if (got != nil) != tt.want {
+				t.Errorf("Expected scaled to zero MachineSet %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDataCrunchMachineReconciler_StopAndStartOnScaling(t *testing.T) {
+	api := &fakeInstanceAPI{
+		state: "running",
+		tags: map[string]string{
+			"cluster.x-k8s.io/cluster-name": "test-cluster",
+			"cluster.x-k8s.io/machine-name": "worker-a",
+			idempotencyKeyTag:               "uid-a",
+		},
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	t.Setenv("DATACRUNCH_API_URL", server.URL)
+
+	scheme := runtime.NewScheme()
+	_ = infrav1beta1.AddToScheme(scheme)
+	_ = clusterv1.AddToScheme(scheme)
+
+	machineDeployment, machineSet := newScalingObjects(0, 0)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(machineDeployment, machineSet).Build()
+	recorder := record.NewFakeRecorder(20)
+	reconciler := &DataCrunchMachineReconciler{Client: kubeClient, Scheme: scheme, Recorder: recorder}
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+		Status:     clusterv1.ClusterStatus{InfrastructureReady: true},
+	}
+	stopInsteadOfDelete := true
+
+	// Scaling the MachineSet to zero deletes the machine, which stops its instance
+	providerID := "datacrunch://instance-123"
+	deleted := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "worker-a",
+			Namespace:  "default",
+			UID:        "uid-a",
+			Finalizers: []string{infrav1beta1.MachineFinalizer},
+		},
+		Spec: infrav1beta1.DataCrunchMachineSpec{
+			InstanceType:        "CPU.4V.16G",
+			ProviderID:          &providerID,
+			StopInsteadOfDelete: &stopInsteadOfDelete,
+		},
+	}
+	if _, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), newMachineSetMachine("worker-a"), deleted, cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if !reflect.DeepEqual(api.calls, []string{"stop"}) {
+		t.Errorf("Expected the instance to be stopped and not deleted, got calls %v", api.calls)
+	}
+	expectedTags := map[string]string{
+		"cluster.x-k8s.io/cluster-name": "test-cluster",
+		stoppedForMachineSetTag:         "workers-abc",
+	}
+	if !reflect.DeepEqual(api.tags, expectedTags) {
+		t.Errorf("Expected tags %v, got %v", expectedTags, api.tags)
+	}
+	if len(deleted.Finalizers) != 0 {
+		t.Errorf("Expected the finalizer to be removed, got %v", deleted.Finalizers)
+	}
+	if !hasEvent(recorder, "InstanceStopped") {
+		t.Error("Expected an InstanceStopped event")
+	}
+
+	// Scaling the MachineSet up again creates a machine, which starts the stopped instance
+	api.calls = nil
+	replicas := int32(1)
+	machineSet.Spec.Replicas = &replicas
+	if err := kubeClient.Update(context.Background(), machineSet); err != nil {
+		t.Fatalf("Failed to scale up MachineSet: %v", err)
+	}
+
+	created := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "worker-b",
+			Namespace:  "default",
+			UID:        "uid-b",
+			Finalizers: []string{infrav1beta1.MachineFinalizer},
+		},
+		Spec: infrav1beta1.DataCrunchMachineSpec{
+			InstanceType:        "CPU.4V.16G",
+			StopInsteadOfDelete: &stopInsteadOfDelete,
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := reconciler.reconcileNormal(context.Background(), logr.Discard(), newMachineSetMachine("worker-b"), created, cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+	}
+
+	if !reflect.DeepEqual(api.calls, []string{"start"}) {
+		t.Errorf("Expected the stopped instance to be started and no instance to be created, got calls %v", api.calls)
+	}
+	if created.Spec.ProviderID == nil || *created.Spec.ProviderID != providerID {
+		t.Errorf("Expected provider ID %s, got %v", providerID, created.Spec.ProviderID)
+	}
+	if created.Status.InstanceType != "CPU.4V.16G" || created.Status.Region != "FIN-01" {
+		t.Errorf("Expected instance type and region of the claimed instance in status, got %q and %q", created.Status.InstanceType, created.Status.Region)
+	}
+	expectedTags = map[string]string{
+		"cluster.x-k8s.io/cluster-name": "test-cluster",
+		"cluster.x-k8s.io/machine-name": "worker-b",
+		idempotencyKeyTag:               "uid-b",
+	}
+	if !reflect.DeepEqual(api.tags, expectedTags) {
+		t.Errorf("Expected tags %v, got %v", expectedTags, api.tags)
+	}
+	if !created.Status.Ready {
+		t.Error("Expected the machine to be ready once the instance is running")
+	}
+	if !hasEvent(recorder, "StoppedInstanceClaimed") {
+		t.Error("Expected a StoppedInstanceClaimed event")
+	}
+}
+
+func TestDataCrunchMachineReconciler_reconcileDelete_DeletesOnRollout(t *testing.T) {
+	api := &fakeInstanceAPI{state: "running", tags: map[string]string{"cluster.x-k8s.io/cluster-name": "test-cluster"}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	t.Setenv("DATACRUNCH_API_URL", server.URL)
+
+	scheme := runtime.NewScheme()
+	_ = clusterv1.AddToScheme(scheme)
+
+	// The old MachineSet is scaled to zero while the MachineDeployment keeps its replicas
+	machineDeployment, machineSet := newScalingObjects(0, 3)
+	reconciler := &DataCrunchMachineReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(machineDeployment, machineSet).Build(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	stopInsteadOfDelete := true
+	providerID := "datacrunch://instance-123"
+	dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-a", Namespace: "default", Finalizers: []string{infrav1beta1.MachineFinalizer}},
+		Spec: infrav1beta1.DataCrunchMachineSpec{
+			InstanceType:        "CPU.4V.16G",
+			ProviderID:          &providerID,
+			StopInsteadOfDelete: &stopInsteadOfDelete,
+		},
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	if _, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), newMachineSetMachine("worker-a"), dataCrunchMachine, cluster, &infrav1beta1.DataCrunchCluster{}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if !reflect.DeepEqual(api.calls, []string{"delete"}) {
+		t.Errorf("Expected the instance to be deleted, got calls %v", api.calls)
+	}
+}
+
+func TestDataCrunchClusterReconciler_reconcileDelete_DeletesStoppedInstances(t *testing.T) {
+	api := &fakeInstanceAPI{
+		state: "stopped",
+		tags: map[string]string{
+			"cluster.x-k8s.io/cluster-name": "test-cluster",
+			stoppedForMachineSetTag:         "workers-abc",
+		},
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	t.Setenv("DATACRUNCH_API_URL", server.URL)
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &DataCrunchClusterReconciler{Recorder: recorder}
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	dataCrunchCluster := &infrav1beta1.DataCrunchCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default", Finalizers: []string{infrav1beta1.ClusterFinalizer}},
+	}
+	if _, err := reconciler.reconcileDelete(context.Background(), logr.Discard(), cluster, dataCrunchCluster); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if !reflect.DeepEqual(api.calls, []string{"delete"}) {
+		t.Errorf("Expected the stopped instance to be deleted, got calls %v", api.calls)
+	}
+	if !hasEvent(recorder, "InstanceDeleted") {
+		t.Error("Expected an InstanceDeleted event")
+	}
+}
+
+func TestDataCrunchMachineReconciler_claimStoppedInstance_Concurrent(t *testing.T) {
+	api := &fakeInstanceAPI{
+		state: "stopped",
+		tags: map[string]string{
+			"cluster.x-k8s.io/cluster-name": "test-cluster",
+			stoppedForMachineSetTag:         "workers-abc",
+		},
+		listDelay: 50 * time.Millisecond,
+		tagDelays: map[string]time.Duration{"uid-worker-b": 100 * time.Millisecond},
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	dataCrunchClient := datacrunch.NewClientWithURL("client-id", "client-secret", server.URL)
+
+	reconciler := &DataCrunchMachineReconciler{Recorder: record.NewFakeRecorder(10)}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	stopInsteadOfDelete := true
+
+	// Two machines of the MachineSet scaled up together are reconciled in parallel
+	names := []string{"worker-a", "worker-b"}
+	claimed := make([]*cloud.Instance, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			dataCrunchMachine := &infrav1beta1.DataCrunchMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+				Spec:       infrav1beta1.DataCrunchMachineSpec{InstanceType: "CPU.4V.16G", StopInsteadOfDelete: &stopInsteadOfDelete},
+			}
+			claimed[i], errs[i] = reconciler.claimStoppedInstance(context.Background(), logr.Discard(), dataCrunchClient, newMachineSetMachine(name), dataCrunchMachine, cluster)
+		}(i, name)
+	}
+	wg.Wait()
+
+	claims := 0
+	for i, instance := range claimed {
+		if errs[i] != nil {
+			t.Fatalf("Expected no error for %s but got: %v", names[i], errs[i])
+		}
+		if instance != nil {
+			claims++
+		}
+	}
+	if claims != 1 {
+		t.Errorf("Expected the stopped instance to be claimed by exactly one machine, got %d claims", claims)
+	}
+}
@@ -22,15 +22,78 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/time/rate"
+
 	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
 )
 
 const (
 	defaultBaseURL = "https://api.datacrunch.io/v1"
-	defaultTimeout = 30 * time.Second
+	priceCacheTTL  = 10 * time.Minute
+
+	// defaultRequestTimeout bounds a single attempt of a request when ClientOptions.RequestTimeout is not set
+	defaultRequestTimeout = 30 * time.Second
+
+	// defaultAPIVersion is the DataCrunch API version the client is known to work with
+	defaultAPIVersion = "v1"
+
+	// apiVersionHeader pins the API version of a request
+	apiVersionHeader = "X-API-Version"
+
+	// DefaultMaxRetries is how often the clients of the controllers retry a request failing with a
+	// transient error
+	DefaultMaxRetries = 3
+
+	// defaultRetryBaseDelay is the delay before the first retry, doubled for every further retry
+	defaultRetryBaseDelay = 500 * time.Millisecond
+
+	// maxRetryDelay bounds the delay between two retries. A request whose Retry-After header asks for
+	// a longer delay is not retried, the caller is better off requeueing than blocking that long.
+	maxRetryDelay = 30 * time.Second
+
+	// defaultInstanceStatePollInterval is the delay before WaitForInstanceState checks an instance again,
+	// doubled for every further check up to maxInstanceStatePollInterval
+	defaultInstanceStatePollInterval = 2 * time.Second
+	maxInstanceStatePollInterval     = 15 * time.Second
+)
+
+// Resource identifies a DataCrunch API resource collection whose path can be configured
+type Resource string
+
+// API resources with configurable paths
+const (
+	ResourceInstances            Resource = "instances"
+	ResourceInstanceTypes        Resource = "instance-types"
+	ResourceImages               Resource = "images"
+	ResourceSSHKeys              Resource = "ssh-keys"
+	ResourceVPCs                 Resource = "vpcs"
+	ResourceSubnets              Resource = "subnets"
+	ResourceAccount              Resource = "account"
+	ResourceLocations            Resource = "locations"
+	ResourceInstanceAvailability Resource = "instance-availability"
+	ResourceLoadBalancers        Resource = "load-balancers"
+	ResourceSnapshots            Resource = "snapshots"
+)
+
+// PayloadField identifies a field of the create instance payload whose name can be configured
+type PayloadField string
+
+// Create instance payload fields with configurable names
+const (
+	PayloadFieldHostname     PayloadField = "hostname"
+	PayloadFieldInstanceType PayloadField = "instance_type"
+	PayloadFieldImage        PayloadField = "image"
+	PayloadFieldSSHKey       PayloadField = "ssh_key"
+	PayloadFieldUserData     PayloadField = "user_data"
 )
 
 // Client implements the cloud.Client interface for DataCrunch
@@ -39,241 +102,1371 @@ type Client struct {
 	clientID     string
 	clientSecret string
 	httpClient   *http.Client
-	token        string
-	tokenExpiry  time.Time
+
+	// tokenMutex guards the token, so that a client shared between goroutines authenticates once
+	tokenMutex  sync.Mutex
+	token       string
+	tokenExpiry time.Time
+
+	priceCacheMutex sync.Mutex
+	priceCache      map[string]cachedPrice
+
+	resourcePaths map[Resource]string
+
+	payloadFieldNames map[PayloadField]string
+
+	limiter *rate.Limiter
+
+	health *HealthTracker
+
+	concurrency *AdaptiveConcurrency
+
+	apiVersion string
+
+	tokenCacheDisabled bool
+
+	maxRetries     int
+	retryBaseDelay time.Duration
+
+	requestTimeout time.Duration
+
+	instanceStatePollInterval time.Duration
+}
+
+// ClientOptions configures a client created by NewClientWithOptions
+type ClientOptions struct {
+	// BaseURL is the URL of the DataCrunch API, the public API if empty
+	BaseURL string
+
+	// MaxRetries is how often a request failing with a transient error is retried. GET requests are
+	// retried on network errors, 429 and server errors, other requests only on 429 and on 503 responses
+	// with a Retry-After header. Zero disables retries.
+	MaxRetries int
+
+	// RetryBaseDelay is the delay before the first retry, doubled and jittered for every further retry.
+	// A Retry-After header of the response takes precedence. Defaults to 500ms.
+	RetryBaseDelay time.Duration
+
+	// RequestTimeout bounds every attempt of a request, including reading its response. A deadline of the
+	// request context that is sooner takes precedence. Defaults to 30s.
+	RequestTimeout time.Duration
+}
+
+// cachedPrice is an instance type price along with the time it stops being valid
+type cachedPrice struct {
+	price     *cloud.InstanceTypePrice
+	expiresAt time.Time
 }
 
 // NewClient creates a new DataCrunch client
 func NewClient(clientID, clientSecret string) *Client {
-	return &Client{
-		baseURL:      defaultBaseURL,
-		clientID:     clientID,
-		clientSecret: clientSecret,
-		httpClient: &http.Client{
-			Timeout: defaultTimeout,
-		},
-	}
+	return NewClientWithOptions(clientID, clientSecret, ClientOptions{})
 }
 
 // NewClientWithURL creates a new DataCrunch client with a custom base URL
 func NewClientWithURL(clientID, clientSecret, baseURL string) *Client {
+	return NewClientWithOptions(clientID, clientSecret, ClientOptions{BaseURL: baseURL})
+}
+
+// NewClientWithOptions creates a new DataCrunch client configured by options
+func NewClientWithOptions(clientID, clientSecret string, options ClientOptions) *Client {
+	baseURL := options.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	retryBaseDelay := options.RetryBaseDelay
+	if retryBaseDelay <= 0 {
+		retryBaseDelay = defaultRetryBaseDelay
+	}
+	requestTimeout := options.RequestTimeout
+	if requestTimeout <= 0 {
+		requestTimeout = defaultRequestTimeout
+	}
+	// The timeout is applied per request through its context rather than http.Client.Timeout, which
+	// would cut requests short regardless of the deadline of the caller's context
 	return &Client{
-		baseURL:      baseURL,
-		clientID:     clientID,
-		clientSecret: clientSecret,
-		httpClient: &http.Client{
-			Timeout: defaultTimeout,
-		},
+		baseURL:        baseURL,
+		clientID:       clientID,
+		clientSecret:   clientSecret,
+		httpClient:     &http.Client{},
+		maxRetries:     options.MaxRetries,
+		retryBaseDelay: retryBaseDelay,
+		requestTimeout: requestTimeout,
+	}
+}
+
+// BaseURL returns the base URL of the API the client sends its requests to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// SetResourcePath overrides the path of a resource collection, e.g. to use a different API version for
+// it. The path is either relative to the base URL ("/instances") or an absolute URL
+// ("https://api.datacrunch.io/v2/instances").
+func (c *Client) SetResourcePath(resource Resource, path string) {
+	if c.resourcePaths == nil {
+		c.resourcePaths = make(map[Resource]string)
+	}
+	c.resourcePaths[resource] = strings.TrimSuffix(path, "/")
+}
+
+// SetPayloadFieldName overrides the name a field is sent under when creating an instance, e.g. "name"
+// instead of "hostname" or "ssh_key_name" instead of "ssh_key" for API versions using those names.
+func (c *Client) SetPayloadFieldName(field PayloadField, name string) {
+	if c.payloadFieldNames == nil {
+		c.payloadFieldNames = make(map[PayloadField]string)
+	}
+	c.payloadFieldNames[field] = name
+}
+
+// payloadFieldName returns the configured name of a create instance payload field, defaulting to the field itself
+func (c *Client) payloadFieldName(field PayloadField) string {
+	if name, ok := c.payloadFieldNames[field]; ok && name != "" {
+		return name
+	}
+	return string(field)
+}
+
+// SetAPIVersion pins the DataCrunch API version sent with every request, so server-side changes in
+// newer versions don't break the client. An empty version restores the default.
+func (c *Client) SetAPIVersion(version string) {
+	c.apiVersion = version
+}
+
+// setHeaders sets the headers common to all requests
+func (c *Client) setHeaders(req *http.Request) {
+	apiVersion := c.apiVersion
+	if apiVersion == "" {
+		apiVersion = defaultAPIVersion
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(apiVersionHeader, apiVersion)
+}
+
+// SetRateLimiter makes every request wait for a token from limiter before it is sent. The limiter can be
+// shared between clients to enforce a single rate for all of them, e.g. per DataCrunch account.
+func (c *Client) SetRateLimiter(limiter *rate.Limiter) {
+	c.limiter = limiter
+}
+
+// SetHealthTracker records the outcome of every request in tracker. The tracker can be shared between
+// clients to report the health of the API for all of them.
+func (c *Client) SetHealthTracker(tracker *HealthTracker) {
+	c.health = tracker
+}
+
+// SetAdaptiveConcurrency tunes concurrency by the outcome of every request. It can be shared between
+// clients to tune the reconciles of all of them.
+func (c *Client) SetAdaptiveConcurrency(concurrency *AdaptiveConcurrency) {
+	c.concurrency = concurrency
+}
+
+// SetTransport sends every request through transport instead of the default HTTP transport, e.g. to
+// record or replay API interactions in tests.
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.httpClient.Transport = transport
+}
+
+// SetTokenCacheDisabled makes every request obtain a fresh access token instead of reusing the last one
+// until it expires. This is meant for short-lived processes making one-off calls, which gain little from
+// the cache but can trip over a token revoked before its expiry.
+func (c *Client) SetTokenCacheDisabled(disabled bool) {
+	c.tokenCacheDisabled = disabled
+}
+
+// waitForRateLimiter blocks until the rate limiter allows a request or ctx is done
+func (c *Client) waitForRateLimiter(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to wait for rate limiter: %w", err)
+	}
+	return nil
+}
+
+// resourcePath returns the configured path of a resource collection, defaulting to "/<resource>"
+func (c *Client) resourcePath(resource Resource) string {
+	if path, ok := c.resourcePaths[resource]; ok {
+		return path
 	}
+	return "/" + string(resource)
 }
 
-// authenticate obtains an access token from DataCrunch
+// authenticate obtains an access token from DataCrunch unless the cached one is still valid
 func (c *Client) authenticate(ctx context.Context) error {
-	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+	c.tokenMutex.Lock()
+	defer c.tokenMutex.Unlock()
+
+	if !c.tokenCacheDisabled && c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return nil
+	}
+	return c.requestToken(ctx)
+}
+
+// ValidateCredentials obtains a fresh access token without making any other request. The returned error
+// wraps cloud.ErrInvalidCredentials if the API rejects the credentials, any other error means the API
+// couldn't be reached or failed.
+func (c *Client) ValidateCredentials(ctx context.Context) error {
+	c.tokenMutex.Lock()
+	defer c.tokenMutex.Unlock()
+
+	return c.requestToken(ctx)
+}
+
+// requestToken runs the OAuth client credentials flow and caches the access token, the token mutex must
+// be held
+func (c *Client) requestToken(ctx context.Context) error {
+	payload := map[string]string{
+		"client_id":     c.clientID,
+		"client_secret": c.clientSecret,
+		"grant_type":    "client_credentials",
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal auth payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/oauth/token", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create auth request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: authentication failed with %w", cloud.ErrInvalidCredentials, newStatusError(resp.StatusCode))
+	default:
+		return fmt.Errorf("authentication failed with %w", newStatusError(resp.StatusCode))
+	}
+
+	var authResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	if err := decodeResponse(resp, &authResp); err != nil {
+		return fmt.Errorf("failed to decode auth response: %w", err)
+	}
+
+	c.token = authResp.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(authResp.ExpiresIn) * time.Second)
+	c.health.recordAuthentication(c.tokenExpiry)
+
+	return nil
+}
+
+// currentToken returns the last access token obtained by authenticate
+func (c *Client) currentToken() string {
+	c.tokenMutex.Lock()
+	defer c.tokenMutex.Unlock()
+	return c.token
+}
+
+// makeRequest makes an authenticated request to the DataCrunch API
+func (c *Client) makeRequest(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	if err := c.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		bodyReader = bytes.NewBuffer(data)
+	}
+
+	requestURL := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		requestURL = c.baseURL + path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	token := c.currentToken()
+	req.Header.Set("Authorization", "Bearer "+token)
+	c.setHeaders(req)
+
+	resp, err := c.do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The token may have been revoked or expired early, so retry once with a fresh one. Should the fresh
+	// token be rejected too, its response is returned as is instead of authenticating again.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	c.invalidateToken(token)
+	if err := c.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	retry, err := rewindRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", "Bearer "+c.currentToken())
+
+	return c.do(retry)
+}
+
+// invalidateToken drops the cached access token if it is still the given one, so that the next request
+// authenticates again. A token another request refreshed meanwhile is kept.
+func (c *Client) invalidateToken(token string) {
+	c.tokenMutex.Lock()
+	defer c.tokenMutex.Unlock()
+
+	if c.token == token {
+		c.token = ""
+		c.tokenExpiry = time.Time{}
+	}
+}
+
+// do sends a request, retrying it with an exponential backoff while it fails with a transient error.
+// Every attempt is bounded by the request timeout as well as by the deadline of the request context.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForRateLimiter(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.doAttempt(req)
+		c.health.recordRequest(resp, err)
+		c.concurrency.recordRequest(resp, err)
+		if attempt >= c.maxRetries || ctx.Err() != nil || !shouldRetry(req.Method, resp, err) {
+			return resp, err
+		}
+
+		delay, ok := c.retryDelay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to wait for retry: %w", ctx.Err())
+		case <-timer.C:
+		}
+
+		req, err = rewindRequest(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// doAttempt sends a request once, bounded by the request timeout. The timeout keeps running until the
+// response body is closed, so that reading a stalled body is bounded too.
+func (c *Client) doAttempt(req *http.Request) (*http.Response, error) {
+	if c.requestTimeout <= 0 {
+		return c.httpClient.Do(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), c.requestTimeout)
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnCloseBody is a response body releasing the context of its request once it is closed
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the context of its request
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// shouldRetry reports whether a request that got resp or err failed with a transient error. Requests
+// other than GETs may have been processed even when a gateway reports a failure, so they are only
+// retried when the API rejected them before processing: rate limited, or unavailable with a Retry-After.
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		return method == http.MethodGet
+	}
+	if method == http.MethodGet {
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before retrying a request for the attempt-th time, and false if
+// the response asks for a delay longer than maxRetryDelay
+func (c *Client) retryDelay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay, delay <= maxRetryDelay
+		}
+	}
+
+	delay := c.retryBaseDelay << attempt
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	// Jitter the delay between half and all of it, so clients failing together don't retry together
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)), true
+}
+
+// parseRetryAfter parses a Retry-After header holding either a number of seconds or an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// rewindRequest returns a copy of req with its body reset, so that it can be sent again
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+// statusError is the error of an unsuccessful response the API gave no error details for. Like a
+// cloud.APIError it matches cloud.ErrNotFound, cloud.ErrUnauthorized and cloud.ErrRateLimited by its status
+// code, but isn't one, as it doesn't tell whether the request itself was rejected.
+type statusError struct {
+	statusCode int
+}
+
+// newStatusError returns the error of an unsuccessful response with the given status code
+func newStatusError(statusCode int) error {
+	return &statusError{statusCode: statusCode}
+}
+
+// Error implements the error interface
+func (e *statusError) Error() string {
+	return fmt.Sprintf("status: %d", e.statusCode)
+}
+
+// Is makes errors.Is match the typed error of the status code
+func (e *statusError) Is(target error) bool {
+	return target != nil && cloud.ErrorForStatus(e.statusCode) == target
+}
+
+// decodeResponse decodes the JSON body of a successful response into v. A body that doesn't decode
+// wraps cloud.ErrUnexpectedResponse, as the API answered with another structure than the client expects.
+func decodeResponse(resp *http.Response, v interface{}) error {
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", cloud.ErrUnexpectedResponse, err)
+	}
+	return nil
+}
+
+// newAPIError builds a cloud.APIError from an unsuccessful response, including the error code and
+// message from the body when the API provides them
+func newAPIError(resp *http.Response) *cloud.APIError {
+	apiErr := &cloud.APIError{StatusCode: resp.StatusCode}
+
+	var errorData struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errorData); err == nil {
+		apiErr.Code = errorData.Code
+		apiErr.Message = errorData.Message
+	}
+
+	return apiErr
+}
+
+// CreateInstance creates a new DataCrunch instance
+func (c *Client) CreateInstance(ctx context.Context, spec *cloud.InstanceSpec) (*cloud.Instance, error) {
+	payload := map[string]interface{}{
+		c.payloadFieldName(PayloadFieldHostname):     spec.Name,
+		c.payloadFieldName(PayloadFieldInstanceType): spec.InstanceType,
+		c.payloadFieldName(PayloadFieldImage):        spec.ImageID,
+		c.payloadFieldName(PayloadFieldSSHKey):       spec.SSHKeyName,
+		c.payloadFieldName(PayloadFieldUserData):     spec.UserData,
+		"is_public":                                  spec.PublicIP,
+	}
+
+	// Resource overrides only apply to customizable instance types
+	if spec.VCPUs > 0 {
+		payload["vcpus"] = spec.VCPUs
+	}
+	if spec.MemoryGB > 0 {
+		payload["memory_gb"] = spec.MemoryGB
+	}
+
+	if len(spec.Labels) > 0 {
+		payload["labels"] = spec.Labels
+	}
+
+	if len(spec.Metadata) > 0 {
+		payload["metadata"] = spec.Metadata
+	}
+
+	if len(spec.Tags) > 0 {
+		payload["tags"] = spec.Tags
+	}
+
+	if spec.Region != "" {
+		payload["location_code"] = spec.Region
+	}
+
+	if spec.AvailabilityZone != "" {
+		payload["availability_zone"] = spec.AvailabilityZone
+	}
+
+	if spec.AntiAffinityGroup != "" {
+		payload["anti_affinity_group"] = spec.AntiAffinityGroup
+	}
+
+	if spec.HardwareGeneration != "" {
+		payload["hardware_generation"] = spec.HardwareGeneration
+	}
+
+	if spec.Spot != nil {
+		payload["spot"] = true
+		if spec.Spot.MaxPrice != "" {
+			payload["max_price"] = spec.Spot.MaxPrice
+		}
+	}
+
+	if spec.RootVolume != nil {
+		osVolume := map[string]interface{}{
+			"delete_on_termination": spec.RootVolume.DeleteOnTermination,
+		}
+		if spec.RootVolume.SizeGB > 0 {
+			osVolume["size"] = spec.RootVolume.SizeGB
+		}
+		if spec.RootVolume.Type != "" {
+			osVolume["type"] = spec.RootVolume.Type
+		}
+		payload["os_volume"] = osVolume
+	}
+
+	if len(spec.NetworkInterfaces) > 0 {
+		networkInterfaces := make([]map[string]interface{}, len(spec.NetworkInterfaces))
+		for i, nic := range spec.NetworkInterfaces {
+			networkInterface := map[string]interface{}{}
+			if nic.SubnetID != "" {
+				networkInterface["subnet_id"] = nic.SubnetID
+			}
+			if nic.DeviceIndex != nil {
+				networkInterface["device_index"] = *nic.DeviceIndex
+			}
+			if nic.AssociatePublicIP != nil {
+				networkInterface["associate_public_ip"] = *nic.AssociatePublicIP
+			}
+			if nic.DeleteOnTermination != nil {
+				networkInterface["delete_on_termination"] = *nic.DeleteOnTermination
+			}
+			if len(nic.SecurityGroupIDs) > 0 {
+				networkInterface["security_group_ids"] = nic.SecurityGroupIDs
+			}
+			if nic.SecondaryPrivateIPCount > 0 {
+				networkInterface["secondary_private_ip_count"] = nic.SecondaryPrivateIPCount
+			}
+			networkInterfaces[i] = networkInterface
+		}
+		payload["network_interfaces"] = networkInterfaces
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", c.resourcePath(ResourceInstances), payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create instance: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("failed to create instance, %w", newAPIError(resp))
+	}
+
+	var instanceResp struct {
+		ID          string `json:"id"`
+		OperationID string `json:"operation_id"`
+	}
+
+	if err := decodeResponse(resp, &instanceResp); err != nil {
+		return nil, fmt.Errorf("failed to decode create instance response: %w", err)
+	}
+
+	// Looking up an empty ID would hit the list endpoint instead of the created instance
+	if instanceResp.ID == "" {
+		return nil, fmt.Errorf("failed to create instance: %w", cloud.ErrMissingInstanceID)
+	}
+
+	// Return the instance details
+	instance, err := c.GetInstance(ctx, instanceResp.ID)
+	if err != nil {
+		return nil, err
+	}
+	instance.OperationID = instanceResp.OperationID
+	return instance, nil
+}
+
+// instanceData is the representation of an instance in DataCrunch API responses
+type instanceData struct {
+	ID           string            `json:"id"`
+	Hostname     string            `json:"hostname"`
+	Status       string            `json:"status"`
+	InstanceType string            `json:"instance_type"`
+	Image        string            `json:"image"`
+	PublicIP     string            `json:"public_ip"`
+	PrivateIP    string            `json:"private_ip"`
+	SSHKey       string            `json:"ssh_key"`
+	CreatedAt    string            `json:"created_at"`
+	Labels       map[string]string `json:"labels"`
+	Tags         map[string]string `json:"tags"`
+	LocationCode string            `json:"location_code"`
+
+	AntiAffinityGroup  string   `json:"anti_affinity_group"`
+	SecurityGroupIDs   []string `json:"security_group_ids"`
+	InterruptionReason string   `json:"interruption_reason"`
+}
+
+func (d *instanceData) toInstance() *cloud.Instance {
+	return &cloud.Instance{
+		ID:           d.ID,
+		Name:         d.Hostname,
+		State:        d.Status,
+		InstanceType: d.InstanceType,
+		ImageID:      d.Image,
+		PublicIP:     d.PublicIP,
+		PrivateIP:    d.PrivateIP,
+		SSHKeyName:   d.SSHKey,
+		CreatedAt:    d.CreatedAt,
+		Region:       d.LocationCode,
+		Labels:       d.Labels,
+		Tags:         d.Tags,
+
+		AntiAffinityGroup:  d.AntiAffinityGroup,
+		SecurityGroupIDs:   d.SecurityGroupIDs,
+		InterruptionReason: d.InterruptionReason,
+	}
+}
+
+// ListInstances lists all instances
+func (c *Client) ListInstances(ctx context.Context) ([]*cloud.Instance, error) {
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceInstances), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list instances, %w", newStatusError(resp.StatusCode))
+	}
+
+	var instancesResp struct {
+		Instances json.RawMessage `json:"instances"`
+	}
+
+	if err := decodeResponse(resp, &instancesResp); err != nil {
+		return nil, fmt.Errorf("failed to decode instances response: %w", err)
+	}
+	// Taking a missing list for no instances would make the controllers create them again
+	if len(instancesResp.Instances) == 0 {
+		return nil, fmt.Errorf("failed to decode instances response: %w: no instances field", cloud.ErrUnexpectedResponse)
+	}
+	var instancesData []instanceData
+	if err := json.Unmarshal(instancesResp.Instances, &instancesData); err != nil {
+		return nil, fmt.Errorf("failed to decode instances response: %w: %w", cloud.ErrUnexpectedResponse, err)
+	}
+
+	instances := make([]*cloud.Instance, len(instancesData))
+	for i, data := range instancesData {
+		if data.ID == "" {
+			return nil, fmt.Errorf("failed to decode instances response: %w: instance without an ID", cloud.ErrUnexpectedResponse)
+		}
+		instances[i] = data.toInstance()
+	}
+
+	return instances, nil
+}
+
+// GetInstance retrieves an instance by ID
+func (c *Client) GetInstance(ctx context.Context, instanceID string) (*cloud.Instance, error) {
+	if instanceID == "" {
+		return nil, fmt.Errorf("instance ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceInstances)+"/"+instanceID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("instance %w: %s", cloud.ErrNotFound, instanceID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get instance, %w", newStatusError(resp.StatusCode))
+	}
+
+	var data instanceData
+	if err := decodeResponse(resp, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode instance response: %w", err)
+	}
+	if data.ID == "" {
+		return nil, fmt.Errorf("failed to decode instance response: %w: instance without an ID", cloud.ErrUnexpectedResponse)
+	}
+
+	return data.toInstance(), nil
+}
+
+// GetInstanceByName gets the instance with the given hostname, or nil if there is none. It fails with
+// cloud.ErrDuplicateInstanceName if more than one instance has the hostname.
+func (c *Client) GetInstanceByName(ctx context.Context, name string) (*cloud.Instance, error) {
+	if name == "" {
+		return nil, fmt.Errorf("instance name is required")
+	}
+
+	instances, err := c.ListInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *cloud.Instance
+	for _, instance := range instances {
+		if instance.Name != name {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w %s: %s, %s", cloud.ErrDuplicateInstanceName, name, found.ID, instance.ID)
+		}
+		found = instance
+	}
+
+	return found, nil
+}
+
+// WaitForInstanceState polls an instance until it is in the target state and returns it. It fails once
+// the timeout or the context expires, or when the instance is terminated while waiting for another state.
+func (c *Client) WaitForInstanceState(ctx context.Context, instanceID, target string, timeout time.Duration) (*cloud.Instance, error) {
+	if instanceID == "" {
+		return nil, fmt.Errorf("instance ID is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	interval := c.instanceStatePollInterval
+	if interval <= 0 {
+		interval = defaultInstanceStatePollInterval
+	}
+	for {
+		instance, err := c.GetInstance(ctx, instanceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wait for instance %s to be %s: %w", instanceID, target, err)
+		}
+		if instance.State == target {
+			return instance, nil
+		}
+		if instance.State == "terminated" {
+			return nil, fmt.Errorf("instance %s was terminated while waiting for it to be %s", instanceID, target)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to wait for instance %s to be %s, still %s: %w", instanceID, target, instance.State, ctx.Err())
+		case <-timer.C:
+		}
+
+		interval *= 2
+		if interval > maxInstanceStatePollInterval {
+			interval = maxInstanceStatePollInterval
+		}
+	}
+}
+
+// DeleteInstance deletes an instance
+func (c *Client) DeleteInstance(ctx context.Context, instanceID string) error {
+	if instanceID == "" {
+		return fmt.Errorf("instance ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "DELETE", c.resourcePath(ResourceInstances)+"/"+instanceID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete instance: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete instance, %w", newStatusError(resp.StatusCode))
+	}
+
+	return nil
+}
+
+// UpdateInstanceTags replaces the tags of an instance
+func (c *Client) UpdateInstanceTags(ctx context.Context, instanceID string, tags map[string]string) error {
+	if instanceID == "" {
+		return fmt.Errorf("instance ID is required")
+	}
+
+	payload := map[string]interface{}{
+		"tags": tags,
+	}
+
+	resp, err := c.makeRequest(ctx, "PUT", c.resourcePath(ResourceInstances)+"/"+instanceID+"/tags", payload)
+	if err != nil {
+		return fmt.Errorf("failed to update instance tags: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update instance tags, %w", newStatusError(resp.StatusCode))
+	}
+
+	return nil
+}
+
+// CreateInstanceSnapshot creates a snapshot of an instance's volumes
+func (c *Client) CreateInstanceSnapshot(ctx context.Context, instanceID, name string) (*cloud.Snapshot, error) {
+	if instanceID == "" {
+		return nil, fmt.Errorf("instance ID is required")
+	}
+
+	payload := map[string]string{
+		"name": name,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", c.resourcePath(ResourceInstances)+"/"+instanceID+"/snapshots", payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create instance snapshot: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to create instance snapshot, %w", newStatusError(resp.StatusCode))
+	}
+
+	snapshot, err := decodeSnapshot(resp)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Name == "" {
+		snapshot.Name = name
+	}
+	if snapshot.InstanceID == "" {
+		snapshot.InstanceID = instanceID
+	}
+
+	return snapshot, nil
+}
+
+// GetSnapshot retrieves a snapshot by ID
+func (c *Client) GetSnapshot(ctx context.Context, snapshotID string) (*cloud.Snapshot, error) {
+	if snapshotID == "" {
+		return nil, fmt.Errorf("snapshot ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceSnapshots)+"/"+url.PathEscape(snapshotID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("snapshot %w: %s", cloud.ErrNotFound, snapshotID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get snapshot, %w", newStatusError(resp.StatusCode))
+	}
+
+	return decodeSnapshot(resp)
+}
+
+func decodeSnapshot(resp *http.Response) (*cloud.Snapshot, error) {
+	var snapshotData struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		InstanceID string `json:"instance_id"`
+		Status     string `json:"status"`
+		CreatedAt  string `json:"created_at"`
+	}
+
+	if err := decodeResponse(resp, &snapshotData); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot response: %w", err)
+	}
+
+	return &cloud.Snapshot{
+		ID:         snapshotData.ID,
+		Name:       snapshotData.Name,
+		InstanceID: snapshotData.InstanceID,
+		State:      snapshotData.Status,
+		CreatedAt:  snapshotData.CreatedAt,
+	}, nil
+}
+
+// UpdateInstanceSSHKey replaces the SSH key of an existing instance. It returns cloud.ErrOperationNotSupported
+// if the API does not allow changing the SSH key after creation.
+func (c *Client) UpdateInstanceSSHKey(ctx context.Context, instanceID, sshKeyName string) error {
+	if instanceID == "" {
+		return fmt.Errorf("instance ID is required")
+	}
+
+	payload := map[string]string{
+		"ssh_key": sshKeyName,
+	}
+
+	resp, err := c.makeRequest(ctx, "PUT", c.resourcePath(ResourceInstances)+"/"+instanceID+"/ssh-key", payload)
+	if err != nil {
+		return fmt.Errorf("failed to update instance SSH key: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
 		return nil
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return fmt.Errorf("failed to update instance SSH key: %w", cloud.ErrOperationNotSupported)
+	default:
+		return fmt.Errorf("failed to update instance SSH key, %w", newStatusError(resp.StatusCode))
+	}
+}
+
+// UpdateInstanceSecurityGroups replaces the security groups of an existing instance. It returns
+// cloud.ErrOperationNotSupported if the API does not allow changing them while the instance exists.
+func (c *Client) UpdateInstanceSecurityGroups(ctx context.Context, instanceID string, securityGroupIDs []string) error {
+	if instanceID == "" {
+		return fmt.Errorf("instance ID is required")
+	}
+
+	payload := map[string][]string{
+		"security_group_ids": securityGroupIDs,
 	}
 
-	payload := map[string]string{
-		"client_id":     c.clientID,
-		"client_secret": c.clientSecret,
-		"grant_type":    "client_credentials",
+	resp, err := c.makeRequest(ctx, "PUT", c.resourcePath(ResourceInstances)+"/"+instanceID+"/security-groups", payload)
+	if err != nil {
+		return fmt.Errorf("failed to update instance security groups: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+		return nil
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return fmt.Errorf("failed to update instance security groups: %w", cloud.ErrOperationNotSupported)
+	default:
+		return fmt.Errorf("failed to update instance security groups, %w", newStatusError(resp.StatusCode))
+	}
+}
+
+// StartInstance starts an instance
+func (c *Client) StartInstance(ctx context.Context, instanceID string) error {
+	if instanceID == "" {
+		return fmt.Errorf("instance ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", c.resourcePath(ResourceInstances)+"/"+instanceID+"/start", nil)
+	if err != nil {
+		return fmt.Errorf("failed to start instance: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to start instance, %w", newStatusError(resp.StatusCode))
+	}
+
+	return nil
+}
+
+// StopInstance stops an instance
+func (c *Client) StopInstance(ctx context.Context, instanceID string) error {
+	if instanceID == "" {
+		return fmt.Errorf("instance ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", c.resourcePath(ResourceInstances)+"/"+instanceID+"/stop", nil)
+	if err != nil {
+		return fmt.Errorf("failed to stop instance: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to stop instance, %w", newStatusError(resp.StatusCode))
+	}
+
+	return nil
+}
+
+// ForceStopInstance stops an instance without waiting for a graceful shutdown
+func (c *Client) ForceStopInstance(ctx context.Context, instanceID string) error {
+	if instanceID == "" {
+		return fmt.Errorf("instance ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", c.resourcePath(ResourceInstances)+"/"+instanceID+"/force-stop", nil)
+	if err != nil {
+		return fmt.Errorf("failed to force stop instance: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to force stop instance, %w", newStatusError(resp.StatusCode))
+	}
+
+	return nil
+}
+
+// GetSpotInterruptionNotice retrieves the pending interruption notice of a spot instance.
+// It returns nil if the instance has not been scheduled for interruption.
+func (c *Client) GetSpotInterruptionNotice(ctx context.Context, instanceID string) (*cloud.SpotInterruptionNotice, error) {
+	if instanceID == "" {
+		return nil, fmt.Errorf("instance ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceInstances)+"/"+instanceID+"/interruption-notice", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spot interruption notice: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get spot interruption notice, %w", newStatusError(resp.StatusCode))
+	}
+
+	var noticeResp struct {
+		InstanceID  string `json:"instance_id"`
+		Action      string `json:"action"`
+		TerminateAt string `json:"terminate_at"`
+	}
+	if err := decodeResponse(resp, &noticeResp); err != nil {
+		return nil, fmt.Errorf("failed to decode spot interruption notice response: %w", err)
+	}
+
+	return &cloud.SpotInterruptionNotice{
+		InstanceID:      noticeResp.InstanceID,
+		Action:          noticeResp.Action,
+		TerminationTime: noticeResp.TerminateAt,
+	}, nil
+}
+
+// GetInstanceMetrics retrieves the recent GPU, CPU and memory utilization of an instance.
+// It returns nil if no metrics have been collected for the instance yet.
+func (c *Client) GetInstanceMetrics(ctx context.Context, instanceID string) (*cloud.InstanceMetrics, error) {
+	if instanceID == "" {
+		return nil, fmt.Errorf("instance ID is required")
 	}
 
-	data, err := json.Marshal(payload)
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceInstances)+"/"+instanceID+"/metrics", nil)
 	if err != nil {
-		return fmt.Errorf("failed to marshal auth payload: %w", err)
+		return nil, fmt.Errorf("failed to get instance metrics: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, fmt.Errorf("failed to get instance metrics: %w", cloud.ErrOperationNotSupported)
+	default:
+		return nil, fmt.Errorf("failed to get instance metrics, %w", newStatusError(resp.StatusCode))
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/oauth/token", bytes.NewBuffer(data))
-	if err != nil {
-		return fmt.Errorf("failed to create auth request: %w", err)
+	var metricsResp struct {
+		InstanceID        string  `json:"instance_id"`
+		GPUUtilization    float64 `json:"gpu_utilization"`
+		CPUUtilization    float64 `json:"cpu_utilization"`
+		MemoryUtilization float64 `json:"memory_utilization"`
+		Timestamp         string  `json:"timestamp"`
+	}
+	if err := decodeResponse(resp, &metricsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode instance metrics response: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	return &cloud.InstanceMetrics{
+		InstanceID:        metricsResp.InstanceID,
+		GPUUtilization:    metricsResp.GPUUtilization,
+		CPUUtilization:    metricsResp.CPUUtilization,
+		MemoryUtilization: metricsResp.MemoryUtilization,
+		Timestamp:         metricsResp.Timestamp,
+	}, nil
+}
 
-	resp, err := c.httpClient.Do(req)
+// ListInstanceTypes lists available instance types
+func (c *Client) ListInstanceTypes(ctx context.Context) ([]*cloud.InstanceType, error) {
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceInstanceTypes), nil)
 	if err != nil {
-		return fmt.Errorf("failed to authenticate: %w", err)
+		return nil, fmt.Errorf("failed to list instance types: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("authentication failed with status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to list instance types, %w", newStatusError(resp.StatusCode))
 	}
 
-	var authResp struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int    `json:"expires_in"`
+	var typesResp struct {
+		InstanceTypes []struct {
+			InstanceType string `json:"instance_type"`
+			Description  string `json:"description"`
+			VCPUs        int    `json:"vcpus"`
+			MemoryGB     int    `json:"memory_gb"`
+			GPUs         int    `json:"gpus"`
+			Customizable bool   `json:"customizable"`
+			MinVCPUs     int    `json:"min_vcpus"`
+			MaxVCPUs     int    `json:"max_vcpus"`
+			MinMemoryGB  int    `json:"min_memory_gb"`
+			MaxMemoryGB  int    `json:"max_memory_gb"`
+
+			HardwareGenerations []string `json:"hardware_generations"`
+		} `json:"instance_types"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
-		return fmt.Errorf("failed to decode auth response: %w", err)
+	if err := decodeResponse(resp, &typesResp); err != nil {
+		return nil, fmt.Errorf("failed to decode instance types response: %w", err)
 	}
 
-	c.token = authResp.AccessToken
-	c.tokenExpiry = time.Now().Add(time.Duration(authResp.ExpiresIn) * time.Second)
+	instanceTypes := make([]*cloud.InstanceType, len(typesResp.InstanceTypes))
+	for i, it := range typesResp.InstanceTypes {
+		instanceTypes[i] = &cloud.InstanceType{
+			Name:         it.InstanceType,
+			Description:  it.Description,
+			VCPUs:        it.VCPUs,
+			MemoryGB:     it.MemoryGB,
+			GPUs:         it.GPUs,
+			Customizable: it.Customizable,
+			MinVCPUs:     it.MinVCPUs,
+			MaxVCPUs:     it.MaxVCPUs,
+			MinMemoryGB:  it.MinMemoryGB,
+			MaxMemoryGB:  it.MaxMemoryGB,
+
+			HardwareGenerations: it.HardwareGenerations,
+		}
+	}
 
-	return nil
+	return instanceTypes, nil
 }
 
-// makeRequest makes an authenticated request to the DataCrunch API
-func (c *Client) makeRequest(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
-	if err := c.authenticate(ctx); err != nil {
-		return nil, err
+// GetInstanceTypePrice retrieves the current on-demand and spot price of an instance type in a region.
+// Prices are cached for priceCacheTTL to avoid querying the API on every reconcile.
+func (c *Client) GetInstanceTypePrice(ctx context.Context, instanceType, region string) (*cloud.InstanceTypePrice, error) {
+	if instanceType == "" {
+		return nil, fmt.Errorf("instance type is required")
 	}
 
-	var bodyReader io.Reader
-	if body != nil {
-		data, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
-		}
-		bodyReader = bytes.NewBuffer(data)
+	cacheKey := instanceType + "/" + region
+
+	c.priceCacheMutex.Lock()
+	if cached, ok := c.priceCache[cacheKey]; ok && time.Now().Before(cached.expiresAt) {
+		c.priceCacheMutex.Unlock()
+		return cached.price, nil
 	}
+	c.priceCacheMutex.Unlock()
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	path := c.resourcePath(ResourceInstanceTypes) + "/" + url.PathEscape(instanceType) + "/price"
+	if region != "" {
+		path += "?location_code=" + url.QueryEscape(region)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
-	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance type price: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
 
-	return c.httpClient.Do(req)
-}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("instance type %w: %s", cloud.ErrNotFound, instanceType)
+	}
 
-// CreateInstance creates a new DataCrunch instance
-func (c *Client) CreateInstance(ctx context.Context, spec *cloud.InstanceSpec) (*cloud.Instance, error) {
-	payload := map[string]interface{}{
-		"hostname":      spec.Name,
-		"instance_type": spec.InstanceType,
-		"image":         spec.ImageID,
-		"ssh_key":       spec.SSHKeyName,
-		"user_data":     spec.UserData,
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get instance type price, %w", newStatusError(resp.StatusCode))
 	}
 
-	resp, err := c.makeRequest(ctx, "POST", "/instances", payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create instance: %w", err)
+	var priceData struct {
+		Currency      string  `json:"currency"`
+		OnDemandPrice float64 `json:"on_demand_price"`
+		SpotPrice     float64 `json:"spot_price"`
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("failed to create instance, status: %d", resp.StatusCode)
+	if err := decodeResponse(resp, &priceData); err != nil {
+		return nil, fmt.Errorf("failed to decode instance type price response: %w", err)
 	}
 
-	var instanceResp struct {
-		ID string `json:"id"`
+	price := &cloud.InstanceTypePrice{
+		InstanceType:  instanceType,
+		Region:        region,
+		Currency:      priceData.Currency,
+		OnDemandPrice: priceData.OnDemandPrice,
+		SpotPrice:     priceData.SpotPrice,
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&instanceResp); err != nil {
-		return nil, fmt.Errorf("failed to decode create instance response: %w", err)
+	c.priceCacheMutex.Lock()
+	if c.priceCache == nil {
+		c.priceCache = make(map[string]cachedPrice)
 	}
+	c.priceCache[cacheKey] = cachedPrice{price: price, expiresAt: time.Now().Add(priceCacheTTL)}
+	c.priceCacheMutex.Unlock()
 
-	// Return the instance details
-	return c.GetInstance(ctx, instanceResp.ID)
+	return price, nil
 }
 
-// GetInstance retrieves an instance by ID
-func (c *Client) GetInstance(ctx context.Context, instanceID string) (*cloud.Instance, error) {
-	resp, err := c.makeRequest(ctx, "GET", "/instances/"+instanceID, nil)
+// IsInstanceTypeAvailable reports whether an instance type currently has capacity in a region.
+func (c *Client) IsInstanceTypeAvailable(ctx context.Context, instanceType, region string) (bool, error) {
+	path := c.resourcePath(ResourceInstanceAvailability) + "/" + url.PathEscape(instanceType)
+	if region != "" {
+		path += "?location_code=" + url.QueryEscape(region)
+	}
+
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get instance: %w", err)
+		return false, fmt.Errorf("failed to get instance type availability: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("instance not found: %s", instanceID)
+		return false, fmt.Errorf("instance type %w: %s", cloud.ErrNotFound, instanceType)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get instance, status: %d", resp.StatusCode)
+		return false, fmt.Errorf("failed to get instance type availability, %w", newStatusError(resp.StatusCode))
 	}
 
-	var instanceData struct {
-		ID           string `json:"id"`
-		Hostname     string `json:"hostname"`
-		Status       string `json:"status"`
-		InstanceType string `json:"instance_type"`
-		Image        string `json:"image"`
-		PublicIP     string `json:"public_ip"`
-		PrivateIP    string `json:"private_ip"`
-		SSHKey       string `json:"ssh_key"`
-		CreatedAt    string `json:"created_at"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&instanceData); err != nil {
-		return nil, fmt.Errorf("failed to decode instance response: %w", err)
+	var available bool
+	if err := decodeResponse(resp, &available); err != nil {
+		return false, fmt.Errorf("failed to decode instance type availability response: %w", err)
 	}
 
-	return &cloud.Instance{
-		ID:           instanceData.ID,
-		Name:         instanceData.Hostname,
-		State:        instanceData.Status,
-		InstanceType: instanceData.InstanceType,
-		ImageID:      instanceData.Image,
-		PublicIP:     instanceData.PublicIP,
-		PrivateIP:    instanceData.PrivateIP,
-		SSHKeyName:   instanceData.SSHKey,
-		CreatedAt:    instanceData.CreatedAt,
-	}, nil
+	return available, nil
 }
 
-// DeleteInstance deletes an instance
-func (c *Client) DeleteInstance(ctx context.Context, instanceID string) error {
-	resp, err := c.makeRequest(ctx, "DELETE", "/instances/"+instanceID, nil)
+// GetAccountLimits retrieves the instance and GPU limits of the account and their current usage
+func (c *Client) GetAccountLimits(ctx context.Context) (*cloud.AccountLimits, error) {
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceAccount)+"/limits", nil)
 	if err != nil {
-		return fmt.Errorf("failed to delete instance: %w", err)
+		return nil, fmt.Errorf("failed to get account limits: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to delete instance, status: %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get account limits, %w", newStatusError(resp.StatusCode))
 	}
 
-	return nil
+	var limitsResp struct {
+		MaxInstances  int `json:"max_instances"`
+		MaxGPUs       int `json:"max_gpus"`
+		UsedInstances int `json:"used_instances"`
+		UsedGPUs      int `json:"used_gpus"`
+	}
+
+	if err := decodeResponse(resp, &limitsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode account limits response: %w", err)
+	}
+
+	return &cloud.AccountLimits{
+		MaxInstances:  limitsResp.MaxInstances,
+		MaxGPUs:       limitsResp.MaxGPUs,
+		UsedInstances: limitsResp.UsedInstances,
+		UsedGPUs:      limitsResp.UsedGPUs,
+	}, nil
 }
 
-// StartInstance starts an instance
-func (c *Client) StartInstance(ctx context.Context, instanceID string) error {
-	resp, err := c.makeRequest(ctx, "POST", "/instances/"+instanceID+"/start", nil)
+// ListLocations lists the regions instances can be placed in
+func (c *Client) ListLocations(ctx context.Context) ([]*cloud.Location, error) {
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceLocations), nil)
 	if err != nil {
-		return fmt.Errorf("failed to start instance: %w", err)
+		return nil, fmt.Errorf("failed to list locations: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to start instance, status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to list locations, %w", newStatusError(resp.StatusCode))
 	}
 
-	return nil
-}
+	var locationsResp []struct {
+		Code        string `json:"code"`
+		Name        string `json:"name"`
+		CountryCode string `json:"country_code"`
+	}
 
-// StopInstance stops an instance
-func (c *Client) StopInstance(ctx context.Context, instanceID string) error {
-	resp, err := c.makeRequest(ctx, "POST", "/instances/"+instanceID+"/stop", nil)
-	if err != nil {
-		return fmt.Errorf("failed to stop instance: %w", err)
+	if err := decodeResponse(resp, &locationsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode locations response: %w", err)
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to stop instance, status: %d", resp.StatusCode)
+	locations := make([]*cloud.Location, len(locationsResp))
+	for i, location := range locationsResp {
+		locations[i] = &cloud.Location{
+			Code:        location.Code,
+			Name:        location.Name,
+			CountryCode: location.CountryCode,
+		}
 	}
 
-	return nil
+	return locations, nil
 }
 
-// ListImages lists available images
-func (c *Client) ListImages(ctx context.Context) ([]*cloud.Image, error) {
-	resp, err := c.makeRequest(ctx, "GET", "/images", nil)
+// ListImages lists the images available in a region, or all images if region is empty
+func (c *Client) ListImages(ctx context.Context, region string) ([]*cloud.Image, error) {
+	path := c.resourcePath(ResourceImages)
+	if region != "" {
+		path += "?location_code=" + url.QueryEscape(region)
+	}
+
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list images: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to list images, status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to list images, %w", newStatusError(resp.StatusCode))
 	}
 
 	var imagesResp struct {
@@ -283,10 +1476,11 @@ func (c *Client) ListImages(ctx context.Context) ([]*cloud.Image, error) {
 			Description string `json:"description"`
 			OSType      string `json:"os_type"`
 			CreatedAt   string `json:"created_at"`
+			Region      string `json:"location_code"`
 		} `json:"images"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&imagesResp); err != nil {
+	if err := decodeResponse(resp, &imagesResp); err != nil {
 		return nil, fmt.Errorf("failed to decode images response: %w", err)
 	}
 
@@ -298,26 +1492,40 @@ func (c *Client) ListImages(ctx context.Context) ([]*cloud.Image, error) {
 			Description: img.Description,
 			OSType:      img.OSType,
 			CreatedAt:   img.CreatedAt,
+			Region:      img.Region,
 		}
 	}
 
 	return images, nil
 }
 
-// GetImage retrieves an image by ID
-func (c *Client) GetImage(ctx context.Context, imageID string) (*cloud.Image, error) {
-	resp, err := c.makeRequest(ctx, "GET", "/images/"+imageID, nil)
+// GetImage retrieves an image by ID. A non-empty region restricts the lookup to images available in that
+// region, as the same image may have a different ID in each region.
+func (c *Client) GetImage(ctx context.Context, imageID, region string) (*cloud.Image, error) {
+	if imageID == "" {
+		return nil, fmt.Errorf("image ID is required")
+	}
+
+	path := c.resourcePath(ResourceImages) + "/" + imageID
+	if region != "" {
+		path += "?location_code=" + url.QueryEscape(region)
+	}
+
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get image: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("image not found: %s", imageID)
+		if region != "" {
+			return nil, fmt.Errorf("image %w in region %s: %s", cloud.ErrNotFound, region, imageID)
+		}
+		return nil, fmt.Errorf("image %w: %s", cloud.ErrNotFound, imageID)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get image, status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to get image, %w", newStatusError(resp.StatusCode))
 	}
 
 	var imageData struct {
@@ -326,9 +1534,10 @@ func (c *Client) GetImage(ctx context.Context, imageID string) (*cloud.Image, er
 		Description string `json:"description"`
 		OSType      string `json:"os_type"`
 		CreatedAt   string `json:"created_at"`
+		Region      string `json:"location_code"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&imageData); err != nil {
+	if err := decodeResponse(resp, &imageData); err != nil {
 		return nil, fmt.Errorf("failed to decode image response: %w", err)
 	}
 
@@ -338,31 +1547,33 @@ func (c *Client) GetImage(ctx context.Context, imageID string) (*cloud.Image, er
 		Description: imageData.Description,
 		OSType:      imageData.OSType,
 		CreatedAt:   imageData.CreatedAt,
+		Region:      imageData.Region,
 	}, nil
 }
 
 // ListSSHKeys lists SSH keys
 func (c *Client) ListSSHKeys(ctx context.Context) ([]*cloud.SSHKey, error) {
-	resp, err := c.makeRequest(ctx, "GET", "/ssh-keys", nil)
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceSSHKeys), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list SSH keys: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to list SSH keys, status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to list SSH keys, %w", newStatusError(resp.StatusCode))
 	}
 
 	var keysResp struct {
 		Keys []struct {
-			ID        string `json:"id"`
-			Name      string `json:"name"`
-			PublicKey string `json:"public_key"`
-			CreatedAt string `json:"created_at"`
+			ID        string            `json:"id"`
+			Name      string            `json:"name"`
+			PublicKey string            `json:"public_key"`
+			CreatedAt string            `json:"created_at"`
+			Tags      map[string]string `json:"tags"`
 		} `json:"ssh_keys"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&keysResp); err != nil {
+	if err := decodeResponse(resp, &keysResp); err != nil {
 		return nil, fmt.Errorf("failed to decode SSH keys response: %w", err)
 	}
 
@@ -373,6 +1584,7 @@ func (c *Client) ListSSHKeys(ctx context.Context) ([]*cloud.SSHKey, error) {
 			Name:      key.Name,
 			PublicKey: key.PublicKey,
 			CreatedAt: key.CreatedAt,
+			Tags:      key.Tags,
 		}
 	}
 
@@ -380,30 +1592,39 @@ func (c *Client) ListSSHKeys(ctx context.Context) ([]*cloud.SSHKey, error) {
 }
 
 // CreateSSHKey creates a new SSH key
-func (c *Client) CreateSSHKey(ctx context.Context, name, publicKey string) (*cloud.SSHKey, error) {
-	payload := map[string]string{
+func (c *Client) CreateSSHKey(ctx context.Context, name, publicKey string, tags map[string]string) (*cloud.SSHKey, error) {
+	// The API only rejects malformed keys with a generic bad request
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey)); err != nil {
+		return nil, fmt.Errorf("failed to create SSH key %s: %w: %v", name, cloud.ErrInvalidPublicKey, err)
+	}
+
+	payload := map[string]interface{}{
 		"name":       name,
 		"public_key": publicKey,
 	}
+	if len(tags) > 0 {
+		payload["tags"] = tags
+	}
 
-	resp, err := c.makeRequest(ctx, "POST", "/ssh-keys", payload)
+	resp, err := c.makeRequest(ctx, "POST", c.resourcePath(ResourceSSHKeys), payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SSH key: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("failed to create SSH key, status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to create SSH key, %w", newStatusError(resp.StatusCode))
 	}
 
 	var keyData struct {
-		ID        string `json:"id"`
-		Name      string `json:"name"`
-		PublicKey string `json:"public_key"`
-		CreatedAt string `json:"created_at"`
+		ID        string            `json:"id"`
+		Name      string            `json:"name"`
+		PublicKey string            `json:"public_key"`
+		CreatedAt string            `json:"created_at"`
+		Tags      map[string]string `json:"tags"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&keyData); err != nil {
+	if err := decodeResponse(resp, &keyData); err != nil {
 		return nil, fmt.Errorf("failed to decode SSH key response: %w", err)
 	}
 
@@ -412,39 +1633,332 @@ func (c *Client) CreateSSHKey(ctx context.Context, name, publicKey string) (*clo
 		Name:      keyData.Name,
 		PublicKey: keyData.PublicKey,
 		CreatedAt: keyData.CreatedAt,
+		Tags:      keyData.Tags,
 	}, nil
 }
 
 // DeleteSSHKey deletes an SSH key
 func (c *Client) DeleteSSHKey(ctx context.Context, keyID string) error {
-	resp, err := c.makeRequest(ctx, "DELETE", "/ssh-keys/"+keyID, nil)
+	if keyID == "" {
+		return fmt.Errorf("SSH key ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "DELETE", c.resourcePath(ResourceSSHKeys)+"/"+keyID, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete SSH key: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to delete SSH key, status: %d", resp.StatusCode)
+		return fmt.Errorf("failed to delete SSH key, %w", newStatusError(resp.StatusCode))
+	}
+
+	return nil
+}
+
+// CreateVPC creates a new VPC
+func (c *Client) CreateVPC(ctx context.Context, spec *cloud.VPCSpec) (*cloud.VPC, error) {
+	payload := map[string]interface{}{
+		"name":          spec.Name,
+		"cidr_block":    spec.CidrBlock,
+		"location_code": spec.Region,
+	}
+
+	if len(spec.Tags) > 0 {
+		payload["tags"] = spec.Tags
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", c.resourcePath(ResourceVPCs), payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VPC: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to create VPC, %w", newAPIError(resp))
+	}
+
+	return decodeVPC(resp)
+}
+
+// GetVPC retrieves a VPC by ID
+func (c *Client) GetVPC(ctx context.Context, vpcID string) (*cloud.VPC, error) {
+	if vpcID == "" {
+		return nil, fmt.Errorf("VPC ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceVPCs)+"/"+url.PathEscape(vpcID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VPC: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("VPC %w: %s", cloud.ErrNotFound, vpcID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get VPC, %w", newStatusError(resp.StatusCode))
+	}
+
+	return decodeVPC(resp)
+}
+
+// DeleteVPC deletes a VPC. A VPC that is already gone is not an error.
+func (c *Client) DeleteVPC(ctx context.Context, vpcID string) error {
+	if vpcID == "" {
+		return fmt.Errorf("VPC ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "DELETE", c.resourcePath(ResourceVPCs)+"/"+url.PathEscape(vpcID), nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete VPC: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete VPC, %w", newStatusError(resp.StatusCode))
+	}
+
+	return nil
+}
+
+func decodeVPC(resp *http.Response) (*cloud.VPC, error) {
+	var vpcData struct {
+		ID           string `json:"id"`
+		Name         string `json:"name"`
+		CidrBlock    string `json:"cidr_block"`
+		Status       string `json:"status"`
+		LocationCode string `json:"location_code"`
+	}
+
+	if err := decodeResponse(resp, &vpcData); err != nil {
+		return nil, fmt.Errorf("failed to decode VPC response: %w", err)
+	}
+
+	return &cloud.VPC{
+		ID:        vpcData.ID,
+		Name:      vpcData.Name,
+		CidrBlock: vpcData.CidrBlock,
+		State:     vpcData.Status,
+		Region:    vpcData.LocationCode,
+	}, nil
+}
+
+// CreateSubnet creates a new subnet in a VPC
+func (c *Client) CreateSubnet(ctx context.Context, spec *cloud.SubnetSpec) (*cloud.Subnet, error) {
+	payload := map[string]interface{}{
+		"name":              spec.Name,
+		"vpc_id":            spec.VPCID,
+		"cidr_block":        spec.CidrBlock,
+		"availability_zone": spec.AvailabilityZone,
+		"is_public":         spec.IsPublic,
+	}
+
+	if len(spec.Tags) > 0 {
+		payload["tags"] = spec.Tags
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", c.resourcePath(ResourceSubnets), payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subnet: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to create subnet, %w", newAPIError(resp))
+	}
+
+	return decodeSubnet(resp)
+}
+
+// GetSubnet retrieves a subnet by ID
+func (c *Client) GetSubnet(ctx context.Context, subnetID string) (*cloud.Subnet, error) {
+	if subnetID == "" {
+		return nil, fmt.Errorf("subnet ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceSubnets)+"/"+url.PathEscape(subnetID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subnet: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("subnet %w: %s", cloud.ErrNotFound, subnetID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get subnet, %w", newStatusError(resp.StatusCode))
+	}
+
+	return decodeSubnet(resp)
+}
+
+// DeleteSubnet deletes a subnet. A subnet that is already gone is not an error.
+func (c *Client) DeleteSubnet(ctx context.Context, subnetID string) error {
+	if subnetID == "" {
+		return fmt.Errorf("subnet ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "DELETE", c.resourcePath(ResourceSubnets)+"/"+url.PathEscape(subnetID), nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete subnet: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete subnet, %w", newStatusError(resp.StatusCode))
 	}
 
 	return nil
 }
 
-// Load balancer methods (placeholder implementations as DataCrunch may not have native LB support)
+func decodeSubnet(resp *http.Response) (*cloud.Subnet, error) {
+	var subnetData struct {
+		ID               string `json:"id"`
+		Name             string `json:"name"`
+		VPCID            string `json:"vpc_id"`
+		CidrBlock        string `json:"cidr_block"`
+		AvailabilityZone string `json:"availability_zone"`
+		Status           string `json:"status"`
+	}
+
+	if err := decodeResponse(resp, &subnetData); err != nil {
+		return nil, fmt.Errorf("failed to decode subnet response: %w", err)
+	}
+
+	return &cloud.Subnet{
+		ID:               subnetData.ID,
+		Name:             subnetData.Name,
+		VPCID:            subnetData.VPCID,
+		CidrBlock:        subnetData.CidrBlock,
+		AvailabilityZone: subnetData.AvailabilityZone,
+		State:            subnetData.Status,
+	}, nil
+}
+
+// CreateLoadBalancer creates a new load balancer
 func (c *Client) CreateLoadBalancer(ctx context.Context, spec *cloud.LoadBalancerSpec) (*cloud.LoadBalancer, error) {
-	// This is a placeholder - DataCrunch may not have native load balancer support
-	// In a real implementation, you might use an external load balancer service
-	return nil, fmt.Errorf("load balancer creation not yet implemented")
+	payload := map[string]interface{}{
+		"name": spec.Name,
+		"type": spec.Type,
+	}
+
+	// The control plane load balancer is created before its first target exists
+	if len(spec.Targets) > 0 {
+		payload["targets"] = spec.Targets
+	}
+
+	if spec.HealthCheckPath != "" {
+		payload["health_check_path"] = spec.HealthCheckPath
+	}
+
+	if len(spec.Tags) > 0 {
+		payload["tags"] = spec.Tags
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", c.resourcePath(ResourceLoadBalancers), payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create load balancer: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to create load balancer, %w", newAPIError(resp))
+	}
+
+	return decodeLoadBalancer(resp)
 }
 
+// GetLoadBalancer retrieves a load balancer by ID
 func (c *Client) GetLoadBalancer(ctx context.Context, lbID string) (*cloud.LoadBalancer, error) {
-	return nil, fmt.Errorf("load balancer retrieval not yet implemented")
+	if lbID == "" {
+		return nil, fmt.Errorf("load balancer ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "GET", c.resourcePath(ResourceLoadBalancers)+"/"+url.PathEscape(lbID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get load balancer: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("load balancer %w: %s", cloud.ErrNotFound, lbID)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get load balancer, %w", newStatusError(resp.StatusCode))
+	}
+
+	return decodeLoadBalancer(resp)
+}
+
+func decodeLoadBalancer(resp *http.Response) (*cloud.LoadBalancer, error) {
+	var lbData struct {
+		ID      string   `json:"id"`
+		Name    string   `json:"name"`
+		DNSName string   `json:"dns_name"`
+		Status  string   `json:"status"`
+		Type    string   `json:"type"`
+		Targets []string `json:"targets"`
+	}
+
+	if err := decodeResponse(resp, &lbData); err != nil {
+		return nil, fmt.Errorf("failed to decode load balancer response: %w", err)
+	}
+
+	return &cloud.LoadBalancer{
+		ID:      lbData.ID,
+		Name:    lbData.Name,
+		DNSName: lbData.DNSName,
+		State:   lbData.Status,
+		Type:    lbData.Type,
+		Targets: lbData.Targets,
+	}, nil
 }
 
+// DeleteLoadBalancer deletes a load balancer. Deleting a load balancer that no longer exists succeeds.
 func (c *Client) DeleteLoadBalancer(ctx context.Context, lbID string) error {
-	return fmt.Errorf("load balancer deletion not yet implemented")
+	if lbID == "" {
+		return fmt.Errorf("load balancer ID is required")
+	}
+
+	resp, err := c.makeRequest(ctx, "DELETE", c.resourcePath(ResourceLoadBalancers)+"/"+url.PathEscape(lbID), nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete load balancer: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete load balancer, %w", newStatusError(resp.StatusCode))
+	}
+
+	return nil
 }
 
+// UpdateLoadBalancerTargets replaces the targets of a load balancer
 func (c *Client) UpdateLoadBalancerTargets(ctx context.Context, lbID string, targets []string) error {
-	return fmt.Errorf("load balancer target update not yet implemented")
+	if lbID == "" {
+		return fmt.Errorf("load balancer ID is required")
+	}
+
+	payload := map[string][]string{
+		"targets": targets,
+	}
+
+	resp, err := c.makeRequest(ctx, "PUT", c.resourcePath(ResourceLoadBalancers)+"/"+url.PathEscape(lbID)+"/targets", payload)
+	if err != nil {
+		return fmt.Errorf("failed to update load balancer targets: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+		return nil
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return fmt.Errorf("failed to update load balancer targets: %w", cloud.ErrOperationNotSupported)
+	default:
+		return fmt.Errorf("failed to update load balancer targets, %w", newStatusError(resp.StatusCode))
+	}
 }
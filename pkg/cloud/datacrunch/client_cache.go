@@ -0,0 +1,66 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datacrunch
+
+import (
+	"sync"
+)
+
+// NewClientFunc creates a client for a set of credentials and a base URL, an empty base URL meaning the
+// public API
+type NewClientFunc func(clientID, clientSecret, baseURL string) *Client
+
+// ClientCache hands out one client per client ID and base URL, so that the access token of a client is
+// reused across reconciles instead of authenticating for each of them. It is safe for concurrent use.
+type ClientCache struct {
+	mu      sync.Mutex
+	clients map[clientCacheKey]*Client
+}
+
+// clientCacheKey identifies the clients of a ClientCache
+type clientCacheKey struct {
+	clientID string
+	baseURL  string
+}
+
+// NewClientCache creates an empty ClientCache
+func NewClientCache() *ClientCache {
+	return &ClientCache{clients: map[clientCacheKey]*Client{}}
+}
+
+// Get returns the cached client for the client ID and base URL, creating it with newClient if there is
+// none yet. A cached client whose secret differs, e.g. after the secret was rotated, is replaced. The
+// client is configured by newClient only, so that it is not modified while other goroutines use it.
+func (cc *ClientCache) Get(clientID, clientSecret, baseURL string, newClient NewClientFunc) *Client {
+	key := clientCacheKey{clientID: clientID, baseURL: baseURL}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if client, ok := cc.clients[key]; ok && client.clientSecret == clientSecret {
+		return client
+	}
+
+	if newClient == nil {
+		newClient = func(clientID, clientSecret, baseURL string) *Client {
+			return NewClientWithOptions(clientID, clientSecret, ClientOptions{BaseURL: baseURL})
+		}
+	}
+	client := newClient(clientID, clientSecret, baseURL)
+	cc.clients[key] = client
+	return client
+}
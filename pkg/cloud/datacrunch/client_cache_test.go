@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datacrunch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newTokenCountingServer serves an empty instance list and counts the authentications
+func newTokenCountingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var authentications atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oauth/token":
+			authentications.Add(1)
+			_, _ = w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+		case "/instances":
+			_, _ = w.Write([]byte(`{"instances":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &authentications
+}
+
+func TestClientCache_ReusesToken(t *testing.T) {
+	server, authentications := newTokenCountingServer(t)
+	cache := NewClientCache()
+
+	first := cache.Get("client-id", "client-secret", server.URL, nil)
+	for i := 0; i < 3; i++ {
+		client := cache.Get("client-id", "client-secret", server.URL, nil)
+		if client != first {
+			t.Fatal("Expected the cached client to be returned")
+		}
+		if _, err := client.ListInstances(context.Background()); err != nil {
+			t.Fatalf("ListInstances() error = %v", err)
+		}
+	}
+
+	// Concurrent reconciles share the client and still authenticate once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Get("client-id", "client-secret", server.URL, nil).ListInstances(context.Background()); err != nil {
+				t.Errorf("ListInstances() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := authentications.Load(); got != 1 {
+		t.Errorf("Expected one authentication, got %d", got)
+	}
+}
+
+func TestClientCache_ConcurrentFirstUse(t *testing.T) {
+	server, authentications := newTokenCountingServer(t)
+	cache := NewClientCache()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Get("client-id", "client-secret", server.URL, nil).ListInstances(context.Background()); err != nil {
+				t.Errorf("ListInstances() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := authentications.Load(); got != 1 {
+		t.Errorf("Expected one authentication, got %d", got)
+	}
+}
+
+func TestClientCache_Keys(t *testing.T) {
+	cache := NewClientCache()
+	var created int
+	newClient := func(clientID, clientSecret, baseURL string) *Client {
+		created++
+		return NewClientWithURL(clientID, clientSecret, baseURL)
+	}
+
+	client := cache.Get("client-id", "client-secret", "https://a.example.com", newClient)
+	if cache.Get("client-id", "client-secret", "https://a.example.com", newClient) != client {
+		t.Error("Expected the same client for the same client ID and base URL")
+	}
+	if cache.Get("other-id", "client-secret", "https://a.example.com", newClient) == client {
+		t.Error("Expected another client for another client ID")
+	}
+	if cache.Get("client-id", "client-secret", "https://b.example.com", newClient) == client {
+		t.Error("Expected another client for another base URL")
+	}
+
+	rotated := cache.Get("client-id", "rotated-secret", "https://a.example.com", newClient)
+	if rotated == client || rotated.clientSecret != "rotated-secret" {
+		t.Error("Expected the client to be replaced after the secret was rotated")
+	}
+	if cache.Get("client-id", "rotated-secret", "https://a.example.com", newClient) != rotated {
+		t.Error("Expected the replacement client to be cached")
+	}
+
+	if created != 4 {
+		t.Errorf("Expected 4 clients to be created, got %d", created)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datacrunch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	// concurrencyDecreaseAfter is the number of rate limited requests since the last change of the limit
+	// that halves it, so a single 429 doesn't throttle the reconcilers
+	concurrencyDecreaseAfter = 3
+
+	// concurrencyIncreaseAfter is the number of successful requests in a row that raises the limit by one
+	concurrencyIncreaseAfter = 20
+)
+
+// AdaptiveConcurrency limits how many reconciles run at once. The limit is halved while the API responds
+// with sustained 429s and raised again one by one while requests succeed, within bounds. It is fed by the
+// requests of every client it is set on and can be shared between reconcilers.
+type AdaptiveConcurrency struct {
+	mutex sync.Mutex
+
+	min, max int
+	limit    int
+	inFlight int
+
+	// rateLimited and succeeded count the rate limited requests since the last change of the limit and the
+	// successful requests since the last rate limited one
+	rateLimited int
+	succeeded   int
+
+	// released is closed and replaced whenever a reconcile may start, waking up the waiting ones
+	released chan struct{}
+}
+
+// NewAdaptiveConcurrency creates an AdaptiveConcurrency allowing maxConcurrency reconciles at once, which
+// may drop down to minConcurrency while the API rate limits requests
+func NewAdaptiveConcurrency(minConcurrency, maxConcurrency int) (*AdaptiveConcurrency, error) {
+	if minConcurrency < 1 || maxConcurrency < minConcurrency {
+		return nil, fmt.Errorf("concurrency bounds must satisfy 1 <= min <= max, got min %d and max %d", minConcurrency, maxConcurrency)
+	}
+	return &AdaptiveConcurrency{
+		min:      minConcurrency,
+		max:      maxConcurrency,
+		limit:    maxConcurrency,
+		released: make(chan struct{}),
+	}, nil
+}
+
+// Acquire blocks until a reconcile may start or ctx is done. Every successful Acquire must be followed
+// by a Release once the reconcile is done.
+func (a *AdaptiveConcurrency) Acquire(ctx context.Context) error {
+	for {
+		a.mutex.Lock()
+		if a.inFlight < a.limit {
+			a.inFlight++
+			a.mutex.Unlock()
+			return nil
+		}
+		released := a.released
+		a.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for a reconcile slot: %w", ctx.Err())
+		case <-released:
+		}
+	}
+}
+
+// Release ends a reconcile started by Acquire
+func (a *AdaptiveConcurrency) Release() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.inFlight--
+	a.wakeUp()
+}
+
+// Limit returns how many reconciles may currently run at once
+func (a *AdaptiveConcurrency) Limit() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.limit
+}
+
+// recordRequest tunes the limit by the outcome of a request
+func (a *AdaptiveConcurrency) recordRequest(resp *http.Response, err error) {
+	if a == nil {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusTooManyRequests:
+		a.succeeded = 0
+		a.rateLimited++
+		if a.rateLimited >= concurrencyDecreaseAfter {
+			a.rateLimited = 0
+			a.limit = max(a.limit/2, a.min)
+		}
+	case err == nil && resp.StatusCode < http.StatusInternalServerError:
+		a.succeeded++
+		if a.succeeded >= concurrencyIncreaseAfter {
+			a.succeeded = 0
+			a.rateLimited = 0
+			if a.limit < a.max {
+				a.limit++
+				a.wakeUp()
+			}
+		}
+	}
+}
+
+// wakeUp lets the waiting reconciles check whether they may start, the mutex must be held
+func (a *AdaptiveConcurrency) wakeUp() {
+	close(a.released)
+	a.released = make(chan struct{})
+}
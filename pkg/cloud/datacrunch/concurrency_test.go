@@ -0,0 +1,208 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datacrunch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewAdaptiveConcurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max int
+		wantErr  bool
+	}{
+		{name: "valid bounds", min: 1, max: 10},
+		{name: "fixed concurrency", min: 4, max: 4},
+		{name: "zero minimum", min: 0, max: 10, wantErr: true},
+		{name: "minimum above maximum", min: 5, max: 4, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			concurrency, err := NewAdaptiveConcurrency(tt.min, tt.max)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error for invalid bounds")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewAdaptiveConcurrency failed: %v", err)
+			}
+			if concurrency.Limit() != tt.max {
+				t.Errorf("Expected limit %d, got %d", tt.max, concurrency.Limit())
+			}
+		})
+	}
+}
+
+func TestAdaptiveConcurrency_recordRequest(t *testing.T) {
+	ok := &http.Response{StatusCode: http.StatusOK}
+	rateLimited := &http.Response{StatusCode: http.StatusTooManyRequests}
+	serverError := &http.Response{StatusCode: http.StatusInternalServerError}
+
+	tests := []struct {
+		name      string
+		record    func(a *AdaptiveConcurrency)
+		wantLimit int
+	}{
+		{
+			name:      "no requests",
+			record:    func(_ *AdaptiveConcurrency) {},
+			wantLimit: 16,
+		},
+		{
+			name: "a single rate limited request",
+			record: func(a *AdaptiveConcurrency) {
+				a.recordRequest(rateLimited, nil)
+			},
+			wantLimit: 16,
+		},
+		{
+			name: "sustained rate limiting halves the limit",
+			record: func(a *AdaptiveConcurrency) {
+				for i := 0; i < concurrencyDecreaseAfter; i++ {
+					a.recordRequest(rateLimited, nil)
+				}
+			},
+			wantLimit: 8,
+		},
+		{
+			name: "rate limiting backs off down to the minimum",
+			record: func(a *AdaptiveConcurrency) {
+				for i := 0; i < 10*concurrencyDecreaseAfter; i++ {
+					a.recordRequest(rateLimited, nil)
+				}
+			},
+			wantLimit: 2,
+		},
+		{
+			name: "successful requests raise the limit again",
+			record: func(a *AdaptiveConcurrency) {
+				for i := 0; i < concurrencyDecreaseAfter; i++ {
+					a.recordRequest(rateLimited, nil)
+				}
+				for i := 0; i < 2*concurrencyIncreaseAfter; i++ {
+					a.recordRequest(ok, nil)
+				}
+			},
+			wantLimit: 10,
+		},
+		{
+			name: "successful requests don't exceed the maximum",
+			record: func(a *AdaptiveConcurrency) {
+				for i := 0; i < 10*concurrencyIncreaseAfter; i++ {
+					a.recordRequest(ok, nil)
+				}
+			},
+			wantLimit: 16,
+		},
+		{
+			name: "server and network errors don't change the limit",
+			record: func(a *AdaptiveConcurrency) {
+				for i := 0; i < concurrencyDecreaseAfter; i++ {
+					a.recordRequest(rateLimited, nil)
+				}
+				for i := 0; i < 2*concurrencyIncreaseAfter; i++ {
+					a.recordRequest(serverError, nil)
+					a.recordRequest(nil, errors.New("connection refused"))
+				}
+			},
+			wantLimit: 8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			concurrency, err := NewAdaptiveConcurrency(2, 16)
+			if err != nil {
+				t.Fatalf("NewAdaptiveConcurrency failed: %v", err)
+			}
+			tt.record(concurrency)
+			if concurrency.Limit() != tt.wantLimit {
+				t.Errorf("Expected limit %d, got %d", tt.wantLimit, concurrency.Limit())
+			}
+		})
+	}
+}
+
+func TestAdaptiveConcurrency_Acquire(t *testing.T) {
+	concurrency, err := NewAdaptiveConcurrency(1, 2)
+	if err != nil {
+		t.Fatalf("NewAdaptiveConcurrency failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := concurrency.Acquire(context.Background()); err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := concurrency.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Acquire to wait until the deadline at the limit, got: %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- concurrency.Acquire(context.Background())
+	}()
+	concurrency.Release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("Acquire failed after a release: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire didn't return after a release")
+	}
+}
+
+func TestClient_SetAdaptiveConcurrency(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/oauth/token" {
+			_, _ = w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	concurrency, err := NewAdaptiveConcurrency(1, 8)
+	if err != nil {
+		t.Fatalf("NewAdaptiveConcurrency failed: %v", err)
+	}
+	client := NewClientWithURL("client-id", "client-secret", server.URL)
+	client.SetAdaptiveConcurrency(concurrency)
+
+	for i := 0; i < 2*concurrencyDecreaseAfter; i++ {
+		if _, err := client.ListInstances(context.Background()); err == nil {
+			t.Fatal("Expected ListInstances to fail while rate limited")
+		}
+	}
+
+	if concurrency.Limit() != 2 {
+		t.Errorf("Expected the limit to back off to 2 under sustained rate limiting, got %d", concurrency.Limit())
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package datacrunchtest records DataCrunch API interactions to fixture files and replays them, so that
+// tests can run deterministically against responses captured from the real API.
+package datacrunchtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud/datacrunch"
+)
+
+// tokenPath is the path of the authentication endpoint below the base URL, whose request body carries
+// the client credentials
+const tokenPath = "/oauth/token"
+
+// redacted replaces credentials in recorded interactions
+const redacted = "REDACTED"
+
+// Interaction is a request sent to the API along with the response it got
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the part of a request an interaction is matched on. The URL is the path and query
+// of the request without the host, so fixtures can be replayed against any host serving the API under
+// the same path.
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response of an interaction
+type RecordedResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that sends requests through another transport and records every
+// interaction. Credentials are redacted from the recording.
+type Recorder struct {
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder creates a Recorder sending requests through transport, http.DefaultTransport if nil
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{transport: transport}
+}
+
+// NewRecordingClient creates a DataCrunch client whose interactions are recorded by the returned Recorder
+func NewRecordingClient(clientID, clientSecret, baseURL string) (*datacrunch.Client, *Recorder) {
+	recorder := NewRecorder(nil)
+	client := datacrunch.NewClientWithURL(clientID, clientSecret, baseURL)
+	client.SetTransport(recorder)
+	return client, recorder
+}
+
+// RoundTrip sends the request and records it along with its response
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.RequestURI(),
+			Body:   rawJSON(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Body:       rawJSON(respBody),
+		},
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		interaction.Response.Header = map[string]string{"Content-Type": contentType}
+	}
+	if isTokenRequest(req) {
+		interaction.Request.Body = nil
+		interaction.Response.Body = redactToken(interaction.Response.Body)
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Interactions returns the interactions recorded so far
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Save writes the recorded interactions to a fixture file
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Interactions(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal interactions: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture %s: %w", path, err)
+	}
+	return nil
+}
+
+// Replayer is an http.RoundTripper serving recorded interactions instead of sending requests. Each
+// interaction is served once, in the order it was recorded among the interactions for the same request.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer creates a Replayer serving the interactions of a fixture file
+func NewReplayer(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %w", path, err)
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("failed to decode fixture %s: %w", path, err)
+	}
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions))}, nil
+}
+
+// NewReplayingClient creates a DataCrunch client served by the interactions of a fixture file. The client
+// never reaches the network, so only the path of baseURL has to match the one used for the recording.
+func NewReplayingClient(path, baseURL string) (*datacrunch.Client, *Replayer, error) {
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := datacrunch.NewClientWithURL("replay", "replay", baseURL)
+	client.SetTransport(replayer)
+	return client, replayer, nil
+}
+
+// RoundTrip serves the first unused interaction recorded for the request
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	url := req.URL.RequestURI()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || interaction.Request.URL != url {
+			continue
+		}
+		// Credentials are not recorded, so the body of authentication requests is not compared
+		if !isTokenRequest(req) && !equalJSON(interaction.Request.Body, reqBody) {
+			continue
+		}
+		r.used[i] = true
+		return interaction.Response.toResponse(req), nil
+	}
+	return nil, fmt.Errorf("no recorded interaction for %s %s", req.Method, url)
+}
+
+// Unused returns the recorded interactions that have not been served
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// toResponse builds the HTTP response of a recorded response to req
+func (r RecordedResponse) toResponse(req *http.Request) *http.Response {
+	header := make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// isTokenRequest reports whether req is sent to the authentication endpoint
+func isTokenRequest(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, tokenPath)
+}
+
+// readBody reads a request or response body and replaces it with a copy that can be read again
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	_ = (*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// rawJSON returns data as raw JSON, encoding it as a JSON string if it is not valid JSON
+func rawJSON(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		return json.RawMessage(data)
+	}
+	encoded, _ := json.Marshal(string(data))
+	return encoded
+}
+
+// redactToken replaces the access token of an authentication response
+func redactToken(body json.RawMessage) json.RawMessage {
+	var token map[string]interface{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil
+	}
+	if _, ok := token["access_token"]; ok {
+		token["access_token"] = redacted
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// equalJSON reports whether a recorded body and a request body are the same JSON value
+func equalJSON(recorded json.RawMessage, body []byte) bool {
+	if len(recorded) == 0 || len(body) == 0 {
+		return len(recorded) == 0 && len(body) == 0
+	}
+	var a, b interface{}
+	if json.Unmarshal(recorded, &a) != nil || json.Unmarshal(rawJSON(body), &b) != nil {
+		return bytes.Equal(recorded, body)
+	}
+	return reflect.DeepEqual(a, b)
+}
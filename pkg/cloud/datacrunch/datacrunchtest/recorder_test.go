@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datacrunchtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rusik69/cluster-api-provider-datacrunch/pkg/cloud"
+)
+
+func TestRecorder_RoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/v1/oauth/token":
+			_, _ = w.Write([]byte(`{"access_token":"secret-token","expires_in":3600}`))
+		case r.URL.Path == "/v1/instances" && r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"instance-123","hostname":"test","status":"provisioning"}`))
+		case r.URL.Path == "/v1/instances/instance-123":
+			_, _ = w.Write([]byte(`{"id":"instance-123","hostname":"test","status":"running","ip":"1.2.3.4"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	ctx := context.Background()
+	spec := &cloud.InstanceSpec{Name: "test", InstanceType: "1V100.6V", ImageID: "ubuntu-22.04"}
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+
+	client, recorder := NewRecordingClient("client-id", "client-secret", server.URL+"/v1")
+	created, err := client.CreateInstance(ctx, spec)
+	if err != nil {
+		t.Fatalf("CreateInstance() error = %v", err)
+	}
+	instance, err := client.GetInstance(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetInstance() error = %v", err)
+	}
+	if err := recorder.Save(fixture); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	server.Close()
+
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	for _, secret := range []string{"client-secret", "secret-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %q to be redacted from the fixture, got %s", secret, data)
+		}
+	}
+
+	replayClient, replayer, err := NewReplayingClient(fixture, "http://replay.invalid/v1")
+	if err != nil {
+		t.Fatalf("NewReplayingClient() error = %v", err)
+	}
+	replayedCreated, err := replayClient.CreateInstance(ctx, spec)
+	if err != nil {
+		t.Fatalf("replayed CreateInstance() error = %v", err)
+	}
+	replayedInstance, err := replayClient.GetInstance(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("replayed GetInstance() error = %v", err)
+	}
+	if !reflect.DeepEqual(replayedCreated, created) {
+		t.Errorf("Expected replayed instance %+v, got %+v", created, replayedCreated)
+	}
+	if !reflect.DeepEqual(replayedInstance, instance) {
+		t.Errorf("Expected replayed instance %+v, got %+v", instance, replayedInstance)
+	}
+	if unused := replayer.Unused(); len(unused) != 0 {
+		t.Errorf("Expected every interaction to be replayed, %d unused", len(unused))
+	}
+
+	// Every interaction is served once and requests that were not recorded fail
+	if _, err := replayClient.GetInstance(ctx, created.ID); err == nil {
+		t.Error("Expected an error for a request without a recorded interaction")
+	}
+	if _, err := replayClient.CreateInstance(ctx, &cloud.InstanceSpec{Name: "other"}); err == nil {
+		t.Error("Expected an error for a request body that was not recorded")
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datacrunch
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// healthWindowSize is the number of most recent requests the error rate is computed over
+	healthWindowSize = 50
+
+	// healthMinRequests is the number of requests needed before the error rate is taken into account,
+	// so a single failure after a restart doesn't report the API as degraded
+	healthMinRequests = 5
+
+	// healthMaxErrorRate is the error rate above which the API is reported as degraded
+	healthMaxErrorRate = 0.5
+)
+
+// HealthSummary is a snapshot of the health of the DataCrunch API as seen by the clients
+type HealthSummary struct {
+	// LastAuthTime is when a client last obtained an access token, zero if none has yet
+	LastAuthTime time.Time
+	// TokenValid is true while the last obtained access token has not expired
+	TokenValid bool
+	// RecentRequests and RecentErrors count the most recent requests and how many of them failed
+	RecentRequests int
+	RecentErrors   int
+}
+
+// ErrorRate returns the share of recent requests that failed
+func (s HealthSummary) ErrorRate() float64 {
+	if s.RecentRequests == 0 {
+		return 0
+	}
+	return float64(s.RecentErrors) / float64(s.RecentRequests)
+}
+
+// HealthTracker records the outcome of the requests of every client it is set on. A request fails when
+// it can't be sent or the API responds with a server error. The tracker can be shared between clients
+// to report the health of the API for all of them.
+type HealthTracker struct {
+	mutex sync.Mutex
+
+	lastAuthTime time.Time
+	tokenExpiry  time.Time
+
+	// failures is a ring buffer of the outcome of the most recent requests
+	failures []bool
+	next     int
+}
+
+// NewHealthTracker creates a HealthTracker without any recorded requests
+func NewHealthTracker() *HealthTracker {
+	return &HealthTracker{}
+}
+
+// recordAuthentication records that an access token valid until expiry was obtained
+func (h *HealthTracker) recordAuthentication(expiry time.Time) {
+	if h == nil {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastAuthTime = time.Now()
+	h.tokenExpiry = expiry
+}
+
+// recordRequest records the outcome of a request
+func (h *HealthTracker) recordRequest(resp *http.Response, err error) {
+	if h == nil {
+		return
+	}
+
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.failures) < healthWindowSize {
+		h.failures = append(h.failures, failed)
+		return
+	}
+	h.failures[h.next] = failed
+	h.next = (h.next + 1) % healthWindowSize
+}
+
+// Summary returns a snapshot of the recorded health
+func (h *HealthTracker) Summary() HealthSummary {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	summary := HealthSummary{
+		LastAuthTime:   h.lastAuthTime,
+		TokenValid:     !h.tokenExpiry.IsZero() && time.Now().Before(h.tokenExpiry),
+		RecentRequests: len(h.failures),
+	}
+	for _, failed := range h.failures {
+		if failed {
+			summary.RecentErrors++
+		}
+	}
+
+	return summary
+}
+
+// Check implements a healthz.Checker reporting the API as degraded when too many recent requests failed
+func (h *HealthTracker) Check(_ *http.Request) error {
+	summary := h.Summary()
+	if summary.RecentRequests < healthMinRequests || summary.ErrorRate() <= healthMaxErrorRate {
+		return nil
+	}
+
+	lastAuth := "never"
+	if !summary.LastAuthTime.IsZero() {
+		lastAuth = summary.LastAuthTime.Format(time.RFC3339)
+	}
+	return fmt.Errorf("DataCrunch API is degraded: %d of the last %d requests failed (last authentication: %s, token valid: %t)",
+		summary.RecentErrors, summary.RecentRequests, lastAuth, summary.TokenValid)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package datacrunch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthTracker_Check(t *testing.T) {
+	ok := &http.Response{StatusCode: http.StatusOK}
+	notFound := &http.Response{StatusCode: http.StatusNotFound}
+	serverError := &http.Response{StatusCode: http.StatusInternalServerError}
+	networkError := errors.New("connection refused")
+
+	tests := []struct {
+		name         string
+		record       func(h *HealthTracker)
+		wantDegraded bool
+	}{
+		{
+			name:   "no requests",
+			record: func(_ *HealthTracker) {},
+		},
+		{
+			name: "successful requests",
+			record: func(h *HealthTracker) {
+				for i := 0; i < 10; i++ {
+					h.recordRequest(ok, nil)
+				}
+			},
+		},
+		{
+			name: "client errors are not API failures",
+			record: func(h *HealthTracker) {
+				for i := 0; i < 10; i++ {
+					h.recordRequest(notFound, nil)
+				}
+			},
+		},
+		{
+			name: "too few requests to judge",
+			record: func(h *HealthTracker) {
+				h.recordRequest(nil, networkError)
+				h.recordRequest(serverError, nil)
+			},
+		},
+		{
+			name: "mostly failing requests",
+			record: func(h *HealthTracker) {
+				h.recordRequest(ok, nil)
+				for i := 0; i < 3; i++ {
+					h.recordRequest(serverError, nil)
+					h.recordRequest(nil, networkError)
+				}
+			},
+			wantDegraded: true,
+		},
+		{
+			name: "recovered once failures leave the window",
+			record: func(h *HealthTracker) {
+				for i := 0; i < healthWindowSize; i++ {
+					h.recordRequest(serverError, nil)
+				}
+				for i := 0; i < healthWindowSize; i++ {
+					h.recordRequest(ok, nil)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthTracker()
+			tt.record(h)
+
+			err := h.Check(nil)
+			if tt.wantDegraded && (err == nil || !strings.Contains(err.Error(), "degraded")) {
+				t.Errorf("Expected degraded error, got: %v", err)
+			}
+			if !tt.wantDegraded && err != nil {
+				t.Errorf("Expected healthy, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_SetHealthTracker(t *testing.T) {
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/oauth/token":
+			_, _ = w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+		case failing:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte(`{"instances":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	tracker := NewHealthTracker()
+	client := NewClientWithURL("client-id", "client-secret", server.URL)
+	client.SetHealthTracker(tracker)
+
+	if _, err := client.ListInstances(context.Background()); err != nil {
+		t.Fatalf("ListInstances failed: %v", err)
+	}
+
+	summary := tracker.Summary()
+	if summary.LastAuthTime.IsZero() || !summary.TokenValid {
+		t.Errorf("Expected a recorded authentication with a valid token, got %+v", summary)
+	}
+	if summary.RecentRequests != 2 || summary.RecentErrors != 0 {
+		t.Errorf("Expected 2 successful requests, got %+v", summary)
+	}
+	if err := tracker.Check(nil); err != nil {
+		t.Errorf("Expected healthy, got: %v", err)
+	}
+
+	failing = true
+	for i := 0; i < healthMinRequests; i++ {
+		_, _ = client.ListInstances(context.Background())
+	}
+
+	summary = tracker.Summary()
+	if summary.RecentErrors != healthMinRequests {
+		t.Errorf("Expected %d failed requests, got %+v", healthMinRequests, summary)
+	}
+	if err := tracker.Check(nil); err == nil {
+		t.Error("Expected degraded once most requests fail")
+	}
+}
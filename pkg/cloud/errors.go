@@ -0,0 +1,158 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error codes returned by the DataCrunch API for exhausted account limits
+const (
+	ErrorCodeQuotaExceeded     = "quota_exceeded"
+	ErrorCodeLimitExceeded     = "limit_exceeded"
+	ErrorCodeInsufficientQuota = "insufficient_quota"
+)
+
+// Error codes returned by the DataCrunch API when an instance type has no capacity left
+const (
+	ErrorCodeInsufficientCapacity = "insufficient_capacity"
+	ErrorCodeCapacityUnavailable  = "capacity_unavailable"
+)
+
+// ErrNotFound is matched by the errors of requests for a resource the DataCrunch API doesn't know
+var ErrNotFound = errors.New("not found")
+
+// ErrUnauthorized is matched by the errors of requests the DataCrunch API rejected as unauthenticated or
+// forbidden
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrRateLimited is matched by the errors of requests the DataCrunch API rejected for exceeding its rate limit
+var ErrRateLimited = errors.New("rate limited")
+
+// ErrUnexpectedResponse is wrapped by the errors of requests whose response has another structure than
+// the client expects, e.g. after an incompatible change of the DataCrunch API. Retrying won't help until
+// the provider is upgraded.
+var ErrUnexpectedResponse = errors.New("unexpected response from the DataCrunch API")
+
+// ErrMissingInstanceID is returned when the DataCrunch API accepts an instance creation request
+// but its response does not include the ID of the created instance
+var ErrMissingInstanceID = errors.New("create instance response did not include an instance ID")
+
+// ErrOperationNotSupported is returned when the DataCrunch API does not support an operation,
+// e.g. changing a property of an existing instance that can only be set at creation
+var ErrOperationNotSupported = errors.New("operation not supported by the DataCrunch API")
+
+// ErrInvalidPublicKey is returned when an SSH key is created with a public key that doesn't parse as an
+// OpenSSH authorized key
+var ErrInvalidPublicKey = errors.New("invalid SSH public key")
+
+// ErrDuplicateInstanceName is returned when an instance is looked up by a name more than one instance has
+var ErrDuplicateInstanceName = errors.New("more than one instance has the name")
+
+// ErrInvalidCredentials is returned when the DataCrunch API rejects the client ID and secret, as opposed to
+// failing to authenticate because it can't be reached
+var ErrInvalidCredentials = errors.New("invalid DataCrunch API credentials")
+
+// APIError represents an unsuccessful response from the DataCrunch API
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	if e.Code == "" && e.Message == "" {
+		return fmt.Sprintf("status: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("status: %d, code: %s, message: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is reports whether the status code of the error is the one ErrNotFound, ErrUnauthorized or
+// ErrRateLimited stands for, so that errors.Is matches them
+func (e *APIError) Is(target error) bool {
+	return target != nil && ErrorForStatus(e.StatusCode) == target
+}
+
+// ErrorForStatus returns ErrNotFound, ErrUnauthorized or ErrRateLimited for the status codes of
+// unsuccessful responses they stand for, and nil for any other status code
+func ErrorForStatus(statusCode int) error {
+	switch statusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return nil
+	}
+}
+
+// IsRetryable returns false if the error is an APIError rejecting the request itself, e.g. an invalid
+// instance type or image, which retrying won't fix. Authentication, conflict, rate limiting and server
+// errors are retryable, as are errors that aren't APIErrors, such as network failures.
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+
+	switch {
+	case apiErr.StatusCode >= http.StatusInternalServerError:
+		return true
+	case apiErr.StatusCode == http.StatusUnauthorized, apiErr.StatusCode == http.StatusRequestTimeout,
+		apiErr.StatusCode == http.StatusConflict, apiErr.StatusCode == http.StatusTooManyRequests:
+		return true
+	case apiErr.StatusCode >= http.StatusBadRequest:
+		return false
+	default:
+		return true
+	}
+}
+
+// IsQuotaExceeded returns true if the error is an APIError reporting that an account quota or limit was reached
+func IsQuotaExceeded(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.Code {
+	case ErrorCodeQuotaExceeded, ErrorCodeLimitExceeded, ErrorCodeInsufficientQuota:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsCapacityError returns true if the error is an APIError reporting that the instance type has no capacity left
+func IsCapacityError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.Code {
+	case ErrorCodeInsufficientCapacity, ErrorCodeCapacityUnavailable:
+		return true
+	default:
+		return false
+	}
+}
@@ -18,6 +18,7 @@ package cloud
 
 import (
 	"context"
+	"time"
 )
 
 // Scope defines the interface for passing scope between methods
@@ -30,21 +31,48 @@ type Scope interface {
 type Client interface {
 	// Instance management
 	CreateInstance(ctx context.Context, spec *InstanceSpec) (*Instance, error)
+	ListInstances(ctx context.Context) ([]*Instance, error)
 	GetInstance(ctx context.Context, instanceID string) (*Instance, error)
+	GetInstanceByName(ctx context.Context, name string) (*Instance, error)
+	WaitForInstanceState(ctx context.Context, instanceID, target string, timeout time.Duration) (*Instance, error)
 	DeleteInstance(ctx context.Context, instanceID string) error
 	StartInstance(ctx context.Context, instanceID string) error
 	StopInstance(ctx context.Context, instanceID string) error
+	ForceStopInstance(ctx context.Context, instanceID string) error
+	UpdateInstanceTags(ctx context.Context, instanceID string, tags map[string]string) error
+	CreateInstanceSnapshot(ctx context.Context, instanceID, name string) (*Snapshot, error)
+	GetSnapshot(ctx context.Context, snapshotID string) (*Snapshot, error)
+	GetSpotInterruptionNotice(ctx context.Context, instanceID string) (*SpotInterruptionNotice, error)
+	GetInstanceMetrics(ctx context.Context, instanceID string) (*InstanceMetrics, error)
+	UpdateInstanceSSHKey(ctx context.Context, instanceID, sshKeyName string) error
+	UpdateInstanceSecurityGroups(ctx context.Context, instanceID string, securityGroupIDs []string) error
+
+	// Instance type management
+	ListInstanceTypes(ctx context.Context) ([]*InstanceType, error)
+	GetInstanceTypePrice(ctx context.Context, instanceType, region string) (*InstanceTypePrice, error)
+	IsInstanceTypeAvailable(ctx context.Context, instanceType, region string) (bool, error)
+
+	// Account management
+	ValidateCredentials(ctx context.Context) error
+	GetAccountLimits(ctx context.Context) (*AccountLimits, error)
+	ListLocations(ctx context.Context) ([]*Location, error)
 
 	// Image management
-	ListImages(ctx context.Context) ([]*Image, error)
-	GetImage(ctx context.Context, imageID string) (*Image, error)
+	ListImages(ctx context.Context, region string) ([]*Image, error)
+	GetImage(ctx context.Context, imageID, region string) (*Image, error)
 
 	// SSH Key management
 	ListSSHKeys(ctx context.Context) ([]*SSHKey, error)
-	CreateSSHKey(ctx context.Context, name, publicKey string) (*SSHKey, error)
+	CreateSSHKey(ctx context.Context, name, publicKey string, tags map[string]string) (*SSHKey, error)
 	DeleteSSHKey(ctx context.Context, keyID string) error
 
 	// Network management
+	CreateVPC(ctx context.Context, spec *VPCSpec) (*VPC, error)
+	GetVPC(ctx context.Context, vpcID string) (*VPC, error)
+	DeleteVPC(ctx context.Context, vpcID string) error
+	CreateSubnet(ctx context.Context, spec *SubnetSpec) (*Subnet, error)
+	GetSubnet(ctx context.Context, subnetID string) (*Subnet, error)
+	DeleteSubnet(ctx context.Context, subnetID string) error
 	CreateLoadBalancer(ctx context.Context, spec *LoadBalancerSpec) (*LoadBalancer, error)
 	GetLoadBalancer(ctx context.Context, lbID string) (*LoadBalancer, error)
 	DeleteLoadBalancer(ctx context.Context, lbID string) error
@@ -60,7 +88,52 @@ type InstanceSpec struct {
 	UserData     string
 	Metadata     map[string]string
 	Tags         map[string]string
+	Labels       map[string]string
 	PublicIP     bool
+	VCPUs        int
+	MemoryGB     int
+	RootVolume   *VolumeSpec
+	Region       string
+
+	// AvailabilityZone places the instance in an availability zone of its region, any zone is used if empty
+	AvailabilityZone string
+
+	// AntiAffinityGroup places the instance on a different physical host than the other instances of the group
+	AntiAffinityGroup string
+
+	// HardwareGeneration pins the instance to a hardware generation of its instance type
+	HardwareGeneration string
+
+	// NetworkInterfaces configures the network interfaces of the instance, the API default is used if empty
+	NetworkInterfaces []NetworkInterfaceSpec
+
+	// Spot requests a spot instance, an on-demand instance is created if nil
+	Spot *SpotConfig
+}
+
+// SpotConfig defines the pricing of a spot instance
+type SpotConfig struct {
+	// MaxPrice is the maximum hourly price, the API default is used if empty
+	MaxPrice string
+}
+
+// NetworkInterfaceSpec defines the specification of an instance network interface
+type NetworkInterfaceSpec struct {
+	SubnetID            string
+	DeviceIndex         *int64
+	AssociatePublicIP   *bool
+	DeleteOnTermination *bool
+	SecurityGroupIDs    []string
+
+	// SecondaryPrivateIPCount is the number of private IPs assigned in addition to the primary one
+	SecondaryPrivateIPCount int64
+}
+
+// VolumeSpec defines the specification of an instance volume
+type VolumeSpec struct {
+	SizeGB              int64
+	Type                string
+	DeleteOnTermination bool
 }
 
 // Instance represents a DataCrunch instance
@@ -75,6 +148,61 @@ type Instance struct {
 	SSHKeyName   string
 	CreatedAt    string
 	Region       string
+	Labels       map[string]string
+	Tags         map[string]string
+
+	AntiAffinityGroup string
+
+	// SecurityGroupIDs are the security groups the instance is a member of
+	SecurityGroupIDs []string
+
+	// InterruptionReason is why the API reclaimed a spot instance, empty unless it was interrupted
+	InterruptionReason string
+
+	// OperationID is the ID of the API operation that created the instance, only set on the instance
+	// returned by CreateInstance
+	OperationID string
+}
+
+// InstanceType represents a DataCrunch instance type
+type InstanceType struct {
+	Name         string
+	Description  string
+	VCPUs        int
+	MemoryGB     int
+	GPUs         int
+	Customizable bool
+	MinVCPUs     int
+	MaxVCPUs     int
+	MinMemoryGB  int
+	MaxMemoryGB  int
+
+	// HardwareGenerations are the hardware generations the instance type is offered in
+	HardwareGenerations []string
+}
+
+// AccountLimits represents the quota of a DataCrunch account and how much of it is in use
+type AccountLimits struct {
+	MaxInstances  int
+	MaxGPUs       int
+	UsedInstances int
+	UsedGPUs      int
+}
+
+// Location represents a DataCrunch region instances can be placed in
+type Location struct {
+	Code        string
+	Name        string
+	CountryCode string
+}
+
+// InstanceTypePrice represents the current hourly pricing of a DataCrunch instance type in a region
+type InstanceTypePrice struct {
+	InstanceType  string
+	Region        string
+	Currency      string
+	OnDemandPrice float64
+	SpotPrice     float64
 }
 
 // Image represents a DataCrunch image
@@ -84,6 +212,8 @@ type Image struct {
 	Description string
 	OSType      string
 	CreatedAt   string
+	// Region is the location the image is available in, empty for images available in every location
+	Region string
 }
 
 // SSHKey represents a DataCrunch SSH key
@@ -92,6 +222,105 @@ type SSHKey struct {
 	Name      string
 	PublicKey string
 	CreatedAt string
+	Tags      map[string]string
+}
+
+// FilterInstancesByTags returns the instances carrying all of the given tags
+func FilterInstancesByTags(instances []*Instance, tags map[string]string) []*Instance {
+	var filtered []*Instance
+	for _, instance := range instances {
+		matches := true
+		for k, v := range tags {
+			if value, ok := instance.Tags[k]; !ok || value != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			filtered = append(filtered, instance)
+		}
+	}
+	return filtered
+}
+
+// FilterSSHKeysByTags returns the SSH keys carrying all of the given tags
+func FilterSSHKeysByTags(keys []*SSHKey, tags map[string]string) []*SSHKey {
+	var filtered []*SSHKey
+	for _, key := range keys {
+		matches := true
+		for k, v := range tags {
+			if value, ok := key.Tags[k]; !ok || value != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered
+}
+
+// Snapshot represents a snapshot of a DataCrunch instance
+type Snapshot struct {
+	ID         string
+	Name       string
+	InstanceID string
+	State      string
+	CreatedAt  string
+}
+
+// SpotInterruptionNotice announces that a spot instance is about to be reclaimed
+type SpotInterruptionNotice struct {
+	InstanceID      string
+	Action          string
+	TerminationTime string
+}
+
+// InstanceMetrics reports the recent resource utilization of an instance, in percent
+type InstanceMetrics struct {
+	InstanceID        string
+	GPUUtilization    float64
+	CPUUtilization    float64
+	MemoryUtilization float64
+	Timestamp         string
+}
+
+// VPCSpec defines the specification for creating a VPC
+type VPCSpec struct {
+	Name      string
+	CidrBlock string
+	Region    string
+	Tags      map[string]string
+}
+
+// VPC represents a DataCrunch VPC
+type VPC struct {
+	ID        string
+	Name      string
+	CidrBlock string
+	State     string
+	Region    string
+}
+
+// SubnetSpec defines the specification for creating a subnet
+type SubnetSpec struct {
+	Name             string
+	VPCID            string
+	CidrBlock        string
+	AvailabilityZone string
+	IsPublic         bool
+	Tags             map[string]string
+}
+
+// Subnet represents a DataCrunch subnet
+type Subnet struct {
+	ID               string
+	Name             string
+	VPCID            string
+	CidrBlock        string
+	AvailabilityZone string
+	State            string
 }
 
 // LoadBalancerSpec defines the specification for creating a load balancer
@@ -30,21 +30,37 @@ import (
 
 // MockDataCrunchAPI provides a mock implementation of the DataCrunch API
 type MockDataCrunchAPI struct {
-	server    *httptest.Server
-	instances map[string]*cloud.Instance
-	images    map[string]*cloud.Image
-	sshKeys   map[string]*cloud.SSHKey
-	lbs       map[string]*cloud.LoadBalancer
-	mutex     sync.RWMutex
+	server        *httptest.Server
+	instances     map[string]*cloud.Instance
+	instanceTypes map[string]*cloud.InstanceType
+	prices        map[string]*cloud.InstanceTypePrice
+	images        map[string]*cloud.Image
+	sshKeys       map[string]*cloud.SSHKey
+	lbs           map[string]*cloud.LoadBalancer
+	vpcs          map[string]*cloud.VPC
+	subnets       map[string]*cloud.Subnet
+	snapshots     map[string]*cloud.Snapshot
+	interruptions map[string]*cloud.SpotInterruptionNotice
+	metrics       map[string]*cloud.InstanceMetrics
+	accountLimits *cloud.AccountLimits
+	locations     []*cloud.Location
+	mutex         sync.RWMutex
 }
 
 // NewMockDataCrunchAPI creates a new mock API server
 func NewMockDataCrunchAPI() *MockDataCrunchAPI {
 	mock := &MockDataCrunchAPI{
-		instances: make(map[string]*cloud.Instance),
-		images:    make(map[string]*cloud.Image),
-		sshKeys:   make(map[string]*cloud.SSHKey),
-		lbs:       make(map[string]*cloud.LoadBalancer),
+		instances:     make(map[string]*cloud.Instance),
+		instanceTypes: make(map[string]*cloud.InstanceType),
+		prices:        make(map[string]*cloud.InstanceTypePrice),
+		images:        make(map[string]*cloud.Image),
+		sshKeys:       make(map[string]*cloud.SSHKey),
+		lbs:           make(map[string]*cloud.LoadBalancer),
+		vpcs:          make(map[string]*cloud.VPC),
+		subnets:       make(map[string]*cloud.Subnet),
+		snapshots:     make(map[string]*cloud.Snapshot),
+		interruptions: make(map[string]*cloud.SpotInterruptionNotice),
+		metrics:       make(map[string]*cloud.InstanceMetrics),
 	}
 
 	// Pre-populate with some test data
@@ -70,6 +86,54 @@ func (m *MockDataCrunchAPI) URL() string {
 
 // setupTestData pre-populates the mock with test data
 func (m *MockDataCrunchAPI) setupTestData() {
+	m.accountLimits = &cloud.AccountLimits{
+		MaxInstances: 10,
+		MaxGPUs:      16,
+	}
+
+	m.locations = []*cloud.Location{
+		{Code: "FIN-01", Name: "Finland 1", CountryCode: "FI"},
+		{Code: "ICE-01", Name: "Iceland 1", CountryCode: "IS"},
+	}
+
+	// Add test instance types
+	m.instanceTypes["1xH100"] = &cloud.InstanceType{
+		Name:        "1xH100",
+		Description: "1x H100 80GB",
+		VCPUs:       32,
+		MemoryGB:    185,
+		GPUs:        1,
+
+		HardwareGenerations: []string{"sxm5-rev1", "sxm5-rev2"},
+	}
+
+	m.instanceTypes["CPU.FLEX"] = &cloud.InstanceType{
+		Name:         "CPU.FLEX",
+		Description:  "Customizable CPU instance",
+		VCPUs:        4,
+		MemoryGB:     16,
+		Customizable: true,
+		MinVCPUs:     2,
+		MaxVCPUs:     64,
+		MinMemoryGB:  4,
+		MaxMemoryGB:  256,
+	}
+
+	// Add test instance type prices
+	m.prices["1xH100"] = &cloud.InstanceTypePrice{
+		InstanceType:  "1xH100",
+		Currency:      "USD",
+		OnDemandPrice: 2.19,
+		SpotPrice:     0.99,
+	}
+
+	m.prices["CPU.FLEX"] = &cloud.InstanceTypePrice{
+		InstanceType:  "CPU.FLEX",
+		Currency:      "USD",
+		OnDemandPrice: 0.12,
+		SpotPrice:     0.05,
+	}
+
 	// Add test images
 	m.images["ubuntu-22.04-cuda-12.1"] = &cloud.Image{
 		ID:          "ubuntu-22.04-cuda-12.1",
@@ -77,6 +141,17 @@ func (m *MockDataCrunchAPI) setupTestData() {
 		Description: "Ubuntu 22.04 LTS with CUDA 12.1 and ML frameworks",
 		OSType:      "linux",
 		CreatedAt:   "2024-01-01T00:00:00Z",
+		Region:      "FIN-01",
+	}
+
+	// The same image is published under a different ID in ICE-01
+	m.images["ubuntu-22.04-cuda-12.1-ice"] = &cloud.Image{
+		ID:          "ubuntu-22.04-cuda-12.1-ice",
+		Name:        "Ubuntu 22.04 with CUDA 12.1",
+		Description: "Ubuntu 22.04 LTS with CUDA 12.1 and ML frameworks",
+		OSType:      "linux",
+		CreatedAt:   "2024-01-01T00:00:00Z",
+		Region:      "ICE-01",
 	}
 
 	m.images["ubuntu-20.04"] = &cloud.Image{
@@ -105,6 +180,15 @@ func (m *MockDataCrunchAPI) setupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/instances", m.handleInstances)
 	mux.HandleFunc("/instances/", m.handleInstanceByID)
 
+	// Instance types
+	mux.HandleFunc("/instance-types", m.handleInstanceTypes)
+	mux.HandleFunc("/instance-types/", m.handleInstanceTypePrice)
+	mux.HandleFunc("/instance-availability/", m.handleInstanceAvailability)
+
+	// Account
+	mux.HandleFunc("/account/limits", m.handleAccountLimits)
+	mux.HandleFunc("/locations", m.handleLocations)
+
 	// Images
 	mux.HandleFunc("/images", m.handleImages)
 	mux.HandleFunc("/images/", func(w http.ResponseWriter, r *http.Request) {
@@ -119,6 +203,18 @@ func (m *MockDataCrunchAPI) setupRoutes(mux *http.ServeMux) {
 		m.handleSSHKeyByID(w, r, keyID)
 	})
 
+	// VPCs and subnets
+	mux.HandleFunc("/vpcs", m.handleVPCs)
+	mux.HandleFunc("/vpcs/", func(w http.ResponseWriter, r *http.Request) {
+		vpcID := strings.TrimPrefix(r.URL.Path, "/vpcs/")
+		m.handleVPCByID(w, r, vpcID)
+	})
+	mux.HandleFunc("/subnets", m.handleSubnets)
+	mux.HandleFunc("/subnets/", func(w http.ResponseWriter, r *http.Request) {
+		subnetID := strings.TrimPrefix(r.URL.Path, "/subnets/")
+		m.handleSubnetByID(w, r, subnetID)
+	})
+
 	// Load Balancers
 	mux.HandleFunc("/load-balancers", m.handleLoadBalancers)
 	mux.HandleFunc("/load-balancers/", func(w http.ResponseWriter, r *http.Request) {
@@ -165,6 +261,26 @@ func (m *MockDataCrunchAPI) handleInstanceByID(w http.ResponseWriter, r *http.Re
 		if len(parts) >= 2 {
 			instanceID = parts[0]
 			action := parts[1]
+			switch action {
+			case "tags":
+				m.updateInstanceTags(w, r, instanceID)
+				return
+			case "snapshots":
+				m.createInstanceSnapshot(w, r, instanceID)
+				return
+			case "interruption-notice":
+				m.getSpotInterruptionNotice(w, r, instanceID)
+				return
+			case "metrics":
+				m.getInstanceMetrics(w, r, instanceID)
+				return
+			case "ssh-key":
+				m.updateInstanceSSHKey(w, r, instanceID)
+				return
+			case "security-groups":
+				m.updateInstanceSecurityGroups(w, r, instanceID)
+				return
+			}
 			m.handleInstanceAction(w, r, instanceID, action)
 			return
 		}
@@ -221,6 +337,29 @@ func (m *MockDataCrunchAPI) createInstance(w http.ResponseWriter, r *http.Reques
 		sshKey = sshKeyName
 	}
 
+	var labels map[string]string
+	if rawLabels, ok := req["labels"].(map[string]interface{}); ok {
+		labels = make(map[string]string, len(rawLabels))
+		for k, v := range rawLabels {
+			labels[k], _ = v.(string)
+		}
+	}
+
+	var tags map[string]string
+	if rawTags, ok := req["tags"].(map[string]interface{}); ok {
+		tags = make(map[string]string, len(rawTags))
+		for k, v := range rawTags {
+			tags[k], _ = v.(string)
+		}
+	}
+
+	antiAffinityGroup, _ := req["anti_affinity_group"].(string)
+
+	region := "FIN-01"
+	if locationCode, ok := req["location_code"].(string); ok && locationCode != "" {
+		region = locationCode
+	}
+
 	instance := &cloud.Instance{
 		ID:           instanceID,
 		Name:         name,
@@ -231,7 +370,11 @@ func (m *MockDataCrunchAPI) createInstance(w http.ResponseWriter, r *http.Reques
 		PrivateIP:    "10.0.1.100",
 		SSHKeyName:   sshKey,
 		CreatedAt:    time.Now().Format(time.RFC3339),
-		Region:       "FIN-01",
+		Region:       region,
+		Labels:       labels,
+		Tags:         tags,
+
+		AntiAffinityGroup: antiAffinityGroup,
 	}
 
 	m.mutex.Lock()
@@ -303,7 +446,7 @@ func (m *MockDataCrunchAPI) handleInstanceAction(w http.ResponseWriter, r *http.
 	switch action {
 	case "start":
 		instance.State = "running"
-	case "stop":
+	case "stop", "force-stop":
 		instance.State = "stopped"
 	default:
 		http.Error(w, "Invalid action", http.StatusBadRequest)
@@ -313,6 +456,339 @@ func (m *MockDataCrunchAPI) handleInstanceAction(w http.ResponseWriter, r *http.
 	w.WriteHeader(http.StatusOK)
 }
 
+func (m *MockDataCrunchAPI) updateInstanceTags(w http.ResponseWriter, r *http.Request, instanceID string) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Tags map[string]string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, exists := m.instances[instanceID]
+	if !exists {
+		http.Error(w, "Instance not found", http.StatusNotFound)
+		return
+	}
+
+	instance.Tags = req.Tags
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *MockDataCrunchAPI) updateInstanceSSHKey(w http.ResponseWriter, r *http.Request, instanceID string) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SSHKey string `json:"ssh_key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, exists := m.instances[instanceID]
+	if !exists {
+		http.Error(w, "Instance not found", http.StatusNotFound)
+		return
+	}
+
+	instance.SSHKeyName = req.SSHKey
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *MockDataCrunchAPI) updateInstanceSecurityGroups(w http.ResponseWriter, r *http.Request, instanceID string) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SecurityGroupIDs []string `json:"security_group_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	instance, exists := m.instances[instanceID]
+	if !exists {
+		http.Error(w, "Instance not found", http.StatusNotFound)
+		return
+	}
+
+	instance.SecurityGroupIDs = req.SecurityGroupIDs
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *MockDataCrunchAPI) createInstanceSnapshot(w http.ResponseWriter, r *http.Request, instanceID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.instances[instanceID]; !exists {
+		http.Error(w, "Instance not found", http.StatusNotFound)
+		return
+	}
+
+	snapshot := &cloud.Snapshot{
+		ID:         fmt.Sprintf("snapshot-%d", time.Now().UnixNano()),
+		Name:       req.Name,
+		InstanceID: instanceID,
+		State:      "available",
+		CreatedAt:  time.Now().Format(time.RFC3339),
+	}
+	m.snapshots[snapshot.ID] = snapshot
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":          snapshot.ID,
+		"name":        snapshot.Name,
+		"instance_id": snapshot.InstanceID,
+		"status":      snapshot.State,
+		"created_at":  snapshot.CreatedAt,
+	})
+}
+
+// ScheduleSpotInterruption issues an interruption notice for an instance, reclaiming it in two minutes
+func (m *MockDataCrunchAPI) ScheduleSpotInterruption(instanceID string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.interruptions[instanceID] = &cloud.SpotInterruptionNotice{
+		InstanceID:      instanceID,
+		Action:          "terminate",
+		TerminationTime: time.Now().Add(2 * time.Minute).Format(time.RFC3339),
+	}
+}
+
+func (m *MockDataCrunchAPI) getSpotInterruptionNotice(w http.ResponseWriter, r *http.Request, instanceID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	notice, exists := m.interruptions[instanceID]
+	if !exists {
+		http.Error(w, "No interruption notice", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"instance_id":  notice.InstanceID,
+		"action":       notice.Action,
+		"terminate_at": notice.TerminationTime,
+	})
+}
+
+// SetInstanceMetrics sets the utilization reported for an instance, sampled now
+func (m *MockDataCrunchAPI) SetInstanceMetrics(instanceID string, gpuUtilization, cpuUtilization, memoryUtilization float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.metrics[instanceID] = &cloud.InstanceMetrics{
+		InstanceID:        instanceID,
+		GPUUtilization:    gpuUtilization,
+		CPUUtilization:    cpuUtilization,
+		MemoryUtilization: memoryUtilization,
+		Timestamp:         time.Now().Format(time.RFC3339),
+	}
+}
+
+func (m *MockDataCrunchAPI) getInstanceMetrics(w http.ResponseWriter, r *http.Request, instanceID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	metrics, exists := m.metrics[instanceID]
+	if !exists {
+		http.Error(w, "No metrics", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"instance_id":        metrics.InstanceID,
+		"gpu_utilization":    metrics.GPUUtilization,
+		"cpu_utilization":    metrics.CPUUtilization,
+		"memory_utilization": metrics.MemoryUtilization,
+		"timestamp":          metrics.Timestamp,
+	})
+}
+
+// Instance type handlers
+func (m *MockDataCrunchAPI) handleInstanceTypes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	instanceTypes := make([]map[string]interface{}, 0, len(m.instanceTypes))
+	for _, it := range m.instanceTypes {
+		instanceTypes = append(instanceTypes, map[string]interface{}{
+			"instance_type": it.Name,
+			"description":   it.Description,
+			"vcpus":         it.VCPUs,
+			"memory_gb":     it.MemoryGB,
+			"gpus":          it.GPUs,
+			"customizable":  it.Customizable,
+			"min_vcpus":     it.MinVCPUs,
+			"max_vcpus":     it.MaxVCPUs,
+			"min_memory_gb": it.MinMemoryGB,
+			"max_memory_gb": it.MaxMemoryGB,
+
+			"hardware_generations": it.HardwareGenerations,
+		})
+	}
+
+	response := map[string]interface{}{
+		"instance_types": instanceTypes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (m *MockDataCrunchAPI) handleInstanceTypePrice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	instanceType := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/instance-types/"), "/price")
+
+	m.mutex.RLock()
+	price, exists := m.prices[instanceType]
+	m.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Instance type not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"currency":        price.Currency,
+		"on_demand_price": price.OnDemandPrice,
+		"spot_price":      price.SpotPrice,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (m *MockDataCrunchAPI) handleInstanceAvailability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	instanceType := strings.TrimPrefix(r.URL.Path, "/instance-availability/")
+
+	m.mutex.RLock()
+	_, exists := m.instanceTypes[instanceType]
+	m.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Instance type not found", http.StatusNotFound)
+		return
+	}
+
+	// Every known instance type has capacity in the mock
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(true)
+}
+
+// Account handlers
+func (m *MockDataCrunchAPI) handleAccountLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	// Usage is derived from the instances the mock is currently running
+	usedGPUs := 0
+	for _, instance := range m.instances {
+		if it, ok := m.instanceTypes[instance.InstanceType]; ok {
+			usedGPUs += it.GPUs
+		}
+	}
+
+	response := map[string]interface{}{
+		"max_instances":  m.accountLimits.MaxInstances,
+		"max_gpus":       m.accountLimits.MaxGPUs,
+		"used_instances": len(m.instances),
+		"used_gpus":      usedGPUs,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (m *MockDataCrunchAPI) handleLocations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	locations := make([]map[string]interface{}, 0, len(m.locations))
+	for _, location := range m.locations {
+		locations = append(locations, map[string]interface{}{
+			"code":         location.Code,
+			"name":         location.Name,
+			"country_code": location.CountryCode,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(locations)
+}
+
 // Image handlers
 func (m *MockDataCrunchAPI) handleImages(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -320,12 +796,16 @@ func (m *MockDataCrunchAPI) handleImages(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	region := r.URL.Query().Get("location_code")
+
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	images := make([]*cloud.Image, 0, len(m.images))
+	images := make([]map[string]interface{}, 0, len(m.images))
 	for _, image := range m.images {
-		images = append(images, image)
+		if imageInRegion(image, region) {
+			images = append(images, imageResponse(image))
+		}
 	}
 
 	response := map[string]interface{}{
@@ -346,13 +826,30 @@ func (m *MockDataCrunchAPI) handleImageByID(w http.ResponseWriter, r *http.Reque
 	image, exists := m.images[imageID]
 	m.mutex.RUnlock()
 
-	if !exists {
+	if !exists || !imageInRegion(image, r.URL.Query().Get("location_code")) {
 		http.Error(w, "Image not found", http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(image)
+	_ = json.NewEncoder(w).Encode(imageResponse(image))
+}
+
+// imageInRegion reports whether an image is available in a region, images without a region and
+// lookups without a region always match
+func imageInRegion(image *cloud.Image, region string) bool {
+	return region == "" || image.Region == "" || image.Region == region
+}
+
+func imageResponse(image *cloud.Image) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            image.ID,
+		"name":          image.Name,
+		"description":   image.Description,
+		"os_type":       image.OSType,
+		"created_at":    image.CreatedAt,
+		"location_code": image.Region,
+	}
 }
 
 // SSH Key handlers
@@ -402,11 +899,20 @@ func (m *MockDataCrunchAPI) createSSHKey(w http.ResponseWriter, r *http.Request)
 
 	keyID := fmt.Sprintf("key-%d", time.Now().Unix())
 
+	var tags map[string]string
+	if rawTags, ok := req["tags"].(map[string]interface{}); ok {
+		tags = make(map[string]string, len(rawTags))
+		for k, v := range rawTags {
+			tags[k], _ = v.(string)
+		}
+	}
+
 	sshKey := &cloud.SSHKey{
 		ID:        keyID,
 		Name:      req["name"].(string),
 		PublicKey: req["public_key"].(string),
 		CreatedAt: time.Now().Format(time.RFC3339),
+		Tags:      tags,
 	}
 
 	m.mutex.Lock()
@@ -431,21 +937,245 @@ func (m *MockDataCrunchAPI) deleteSSHKey(w http.ResponseWriter, r *http.Request,
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Load Balancer handlers (placeholder implementations)
+// VPC handlers
+func (m *MockDataCrunchAPI) handleVPCs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	name, _ := req["name"].(string)
+	cidrBlock, _ := req["cidr_block"].(string)
+	region, _ := req["location_code"].(string)
+
+	vpc := &cloud.VPC{
+		ID:        fmt.Sprintf("vpc-%d", time.Now().UnixNano()),
+		Name:      name,
+		CidrBlock: cidrBlock,
+		State:     "available",
+		Region:    region,
+	}
+
+	m.mutex.Lock()
+	m.vpcs[vpc.ID] = vpc
+	m.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(vpcResponse(vpc))
+}
+
+func (m *MockDataCrunchAPI) handleVPCByID(w http.ResponseWriter, r *http.Request, vpcID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mutex.RLock()
+	vpc, exists := m.vpcs[vpcID]
+	m.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "VPC not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(vpcResponse(vpc))
+}
+
+func vpcResponse(vpc *cloud.VPC) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            vpc.ID,
+		"name":          vpc.Name,
+		"cidr_block":    vpc.CidrBlock,
+		"status":        vpc.State,
+		"location_code": vpc.Region,
+	}
+}
+
+// Subnet handlers
+func (m *MockDataCrunchAPI) handleSubnets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	name, _ := req["name"].(string)
+	vpcID, _ := req["vpc_id"].(string)
+	cidrBlock, _ := req["cidr_block"].(string)
+	availabilityZone, _ := req["availability_zone"].(string)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.vpcs[vpcID]; !exists {
+		http.Error(w, "VPC not found", http.StatusNotFound)
+		return
+	}
+
+	subnet := &cloud.Subnet{
+		ID:               fmt.Sprintf("subnet-%d", time.Now().UnixNano()),
+		Name:             name,
+		VPCID:            vpcID,
+		CidrBlock:        cidrBlock,
+		AvailabilityZone: availabilityZone,
+		State:            "available",
+	}
+	m.subnets[subnet.ID] = subnet
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(subnetResponse(subnet))
+}
+
+func (m *MockDataCrunchAPI) handleSubnetByID(w http.ResponseWriter, r *http.Request, subnetID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mutex.RLock()
+	subnet, exists := m.subnets[subnetID]
+	m.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "Subnet not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(subnetResponse(subnet))
+}
+
+func subnetResponse(subnet *cloud.Subnet) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                subnet.ID,
+		"name":              subnet.Name,
+		"vpc_id":            subnet.VPCID,
+		"cidr_block":        subnet.CidrBlock,
+		"availability_zone": subnet.AvailabilityZone,
+		"status":            subnet.State,
+	}
+}
+
+// Load Balancer handlers
 func (m *MockDataCrunchAPI) handleLoadBalancers(w http.ResponseWriter, r *http.Request) {
-	response := map[string]interface{}{
-		"error": "Load balancer operations not yet implemented",
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name    string   `json:"name"`
+		Type    string   `json:"type"`
+		Targets []string `json:"targets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
 	}
+
+	id := fmt.Sprintf("lb-%d", time.Now().UnixNano())
+	lb := &cloud.LoadBalancer{
+		ID:      id,
+		Name:    req.Name,
+		DNSName: id + ".lb.datacrunch.local",
+		State:   "active",
+		Type:    req.Type,
+		Targets: req.Targets,
+	}
+
+	m.mutex.Lock()
+	m.lbs[lb.ID] = lb
+	m.mutex.Unlock()
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
-	_ = json.NewEncoder(w).Encode(response)
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(loadBalancerResponse(lb))
 }
 
 func (m *MockDataCrunchAPI) handleLoadBalancerByID(w http.ResponseWriter, r *http.Request, lbID string) {
-	response := map[string]interface{}{
-		"error": "Load balancer operations not yet implemented",
+	if id, ok := strings.CutSuffix(lbID, "/targets"); ok {
+		m.updateLoadBalancerTargets(w, r, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		m.mutex.RLock()
+		lb, exists := m.lbs[lbID]
+		m.mutex.RUnlock()
+
+		if !exists {
+			http.Error(w, "Load balancer not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(loadBalancerResponse(lb))
+	case http.MethodDelete:
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		if _, exists := m.lbs[lbID]; !exists {
+			http.Error(w, "Load balancer not found", http.StatusNotFound)
+			return
+		}
+
+		delete(m.lbs, lbID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *MockDataCrunchAPI) updateLoadBalancerTargets(w http.ResponseWriter, r *http.Request, lbID string) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Targets []string `json:"targets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	lb, exists := m.lbs[lbID]
+	if !exists {
+		http.Error(w, "Load balancer not found", http.StatusNotFound)
+		return
+	}
+
+	lb.Targets = req.Targets
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func loadBalancerResponse(lb *cloud.LoadBalancer) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       lb.ID,
+		"name":     lb.Name,
+		"dns_name": lb.DNSName,
+		"status":   lb.State,
+		"type":     lb.Type,
+		"targets":  lb.Targets,
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNotImplemented)
-	_ = json.NewEncoder(w).Encode(response)
 }